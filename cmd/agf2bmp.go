@@ -11,8 +11,11 @@ import (
 )
 
 var (
-	agf2bmpOutput  string
-	agf2bmpVerbose bool
+	agf2bmpOutput   string
+	agf2bmpVerbose  bool
+	agf2bmpFormat   string
+	agf2bmpPalette  string
+	agf2bmpTolerant bool
 )
 
 var agf2bmpCmd = &cobra.Command{
@@ -31,7 +34,16 @@ Examples:
   agetools agf2bmp image.AGF output.BMP
 
   # Convert directory of AGF files
-  agetools agf2bmp AGF_folder/ -o BMP_output/`,
+  agetools agf2bmp AGF_folder/ -o BMP_output/
+
+  # Convert to PNG instead of BMP
+  agetools agf2bmp image.AGF --format png
+
+  # Also export the palette of an 8-bit AGF for editing (.act or .pal)
+  agetools agf2bmp image.AGF --export-palette image.pal
+
+  # Stream from stdin to stdout with bounded memory (BMP only)
+  cat image.AGF | agetools agf2bmp - > image.BMP`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runAgf2Bmp,
 }
@@ -43,31 +55,66 @@ func init() {
 		"output file or directory")
 	agf2bmpCmd.Flags().BoolVarP(&agf2bmpVerbose, "verbose", "v", false,
 		"print verbose progress information")
+	agf2bmpCmd.Flags().StringVar(&agf2bmpFormat, "format", "bmp",
+		"output image format (bmp, png, or any registered encoder)")
+	agf2bmpCmd.Flags().StringVar(&agf2bmpPalette, "export-palette", "",
+		"write the palette of an 8-bit AGF to this .act or .pal file (single file only)")
+	agf2bmpCmd.Flags().BoolVar(&agf2bmpTolerant, "tolerant", false,
+		"accept sectors whose duplicated length fields disagree, using the one matching the data")
 }
 
 func runAgf2Bmp(cmd *cobra.Command, args []string) error {
 	input := args[0]
 
+	if _, ok := agf.LookupEncoder(agf2bmpFormat); !ok {
+		return fmt.Errorf("unsupported output format: %s", agf2bmpFormat)
+	}
+
+	if input == "-" {
+		return streamAgfStdin(args[1:])
+	}
+
 	info, err := os.Stat(input)
 	if err != nil {
 		return fmt.Errorf("input not found: %s", input)
 	}
 
 	if info.IsDir() {
+		if agf2bmpPalette != "" {
+			return fmt.Errorf("--export-palette is only supported for single files")
+		}
 		return convertAgfDirectory(input, agf2bmpOutput)
 	}
 
+	var paletteFormat agf.PaletteFormat
+	if agf2bmpPalette != "" {
+		if paletteFormat, err = agf.PaletteFormatFromPath(agf2bmpPalette); err != nil {
+			return err
+		}
+	}
+
 	// Single file
 	output := agf2bmpOutput
 	if output == "" {
 		if len(args) > 1 {
 			output = args[1]
 		} else {
-			output = strings.TrimSuffix(input, filepath.Ext(input)) + ".BMP"
+			output = strings.TrimSuffix(input, filepath.Ext(input)) + agf2bmpExt()
+		}
+	}
+
+	if err := convertAgfFile(input, output); err != nil {
+		return err
+	}
+
+	if agf2bmpPalette != "" {
+		if err := agf.ExportPalette(input, agf2bmpPalette, paletteFormat); err != nil {
+			return fmt.Errorf("failed to export palette: %w", err)
 		}
+		fmt.Printf("Exported palette: %s\n", filepath.Base(agf2bmpPalette))
 	}
 
-	return convertAgfFile(input, output)
+	return nil
 }
 
 func convertAgfFile(input, output string) error {
@@ -75,12 +122,18 @@ func convertAgfFile(input, output string) error {
 		fmt.Printf("Converting %s -> %s\n", input, output)
 	}
 
-	result, err := agf.UnpackFile(input)
+	result, err := agf.UnpackFileWithOptions(input, agf.UnpackOptions{Tolerant: agf2bmpTolerant})
 	if err != nil {
 		return fmt.Errorf("failed to unpack %s: %w", input, err)
 	}
 
-	if err := result.WriteBMPFile(output); err != nil {
+	// BMP output keeps the original bit depth and palette
+	if strings.EqualFold(agf2bmpFormat, "bmp") {
+		err = result.WriteBMPFile(output)
+	} else {
+		err = result.WriteImageFile(output, agf2bmpFormat)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to write %s: %w", output, err)
 	}
 
@@ -91,6 +144,40 @@ func convertAgfFile(input, output string) error {
 	return nil
 }
 
+// streamAgfStdin converts an AGF read from stdin to a BMP written to the
+// given output path, or to stdout when there is none or it is "-"
+func streamAgfStdin(args []string) error {
+	if !strings.EqualFold(agf2bmpFormat, "bmp") {
+		return fmt.Errorf("streaming from stdin only supports BMP output")
+	}
+	if agf2bmpPalette != "" || agf2bmpTolerant {
+		return fmt.Errorf("--export-palette and --tolerant are not supported when streaming from stdin")
+	}
+
+	output := agf2bmpOutput
+	if output == "" && len(args) > 0 {
+		output = args[0]
+	}
+	if output == "" || output == "-" {
+		return agf.StreamConvert(os.Stdin, os.Stdout)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	defer f.Close()
+
+	if err := agf.StreamConvert(os.Stdin, f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Converted: %s\n", filepath.Base(output))
+	return nil
+}
+
 func convertAgfDirectory(inputDir, outputDir string) error {
 	if outputDir == "" {
 		outputDir = inputDir + "_BMP"
@@ -117,7 +204,7 @@ func convertAgfDirectory(inputDir, outputDir string) error {
 
 		// Preserve directory structure
 		relPath, _ := filepath.Rel(inputDir, path)
-		outPath := filepath.Join(outputDir, strings.TrimSuffix(relPath, filepath.Ext(relPath))+".BMP")
+		outPath := filepath.Join(outputDir, strings.TrimSuffix(relPath, filepath.Ext(relPath))+agf2bmpExt())
 
 		// Create subdirectories if needed
 		if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
@@ -140,3 +227,8 @@ func convertAgfDirectory(inputDir, outputDir string) error {
 	fmt.Printf("Converted %d files\n", count)
 	return nil
 }
+
+// agf2bmpExt returns the output file extension for the selected format
+func agf2bmpExt() string {
+	return "." + strings.ToUpper(agf2bmpFormat)
+}
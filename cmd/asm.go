@@ -19,18 +19,50 @@ var asmCmd = &cobra.Command{
 Examples:
   agetools asm BUNKI.txt                       # Output to BUNKI.BIN
   agetools asm BUNKI.txt output.bin            # Output to output.bin
-  agetools asm --dir ./scripts                 # Assemble all .txt files in directory`,
+  agetools asm --dir ./scripts                 # Assemble all .txt files in directory
+  agetools asm --join BUNKI/                   # Assemble split output of disasm --split-functions
+  agetools asm BUNKI.txt --labels BUNKI.labels.json  # Record label offsets for the next disasm
+  agetools asm BUNKI.txt --comments BUNKI.comments.json  # Record "; comment" notes for the next disasm
+  agetools asm --format sys4 old.txt           # Force SYS4 output, ignoring the signature
+  agetools asm --strict BUNKI.txt              # Fail on instructions with wrong argument counts
+  agetools asm --dedupe-strings new.txt        # Store each distinct string once`,
 	Args: cobra.MinimumNArgs(0),
 	RunE: runAsm,
 }
 
 var (
-	asmDir string
+	asmDir      string
+	asmJoin     string
+	asmLabels   string
+	asmComments string
+	asmFormat   string
+	asmStrict   bool
+	asmDedupe   bool
 )
 
 func init() {
 	rootCmd.AddCommand(asmCmd)
 	asmCmd.Flags().StringVarP(&asmDir, "dir", "d", "", "Process all .txt files in directory")
+	asmCmd.Flags().StringVar(&asmJoin, "join", "", "Assemble a directory written by disasm --split-functions")
+	asmCmd.Flags().StringVar(&asmLabels, "labels", "", "Write the offset of every label to this label map (JSON)")
+	asmCmd.Flags().StringVar(&asmComments, "comments", "", "Write the offset of every commented instruction to this comment map (JSON)")
+	asmCmd.Flags().BoolVar(&asmStrict, "strict", false, "Reject instructions with the wrong number of arguments")
+	asmCmd.Flags().BoolVar(&asmDedupe, "dedupe-strings", false, "Store identical strings once (output no longer matches originals byte for byte)")
+	asmCmd.Flags().StringVar(&asmFormat, "format", "", "Force the output format (sys4 or sys5); detected from the signature by default")
+}
+
+// asmVersion returns the format selected with --format, or 0 to detect it
+func asmVersion() (bin.FormatVersion, error) {
+	switch strings.ToLower(asmFormat) {
+	case "":
+		return 0, nil
+	case "sys4":
+		return bin.FormatSYS4, nil
+	case "sys5":
+		return bin.FormatSYS5, nil
+	default:
+		return 0, fmt.Errorf("unknown format %q (expected sys4 or sys5)", asmFormat)
+	}
 }
 
 func runAsm(cmd *cobra.Command, args []string) error {
@@ -39,6 +71,15 @@ func runAsm(cmd *cobra.Command, args []string) error {
 		return asmDirectory(asmDir)
 	}
 
+	// Split directory mode
+	if asmJoin != "" {
+		outputPath := strings.TrimSuffix(filepath.Clean(asmJoin), string(filepath.Separator)) + ".BIN"
+		if len(args) >= 1 {
+			outputPath = args[0]
+		}
+		return asmJoinDirectory(asmJoin, outputPath)
+	}
+
 	// Single file mode
 	if len(args) < 1 {
 		return fmt.Errorf("either --dir or a file path is required")
@@ -64,8 +105,21 @@ func asmFile(inputPath, outputPath string) error {
 		return fmt.Errorf("failed to read %s: %w", inputPath, err)
 	}
 
-	// Assemble
-	result, err := bin.Assemble(string(text), bin.FormatSYS5)
+	return asmText(string(text), inputPath, outputPath)
+}
+
+func asmText(text, inputPath, outputPath string) error {
+	version, err := asmVersion()
+	if err != nil {
+		return err
+	}
+
+	// Assemble, detecting SYS4/SYS5 from the signature line unless forced
+	result, err := bin.AssembleWithOptions(text, bin.FormatVersion(0), bin.AssembleOptions{
+		Version:       version,
+		Strict:        asmStrict,
+		DedupeStrings: asmDedupe,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to assemble %s: %w", inputPath, err)
 	}
@@ -78,6 +132,18 @@ func asmFile(inputPath, outputPath string) error {
 	fmt.Printf("Assembled %s -> %s (%d bytes)\n",
 		filepath.Base(inputPath), filepath.Base(outputPath), len(result.Data))
 
+	if asmLabels != "" {
+		if err := result.Labels.Save(asmLabels); err != nil {
+			return fmt.Errorf("failed to write label map: %w", err)
+		}
+	}
+
+	if asmComments != "" {
+		if err := result.Comments.Save(asmComments); err != nil {
+			return fmt.Errorf("failed to write comment map: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -114,3 +180,29 @@ func asmDirectory(dir string) error {
 	fmt.Printf("\nProcessed %d files, %d errors\n", processed, errors)
 	return nil
 }
+
+func asmJoinDirectory(dir, outputPath string) error {
+	index, err := os.ReadFile(filepath.Join(dir, splitIndexName))
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var sb strings.Builder
+	for _, line := range strings.Split(string(index), "\n") {
+		name := strings.TrimSpace(line)
+		if name == "" || strings.HasPrefix(name, "//") {
+			continue
+		}
+
+		text, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		sb.Write(text)
+		if len(text) > 0 && text[len(text)-1] != '\n' {
+			sb.WriteString("\n")
+		}
+	}
+
+	return asmText(sb.String(), dir, outputPath)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"agetools/pkg/bin"
+
+	"github.com/spf13/cobra"
+)
+
+var binDiffCmd = &cobra.Command{
+	Use:   "bin-diff <old.bin> <new.bin>",
+	Short: "Compare two BIN scripts instruction by instruction",
+	Long: `Compare two Eushully AGE engine BIN scripts and print the instructions that
+differ, in a unified-diff style.
+
+Instructions are aligned by offset when both scripts have the same layout,
+and by opcode sequence otherwise. Jumps to the same (aligned) instruction and
+strings with the same text are not reported, even if their offsets moved.
+
+Examples:
+  agetools bin-diff BUNKI.BIN BUNKI.new.BIN`,
+	Args: cobra.ExactArgs(2),
+	RunE: runBinDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(binDiffCmd)
+}
+
+func runBinDiff(cmd *cobra.Command, args []string) error {
+	a, err := disassembleFile(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := disassembleFile(args[1])
+	if err != nil {
+		return err
+	}
+
+	diffs := bin.DiffScripts(a, b)
+	if len(diffs) == 0 {
+		fmt.Println("Scripts are identical")
+		return nil
+	}
+
+	fmt.Printf("--- %s\n+++ %s\n", args[0], args[1])
+	for _, d := range diffs {
+		fmt.Printf("@@ %s %s -> %s @@\n", d.Kind, diffLocation(d.IndexA, d.OffsetA), diffLocation(d.IndexB, d.OffsetB))
+		if d.Before != "" {
+			fmt.Printf("-    %s\n", d.Before)
+		}
+		if d.After != "" {
+			fmt.Printf("+    %s\n", d.After)
+		}
+	}
+
+	fmt.Printf("\n%d differences\n", len(diffs))
+	return nil
+}
+
+// diffLocation formats an instruction index and offset, or "-" if absent
+func diffLocation(index, offset int) string {
+	if index < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("#%d (0x%08X)", index, offset)
+}
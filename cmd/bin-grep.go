@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"agetools/pkg/bin"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	binGrepDir        string
+	binGrepRegex      bool
+	binGrepIgnoreCase bool
+)
+
+var binGrepCmd = &cobra.Command{
+	Use:   "bin-grep <pattern> [file.bin...]",
+	Short: "Search the strings of BIN scripts",
+	Long: `Search the decoded strings of one or more BIN scripts and print the file,
+string offset, referencing instruction and text of every match.
+
+The pattern is a plain substring unless --regex is given. SYS4 (Shift-JIS)
+and SYS5 (UTF-16) strings are decoded before matching. With --dir, every .bin
+file under the directory is searched.
+
+Examples:
+  agetools bin-grep --dir scripts "探している台詞"
+  agetools bin-grep -e -i "^hello" BUNKI.BIN START.BIN`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runBinGrep,
+}
+
+func init() {
+	rootCmd.AddCommand(binGrepCmd)
+	binGrepCmd.Flags().StringVarP(&binGrepDir, "dir", "d", "", "Search all .bin files under this directory")
+	binGrepCmd.Flags().BoolVarP(&binGrepRegex, "regex", "e", false, "Treat the pattern as a regular expression")
+	binGrepCmd.Flags().BoolVarP(&binGrepIgnoreCase, "ignore-case", "i", false, "Match case-insensitively")
+}
+
+func runBinGrep(cmd *cobra.Command, args []string) error {
+	match, err := grepMatcher(args[0])
+	if err != nil {
+		return err
+	}
+
+	files := args[1:]
+	if binGrepDir != "" {
+		found, err := findBinFiles(binGrepDir)
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("either --dir or a file path is required")
+	}
+
+	total := 0
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to read %s: %v\n", path, err)
+			continue
+		}
+
+		matches, err := bin.GrepStrings(data, match)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+			continue
+		}
+
+		for _, m := range matches {
+			inst := "?"
+			if m.Instruction != "" {
+				inst = fmt.Sprintf("%s@0x%08X", m.Instruction, m.InstructionOffset)
+			}
+			fmt.Printf("%s:0x%08X: %s %q\n", path, m.Offset, inst, m.Value)
+		}
+		total += len(matches)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d matches in %d files\n", total, len(files))
+	return nil
+}
+
+// grepMatcher builds the string predicate for a bin-grep pattern
+func grepMatcher(pattern string) (func(string) bool, error) {
+	if binGrepRegex {
+		if binGrepIgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		return re.MatchString, nil
+	}
+
+	if binGrepIgnoreCase {
+		pattern = strings.ToLower(pattern)
+		return func(s string) bool {
+			return strings.Contains(strings.ToLower(s), pattern)
+		}, nil
+	}
+	return func(s string) bool {
+		return strings.Contains(s, pattern)
+	}, nil
+}
+
+// findBinFiles returns every .bin file under dir, in lexical order
+func findBinFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".bin") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	return files, nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"agetools/pkg/bin"
+
+	"github.com/spf13/cobra"
+)
+
+var binOpcodesCmd = &cobra.Command{
+	Use:   "bin-opcodes [filter]",
+	Short: "List the BIN instruction set",
+	Long: `List every opcode known to the disassembler with its mnemonic and argument
+count. Control-flow instructions, whose label arguments are resolved, are
+marked. An optional filter keeps mnemonics containing it.
+
+Examples:
+  agetools bin-opcodes
+  agetools bin-opcodes text`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runBinOpcodes,
+}
+
+func init() {
+	rootCmd.AddCommand(binOpcodesCmd)
+}
+
+func runBinOpcodes(cmd *cobra.Command, args []string) error {
+	filter := ""
+	if len(args) > 0 {
+		filter = args[0]
+	}
+
+	listed := 0
+	for _, def := range bin.AllOpcodes() {
+		if !strings.Contains(def.Label, filter) {
+			continue
+		}
+		flow := ""
+		if bin.IsControlFlow(def.Opcode) {
+			flow = "control-flow"
+		}
+		line := fmt.Sprintf("0x%04X  %-24s %2d  %s", def.Opcode, def.Label, def.ArgCount, flow)
+		fmt.Println(strings.TrimRight(line, " "))
+		listed++
+	}
+
+	fmt.Printf("\n%d opcodes\n", listed)
+	return nil
+}
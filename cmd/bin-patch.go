@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agetools/pkg/bin"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	binPatchOutput string
+)
+
+var binPatchMakeCmd = &cobra.Command{
+	Use:   "bin-patch-make <original.bin> <modified.bin>",
+	Short: "Create a compact patch between two BIN files",
+	Long: `Create a compact binary patch that turns an original BIN file into a modified one.
+
+The patch records the original file's hash and is refused when applied to
+any other file.
+
+Examples:
+  agetools bin-patch-make old.BIN new.BIN                # Output to new.patch
+  agetools bin-patch-make old.BIN new.BIN -o edit.patch  # Output to edit.patch`,
+	Args: cobra.ExactArgs(2),
+	RunE: runBinPatchMake,
+}
+
+var binPatchApplyCmd = &cobra.Command{
+	Use:   "bin-patch-apply <original.bin> <edit.patch>",
+	Short: "Apply a patch created by bin-patch-make",
+	Long: `Apply a patch created by bin-patch-make to the original BIN file.
+
+Examples:
+  agetools bin-patch-apply old.BIN edit.patch               # Output to old.patched.BIN
+  agetools bin-patch-apply old.BIN edit.patch -o new.BIN    # Output to new.BIN`,
+	Args: cobra.ExactArgs(2),
+	RunE: runBinPatchApply,
+}
+
+func init() {
+	rootCmd.AddCommand(binPatchMakeCmd)
+	rootCmd.AddCommand(binPatchApplyCmd)
+	binPatchMakeCmd.Flags().StringVarP(&binPatchOutput, "output", "o", "", "Output patch path")
+	binPatchApplyCmd.Flags().StringVarP(&binPatchOutput, "output", "o", "", "Output BIN path")
+}
+
+func runBinPatchMake(cmd *cobra.Command, args []string) error {
+	original, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", args[0], err)
+	}
+
+	modified, err := os.ReadFile(args[1])
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", args[1], err)
+	}
+
+	patch, err := bin.MakePatch(original, modified)
+	if err != nil {
+		return fmt.Errorf("failed to create patch: %w", err)
+	}
+
+	outputPath := binPatchOutput
+	if outputPath == "" {
+		outputPath = strings.TrimSuffix(args[1], filepath.Ext(args[1])) + ".patch"
+	}
+
+	if err := os.WriteFile(outputPath, patch, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+
+	fmt.Printf("Created %s (%d bytes)\n", filepath.Base(outputPath), len(patch))
+	return nil
+}
+
+func runBinPatchApply(cmd *cobra.Command, args []string) error {
+	original, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", args[0], err)
+	}
+
+	patch, err := os.ReadFile(args[1])
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", args[1], err)
+	}
+
+	result, err := bin.ApplyPatch(original, patch)
+	if err != nil {
+		return fmt.Errorf("failed to apply patch: %w", err)
+	}
+
+	outputPath := binPatchOutput
+	if outputPath == "" {
+		ext := filepath.Ext(args[0])
+		outputPath = strings.TrimSuffix(args[0], ext) + ".patched" + ext
+	}
+
+	if err := os.WriteFile(outputPath, result, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+
+	fmt.Printf("Patched %s -> %s (%d bytes)\n", filepath.Base(args[0]), filepath.Base(outputPath), len(result))
+	return nil
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"agetools/pkg/bin"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	binStatsDir string
+	binStatsTop int
+)
+
+var binStatsCmd = &cobra.Command{
+	Use:   "bin-stats [file.bin...]",
+	Short: "Count opcodes and argument types in BIN scripts",
+	Long: `Count the instructions, strings, data arrays and argument types of one or
+more BIN scripts and print the most common opcodes across all of them.
+
+Unknown opcodes are disassembled as raw words and counted separately by
+value. With --dir, every .bin file under the directory is included.
+
+Examples:
+  agetools bin-stats --dir scripts
+  agetools bin-stats --top 50 BUNKI.BIN START.BIN`,
+	RunE: runBinStats,
+}
+
+func init() {
+	rootCmd.AddCommand(binStatsCmd)
+	binStatsCmd.Flags().StringVarP(&binStatsDir, "dir", "d", "", "Include all .bin files under this directory")
+	binStatsCmd.Flags().IntVarP(&binStatsTop, "top", "n", 20, "Number of opcodes to list (-1 for all)")
+}
+
+func runBinStats(cmd *cobra.Command, args []string) error {
+	files := args
+	if binStatsDir != "" {
+		found, err := findBinFiles(binStatsDir)
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("either --dir or a file path is required")
+	}
+
+	total := bin.NewStats()
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to read %s: %v\n", path, err)
+			continue
+		}
+
+		script, err := bin.DisassembleWithOptions(data, bin.DisassembleOptions{TolerateUnknown: true})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+			continue
+		}
+		total.Add(script.Stats())
+	}
+
+	fmt.Printf("Scripts:      %d\n", total.Scripts)
+	fmt.Printf("Instructions: %d\n", total.Instructions)
+	fmt.Printf("Strings:      %d\n", total.Strings)
+	fmt.Printf("Arrays:       %d\n", total.Arrays)
+
+	fmt.Printf("\nOpcodes (%d distinct):\n", len(total.Opcodes))
+	for _, oc := range total.TopOpcodes(binStatsTop) {
+		fmt.Printf("  %-24s %8d  %5.1f%%\n", oc.Mnemonic, oc.Count, percent(oc.Count, total.Instructions))
+	}
+
+	argTypes := make([]bin.ArgumentType, 0, len(total.ArgTypes))
+	for t := range total.ArgTypes {
+		argTypes = append(argTypes, t)
+	}
+	sort.Slice(argTypes, func(a, b int) bool { return argTypes[a] < argTypes[b] })
+	fmt.Printf("\nArgument types:\n")
+	for _, t := range argTypes {
+		name := t.String()
+		if name == "" {
+			name = "immediate"
+		}
+		fmt.Printf("  %-24s %8d\n", fmt.Sprintf("%s (0x%X)", name, uint32(t)), total.ArgTypes[t])
+	}
+
+	if len(total.Unknown) > 0 {
+		opcodes := make([]uint32, 0, len(total.Unknown))
+		for op := range total.Unknown {
+			opcodes = append(opcodes, op)
+		}
+		sort.Slice(opcodes, func(a, b int) bool { return opcodes[a] < opcodes[b] })
+		fmt.Printf("\nUnknown opcodes (%d distinct):\n", len(opcodes))
+		for _, op := range opcodes {
+			fmt.Printf("  0x%08X               %8d\n", op, total.Unknown[op])
+		}
+	}
+
+	return nil
+}
+
+// percent returns n as a percentage of total, or 0 for an empty total
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
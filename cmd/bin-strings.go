@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agetools/pkg/bin"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	binStringsOutput string
+)
+
+var binStringsExportCmd = &cobra.Command{
+	Use:   "bin-strings-export <file.bin>",
+	Short: "Export the strings of a BIN script to an editable table",
+	Long: `Export every string argument of a BIN script to a CSV or JSON table.
+
+Each row holds the instruction index, the argument index and the text. Edit
+the text column and apply the table with bin-strings-import.
+
+Examples:
+  agetools bin-strings-export BUNKI.BIN                    # Output to BUNKI.csv
+  agetools bin-strings-export BUNKI.BIN -o BUNKI.json      # JSON instead of CSV`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBinStringsExport,
+}
+
+var binStringsImportCmd = &cobra.Command{
+	Use:   "bin-strings-import <file.bin> <strings.csv>",
+	Short: "Apply an edited string table to a BIN script",
+	Long: `Replace the strings of a BIN script with the text from a table created by
+bin-strings-export, and reassemble the script.
+
+Examples:
+  agetools bin-strings-import BUNKI.BIN BUNKI.csv                # Output to BUNKI.new.BIN
+  agetools bin-strings-import BUNKI.BIN BUNKI.csv -o out.BIN     # Output to out.BIN`,
+	Args: cobra.ExactArgs(2),
+	RunE: runBinStringsImport,
+}
+
+func init() {
+	rootCmd.AddCommand(binStringsExportCmd)
+	rootCmd.AddCommand(binStringsImportCmd)
+	binStringsExportCmd.Flags().StringVarP(&binStringsOutput, "output", "o", "", "Output table path (.csv or .json)")
+	binStringsImportCmd.Flags().StringVarP(&binStringsOutput, "output", "o", "", "Output BIN path")
+}
+
+func runBinStringsExport(cmd *cobra.Command, args []string) error {
+	script, err := disassembleFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	outputPath := binStringsOutput
+	if outputPath == "" {
+		outputPath = strings.TrimSuffix(args[0], filepath.Ext(args[0])) + ".csv"
+	}
+
+	entries := script.ExportStrings()
+	if err := bin.SaveStringTable(outputPath, entries); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d strings to %s\n", len(entries), filepath.Base(outputPath))
+	return nil
+}
+
+func runBinStringsImport(cmd *cobra.Command, args []string) error {
+	script, err := disassembleFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	entries, err := bin.LoadStringTable(args[1])
+	if err != nil {
+		return err
+	}
+	if err := script.ImportStrings(entries); err != nil {
+		return fmt.Errorf("failed to import %s: %w", args[1], err)
+	}
+
+	result, err := bin.AssembleFromScript(script)
+	if err != nil {
+		return fmt.Errorf("failed to assemble: %w", err)
+	}
+
+	outputPath := binStringsOutput
+	if outputPath == "" {
+		outputPath = strings.TrimSuffix(args[0], filepath.Ext(args[0])) + ".new.BIN"
+	}
+	if err := os.WriteFile(outputPath, result.Data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+
+	fmt.Printf("Imported %d strings -> %s (%d bytes)\n", len(entries), filepath.Base(outputPath), len(result.Data))
+	return nil
+}
+
+// disassembleFile reads and disassembles a BIN script
+func disassembleFile(path string) (*bin.Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	script, err := bin.Disassemble(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to disassemble %s: %w", path, err)
+	}
+	return script, nil
+}
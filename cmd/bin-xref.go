@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"agetools/pkg/bin"
+
+	"github.com/spf13/cobra"
+)
+
+var binXrefLabels string
+
+var binXrefCmd = &cobra.Command{
+	Use:   "bin-xref <file.bin> [label]",
+	Short: "List the instructions that reference a label",
+	Long: `List every instruction of a BIN script that jumps to or calls a label.
+
+Without a label, print the reference count of every label, followed by the
+labels nothing references and the references that resolve to no instruction.
+
+Examples:
+  agetools bin-xref BUNKI.BIN label_00001234     # Who jumps to label_00001234
+  agetools bin-xref BUNKI.BIN                    # Summary of all labels
+  agetools bin-xref BUNKI.BIN main --labels BUNKI.labels.json  # Use names from a label map`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runBinXref,
+}
+
+func init() {
+	rootCmd.AddCommand(binXrefCmd)
+	binXrefCmd.Flags().StringVar(&binXrefLabels, "labels", "", "Label map (JSON) whose names to apply before looking up")
+}
+
+func runBinXref(cmd *cobra.Command, args []string) error {
+	script, err := disassembleFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	if binXrefLabels != "" {
+		labels, err := bin.LoadLabelMap(binXrefLabels)
+		if err != nil {
+			return err
+		}
+		script.ApplyLabelNames(labels)
+	}
+
+	xrefs := script.XRefs()
+
+	// Single label
+	if len(args) == 2 {
+		name := args[1]
+		found := false
+		for _, label := range script.Labels {
+			if label == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("label %s not found in %s", name, args[0])
+		}
+
+		refs := xrefs[name]
+		fmt.Printf("%s: %d references\n", name, len(refs))
+		for _, i := range refs {
+			instr := &script.Instructions[i]
+			fmt.Printf("  0x%08X  %s\n", instr.Offset, instr)
+		}
+		return nil
+	}
+
+	// Summary
+	for _, name := range bin.SortLabels(script.Labels) {
+		fmt.Printf("%-24s %d\n", name, len(xrefs[name]))
+	}
+	if unref := script.UnreferencedLabels(); len(unref) > 0 {
+		fmt.Printf("\nUnreferenced labels (%d):\n", len(unref))
+		for _, name := range unref {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+	if dangling := script.DanglingReferences(); len(dangling) > 0 {
+		fmt.Fprintf(os.Stderr, "\nWarning: %d references resolve to no instruction:\n", len(dangling))
+		for _, name := range dangling {
+			fmt.Fprintf(os.Stderr, "  %s\n", name)
+		}
+	}
+	return nil
+}
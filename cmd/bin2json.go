@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var bin2jsonCmd = &cobra.Command{
+	Use:   "bin2json <file.bin> [output.json]",
+	Short: "Convert BIN script files to JSON",
+	Long: `Convert Eushully AGE engine BIN script files to a machine-readable JSON form.
+
+The JSON holds the header, every instruction with its mnemonic, argument
+types, raw values, resolved labels, decoded strings and data arrays.
+
+Examples:
+  agetools bin2json BUNKI.BIN                  # Output to BUNKI.json
+  agetools bin2json BUNKI.BIN output.json      # Output to output.json
+  agetools bin2json --dir ./scripts            # Convert all .bin files in directory`,
+	Args: cobra.MinimumNArgs(0),
+	RunE: runBin2JSON,
+}
+
+var (
+	bin2jsonDir string
+)
+
+func init() {
+	rootCmd.AddCommand(bin2jsonCmd)
+	bin2jsonCmd.Flags().StringVarP(&bin2jsonDir, "dir", "d", "", "Process all .bin files in directory")
+}
+
+func runBin2JSON(cmd *cobra.Command, args []string) error {
+	// Directory mode
+	if bin2jsonDir != "" {
+		return bin2jsonDirectory(bin2jsonDir)
+	}
+
+	// Single file mode
+	if len(args) < 1 {
+		return fmt.Errorf("either --dir or a file path is required")
+	}
+
+	inputPath := args[0]
+	outputPath := ""
+	if len(args) >= 2 {
+		outputPath = args[1]
+	} else {
+		ext := filepath.Ext(inputPath)
+		outputPath = strings.TrimSuffix(inputPath, ext) + ".json"
+	}
+
+	return bin2jsonFile(inputPath, outputPath)
+}
+
+func bin2jsonFile(inputPath, outputPath string) error {
+	script, err := disassembleFile(inputPath)
+	if err != nil {
+		return err
+	}
+
+	data, err := script.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", inputPath, err)
+	}
+
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+
+	fmt.Printf("Converted %s -> %s (%d instructions)\n",
+		filepath.Base(inputPath), filepath.Base(outputPath), len(script.Instructions))
+	return nil
+}
+
+func bin2jsonDirectory(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	processed := 0
+	errors := 0
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		if !strings.HasSuffix(strings.ToLower(name), ".bin") {
+			continue
+		}
+
+		inputPath := filepath.Join(dir, name)
+		outputPath := filepath.Join(dir, strings.TrimSuffix(name, filepath.Ext(name))+".json")
+
+		if err := bin2jsonFile(inputPath, outputPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", name, err)
+			errors++
+		} else {
+			processed++
+		}
+	}
+
+	fmt.Printf("\nProcessed %d files, %d errors\n", processed, errors)
+	return nil
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"agetools/pkg/bin"
+
+	"github.com/spf13/cobra"
+)
+
+var bininfoCmd = &cobra.Command{
+	Use:   "bininfo <file.bin>",
+	Short: "Display BIN script header information",
+	Long: `Display the header fields of an Eushully AGE engine BIN script file.
+
+Fields with undocumented meaning (such as unknown_data) are flagged when
+they hold unexpected values.
+
+Examples:
+  agetools bininfo BUNKI.BIN`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBininfo,
+}
+
+func init() {
+	rootCmd.AddCommand(bininfoCmd)
+}
+
+func runBininfo(cmd *cobra.Command, args []string) error {
+	inputPath := args[0]
+
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", inputPath, err)
+	}
+
+	header, err := bin.ReadHeader(data)
+	if err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+
+	fmt.Printf("File: %s (%d bytes)\n", inputPath, len(data))
+	fmt.Print(header.String())
+	if err := header.Validate(len(data)); err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Printf("WARNING: %s\n", line)
+		}
+	}
+
+	return nil
+}
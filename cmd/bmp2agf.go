@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,6 +15,13 @@ var (
 	bmp2agfOutput   string
 	bmp2agfOriginal string
 	bmp2agfVerbose  bool
+	bmp2agfStrict   bool
+	bmp2agfPalette  string
+	bmp2agfTolerant bool
+	bmp2agfCompress bool
+
+	// bmp2agfPaletteColors is the palette loaded from --palette, if any
+	bmp2agfPaletteColors []agf.RGBQuad
 )
 
 var bmp2agfCmd = &cobra.Command{
@@ -35,7 +43,13 @@ Examples:
   agetools bmp2agf image.BMP output.AGF -r original/image.AGF
 
   # Convert directory
-  agetools bmp2agf BMP_folder/ -o AGF_output/ -r original_AGF/`,
+  agetools bmp2agf BMP_folder/ -o AGF_output/ -r original_AGF/
+
+  # Replace the palette of an 8-bit AGF with an edited one
+  agetools bmp2agf image.BMP -r original/image.AGF --palette image.pal
+
+  # Compress the output to about the size of the original
+  agetools bmp2agf image.BMP -r original/image.AGF -z`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runBmp2Agf,
 }
@@ -49,6 +63,14 @@ func init() {
 		"original AGF file or directory for format reference")
 	bmp2agfCmd.Flags().BoolVarP(&bmp2agfVerbose, "verbose", "v", false,
 		"print verbose progress information")
+	bmp2agfCmd.Flags().BoolVar(&bmp2agfStrict, "strict", false,
+		"fail instead of approximating colors when bit depth conversion is lossy")
+	bmp2agfCmd.Flags().StringVar(&bmp2agfPalette, "palette", "",
+		"replace the palette of 8-bit AGFs with this .act or .pal file")
+	bmp2agfCmd.Flags().BoolVar(&bmp2agfTolerant, "tolerant", false,
+		"accept reference AGFs whose duplicated sector length fields disagree")
+	bmp2agfCmd.Flags().BoolVarP(&bmp2agfCompress, "compress", "z", false,
+		"LZSS compress the sectors like the game's own AGFs")
 }
 
 func runBmp2Agf(cmd *cobra.Command, args []string) error {
@@ -59,6 +81,12 @@ func runBmp2Agf(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("input not found: %s", input)
 	}
 
+	if bmp2agfPalette != "" {
+		if bmp2agfPaletteColors, err = agf.ImportPalette(bmp2agfPalette); err != nil {
+			return fmt.Errorf("failed to load palette: %w", err)
+		}
+	}
+
 	if info.IsDir() {
 		return convertBmpDirectory(input, bmp2agfOutput, bmp2agfOriginal)
 	}
@@ -91,8 +119,26 @@ func convertBmpFile(input, output, original string) error {
 		fmt.Printf("Converting %s -> %s (ref: %s)\n", input, output, original)
 	}
 
-	if err := agf.Pack(input, original, output, agf.PackOptions{}); err != nil {
-		return fmt.Errorf("failed to pack %s: %w", input, err)
+	// Warn about colors that will be quantized to the original palette.
+	// A replacement palette is quantized against instead, so skip the check.
+	if bmp2agfPaletteColors == nil {
+		missing, err := agf.CheckPaletteCompatibleWithOptions(input, original, agf.UnpackOptions{Tolerant: bmp2agfTolerant})
+		if err != nil {
+			return packFailure(input, original, err)
+		}
+		if len(missing) > 0 && !bmp2agfStrict {
+			fmt.Fprintf(os.Stderr, "Warning: %s uses %d colors not in the original palette; they are replaced by the nearest palette color\n",
+				filepath.Base(input), len(missing))
+		}
+	}
+
+	if err := agf.Pack(input, original, output, agf.PackOptions{
+		Strict:   bmp2agfStrict,
+		Palette:  bmp2agfPaletteColors,
+		Tolerant: bmp2agfTolerant,
+		Compress: bmp2agfCompress,
+	}); err != nil {
+		return packFailure(input, original, err)
 	}
 
 	if !bmp2agfVerbose {
@@ -102,6 +148,15 @@ func convertBmpFile(input, output, original string) error {
 	return nil
 }
 
+// packFailure wraps an error from packing input against original.
+func packFailure(input, original string, err error) error {
+	if errors.Is(err, agf.ErrUnsupportedType) {
+		return fmt.Errorf("cannot convert %s: reference %s is a video AGF, cannot pack a bitmap against it: %w",
+			input, original, err)
+	}
+	return fmt.Errorf("failed to pack %s: %w", input, err)
+}
+
 func convertBmpDirectory(inputDir, outputDir, originalDir string) error {
 	if outputDir == "" {
 		outputDir = inputDir + "_AGF"
@@ -115,7 +170,7 @@ func convertBmpDirectory(inputDir, outputDir, originalDir string) error {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	count := 0
+	count, unsupported, failed := 0, 0, 0
 	err := filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -149,6 +204,11 @@ func convertBmpDirectory(inputDir, outputDir, originalDir string) error {
 
 		if err := convertBmpFile(path, outPath, origPath); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			if errors.Is(err, agf.ErrUnsupportedType) {
+				unsupported++
+			} else {
+				failed++
+			}
 			return nil
 		}
 
@@ -161,5 +221,11 @@ func convertBmpDirectory(inputDir, outputDir, originalDir string) error {
 	}
 
 	fmt.Printf("Converted %d files\n", count)
+	if unsupported > 0 {
+		fmt.Printf("Skipped %d files with video/unsupported reference AGFs\n", unsupported)
+	}
+	if failed > 0 {
+		fmt.Printf("Failed %d files\n", failed)
+	}
 	return nil
 }
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"agetools/pkg/alf"
+	"github.com/spf13/cobra"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check <index-file>",
+	Short: "Check archive entries for overlaps, gaps and truncation",
+	Long: `Check the layout of the files an archive index references.
+
+For each DATA*.ALF the entries are sorted by offset and these problems are
+reported:
+  overlap   an entry starts inside the data of an earlier one
+  gap       unreferenced bytes before an entry, beyond alignment padding
+  past end  an entry extends past the end of the archive file
+
+Entries that share exactly the same data are not overlaps. Nothing is
+modified; missing archives are noted and skipped. The command fails if any
+problem is found.
+
+Examples:
+  # Check an index before extracting it
+  agetools check SYS5INI.BIN`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCheck,
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
+
+func runCheck(cmd *cobra.Command, args []string) error {
+	absPath, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	extractor, err := alf.NewExtractor(absPath, alf.ExtractOptions{SkipMissingArchives: true})
+	if err != nil {
+		return fmt.Errorf("failed to create extractor: %w", err)
+	}
+	defer extractor.Close()
+
+	if err := extractor.Open(absPath); err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	archive := extractor.GetArchive()
+	if missing := archive.MissingSources(); len(missing) > 0 {
+		fmt.Printf("Missing archives (not checked): %s\n", strings.Join(missing, ", "))
+	}
+
+	issues := archive.CheckLayout()
+	for _, issue := range issues {
+		fmt.Printf("  %s\n", issue)
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("%d layout issues in %d files", len(issues), len(archive.Entries))
+	}
+	fmt.Printf("Layout OK: %d files in %d archives\n", len(archive.Entries), len(archive.Sources))
+	return nil
+}
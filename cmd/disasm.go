@@ -20,20 +20,45 @@ Examples:
   agetools disasm BUNKI.BIN                    # Output to BUNKI.txt
   agetools disasm BUNKI.BIN output.txt         # Output to output.txt
   agetools disasm --dir ./scripts              # Disassemble all .bin files in directory
-  agetools disasm BUNKI.BIN --verify           # Verify round-trip`,
+  agetools disasm BUNKI.BIN --verify           # Verify round-trip
+  agetools disasm BUNKI.BIN --externals        # List unresolved control-flow targets
+  agetools disasm BUNKI.BIN --functions        # Mark function entries with comments
+  agetools disasm BUNKI.BIN --split-functions -o BUNKI/  # One file per function
+  agetools disasm BUNKI.BIN --labels BUNKI.labels.json   # Keep label names from a label map
+  agetools disasm BUNKI.BIN --comments BUNKI.comments.json  # Restore comments recorded by asm --comments
+  agetools disasm BUNKI.BIN --tolerate-unknown # Emit unknown opcodes as .word and keep going
+  agetools disasm BUNKI.BIN --offsets          # Prefix instructions with file offsets and argument types`,
 	Args: cobra.MinimumNArgs(0),
 	RunE: runDisasm,
 }
 
 var (
-	disasmDir    string
-	disasmVerify bool
+	disasmDir       string
+	disasmVerify    bool
+	disasmExternals bool
+	disasmFunctions bool
+	disasmSplit     bool
+	disasmOutput    string
+	disasmLabels    string
+	disasmComments  string
+	disasmTolerate  bool
+	disasmOffsets   bool
+	disasmStrictHdr bool
 )
 
 func init() {
 	rootCmd.AddCommand(disasmCmd)
 	disasmCmd.Flags().StringVarP(&disasmDir, "dir", "d", "", "Process all .bin files in directory")
 	disasmCmd.Flags().BoolVarP(&disasmVerify, "verify", "v", false, "Verify round-trip (disasm -> asm -> compare)")
+	disasmCmd.Flags().BoolVar(&disasmSplit, "split-functions", false, "Write one file per function plus an index (see asm --join)")
+	disasmCmd.Flags().StringVarP(&disasmOutput, "output", "o", "", "Output directory for --split-functions")
+	disasmCmd.Flags().BoolVar(&disasmFunctions, "functions", false, "Mark call-target labels with function header comments")
+	disasmCmd.Flags().StringVar(&disasmLabels, "labels", "", "Label map (JSON) to apply; created with the current names if missing")
+	disasmCmd.Flags().StringVar(&disasmComments, "comments", "", "Comment map (JSON) written by asm --comments to apply")
+	disasmCmd.Flags().BoolVar(&disasmTolerate, "tolerate-unknown", false, "Emit unknown opcodes as raw .word directives instead of stopping")
+	disasmCmd.Flags().BoolVar(&disasmOffsets, "offsets", false, "Prefix instruction lines with their file offset and argument type indices")
+	disasmCmd.Flags().BoolVar(&disasmStrictHdr, "strict-header", false, "Fail on inconsistent header fields instead of warning")
+	disasmCmd.Flags().BoolVar(&disasmExternals, "externals", false, "List control-flow targets outside the script (engine routines)")
 }
 
 func runDisasm(cmd *cobra.Command, args []string) error {
@@ -48,6 +73,14 @@ func runDisasm(cmd *cobra.Command, args []string) error {
 	}
 
 	inputPath := args[0]
+	if disasmSplit {
+		outputDir := disasmOutput
+		if outputDir == "" {
+			outputDir = strings.TrimSuffix(inputPath, filepath.Ext(inputPath))
+		}
+		return disasmSplitFile(inputPath, outputDir)
+	}
+
 	outputPath := ""
 	if len(args) >= 2 {
 		outputPath = args[1]
@@ -60,6 +93,21 @@ func runDisasm(cmd *cobra.Command, args []string) error {
 	return disasmFile(inputPath, outputPath)
 }
 
+// disasmOptions returns the disassembler options selected on the command line
+func disasmOptions() bin.DisassembleOptions {
+	return bin.DisassembleOptions{TolerateUnknown: disasmTolerate, StrictHeader: disasmStrictHdr}
+}
+
+// warnHeader prints the header problems found while disassembling inputPath
+func warnHeader(script *bin.Script, inputPath string) {
+	if script.HeaderErr == nil {
+		return
+	}
+	for _, line := range strings.Split(script.HeaderErr.Error(), "\n") {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", filepath.Base(inputPath), line)
+	}
+}
+
 func disasmFile(inputPath, outputPath string) error {
 	// Read input file
 	data, err := os.ReadFile(inputPath)
@@ -69,24 +117,35 @@ func disasmFile(inputPath, outputPath string) error {
 
 	// Verify round-trip if requested
 	if disasmVerify {
-		matches, err := bin.VerifyRoundTrip(data)
+		mismatch, err := bin.VerifyRoundTripDetailed(data)
 		if err != nil {
 			fmt.Printf("Verify failed for %s: %v\n", inputPath, err)
-		} else if matches {
+		} else if mismatch == nil {
 			fmt.Printf("Verify OK: %s\n", inputPath)
 		} else {
-			fmt.Printf("Verify MISMATCH: %s\n", inputPath)
+			fmt.Printf("Verify MISMATCH: %s: %s\n", inputPath, mismatch)
 		}
 	}
 
 	// Disassemble
-	script, err := bin.Disassemble(data)
+	script, err := bin.DisassembleWithOptions(data, disasmOptions())
 	if err != nil {
 		return fmt.Errorf("failed to disassemble %s: %w", inputPath, err)
 	}
+	warnHeader(script, inputPath)
+
+	if err := applyLabelMap(script, disasmLabels); err != nil {
+		return err
+	}
+	if err := applyCommentMap(script, disasmComments); err != nil {
+		return err
+	}
 
 	// Convert to text
-	text := script.ToText()
+	text := script.ToTextWithOptions(bin.RenderOptions{
+		FunctionHeaders: disasmFunctions,
+		Offsets:         disasmOffsets,
+	})
 
 	// Write output
 	if err := os.WriteFile(outputPath, []byte(text), 0644); err != nil {
@@ -96,6 +155,57 @@ func disasmFile(inputPath, outputPath string) error {
 	fmt.Printf("Disassembled %s -> %s (%d instructions)\n",
 		filepath.Base(inputPath), filepath.Base(outputPath), len(script.Instructions))
 
+	if disasmExternals {
+		externals := script.ExternalTargets()
+		fmt.Printf("External targets (%d):\n", len(externals))
+		for _, target := range externals {
+			fmt.Printf("  0x%08X\n", target)
+		}
+	}
+
+	return nil
+}
+
+// applyLabelMap renames the script's labels from the label map at path and
+// writes the resulting map back, so the first run records the current names.
+func applyLabelMap(script *bin.Script, path string) error {
+	if path == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		labels, err := bin.LoadLabelMap(path)
+		if err != nil {
+			return err
+		}
+		renamed := script.ApplyLabelNames(labels)
+		fmt.Printf("Applied %d label names from %s\n", renamed, filepath.Base(path))
+	}
+
+	if err := script.LabelMap().Save(path); err != nil {
+		return fmt.Errorf("failed to write label map: %w", err)
+	}
+
+	return nil
+}
+
+// applyCommentMap attaches the comments of the comment map at path to the
+// script's instructions. A missing file is not an error, since asm only
+// writes one after the first edit cycle.
+func applyCommentMap(script *bin.Script, path string) error {
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+
+	comments, err := bin.LoadCommentMap(path)
+	if err != nil {
+		return err
+	}
+	applied := script.ApplyComments(comments)
+	fmt.Printf("Applied %d comments from %s\n", applied, filepath.Base(path))
 	return nil
 }
 
@@ -132,3 +242,86 @@ func disasmDirectory(dir string) error {
 	fmt.Printf("\nProcessed %d files, %d errors\n", processed, errors)
 	return nil
 }
+
+// splitIndexName is the file listing split disassembly files in assembly order
+const splitIndexName = "index.txt"
+
+// splitHeaderName is the split disassembly file holding the header block
+const splitHeaderName = "header.txt"
+
+// splitFunctionPrefix starts the name of every function file, so that no
+// label can name the header or index file
+const splitFunctionPrefix = "fn_"
+
+// splitFileNames returns the file name of each function of a split
+// disassembly. Names that differ only in case are rejected, since they
+// would overwrite each other on case-insensitive file systems.
+func splitFileNames(funcs []bin.FunctionText) ([]string, error) {
+	seen := map[string]string{
+		strings.ToLower(splitHeaderName): splitHeaderName,
+		strings.ToLower(splitIndexName):  splitIndexName,
+	}
+	names := make([]string, len(funcs))
+	for i, fn := range funcs {
+		name := splitFunctionPrefix + fn.Name + ".txt"
+		key := strings.ToLower(name)
+		if other, ok := seen[key]; ok {
+			return nil, fmt.Errorf("function file %s collides with %s", name, other)
+		}
+		seen[key] = name
+		names[i] = name
+	}
+	return names, nil
+}
+
+func disasmSplitFile(inputPath, outputDir string) error {
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", inputPath, err)
+	}
+
+	script, err := bin.DisassembleWithOptions(data, disasmOptions())
+	if err != nil {
+		return fmt.Errorf("failed to disassemble %s: %w", inputPath, err)
+	}
+	warnHeader(script, inputPath)
+
+	if err := applyLabelMap(script, disasmLabels); err != nil {
+		return err
+	}
+	if err := applyCommentMap(script, disasmComments); err != nil {
+		return err
+	}
+
+	funcs := script.SplitFunctions(bin.RenderOptions{FunctionHeaders: disasmFunctions, Offsets: disasmOffsets})
+	names, err := splitFileNames(funcs)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	files := []string{splitHeaderName}
+	if err := os.WriteFile(filepath.Join(outputDir, splitHeaderName), []byte(script.HeaderText()), 0644); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	for i, fn := range funcs {
+		if err := os.WriteFile(filepath.Join(outputDir, names[i]), []byte(fn.Text), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", names[i], err)
+		}
+		files = append(files, names[i])
+	}
+
+	index := "// Split disassembly - files are assembled in this order\n" + strings.Join(files, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(outputDir, splitIndexName), []byte(index), 0644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	fmt.Printf("Disassembled %s -> %s (%d functions, %d instructions)\n",
+		filepath.Base(inputPath), outputDir, len(funcs), len(script.Instructions))
+
+	return nil
+}
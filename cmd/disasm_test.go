@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"agetools/pkg/bin"
+)
+
+func TestSplitFileNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		funcs   []string
+		want    []string
+		wantErr bool
+	}{
+		{"plain", []string{"_start", "setup"}, []string{"fn__start.txt", "fn_setup.txt"}, false},
+		{"header and index labels", []string{"header", "index"}, []string{"fn_header.txt", "fn_index.txt"}, false},
+		{"case collision", []string{"Setup", "setup"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			funcs := make([]bin.FunctionText, len(tt.funcs))
+			for i, name := range tt.funcs {
+				funcs[i].Name = name
+			}
+			got, err := splitFileNames(funcs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitFileNames = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitFileNames = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,18 +1,36 @@
 package cmd
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"agetools/pkg/alf"
 	"github.com/spf13/cobra"
 )
 
 var (
-	extractFilter  string
-	extractOutput  string
-	extractVerbose bool
+	extractFilter   string
+	extractGlob     string
+	extractRegex    string
+	extractOutput   string
+	extractVerbose  bool
+	extractIndex    string
+	extractSince    string
+	extractTolerant bool
+	extractTextExt  []string
+	extractNewlines string
+	extractStore    string
+	extractManifest string
+	extractBase     string
+	extractJobs     int
+	extractDupes    string
+	extractSkipMiss bool
+	extractVerify   string
 )
 
 var extractCmd = &cobra.Command{
@@ -39,8 +57,35 @@ Examples:
   # Extract only .bin script files
   agetools extract SYS5INI.BIN -f .bin
 
+  # Extract scripts SC0000.BIN to SC0099.BIN
+  agetools extract SYS5INI.BIN -g 'SC00??.BIN'
+
+  # Extract files matching a regular expression
+  agetools extract SYS5INI.BIN -e '(?i)^ev[0-9]+\.agf$'
+
   # Extract to a custom output directory
-  agetools extract SYS5INI.BIN -o extracted/`,
+  agetools extract SYS5INI.BIN -o extracted/
+
+  # Extract only files a patch changed relative to an older index
+  agetools extract SYS5INI.BIN --since old/SYS5INI.BIN -o patched/
+
+  # Convert text files to LF line endings for editing
+  agetools extract SYS5INI.BIN --text-ext .txt,.ini --newlines lf
+
+  # Store file bodies by SHA-256 to share them between game versions
+  agetools extract SYS5INI.BIN --store store/ --store-manifest v1.json
+
+  # Extract what is available when some DATA*.ALF files are absent
+  agetools extract SYS5INI.BIN --skip-missing
+
+  # Check every file against a manifest saved with 'agetools verify -o'
+  agetools extract SYS5INI.BIN --verify v1.json
+
+  # Extract a patch whose append index numbers archives after the base's
+  agetools extract APPEND01.AAI --base-index SYS5INI.BIN
+
+  # Write a CSV listing of all entries without extracting
+  agetools extract SYS5INI.BIN --index-csv files.csv`,
 	Args: cobra.ExactArgs(1),
 	RunE: runExtract,
 }
@@ -50,10 +95,39 @@ func init() {
 
 	extractCmd.Flags().StringVarP(&extractFilter, "filter", "f", "",
 		"filter extracted files (case-insensitive substring match)")
+	extractCmd.Flags().StringVarP(&extractGlob, "glob", "g", "",
+		"only extract files matching this glob, e.g. '*.BIN' (case-insensitive)")
+	extractCmd.Flags().StringVarP(&extractRegex, "regex", "e", "",
+		"only extract files matching this regular expression")
+	extractCmd.MarkFlagsMutuallyExclusive("filter", "glob", "regex")
 	extractCmd.Flags().StringVarP(&extractOutput, "output", "o", "data",
 		"output directory for extracted files")
 	extractCmd.Flags().BoolVarP(&extractVerbose, "verbose", "v", false,
 		"print verbose progress information")
+	extractCmd.Flags().StringVar(&extractIndex, "index-csv", "",
+		"write a CSV listing of all entries to this path instead of extracting")
+	extractCmd.Flags().StringVar(&extractSince, "since", "",
+		"only extract files whose entry differs from this older index file")
+	extractCmd.Flags().BoolVar(&extractTolerant, "tolerant", false,
+		"accept indexes whose duplicated size fields disagree, using the one matching the data")
+	extractCmd.Flags().StringSliceVar(&extractTextExt, "text-ext", nil,
+		"extensions of text files whose line endings --newlines rewrites (e.g. .txt,.ini)")
+	extractCmd.Flags().StringVar(&extractNewlines, "newlines", "keep",
+		"line endings for --text-ext files: keep, lf, or crlf")
+	extractCmd.Flags().StringVar(&extractBase, "base-index", "",
+		"base index an append index layers onto (default: SYS5INI.BIN/SYS4INI.BIN beside it)")
+	extractCmd.Flags().StringVar(&extractDupes, "on-duplicate", "overwrite",
+		"what to do with repeated filenames in an archive: overwrite, skip, or rename")
+	extractCmd.Flags().BoolVar(&extractSkipMiss, "skip-missing", false,
+		"extract the files of the archives present even if others are missing")
+	extractCmd.Flags().StringVar(&extractVerify, "verify", "",
+		"check each file's CRC32 against this manifest (written by 'verify -o') and fail on mismatch")
+	extractCmd.Flags().IntVarP(&extractJobs, "jobs", "j", 0,
+		"maximum archives extracted in parallel (0 = GOMAXPROCS)")
+	extractCmd.Flags().StringVar(&extractStore, "store", "",
+		"write file bodies to this directory named by SHA-256 instead of mirroring folders")
+	extractCmd.Flags().StringVar(&extractManifest, "store-manifest", "store-manifest.json",
+		"with --store, write the filename to hash map to this JSON file")
 }
 
 func runExtract(cmd *cobra.Command, args []string) error {
@@ -70,10 +144,46 @@ func runExtract(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("archive not found: %s", archivePath)
 	}
 
+	if extractIndex != "" {
+		return writeIndexCSV(absPath, extractIndex)
+	}
+
+	newlines, err := alf.ParseNewlineMode(extractNewlines)
+	if err != nil {
+		return err
+	}
+	if newlines != alf.NewlinesKeep && len(extractTextExt) == 0 {
+		return fmt.Errorf("--newlines requires --text-ext to select the text files")
+	}
+
+	onDuplicate, err := alf.ParseDuplicatePolicy(extractDupes)
+	if err != nil {
+		return err
+	}
+
+	var hashes map[string]uint32
+	if extractVerify != "" {
+		if hashes, err = readVerifyHashes(extractVerify); err != nil {
+			return err
+		}
+	}
+
 	opts := alf.ExtractOptions{
-		Filter:    extractFilter,
-		OutputDir: extractOutput,
-		Verbose:   extractVerbose,
+		Filter:            extractFilter,
+		Glob:              extractGlob,
+		Regex:             extractRegex,
+		OutputDir:         extractOutput,
+		Verbose:           extractVerbose,
+		Since:             extractSince,
+		Tolerant:          extractTolerant,
+		BaseIndex:         extractBase,
+		TextExtensions:    extractTextExt,
+		NormalizeNewlines: newlines,
+		OnDuplicate:       onDuplicate,
+		Concurrency:       extractJobs,
+
+		SkipMissingArchives: extractSkipMiss,
+		VerifyHashes:        hashes,
 	}
 
 	extractor, err := alf.NewExtractor(absPath, opts)
@@ -87,16 +197,32 @@ func runExtract(cmd *cobra.Command, args []string) error {
 	}
 
 	archive := extractor.GetArchive()
+	if archive.SizeMismatch != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", archive.SizeMismatch)
+	}
 	fmt.Printf("Extracting: %s\n", archive.Header.Title)
 	fmt.Printf("Format: %s\n", archive.Header.Signature)
 	fmt.Printf("Archives: %d\n", len(archive.Sources))
+	if archive.BaseArchives > 0 {
+		fmt.Printf("Base archives: %d\n", archive.BaseArchives)
+	}
 	fmt.Printf("Files: %d\n", len(archive.Entries))
+	if missing := archive.MissingSources(); len(missing) > 0 {
+		fmt.Printf("Missing archives (skipped): %s\n", strings.Join(missing, ", "))
+	}
 
 	if extractFilter != "" {
 		fmt.Printf("Filter: %s\n", extractFilter)
 	}
+	if extractSince != "" {
+		fmt.Printf("Changed since: %s\n", extractSince)
+	}
 	fmt.Println()
 
+	if extractStore != "" {
+		return extractToStore(extractor, extractStore, extractManifest)
+	}
+
 	if err := extractor.Extract(); err != nil {
 		return fmt.Errorf("extraction failed: %w", err)
 	}
@@ -104,3 +230,95 @@ func runExtract(cmd *cobra.Command, args []string) error {
 	fmt.Println("Extraction complete!")
 	return nil
 }
+
+// readVerifyHashes reads a manifest written by the verify command into a
+// filename to CRC32 map
+func readVerifyHashes(path string) (map[string]uint32, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var digests []alf.FileDigest
+	if err := json.Unmarshal(data, &digests); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	hashes := make(map[string]uint32, len(digests))
+	for _, d := range digests {
+		hashes[d.Filename] = d.CRC32
+	}
+	return hashes, nil
+}
+
+// extractToStore extracts into a content-addressed store and writes the
+// filename to hash manifest
+func extractToStore(extractor *alf.Extractor, storeDir, manifestPath string) error {
+	hashes, err := extractor.ExtractToStore(storeDir)
+	if err != nil {
+		return fmt.Errorf("extraction failed: %w", err)
+	}
+
+	data, err := json.MarshalIndent(hashes, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", manifestPath, err)
+	}
+
+	unique := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		unique[h] = true
+	}
+	fmt.Printf("Stored %d files (%d unique) in %s, manifest %s\n", len(hashes), len(unique), storeDir, manifestPath)
+	return nil
+}
+
+// writeIndexCSV writes every entry of the index to a CSV file without extracting
+func writeIndexCSV(indexPath, csvPath string) error {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	_, archiveNames, entries, err := alf.ParseIndexMetadata(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	f, err := os.Create(csvPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", csvPath, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"filename", "archive", "file_index", "offset", "length"}); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		archiveName := "UNKNOWN"
+		if int(entry.ArchiveIndex) < len(archiveNames) {
+			archiveName = archiveNames[entry.ArchiveIndex]
+		}
+		record := []string{
+			entry.Filename,
+			archiveName,
+			strconv.FormatUint(uint64(entry.FileIndex), 10),
+			strconv.FormatUint(uint64(entry.Offset), 10),
+			strconv.FormatUint(uint64(entry.Length), 10),
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", csvPath, err)
+	}
+
+	fmt.Printf("Wrote %d entries to %s\n", len(entries), csvPath)
+	return nil
+}
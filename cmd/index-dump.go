@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agetools/pkg/alf"
+	"github.com/spf13/cobra"
+)
+
+var (
+	indexDumpRaw    bool
+	indexDumpHex    bool
+	indexDumpOutput string
+)
+
+var indexDumpCmd = &cobra.Command{
+	Use:   "index-dump <index-file>",
+	Short: "Dump the decompressed metadata of an archive index",
+	Long: `Decompress the metadata of an archive index file for debugging.
+
+With --raw, the decompressed metadata blob is written as-is to the output
+file. With --hex, the metadata is printed as an annotated hex listing of
+the archive count, archive names, file count and file entries.
+
+Examples:
+  # Write the raw metadata blob
+  agetools index-dump SYS5INI.BIN --raw -o meta.bin
+
+  # Print an annotated hex listing
+  agetools index-dump SYS5INI.BIN --hex`,
+	Args: cobra.ExactArgs(1),
+	RunE: runIndexDump,
+}
+
+func init() {
+	rootCmd.AddCommand(indexDumpCmd)
+
+	indexDumpCmd.Flags().BoolVar(&indexDumpRaw, "raw", false,
+		"write the raw decompressed metadata to the output file")
+	indexDumpCmd.Flags().BoolVar(&indexDumpHex, "hex", false,
+		"print an annotated hex listing of the metadata")
+	indexDumpCmd.Flags().StringVarP(&indexDumpOutput, "output", "o", "",
+		"output path for --raw (default: <index>.meta.bin)")
+}
+
+func runIndexDump(cmd *cobra.Command, args []string) error {
+	indexPath := args[0]
+
+	if !indexDumpRaw && !indexDumpHex {
+		return fmt.Errorf("either --raw or --hex is required")
+	}
+
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	header, metadata, err := alf.DecompressMetadata(data)
+	if err != nil {
+		return fmt.Errorf("failed to decompress metadata: %w", err)
+	}
+
+	fmt.Printf("File: %s\n", filepath.Base(indexPath))
+	fmt.Printf("Format: S%d (%s)\n", header.Version, header.Signature)
+	fmt.Printf("Metadata: %d bytes\n", len(metadata))
+
+	if indexDumpRaw {
+		output := indexDumpOutput
+		if output == "" {
+			output = strings.TrimSuffix(indexPath, filepath.Ext(indexPath)) + ".meta.bin"
+		}
+		if err := os.WriteFile(output, metadata, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", output, err)
+		}
+		fmt.Printf("Raw metadata written to: %s\n", output)
+	}
+
+	if indexDumpHex {
+		fmt.Println()
+		dumpMetadataHex(metadata, header.Version)
+	}
+
+	return nil
+}
+
+// dumpMetadataHex prints the metadata with each structural field annotated
+func dumpMetadataHex(metadata []byte, version alf.FormatVersion) {
+	arcEntrySize := alf.S5ArchiveEntrySize
+	fileEntrySize := alf.S5FileEntrySize
+	nameSize := 0x80
+	if version == alf.FormatS4 {
+		arcEntrySize = alf.S4ArchiveEntrySize
+		fileEntrySize = alf.S4FileEntrySize
+		nameSize = 0x40
+	}
+
+	decodeName := func(b []byte) string {
+		if version == alf.FormatS4 {
+			if i := strings.IndexByte(string(b), 0); i >= 0 {
+				return string(b[:i])
+			}
+			return string(b)
+		}
+		return alf.DecodeUTF16LE(b)
+	}
+
+	pos := 0
+	if pos+4 > len(metadata) {
+		fmt.Println("  (truncated before archive count)")
+		return
+	}
+	arcCount := binary.LittleEndian.Uint32(metadata[pos:])
+	printHexField(metadata, pos, 4, fmt.Sprintf("archive count = %d", arcCount))
+	pos += 4
+
+	for i := uint32(0); i < arcCount; i++ {
+		if pos+arcEntrySize > len(metadata) {
+			fmt.Printf("  (truncated at archive %d)\n", i)
+			return
+		}
+		name := decodeName(metadata[pos : pos+arcEntrySize])
+		printHexField(metadata, pos, arcEntrySize, fmt.Sprintf("archive[%d] = %q", i, name))
+		pos += arcEntrySize
+	}
+
+	if pos+4 > len(metadata) {
+		fmt.Println("  (truncated before file count)")
+		return
+	}
+	fileCount := binary.LittleEndian.Uint32(metadata[pos:])
+	printHexField(metadata, pos, 4, fmt.Sprintf("file count = %d", fileCount))
+	pos += 4
+
+	for i := uint32(0); i < fileCount; i++ {
+		if pos+fileEntrySize > len(metadata) {
+			fmt.Printf("  (truncated at entry %d)\n", i)
+			return
+		}
+		name := decodeName(metadata[pos : pos+nameSize])
+		fields := metadata[pos+nameSize:]
+		printHexField(metadata, pos+nameSize, 16, fmt.Sprintf("entry[%d] %q archive=%d index=%d offset=0x%X length=%d",
+			i, name,
+			binary.LittleEndian.Uint32(fields[0:]),
+			binary.LittleEndian.Uint32(fields[4:]),
+			binary.LittleEndian.Uint32(fields[8:]),
+			binary.LittleEndian.Uint32(fields[12:])))
+		pos += fileEntrySize
+	}
+
+	if pos < len(metadata) {
+		printHexField(metadata, pos, len(metadata)-pos, fmt.Sprintf("trailing data (%d bytes)", len(metadata)-pos))
+	}
+}
+
+// printHexField prints up to 16 bytes of a field followed by its annotation
+func printHexField(data []byte, offset, length int, annotation string) {
+	shown := length
+	if shown > 16 {
+		shown = 16
+	}
+
+	var hex strings.Builder
+	for i := 0; i < shown; i++ {
+		fmt.Fprintf(&hex, "%02X ", data[offset+i])
+	}
+	if shown < length {
+		hex.WriteString("..")
+	}
+
+	fmt.Printf("%08X  %-50s ; %s\n", offset, hex.String(), annotation)
+}
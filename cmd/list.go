@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+
+	"agetools/pkg/alf"
+	"github.com/spf13/cobra"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list <index-file>",
+	Short: "List every file in an archive index",
+	Long: `List every file entry of an archive index (SYS5INI.BIN, SYS4INI.BIN or
+APPENDxx.AAI) with its archive number and size.
+
+Only the index is read, so the DATA*.ALF files do not need to be present.
+
+Examples:
+  # List all files
+  agetools list SYS5INI.BIN
+
+  # Find a script
+  agetools list SYS5INI.BIN | grep SC0000`,
+	Args: cobra.ExactArgs(1),
+	RunE: runList,
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	header, entries, err := alf.ListEntries(args[0])
+	if err != nil {
+		return err
+	}
+
+	var total uint64
+	for _, entry := range entries {
+		fmt.Printf("%10d  [%d] %s\n", entry.Length, entry.ArchiveIndex, entry.Filename)
+		total += uint64(entry.Length)
+	}
+	fmt.Printf("%d files, %d bytes (%s)\n", len(entries), total, header.Signature)
+
+	return nil
+}
@@ -12,6 +12,11 @@ import (
 var (
 	packOutput  string
 	packVerbose bool
+	packJobs    int
+
+	packAlignEntries int
+	packAlignArchive int
+	packCompact      bool
 )
 
 var packCmd = &cobra.Command{
@@ -19,8 +24,10 @@ var packCmd = &cobra.Command{
 	Short: "Pack files into ALF archives",
 	Long: `Pack modified files back into Eushully AGE engine archives.
 
-This command takes an original archive index file (SYS5INI.BIN) and a directory
-containing modified files, and creates new archive files with the modifications.
+This command takes an original archive index file (SYS5INI.BIN, SYS4INI.BIN
+or an APPENDxx.AAI) and a directory containing modified files, and creates new
+archive files with the modifications. If no entry changed, the original index
+is copied unchanged.
 
 The input directory structure should match the extraction output:
   input_dir/
@@ -31,14 +38,22 @@ The input directory structure should match the extraction output:
       ...
 
 Files that exist in the input directory will be used; missing files will be
-copied from the original archives.
+copied from the original archives. Files with no entry in the original index
+are added to their directory's archive after the original files.
 
 Examples:
   # Repack with modifications from data/ directory
   agetools pack SYS5INI.BIN data/ -o output/
 
   # Repack with verbose output
-  agetools pack SYS5INI.BIN modified/ -o repacked/ -v`,
+  agetools pack SYS5INI.BIN modified/ -o repacked/ -v
+
+  # Align each file to 0x800 sectors, or reproduce the original's alignment
+  agetools pack SYS5INI.BIN data/ --align-entries 0x800
+  agetools pack SYS5INI.BIN data/ --align-entries -1
+
+  # Drop bytes left unreferenced by earlier in-place replacements
+  agetools pack SYS5INI.BIN data/ --compact -v`,
 	Args: cobra.ExactArgs(2),
 	RunE: runPack,
 }
@@ -50,6 +65,15 @@ func init() {
 		"output directory for repacked archives")
 	packCmd.Flags().BoolVarP(&packVerbose, "verbose", "v", false,
 		"print verbose progress information")
+	packCmd.Flags().IntVarP(&packJobs, "jobs", "j", 0,
+		"maximum archives written in parallel (0 = number of CPUs)")
+	packCmd.Flags().IntVar(&packAlignEntries, "align-entries", 0,
+		"pad each file to start on this boundary (0 = none, -1 = match original)")
+	packCmd.Flags().IntVar(&packAlignArchive, "align-archive", 0,
+		"pad each archive's size to a multiple of this boundary (0 = none)")
+	packCmd.Flags().BoolVar(&packCompact, "compact", false,
+		"store shared file data once and drop trailing padding, reporting bytes saved with -v")
+	packCmd.MarkFlagsMutuallyExclusive("compact", "align-archive")
 }
 
 func runPack(cmd *cobra.Command, args []string) error {
@@ -93,6 +117,11 @@ func runPack(cmd *cobra.Command, args []string) error {
 		OutputDir:   absOutput,
 		Verbose:     packVerbose,
 		OriginalBIN: absOriginal,
+		Concurrency: packJobs,
+
+		AlignEntries: packAlignEntries,
+		AlignArchive: packAlignArchive,
+		Compact:      packCompact,
 	}
 
 	packer, err := alf.NewPacker(absInput, opts)
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"agetools/pkg/alf"
+	"github.com/spf13/cobra"
+)
+
+var (
+	removeOutput  string
+	removeVerbose bool
+)
+
+var removeCmd = &cobra.Command{
+	Use:   "remove <index-file> <filename>...",
+	Short: "Remove files from an archive index",
+	Long: `Remove file entries from an archive index.
+
+The DATA*.ALF files are left unchanged: the removed files' data stays in
+them but is no longer referenced. Filenames are matched case-insensitively
+and every entry with a matching name is removed. Without -o, the index is
+modified in place.
+
+Examples:
+  # Remove two files from the index in place
+  agetools remove SYS5INI.BIN SC0000.BIN SC0001.BIN
+
+  # Write the slimmed index elsewhere
+  agetools remove SYS5INI.BIN SC0000.BIN -o mod/SYS5INI.BIN -v`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runRemove,
+}
+
+func init() {
+	rootCmd.AddCommand(removeCmd)
+
+	removeCmd.Flags().StringVarP(&removeOutput, "output", "o", "",
+		"output path for the modified index (default: modify in place)")
+	removeCmd.Flags().BoolVarP(&removeVerbose, "verbose", "v", false,
+		"print verbose progress information")
+}
+
+func runRemove(cmd *cobra.Command, args []string) error {
+	if removeOutput != "" {
+		if err := os.MkdirAll(filepath.Dir(removeOutput), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	opts := alf.RemoveOptions{
+		OutputPath: removeOutput,
+		Verbose:    removeVerbose,
+	}
+	if err := alf.RemoveEntries(args[0], args[1:], opts); err != nil {
+		return fmt.Errorf("failed to remove files: %w", err)
+	}
+
+	fmt.Printf("Removed %d files\n", len(args)-1)
+	return nil
+}
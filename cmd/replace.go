@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"agetools/pkg/alf"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replaceOutput  string
+	replaceVerbose bool
+)
+
+var replaceCmd = &cobra.Command{
+	Use:   "replace <index-file> <file>...",
+	Short: "Replace files inside the existing archives",
+	Long: `Replace the contents of files in their existing DATA*.ALF archives.
+
+Each file replaces the archive entry with the same base name. Only the
+archives holding a replaced file are rewritten, with later files moved as
+needed, and the index is updated to match. Without -o, the index and
+archives are modified in place; -o must name a file in another directory,
+since the original index still references the archives next to it.
+
+Examples:
+  # Replace a script in place
+  agetools replace SYS5INI.BIN SC0000.BIN
+
+  # Write the modified index and archives to another directory
+  agetools replace SYS5INI.BIN SC0000.BIN SC0001.BIN -o patched/SYS5INI.BIN -v`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runReplace,
+}
+
+func init() {
+	rootCmd.AddCommand(replaceCmd)
+
+	replaceCmd.Flags().StringVarP(&replaceOutput, "output", "o", "",
+		"output path for the modified index (default: modify in place)")
+	replaceCmd.Flags().BoolVarP(&replaceVerbose, "verbose", "v", false,
+		"print verbose progress information")
+}
+
+func runReplace(cmd *cobra.Command, args []string) error {
+	indexPath := args[0]
+
+	replacements := make(map[string][]byte, len(args)-1)
+	for _, path := range args[1:] {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		replacements[filepath.Base(path)] = data
+	}
+
+	if replaceOutput != "" {
+		if err := os.MkdirAll(filepath.Dir(replaceOutput), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	opts := alf.ReplaceOptions{
+		OutputPath: replaceOutput,
+		Verbose:    replaceVerbose,
+	}
+	if err := alf.ReplaceFiles(indexPath, replacements, opts); err != nil {
+		return fmt.Errorf("failed to replace files: %w", err)
+	}
+
+	fmt.Printf("Replaced %d files\n", len(replacements))
+	return nil
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"agetools/pkg/alf"
+	"github.com/spf13/cobra"
+)
+
+var (
+	roundtripWorkdir string
+	roundtripVerbose bool
+)
+
+var roundtripCmd = &cobra.Command{
+	Use:   "roundtrip <archive>",
+	Short: "Check that extract + repack reproduces the original archives",
+	Long: `Extract an archive, repack it without modifications, and compare every
+produced .alf file and the index against the originals byte for byte.
+
+Any difference points at a bug in extraction or packing (ordering, offsets,
+padding or metadata encoding). The command exits non-zero on a mismatch.
+
+Examples:
+  agetools roundtrip SYS5INI.BIN
+  agetools roundtrip SYS5INI.BIN --workdir tmp/    # Keep the intermediate files`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRoundtrip,
+}
+
+func init() {
+	rootCmd.AddCommand(roundtripCmd)
+
+	roundtripCmd.Flags().StringVar(&roundtripWorkdir, "workdir", "",
+		"directory for extracted and repacked files (default: temporary, removed afterwards)")
+	roundtripCmd.Flags().BoolVarP(&roundtripVerbose, "verbose", "v", false,
+		"print verbose progress information")
+}
+
+func runRoundtrip(cmd *cobra.Command, args []string) error {
+	absPath, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	workdir := roundtripWorkdir
+	if workdir == "" {
+		workdir, err = os.MkdirTemp("", "agetools-roundtrip-")
+		if err != nil {
+			return fmt.Errorf("failed to create work directory: %w", err)
+		}
+		defer os.RemoveAll(workdir)
+	}
+	extractDir := filepath.Join(workdir, "extracted")
+	repackDir := filepath.Join(workdir, "repacked")
+
+	// Extract
+	extractor, err := alf.NewExtractor(absPath, alf.ExtractOptions{
+		OutputDir: extractDir,
+		Verbose:   roundtripVerbose,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create extractor: %w", err)
+	}
+	if err := extractor.Open(absPath); err != nil {
+		extractor.Close()
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	archive := extractor.GetArchive()
+	err = extractor.Extract()
+	extractor.Close()
+	if err != nil {
+		return fmt.Errorf("extraction failed: %w", err)
+	}
+	fmt.Printf("Extracted %d files to %s\n", len(archive.Entries), extractDir)
+
+	// Repack
+	if err := os.MkdirAll(repackDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	packer, err := alf.NewPacker(extractDir, alf.PackOptions{
+		OutputDir:   repackDir,
+		Verbose:     roundtripVerbose,
+		OriginalBIN: absPath,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create packer: %w", err)
+	}
+	defer packer.Close()
+	if err := packer.LoadOriginal(absPath); err != nil {
+		return fmt.Errorf("failed to load original archive: %w", err)
+	}
+	if err := packer.Pack(); err != nil {
+		return fmt.Errorf("packing failed: %w", err)
+	}
+	fmt.Printf("Repacked to %s\n\n", repackDir)
+
+	// Compare every archive and the index
+	names := []string{filepath.Base(absPath)}
+	for _, src := range archive.Sources {
+		names = append(names, src.Name)
+	}
+
+	mismatches := 0
+	for _, name := range names {
+		original := filepath.Join(filepath.Dir(absPath), name)
+		repacked := filepath.Join(repackDir, name)
+
+		diff, err := describeFileDifference(original, repacked)
+		if err != nil {
+			return err
+		}
+		if diff == "" {
+			fmt.Printf("  OK        %s\n", name)
+			continue
+		}
+		fmt.Printf("  MISMATCH  %s: %s\n", name, diff)
+		mismatches++
+	}
+
+	if mismatches > 0 {
+		return fmt.Errorf("%d of %d files differ after round trip", mismatches, len(names))
+	}
+
+	fmt.Printf("\nRound trip OK: %d files identical\n", len(names))
+	return nil
+}
+
+// describeFileDifference compares two files in chunks and returns an empty
+// string if they are identical, or a description of the first difference.
+func describeFileDifference(pathA, pathB string) (string, error) {
+	fa, err := os.Open(pathA)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", pathA, err)
+	}
+	defer fa.Close()
+
+	fb, err := os.Open(pathB)
+	if os.IsNotExist(err) {
+		return "not produced by the packer", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", pathB, err)
+	}
+	defer fb.Close()
+
+	ra := bufio.NewReaderSize(fa, 1<<20)
+	rb := bufio.NewReaderSize(fb, 1<<20)
+	bufA := make([]byte, 64<<10)
+	bufB := make([]byte, 64<<10)
+
+	var offset int64
+	for {
+		na, errA := io.ReadFull(ra, bufA)
+		nb, errB := io.ReadFull(rb, bufB)
+
+		n := min(na, nb)
+		if !bytes.Equal(bufA[:n], bufB[:n]) {
+			for i := 0; i < n; i++ {
+				if bufA[i] != bufB[i] {
+					return fmt.Sprintf("first difference at offset 0x%X", offset+int64(i)), nil
+				}
+			}
+		}
+		offset += int64(n)
+
+		if na != nb {
+			sizeA, sizeB := fileSize(fa), fileSize(fb)
+			return fmt.Sprintf("size differs (original %d bytes, repacked %d bytes)", sizeA, sizeB), nil
+		}
+
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !endA {
+			return "", fmt.Errorf("failed to read %s: %w", pathA, errA)
+		}
+		if errB != nil && !endB {
+			return "", fmt.Errorf("failed to read %s: %w", pathB, errB)
+		}
+		if endA || endB {
+			return "", nil
+		}
+	}
+}
+
+// fileSize returns the size of an open file, or -1 if it cannot be determined.
+func fileSize(f *os.File) int64 {
+	info, err := f.Stat()
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
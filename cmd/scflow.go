@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strconv"
 
 	"agetools/pkg/scflow"
@@ -22,13 +23,21 @@ Examples:
   agetools scflow SC0000.txt analyze                    # Analyze file
   agetools scflow SC0000.txt char-id 841               # Find character at line 841
   agetools scflow SC0000.txt trace-var "local-int:0" 100  # Trace variable at line 100
-  agetools scflow SC0000.txt calls "label_000C0248"    # Find all calls to function`,
+  agetools scflow SC0000.txt calls "label_000C0248"    # Find all calls to function
+  agetools scflow SC0000.txt coverage                  # Count instructions reachable from entry points
+  agetools scflow SC0000.txt coverage label_00000044   # Count instructions reachable from given labels
+  agetools scflow SC0000.txt strings                   # List strings reachable from entry points
+  agetools scflow SC0000.txt dead-strings              # List strings only reachable from dead code
+  agetools scflow SC0000.txt annotate -o annotated.txt # Add speaker comments above dialogue`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runSCFlow,
 }
 
+var scflowOutput string
+
 func init() {
 	rootCmd.AddCommand(scflowCmd)
+	scflowCmd.Flags().StringVarP(&scflowOutput, "output", "o", "", "Output file (annotate)")
 }
 
 func runSCFlow(cmd *cobra.Command, args []string) error {
@@ -84,6 +93,18 @@ func runSCFlow(cmd *cobra.Command, args []string) error {
 		}
 		return handleAssigns(analyzer, args[2])
 
+	case "coverage":
+		return handleCoverage(analyzer, args[2:])
+
+	case "strings":
+		return handleStrings(analyzer, args[2:], false)
+
+	case "dead-strings":
+		return handleStrings(analyzer, args[2:], true)
+
+	case "annotate":
+		return handleAnnotate(analyzer)
+
 	default:
 		return fmt.Errorf("unknown subcommand: %s", subcommand)
 	}
@@ -154,3 +175,77 @@ func handleAssigns(analyzer *scflow.Analyzer, varName string) error {
 
 	return nil
 }
+
+// handleCoverage handles instruction reachability queries
+func handleCoverage(analyzer *scflow.Analyzer, entries []string) error {
+	if len(entries) == 0 {
+		if err := analyzer.ResolveEntryPoints(); err != nil {
+			return fmt.Errorf("failed to resolve entry points: %w", err)
+		}
+	}
+	cfg := analyzer.BuildCFG()
+	if len(entries) == 0 {
+		entries = cfg.EntryPoints()
+	}
+
+	reachable, total := cfg.ReachableInstructionCount(entries)
+
+	fmt.Printf("\nCoverage from %v:\n", entries)
+	fmt.Printf("  Reachable: %d / %d instructions\n", reachable, total)
+	if total > 0 {
+		fmt.Printf("  Unreachable: %d (%.1f%%)\n", total-reachable, float64(total-reachable)*100/float64(total))
+	}
+
+	return nil
+}
+
+// handleStrings handles live/dead string listing
+func handleStrings(analyzer *scflow.Analyzer, entries []string, dead bool) error {
+	if len(entries) == 0 {
+		if err := analyzer.ResolveEntryPoints(); err != nil {
+			return fmt.Errorf("failed to resolve entry points: %w", err)
+		}
+	}
+	live, unreachable := analyzer.LiveStrings(entries)
+
+	refs, kind := live, "Live"
+	if dead {
+		refs, kind = unreachable, "Dead"
+	}
+
+	fmt.Printf("\n%s strings (%d of %d):\n", kind, len(refs), len(live)+len(unreachable))
+	for _, ref := range refs {
+		fmt.Printf("  Line %5d: \"%s\"\n", ref.LineNum, ref.Value)
+	}
+
+	return nil
+}
+
+// handleAnnotate writes the file with speaker comments above dialogue lines
+func handleAnnotate(analyzer *scflow.Analyzer) error {
+	if scflowOutput == "" {
+		return fmt.Errorf("annotate requires -o <output.txt>")
+	}
+
+	speakers := analyzer.ResolveAllSpeakers()
+
+	narrator := 0
+	for _, charID := range speakers {
+		if charID == 0 {
+			narrator++
+		}
+	}
+
+	out, err := os.Create(scflowOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer out.Close()
+
+	if err := analyzer.WriteAnnotated(out, speakers); err != nil {
+		return fmt.Errorf("failed to write annotated file: %w", err)
+	}
+
+	fmt.Printf("\nAnnotated %d dialogue lines (%d narrator) -> %s\n", len(speakers), narrator, scflowOutput)
+	return nil
+}
@@ -82,12 +82,13 @@ func runSys5iniAddArchive(cmd *cobra.Command, args []string) error {
 		Verbose:     addArchiveVerbose,
 	}
 
-	if err := alf.AddArchive(absSys5ini, opts); err != nil {
+	result, err := alf.AddArchive(absSys5ini, opts)
+	if err != nil {
 		return fmt.Errorf("failed to add archive: %w", err)
 	}
 
 	fmt.Printf("\nSuccess! Modified SYS5INI.BIN written to: %s\n", absOutput)
-	fmt.Printf("New archive created: %s\n", filepath.Join(filepath.Dir(absOutput), archiveName))
+	fmt.Printf("New archive created: %s\n", result.NewArchivePath)
 
 	return nil
 }
@@ -10,9 +10,9 @@ import (
 )
 
 var sys5iniDumpCmd = &cobra.Command{
-	Use:   "sys5ini-dump <sys5ini.bin>",
-	Short: "Display SYS5INI.BIN archive structure",
-	Long: `Display the structure of SYS5INI.BIN archive index file.
+	Use:   "sys5ini-dump <index-file>",
+	Short: "Display SYS5INI.BIN or SYS4INI.BIN archive structure",
+	Long: `Display the structure of a SYS5INI.BIN or SYS4INI.BIN archive index file.
 
 Shows:
   - Archive format version and signature
@@ -23,6 +23,9 @@ Examples:
   # Display SYS5INI.BIN structure
   agetools sys5ini-dump SYS5INI.BIN
 
+  # Display an older game's S4 index
+  agetools sys5ini-dump SYS4INI.BIN
+
   # Display with detailed file list
   agetools sys5ini-dump ../../game/SYS5INI.BIN`,
 	Args: cobra.ExactArgs(1),
@@ -54,7 +57,11 @@ func runSys5iniDump(cmd *cobra.Command, args []string) error {
 	}
 
 	// Parse metadata without opening ALF files
-	header, archiveNames, entries, err := alf.ParseSYS5Metadata(data)
+	parse := alf.ParseSYS5Metadata
+	if version, err := alf.DetectFormat(data); err == nil && version == alf.FormatS4 {
+		parse = alf.ParseSYS4Metadata
+	}
+	header, archiveNames, entries, err := parse(data)
 	if err != nil {
 		return fmt.Errorf("failed to parse metadata: %w", err)
 	}
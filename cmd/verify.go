@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agetools/pkg/alf"
+	"github.com/spf13/cobra"
+)
+
+var verifyOutput string
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <old> [new]",
+	Short: "Compare the CRC32 manifests of two archive sets",
+	Long: `Compute a manifest of every file in an archive index, holding each
+file's archive, offset, length and CRC32, and compare two manifests.
+
+Each argument is either an index file (SYS5INI.BIN, SYS4INI.BIN or
+APPENDxx.AAI, with its .alf files beside it) or a manifest previously
+written with -o (a .json file). Files are listed as added (+), removed (-)
+or changed (M); filenames are compared case-insensitively and files that
+only moved within the archives are not reported.
+
+Examples:
+  # Save the manifest of an installation
+  agetools verify SYS5INI.BIN -o v1.json
+
+  # Show what a patch changed compared to the saved manifest
+  agetools verify v1.json patched/SYS5INI.BIN
+
+  # Compare two installations directly
+  agetools verify old/SYS5INI.BIN new/SYS5INI.BIN`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().StringVarP(&verifyOutput, "output", "o", "",
+		"write the manifest of a single index to this JSON file")
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	if len(args) == 1 {
+		if verifyOutput == "" {
+			return fmt.Errorf("a second manifest to compare with, or -o, is required")
+		}
+		digests, err := loadManifest(args[0])
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(digests, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(verifyOutput, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", verifyOutput, err)
+		}
+		fmt.Printf("Wrote %d digests to %s\n", len(digests), verifyOutput)
+		return nil
+	}
+	if verifyOutput != "" {
+		return fmt.Errorf("-o takes a single index")
+	}
+
+	older, err := loadManifest(args[0])
+	if err != nil {
+		return err
+	}
+	newer, err := loadManifest(args[1])
+	if err != nil {
+		return err
+	}
+
+	added, removed, changed := alf.CompareManifests(older, newer)
+	for _, d := range added {
+		fmt.Printf("+ %s\n", d.Filename)
+	}
+	for _, d := range removed {
+		fmt.Printf("- %s\n", d.Filename)
+	}
+	for _, d := range changed {
+		fmt.Printf("M %s\n", d.Filename)
+	}
+	fmt.Printf("%d added, %d removed, %d changed\n", len(added), len(removed), len(changed))
+
+	return nil
+}
+
+// loadManifest reads a JSON manifest, or computes one from an index file
+// and its archives
+func loadManifest(path string) ([]alf.FileDigest, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		var digests []alf.FileDigest
+		if err := json.Unmarshal(data, &digests); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		return digests, nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	extractor, err := alf.NewExtractor(absPath, alf.ExtractOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create extractor: %w", err)
+	}
+	defer extractor.Close()
+
+	if err := extractor.Open(absPath); err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	digests, err := extractor.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return digests, nil
+}
@@ -0,0 +1,187 @@
+package agf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ImageEncoder encodes a decoded AGF image into an output format.
+// Encoders for formats without a built-in implementation (e.g. WebP) can be
+// added with RegisterEncoder.
+type ImageEncoder interface {
+	Encode(w io.Writer, img image.Image) error
+}
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]ImageEncoder{
+		"bmp": bmpEncoder{},
+		"png": pngEncoder{},
+	}
+)
+
+// RegisterEncoder registers an encoder for the given format name (e.g. "webp").
+// Registering an existing name replaces the previous encoder.
+func RegisterEncoder(format string, enc ImageEncoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[strings.ToLower(format)] = enc
+}
+
+// LookupEncoder returns the encoder registered for the given format name.
+func LookupEncoder(format string) (ImageEncoder, bool) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	enc, ok := encoders[strings.ToLower(format)]
+	return enc, ok
+}
+
+// Image returns the unpacked pixel data as an image, with alpha preserved for 32-bit AGFs.
+func (r *UnpackResult) Image() (image.Image, error) {
+	width := int(r.InfoHeader.Width)
+	height := int(r.InfoHeader.Height)
+	bottomUp := true
+	if height < 0 {
+		height = -height
+		bottomUp = false
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	// BMP rows are stored bottom-up unless the height is negative
+	rowY := func(y int) int {
+		if bottomUp {
+			return height - y - 1
+		}
+		return y
+	}
+
+	if r.Header.Type == Type32Bit {
+		if len(r.DecodedData) < width*height*4 {
+			return nil, fmt.Errorf("decoded data too short: got %d bytes, expected %d",
+				len(r.DecodedData), width*height*4)
+		}
+		for y := 0; y < height; y++ {
+			src := y * width * 4
+			for x := 0; x < width; x++ {
+				p := r.DecodedData[src+x*4:]
+				img.SetNRGBA(x, rowY(y), color.NRGBA{R: p[2], G: p[1], B: p[0], A: p[3]})
+			}
+		}
+		return img, nil
+	}
+
+	bitCount := int(r.InfoHeader.BitCount)
+	stride := RowStride(width, r.InfoHeader.BitCount)
+	if expected := ExpectedPixelDataSize(width, height, r.InfoHeader.BitCount); len(r.PixelData) < expected {
+		return nil, fmt.Errorf("pixel data too short: got %d bytes, expected %d",
+			len(r.PixelData), expected)
+	}
+
+	for y := 0; y < height; y++ {
+		line := r.PixelData[y*stride:]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bitCount {
+			case 8:
+				idx := int(line[x])
+				if idx >= len(r.Palette) {
+					return nil, fmt.Errorf("palette index %d out of range", idx)
+				}
+				pal := r.Palette[idx]
+				c = color.NRGBA{R: pal.Red, G: pal.Green, B: pal.Blue, A: 0xFF}
+			case 24:
+				c = color.NRGBA{R: line[x*3+2], G: line[x*3+1], B: line[x*3], A: 0xFF}
+			default:
+				return nil, fmt.Errorf("unsupported bit depth: %d", bitCount)
+			}
+			img.SetNRGBA(x, rowY(y), c)
+		}
+	}
+
+	return img, nil
+}
+
+// WriteImage encodes the unpacked data with the encoder registered for format.
+func (r *UnpackResult) WriteImage(w io.Writer, format string) error {
+	enc, ok := LookupEncoder(format)
+	if !ok {
+		return fmt.Errorf("no encoder registered for format %q", format)
+	}
+
+	img, err := r.Image()
+	if err != nil {
+		return err
+	}
+
+	return enc.Encode(w, img)
+}
+
+// WriteImageFile encodes the unpacked data to a file with the encoder registered for format.
+func (r *UnpackResult) WriteImageFile(path, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create image file: %w", err)
+	}
+	defer f.Close()
+
+	return r.WriteImage(f, format)
+}
+
+// pngEncoder encodes images as PNG.
+type pngEncoder struct{}
+
+func (pngEncoder) Encode(w io.Writer, img image.Image) error {
+	return png.Encode(w, img)
+}
+
+// bmpEncoder encodes images as bottom-up 32-bit BMP.
+type bmpEncoder struct{}
+
+func (bmpEncoder) Encode(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	dataSize := width * height * 4
+
+	bmf := BitmapFileHeader{
+		Type:       0x4D42, // "BM"
+		Size:       uint32(14 + 40 + dataSize),
+		OffsetBits: 14 + 40,
+	}
+	bmi := BitmapInfoHeader{
+		Size:     40,
+		Width:    int32(width),
+		Height:   int32(height),
+		Planes:   1,
+		BitCount: 32,
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, &bmf); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, &bmi); err != nil {
+		return err
+	}
+
+	data := make([]byte, dataSize)
+	for y := 0; y < height; y++ {
+		line := data[(height-y-1)*width*4:]
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			line[x*4] = c.B
+			line[x*4+1] = c.G
+			line[x*4+2] = c.R
+			line[x*4+3] = c.A
+		}
+	}
+
+	_, err := w.Write(data)
+	return err
+}
@@ -6,23 +6,45 @@ import (
 	"io"
 	"math"
 	"os"
+
+	"agetools/pkg/lzss"
 )
 
 // PackOptions configures the packing process.
 type PackOptions struct {
-	Compress bool // Whether to LZSS compress sectors (not implemented yet)
+	Compress bool      // LZSS compress sectors that get smaller (default: store uncompressed)
+	Strict   bool      // Fail instead of approximating colors when a conversion is lossy
+	Palette  []RGBQuad // Replacement palette for 8-bit AGFs (see ImportPalette)
+	Tolerant bool      // Accept an original AGF with disagreeing sector length fields
 }
 
 // Pack repacks a BMP file into AGF format using the original AGF as reference.
 func Pack(bmpPath, agfPath, outputPath string, opts PackOptions) error {
 	// First, unpack the original AGF to get format information
-	original, err := UnpackFile(agfPath)
+	original, err := UnpackFileWithOptions(agfPath, UnpackOptions{Tolerant: opts.Tolerant})
 	if err != nil {
 		return fmt.Errorf("failed to read original AGF: %w", err)
 	}
 
+	return PackWithReferenceWithOptions(bmpPath, outputPath, original, opts)
+}
+
+// PackWithReference packs a BMP using pre-loaded original AGF data.
+func PackWithReference(bmpPath, outputPath string, original *UnpackResult) error {
+	return PackWithReferenceWithOptions(bmpPath, outputPath, original, PackOptions{})
+}
+
+// PackWithReferenceWithOptions is PackWithReference with the given options.
+// Tolerant has no effect, as the original AGF is already read; original is
+// not modified by a replacement Palette.
+func PackWithReferenceWithOptions(bmpPath, outputPath string, original *UnpackResult, opts PackOptions) error {
+	original, err := withPalette(original, opts.Palette)
+	if err != nil {
+		return err
+	}
+
 	// Read the BMP file
-	_, bmi, _, pixelData, err := ReadBMPFile(bmpPath)
+	_, bmi, palette, pixelData, err := ReadBMPFile(bmpPath)
 	if err != nil {
 		return fmt.Errorf("failed to read BMP: %w", err)
 	}
@@ -34,85 +56,30 @@ func Pack(bmpPath, agfPath, outputPath string, opts PackOptions) error {
 	}
 	defer f.Close()
 
-	// Write AGF header (copy from original)
-	if err := WriteHeader(f, original.Header); err != nil {
-		return fmt.Errorf("failed to write AGF header: %w", err)
-	}
-
-	// Prepare BMP header data for sector
-	var sectorPalette []RGBQuad
-	if original.InfoHeader.BitCount == 8 {
-		sectorPalette = original.Palette
-	}
-	bmpHeaderData := WriteBitmapHeaders(original.FileHeader, original.InfoHeader, sectorPalette)
-
-	// Write BMP header sector (uncompressed for now)
-	if err := writeSector(f, bmpHeaderData); err != nil {
-		return fmt.Errorf("failed to write BMP header sector: %w", err)
-	}
-
-	// Handle pixel data based on AGF type
-	if original.Header.Type == Type32Bit {
-		// For 32-bit, we need to separate RGB and Alpha
-		encodedData, alphaData := encodeColorMapWithAlpha(pixelData, bmi, original)
-
-		// Write pixel data sector
-		if err := writeSector(f, encodedData); err != nil {
-			return fmt.Errorf("failed to write pixel sector: %w", err)
-		}
-
-		// Write alpha header
-		if err := WriteAlphaHeader(f, original.AlphaHeader); err != nil {
-			return fmt.Errorf("failed to write alpha header: %w", err)
-		}
-
-		// Write alpha sector
-		if err := writeSector(f, alphaData); err != nil {
-			return fmt.Errorf("failed to write alpha sector: %w", err)
-		}
-	} else {
-		// For 24-bit, encode pixel data
-		var encodedData []byte
-		if bmi.BitCount == 8 && original.InfoHeader.BitCount == 8 {
-			// 8-bit to 8-bit: use as-is but may need palette matching
-			encodedData = pixelData
-		} else if bmi.BitCount == 8 {
-			// 8-bit input, need to expand to match original
-			encodedData = pixelData
-		} else {
-			// Direct copy for matching bit depths
-			encodedData = pixelData
-		}
-
-		// Write pixel data sector
-		if err := writeSector(f, encodedData); err != nil {
-			return fmt.Errorf("failed to write pixel sector: %w", err)
-		}
-	}
-
-	return nil
+	return packToWriter(f, pixelData, bmi, palette, original, opts)
 }
 
-// PackWithReference packs a BMP using pre-loaded original AGF data.
-func PackWithReference(bmpPath, outputPath string, original *UnpackResult) error {
-	// Read the BMP file
-	_, bmi, _, pixelData, err := ReadBMPFile(bmpPath)
-	if err != nil {
-		return fmt.Errorf("failed to read BMP: %w", err)
+// withPalette returns a copy of original using palette, an edited palette
+// for 8-bit AGFs that pixels are quantized against as well. An empty
+// palette leaves original as is.
+func withPalette(original *UnpackResult, palette []RGBQuad) (*UnpackResult, error) {
+	if len(palette) == 0 {
+		return original, nil
 	}
-
-	// Create output file
-	f, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+	if len(original.Palette) == 0 {
+		return nil, fmt.Errorf("cannot apply palette: original AGF is %d-bit", original.InfoHeader.BitCount)
+	}
+	if len(palette) != len(original.Palette) {
+		return nil, fmt.Errorf("palette has %d colors, original has %d", len(palette), len(original.Palette))
 	}
-	defer f.Close()
 
-	return packToWriter(f, pixelData, bmi, original)
+	swapped := *original
+	swapped.Palette = palette
+	return &swapped, nil
 }
 
 // packToWriter writes packed AGF data to a writer.
-func packToWriter(w io.Writer, pixelData []byte, bmi *BitmapInfoHeader, original *UnpackResult) error {
+func packToWriter(w io.Writer, pixelData []byte, bmi *BitmapInfoHeader, palette []RGBQuad, original *UnpackResult, opts PackOptions) error {
 	// Write AGF header (copy from original)
 	if err := WriteHeader(w, original.Header); err != nil {
 		return fmt.Errorf("failed to write AGF header: %w", err)
@@ -126,7 +93,7 @@ func packToWriter(w io.Writer, pixelData []byte, bmi *BitmapInfoHeader, original
 	bmpHeaderData := WriteBitmapHeaders(original.FileHeader, original.InfoHeader, sectorPalette)
 
 	// Write BMP header sector
-	if err := writeSector(w, bmpHeaderData); err != nil {
+	if err := writeSector(w, bmpHeaderData, opts.Compress); err != nil {
 		return fmt.Errorf("failed to write BMP header sector: %w", err)
 	}
 
@@ -134,7 +101,7 @@ func packToWriter(w io.Writer, pixelData []byte, bmi *BitmapInfoHeader, original
 	if original.Header.Type == Type32Bit {
 		encodedData, alphaData := encodeColorMapWithAlpha(pixelData, bmi, original)
 
-		if err := writeSector(w, encodedData); err != nil {
+		if err := writeSector(w, encodedData, opts.Compress); err != nil {
 			return fmt.Errorf("failed to write pixel sector: %w", err)
 		}
 
@@ -142,11 +109,16 @@ func packToWriter(w io.Writer, pixelData []byte, bmi *BitmapInfoHeader, original
 			return fmt.Errorf("failed to write alpha header: %w", err)
 		}
 
-		if err := writeSector(w, alphaData); err != nil {
+		if err := writeSector(w, alphaData, opts.Compress); err != nil {
 			return fmt.Errorf("failed to write alpha sector: %w", err)
 		}
 	} else {
-		if err := writeSector(w, pixelData); err != nil {
+		encodedData, err := convertBitDepth(pixelData, bmi, palette, original, opts.Strict)
+		if err != nil {
+			return err
+		}
+
+		if err := writeSector(w, encodedData, opts.Compress); err != nil {
 			return fmt.Errorf("failed to write pixel sector: %w", err)
 		}
 	}
@@ -156,42 +128,200 @@ func packToWriter(w io.Writer, pixelData []byte, bmi *BitmapInfoHeader, original
 
 // PackToBytes packs a BMP to AGF and returns the result as bytes.
 func PackToBytes(bmpPath string, original *UnpackResult) ([]byte, error) {
-	_, bmi, _, pixelData, err := ReadBMPFile(bmpPath)
+	return PackToBytesWithOptions(bmpPath, original, PackOptions{})
+}
+
+// PackToBytesWithOptions is PackToBytes with the given options, which apply
+// as for PackWithReferenceWithOptions.
+func PackToBytesWithOptions(bmpPath string, original *UnpackResult, opts PackOptions) ([]byte, error) {
+	original, err := withPalette(original, opts.Palette)
+	if err != nil {
+		return nil, err
+	}
+
+	_, bmi, palette, pixelData, err := ReadBMPFile(bmpPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read BMP: %w", err)
 	}
 
 	var buf bytes.Buffer
-	if err := packToWriter(&buf, pixelData, bmi, original); err != nil {
+	if err := packToWriter(&buf, pixelData, bmi, palette, original, opts); err != nil {
 		return nil, err
 	}
 
 	return buf.Bytes(), nil
 }
 
-// writeSector writes data as an uncompressed sector.
-func writeSector(w io.Writer, data []byte) error {
+// writeSector writes data as a sector, LZSS compressed if compress is set
+// and that makes it smaller. Readers tell compressed sectors apart by
+// Length differing from OriginalLength, so data that does not shrink is
+// stored as is.
+func writeSector(w io.Writer, data []byte, compress bool) error {
+	stored := data
+	if compress {
+		compressed, err := lzss.CompressVerified(data)
+		if err != nil {
+			return fmt.Errorf("failed to compress sector: %w", err)
+		}
+		if len(compressed) < len(data) {
+			stored = compressed
+		}
+	}
+
 	hdr := &SectorHeader{
 		OriginalLength:  uint32(len(data)),
 		OriginalLength2: uint32(len(data)),
-		Length:          uint32(len(data)),
+		Length:          uint32(len(stored)),
 	}
 
 	if err := WriteSectorHeader(w, hdr); err != nil {
 		return err
 	}
 
-	_, err := w.Write(data)
+	_, err := w.Write(stored)
 	return err
 }
 
+// convertBitDepth converts BMP pixel data to the bit depth of the original AGF.
+// 8-bit input is expanded to 24-bit using the BMP's own palette, and 24-bit input
+// is mapped onto the original's 8-bit palette. 8-bit input for an 8-bit AGF is
+// remapped by color through the BMP's palette (see remapPaletteIndices). When
+// strict is set, a pixel without an exact palette match is reported as an
+// error instead of approximated.
+func convertBitDepth(pixelData []byte, bmi *BitmapInfoHeader, palette []RGBQuad, original *UnpackResult, strict bool) ([]byte, error) {
+	srcBits := int(bmi.BitCount)
+	dstBits := int(original.InfoHeader.BitCount)
+
+	// 24-bit pixels, and 8-bit indices without a BMP palette to say
+	// otherwise, are already in the original's format
+	if srcBits == dstBits && (srcBits != 8 || len(palette) == 0) {
+		return pixelData, nil
+	}
+
+	width := int(original.InfoHeader.Width)
+	height := int(original.InfoHeader.Height)
+	if int(bmi.Width) != width || int(bmi.Height) != height {
+		return nil, fmt.Errorf("BMP dimensions %dx%d do not match original %dx%d",
+			bmi.Width, bmi.Height, width, height)
+	}
+
+	srcStride := RowStride(width, uint16(srcBits))
+	dstStride := RowStride(width, uint16(dstBits))
+	if expected := ExpectedPixelDataSize(width, height, uint16(srcBits)); len(pixelData) < expected {
+		return nil, fmt.Errorf("BMP pixel data too short: got %d bytes, expected %d",
+			len(pixelData), expected)
+	}
+
+	if srcBits == 8 && dstBits == 8 {
+		return remapPaletteIndices(pixelData, width, height, palette, original.Palette, strict)
+	}
+
+	encodedData := make([]byte, ExpectedPixelDataSize(width, height, uint16(dstBits)))
+
+	switch {
+	case srcBits == 8 && dstBits == 24:
+		// Expand palette indices to BGR triplets
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				palIndex := int(pixelData[y*srcStride+x])
+				if palIndex >= len(palette) {
+					return nil, fmt.Errorf("palette index %d out of range at (%d, %d)", palIndex, x, y)
+				}
+				c := palette[palIndex]
+				dst := y*dstStride + x*3
+				encodedData[dst] = c.Blue
+				encodedData[dst+1] = c.Green
+				encodedData[dst+2] = c.Red
+			}
+		}
+
+	case srcBits == 24 && dstBits == 8:
+		// Map BGR triplets onto the original palette
+		if len(original.Palette) == 0 {
+			return nil, fmt.Errorf("original 8-bit AGF has no palette")
+		}
+		cache := make(map[RGBQuad]int)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				src := y*srcStride + x*3
+				c := RGBQuad{
+					Blue:  pixelData[src],
+					Green: pixelData[src+1],
+					Red:   pixelData[src+2],
+				}
+				palIndex := findNearestPalette(c, original.Palette, cache)
+				if strict && !sameColor(original.Palette[palIndex], c) {
+					return nil, fmt.Errorf("color #%02X%02X%02X at (%d, %d) is not in the original palette",
+						c.Red, c.Green, c.Blue, x, y)
+				}
+				encodedData[y*dstStride+x] = byte(palIndex)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported bit depth conversion: %d-bit BMP to %d-bit AGF", srcBits, dstBits)
+	}
+
+	return encodedData, nil
+}
+
+// remapPaletteIndices maps the palette indices of 8-bit BMP pixels to the
+// indices of the same colors in the original AGF's palette, so a BMP whose
+// editor reordered or changed its palette still packs to the colors it
+// shows. An index whose BMP color is the original's color at that index is
+// kept, so an unedited image packs unchanged. Colors missing from the
+// original palette are approximated, or rejected when strict is set.
+func remapPaletteIndices(pixelData []byte, width, height int, bmpPalette, agfPalette []RGBQuad, strict bool) ([]byte, error) {
+	if len(agfPalette) == 0 {
+		return nil, fmt.Errorf("original 8-bit AGF has no palette")
+	}
+
+	var remap [256]int
+	for i := range remap {
+		remap[i] = -1
+	}
+	cache := make(map[RGBQuad]int)
+
+	stride := RowStride(width, 8)
+	encodedData := make([]byte, len(pixelData))
+	copy(encodedData, pixelData)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			palIndex := pixelData[y*stride+x]
+			if remap[palIndex] < 0 {
+				if int(palIndex) >= len(bmpPalette) {
+					return nil, fmt.Errorf("palette index %d out of range at (%d, %d)", palIndex, x, y)
+				}
+				c := bmpPalette[palIndex]
+				if int(palIndex) < len(agfPalette) && sameColor(agfPalette[palIndex], c) {
+					remap[palIndex] = int(palIndex)
+				} else {
+					remap[palIndex] = findNearestPalette(c, agfPalette, cache)
+					if strict && !sameColor(agfPalette[remap[palIndex]], c) {
+						return nil, fmt.Errorf("color #%02X%02X%02X at (%d, %d) is not in the original palette",
+							c.Red, c.Green, c.Blue, x, y)
+					}
+				}
+			}
+			encodedData[y*stride+x] = byte(remap[palIndex])
+		}
+	}
+
+	return encodedData, nil
+}
+
+// sameColor reports whether two palette entries have the same color,
+// ignoring the reserved byte.
+func sameColor(a, b RGBQuad) bool {
+	return a.Blue == b.Blue && a.Green == b.Green && a.Red == b.Red
+}
+
 // encodeColorMapWithAlpha separates RGBA pixel data into RGB and Alpha.
 func encodeColorMapWithAlpha(decodedData []byte, bmi *BitmapInfoHeader, original *UnpackResult) ([]byte, []byte) {
 	width := int(original.InfoHeader.Width)
 	height := int(original.InfoHeader.Height)
 
-	// RGB stride must be padded to 4 bytes
-	rgbStride := (width*int(original.InfoHeader.BitCount)/8 + 3) &^ 3
+	rgbStride := RowStride(width, original.InfoHeader.BitCount)
 
 	var alphaSize int
 	if original.InfoHeader.BitCount == 8 {
@@ -280,3 +410,81 @@ func findNearestPalette(input RGBQuad, palette []RGBQuad, cache map[RGBQuad]int)
 	cache[input] = minIdx
 	return minIdx
 }
+
+// CheckPaletteCompatible returns the colors used by the BMP that are not in the
+// original 8-bit AGF's palette, in order of first appearance. Packing
+// approximates these colors with the nearest palette entry, or fails on them
+// in strict mode. The colors of an 8-bit BMP are read through its own
+// palette, as packing remaps them; an 8-bit BMP without a palette packs its
+// indices unchanged and has no colors to report.
+// Returns nil when the original AGF is not paletted.
+func CheckPaletteCompatible(bmpPath, agfPath string) ([]RGBQuad, error) {
+	return CheckPaletteCompatibleWithOptions(bmpPath, agfPath, UnpackOptions{})
+}
+
+// CheckPaletteCompatibleWithOptions is CheckPaletteCompatible reading the
+// original AGF with the given options.
+func CheckPaletteCompatibleWithOptions(bmpPath, agfPath string, opts UnpackOptions) ([]RGBQuad, error) {
+	original, err := UnpackFileWithOptions(agfPath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original AGF: %w", err)
+	}
+
+	if original.InfoHeader.BitCount != 8 {
+		return nil, nil
+	}
+
+	_, bmi, palette, pixelData, err := ReadBMPFile(bmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read BMP: %w", err)
+	}
+	if bmi.BitCount == 8 && len(palette) == 0 {
+		return nil, nil
+	}
+
+	inPalette := make(map[RGBQuad]bool, len(original.Palette))
+	for _, c := range original.Palette {
+		inPalette[RGBQuad{Blue: c.Blue, Green: c.Green, Red: c.Red}] = true
+	}
+
+	width := int(bmi.Width)
+	height := int(bmi.Height)
+	if height < 0 {
+		height = -height
+	}
+	bitCount := int(bmi.BitCount)
+	stride := RowStride(width, bmi.BitCount)
+	if expected := ExpectedPixelDataSize(width, height, bmi.BitCount); len(pixelData) < expected {
+		return nil, fmt.Errorf("BMP pixel data too short: got %d bytes, expected %d",
+			len(pixelData), expected)
+	}
+
+	seen := make(map[RGBQuad]bool)
+	var missing []RGBQuad
+	for y := 0; y < height; y++ {
+		line := pixelData[y*stride:]
+		for x := 0; x < width; x++ {
+			var c RGBQuad
+			switch bitCount {
+			case 8:
+				idx := int(line[x])
+				if idx >= len(palette) {
+					return nil, fmt.Errorf("palette index %d out of range at (%d, %d)", idx, x, y)
+				}
+				c = RGBQuad{Blue: palette[idx].Blue, Green: palette[idx].Green, Red: palette[idx].Red}
+			case 24, 32:
+				bpp := bitCount / 8
+				c = RGBQuad{Blue: line[x*bpp], Green: line[x*bpp+1], Red: line[x*bpp+2]}
+			default:
+				return nil, fmt.Errorf("unsupported BMP bit depth: %d", bitCount)
+			}
+
+			if !inPalette[c] && !seen[c] {
+				seen[c] = true
+				missing = append(missing, c)
+			}
+		}
+	}
+
+	return missing, nil
+}
@@ -0,0 +1,596 @@
+package agf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testImage describes an AGF written by writeTestAGF.
+type testImage struct {
+	agfType  uint32
+	bitCount uint16
+	width    int
+	height   int
+	palette  []RGBQuad
+	pixels   []byte // BMP pixel rows, bottom-up and padded to RowStride
+	alpha    []byte // Alpha plane of a Type32Bit image
+
+	// ACIF dimensions of a Type32Bit image; zero means the image's
+	alphaWidth, alphaHeight int
+}
+
+var (
+	black = RGBQuad{}
+	red   = RGBQuad{Red: 0xFF}
+	green = RGBQuad{Green: 0xFF}
+	blue  = RGBQuad{Blue: 0xFF}
+	gray  = RGBQuad{Blue: 0x80, Green: 0x80, Red: 0x80}
+)
+
+// writeTestAGF writes img as an uncompressed AGF to path.
+func writeTestAGF(tb testing.TB, path string, img testImage) {
+	tb.Helper()
+
+	var buf bytes.Buffer
+	hdr := &Header{Signature: [4]byte{'A', 'C', 'G', 'F'}, Type: img.agfType}
+	if err := WriteHeader(&buf, hdr); err != nil {
+		tb.Fatal(err)
+	}
+
+	bmf := &BitmapFileHeader{Type: 0x4D42, OffsetBits: uint32(54 + len(img.palette)*4)}
+	bmi := &BitmapInfoHeader{Size: 40, Width: int32(img.width), Height: int32(img.height), Planes: 1, BitCount: img.bitCount}
+	if err := writeSector(&buf, WriteBitmapHeaders(bmf, bmi, img.palette), false); err != nil {
+		tb.Fatal(err)
+	}
+	if err := writeSector(&buf, img.pixels, false); err != nil {
+		tb.Fatal(err)
+	}
+
+	if img.agfType == Type32Bit {
+		alphaHdr := &AlphaHeader{
+			Signature:      [4]byte{'A', 'C', 'I', 'F'},
+			OriginalLength: uint32(len(img.alpha)),
+			Width:          uint32(img.width),
+			Height:         uint32(img.height),
+		}
+		if img.alphaWidth != 0 {
+			alphaHdr.Width, alphaHdr.Height = uint32(img.alphaWidth), uint32(img.alphaHeight)
+		}
+		if err := WriteAlphaHeader(&buf, alphaHdr); err != nil {
+			tb.Fatal(err)
+		}
+		if err := writeSector(&buf, img.alpha, false); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// writeTestBMP writes an 8- or 24-bit BMP to path. An 8-bit BMP with a nil
+// palette has none, as unpacking writes for AGFs that store none.
+func writeTestBMP(tb testing.TB, path string, bitCount uint16, width, height int, palette []RGBQuad, pixels []byte) {
+	tb.Helper()
+
+	var buf bytes.Buffer
+	bmi := &BitmapInfoHeader{Width: int32(width), Height: int32(height), BitCount: bitCount}
+	if err := writeBMP24Headers(&buf, &BitmapFileHeader{}, bmi, palette, len(pixels)); err != nil {
+		tb.Fatal(err)
+	}
+	buf.Write(pixels)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// rows pads each row of pixel bytes to the BMP row stride of width pixels.
+func rows(width int, bitCount uint16, data ...[]byte) []byte {
+	stride := RowStride(width, bitCount)
+	var out []byte
+	for _, row := range data {
+		out = append(out, row...)
+		out = append(out, make([]byte, stride-len(row))...)
+	}
+	return out
+}
+
+// bgr returns the BMP bytes of colors.
+func bgr(colors ...RGBQuad) []byte {
+	var out []byte
+	for _, c := range colors {
+		out = append(out, c.Blue, c.Green, c.Red)
+	}
+	return out
+}
+
+// packTest packs the BMP at bmpPath against the AGF at agfPath and
+// returns the repacked AGF unpacked.
+func packTest(t *testing.T, bmpPath, agfPath string, opts PackOptions) (*UnpackResult, error) {
+	t.Helper()
+
+	out := filepath.Join(t.TempDir(), "OUT.AGF")
+	if err := Pack(bmpPath, agfPath, out, opts); err != nil {
+		return nil, err
+	}
+	result, err := UnpackFile(out)
+	if err != nil {
+		t.Fatalf("unpacking repacked AGF: %v", err)
+	}
+	return result, nil
+}
+
+func TestPackConvertsBitDepth(t *testing.T) {
+	palette := []RGBQuad{black, red, green, blue}
+
+	tests := []struct {
+		name       string
+		agf        testImage
+		bmpBits    uint16
+		bmpPalette []RGBQuad
+		bmpPixels  []byte
+		strict     bool
+		want       []byte
+		wantErr    string
+	}{
+		{
+			name:       "8-bit BMP to 24-bit AGF",
+			agf:        testImage{bitCount: 24, pixels: rows(3, 24, bgr(black, black, black), bgr(black, black, black))},
+			bmpBits:    8,
+			bmpPalette: palette,
+			bmpPixels:  rows(3, 8, []byte{1, 2, 3}, []byte{0, 3, 1}),
+			want:       rows(3, 24, bgr(red, green, blue), bgr(black, blue, red)),
+		},
+		{
+			name:      "24-bit BMP to 8-bit AGF",
+			agf:       testImage{bitCount: 8, palette: palette, pixels: rows(3, 8, []byte{0, 0, 0}, []byte{0, 0, 0})},
+			bmpBits:   24,
+			bmpPixels: rows(3, 24, bgr(blue, green, red), bgr(red, black, blue)),
+			want:      rows(3, 8, []byte{3, 2, 1}, []byte{1, 0, 3}),
+		},
+		{
+			name:      "24-bit BMP to 8-bit AGF approximates new colors",
+			agf:       testImage{bitCount: 8, palette: palette, pixels: rows(3, 8, []byte{0, 0, 0}, []byte{0, 0, 0})},
+			bmpBits:   24,
+			bmpPixels: rows(3, 24, bgr(RGBQuad{Red: 0xF0, Green: 0x10}, green, red), bgr(red, black, blue)),
+			want:      rows(3, 8, []byte{1, 2, 1}, []byte{1, 0, 3}),
+		},
+		{
+			name:      "strict 24-bit BMP to 8-bit AGF rejects new colors",
+			agf:       testImage{bitCount: 8, palette: palette, pixels: rows(3, 8, []byte{0, 0, 0}, []byte{0, 0, 0})},
+			bmpBits:   24,
+			bmpPixels: rows(3, 24, bgr(red, green, red), bgr(red, gray, blue)),
+			strict:    true,
+			wantErr:   "color #808080 at (1, 1) is not in the original palette",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.agf.agfType = Type24Bit
+			tt.agf.width, tt.agf.height = 3, 2
+			agfPath := filepath.Join(dir, "ORIG.AGF")
+			writeTestAGF(t, agfPath, tt.agf)
+			bmpPath := filepath.Join(dir, "EDIT.BMP")
+			writeTestBMP(t, bmpPath, tt.bmpBits, 3, 2, tt.bmpPalette, tt.bmpPixels)
+
+			result, err := packTest(t, bmpPath, agfPath, PackOptions{Strict: tt.strict})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Pack = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(result.PixelData, tt.want) {
+				t.Errorf("pixel data = % X, want % X", result.PixelData, tt.want)
+			}
+		})
+	}
+}
+
+func TestPack8BitRemapsPalette(t *testing.T) {
+	// The original palette repeats red, as game palettes often repeat colors
+	palette := []RGBQuad{black, red, red, blue}
+	original := testImage{
+		agfType:  Type24Bit,
+		bitCount: 8,
+		width:    3,
+		height:   2,
+		palette:  palette,
+		pixels:   rows(3, 8, []byte{2, 1, 3}, []byte{0, 2, 2}),
+	}
+
+	tests := []struct {
+		name       string
+		bmpPalette []RGBQuad
+		bmpPixels  []byte
+		strict     bool
+		want       []byte
+		wantErr    string
+	}{
+		{
+			name:       "unedited image keeps indices",
+			bmpPalette: palette,
+			bmpPixels:  original.pixels,
+			want:       original.pixels,
+		},
+		{
+			name:       "reordered palette",
+			bmpPalette: []RGBQuad{blue, black, red, red},
+			bmpPixels:  rows(3, 8, []byte{2, 3, 0}, []byte{1, 2, 2}),
+			want:       rows(3, 8, []byte{2, 1, 3}, []byte{0, 2, 2}),
+		},
+		{
+			name:       "no BMP palette passes indices through",
+			bmpPalette: nil,
+			bmpPixels:  rows(3, 8, []byte{3, 3, 1}, []byte{0, 0, 2}),
+			want:       rows(3, 8, []byte{3, 3, 1}, []byte{0, 0, 2}),
+		},
+		{
+			name:       "new color is approximated",
+			bmpPalette: []RGBQuad{black, {Blue: 0xF0}, red, blue},
+			bmpPixels:  rows(3, 8, []byte{1, 2, 0}, []byte{0, 0, 0}),
+			want:       rows(3, 8, []byte{3, 2, 0}, []byte{0, 0, 0}),
+		},
+		{
+			name:       "strict rejects new color",
+			bmpPalette: []RGBQuad{black, gray, red, blue},
+			bmpPixels:  rows(3, 8, []byte{0, 2, 0}, []byte{0, 1, 0}),
+			strict:     true,
+			wantErr:    "color #808080 at (1, 1) is not in the original palette",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			agfPath := filepath.Join(dir, "ORIG.AGF")
+			writeTestAGF(t, agfPath, original)
+			bmpPath := filepath.Join(dir, "EDIT.BMP")
+			writeTestBMP(t, bmpPath, 8, 3, 2, tt.bmpPalette, tt.bmpPixels)
+
+			result, err := packTest(t, bmpPath, agfPath, PackOptions{Strict: tt.strict})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Pack = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(result.PixelData, tt.want) {
+				t.Errorf("pixel data = % X, want % X", result.PixelData, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackWithReferenceOptions(t *testing.T) {
+	palette := []RGBQuad{black, red, green, blue}
+	width, height := 32, 16
+	var indices, colors [][]byte
+	for y := 0; y < height; y++ {
+		row, col := make([]byte, width), []RGBQuad{}
+		for x := range row {
+			row[x] = 1
+			col = append(col, red)
+		}
+		indices = append(indices, row)
+		colors = append(colors, bgr(col...))
+	}
+	colors[0] = append(bgr(gray), colors[0][3:]...)
+
+	dir := t.TempDir()
+	agfPath := filepath.Join(dir, "ORIG.AGF")
+	writeTestAGF(t, agfPath, testImage{
+		agfType:  Type24Bit,
+		bitCount: 8,
+		width:    width,
+		height:   height,
+		palette:  palette,
+		pixels:   rows(width, 8, indices...),
+	})
+	bmpPath := filepath.Join(dir, "EDIT.BMP")
+	writeTestBMP(t, bmpPath, 24, width, height, nil, rows(width, 24, colors...))
+
+	original, err := UnpackFile(agfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := PackToBytes(bmpPath, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    PackOptions
+		wantErr string
+	}{
+		{"defaults", PackOptions{}, ""},
+		{"compress", PackOptions{Compress: true}, ""},
+		{"strict", PackOptions{Strict: true}, "color #808080 at (0, 0) is not in the original palette"},
+		{"palette", PackOptions{Palette: []RGBQuad{black, red, green, gray}, Strict: true}, ""},
+		{"palette size", PackOptions{Palette: []RGBQuad{black, red, green}}, "palette has 3 colors, original has 4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Packing against a pre-loaded original honors the options as
+			// Pack does, and leaves the original as it was
+			fromPack := filepath.Join(t.TempDir(), "PACK.AGF")
+			packErr := Pack(bmpPath, agfPath, fromPack, tt.opts)
+			fromRef := filepath.Join(t.TempDir(), "REF.AGF")
+			refErr := PackWithReferenceWithOptions(bmpPath, fromRef, original, tt.opts)
+			got, bytesErr := PackToBytesWithOptions(bmpPath, original, tt.opts)
+
+			for _, err := range []error{packErr, refErr, bytesErr} {
+				if tt.wantErr == "" && err != nil {
+					t.Fatal(err)
+				}
+				if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+					t.Fatalf("packing = %v, want error containing %q", err, tt.wantErr)
+				}
+			}
+			if !slices.Equal(original.Palette, palette) {
+				t.Errorf("original palette changed to %v", original.Palette)
+			}
+			if tt.wantErr != "" {
+				return
+			}
+
+			want, err := os.ReadFile(fromPack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ref, err := os.ReadFile(fromRef)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(ref, want) || !bytes.Equal(got, want) {
+				t.Error("packing against the pre-loaded original differs from Pack")
+			}
+			if tt.opts.Compress != (len(got) < len(plain)) {
+				t.Errorf("%d bytes, %d without options", len(got), len(plain))
+			}
+
+			result, err := Unpack(bytes.NewReader(got))
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantPalette := palette
+			if tt.opts.Palette != nil {
+				wantPalette = tt.opts.Palette
+			}
+			if !slices.Equal(result.Palette, wantPalette) {
+				t.Errorf("palette = %v, want %v", result.Palette, wantPalette)
+			}
+		})
+	}
+}
+
+func TestCheckPaletteCompatible(t *testing.T) {
+	palette := []RGBQuad{black, red, green, blue}
+	original := testImage{
+		agfType:  Type24Bit,
+		bitCount: 8,
+		width:    2,
+		height:   2,
+		palette:  palette,
+		pixels:   rows(2, 8, []byte{0, 1}, []byte{2, 3}),
+	}
+
+	tests := []struct {
+		name    string
+		bits    uint16
+		palette []RGBQuad
+		pixels  []byte
+		want    []RGBQuad
+	}{
+		{"reordered 8-bit palette", 8, []RGBQuad{blue, green, red, black}, rows(2, 8, []byte{0, 1}, []byte{2, 3}), nil},
+		{"new 8-bit color", 8, []RGBQuad{blue, gray, red, gray}, rows(2, 8, []byte{0, 1}, []byte{3, 2}), []RGBQuad{gray}},
+		{"unused new color", 8, []RGBQuad{blue, gray}, rows(2, 8, []byte{0, 0}, []byte{0, 0}), nil},
+		{"8-bit without palette", 8, nil, rows(2, 8, []byte{0, 1}, []byte{2, 3}), nil},
+		{"24-bit colors", 24, nil, rows(2, 24, bgr(red, gray), bgr(gray, blue)), []RGBQuad{gray}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			agfPath := filepath.Join(dir, "ORIG.AGF")
+			writeTestAGF(t, agfPath, original)
+			bmpPath := filepath.Join(dir, "EDIT.BMP")
+			writeTestBMP(t, bmpPath, tt.bits, 2, 2, tt.palette, tt.pixels)
+
+			missing, err := CheckPaletteCompatible(bmpPath, agfPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(missing) != len(tt.want) {
+				t.Fatalf("missing = %v, want %v", missing, tt.want)
+			}
+			for i := range missing {
+				if !sameColor(missing[i], tt.want[i]) {
+					t.Errorf("missing[%d] = %v, want %v", i, missing[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := CheckPaletteCompatible(filepath.Join(t.TempDir(), "NONE.BMP"), filepath.Join(t.TempDir(), "NONE.AGF")); err == nil {
+		t.Error("CheckPaletteCompatible succeeded without an original AGF")
+	}
+}
+
+// testImage32 returns a 32-bit AGF image with 24-bit colors and alpha
+// in flat blocks, so its sectors compress.
+func testImage32(width, height int) testImage {
+	img := testImage{agfType: Type32Bit, bitCount: 24, width: width, height: height}
+	stride := RowStride(width, 24)
+	img.pixels = make([]byte, stride*height)
+	img.alpha = make([]byte, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.pixels[y*stride+x*3] = byte(x / 8 * 40)
+			img.pixels[y*stride+x*3+1] = byte(y / 8 * 40)
+			img.pixels[y*stride+x*3+2] = 0x55
+			img.alpha[y*width+x] = byte(x / 16 * 0x50)
+		}
+	}
+	return img
+}
+
+func TestPackCompressRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	agfPath := filepath.Join(dir, "ORIG.AGF")
+	writeTestAGF(t, agfPath, testImage32(61, 37))
+
+	original, err := UnpackFile(agfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bmpPath := filepath.Join(dir, "ORIG.BMP")
+	if err := original.WriteBMPFile(bmpPath); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(bmpPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packed := filepath.Join(dir, "PACKED.AGF")
+	if err := Pack(bmpPath, agfPath, packed, PackOptions{Compress: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every sector of this image shrinks, so each must be stored compressed
+	data, err := os.ReadFile(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(data)
+	if _, err := ReadHeader(r); err != nil {
+		t.Fatal(err)
+	}
+	for _, sector := range []string{"BMP header", "pixel", "alpha"} {
+		if sector == "alpha" {
+			if _, err := ReadAlphaHeader(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		hdr, err := ReadSectorHeader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hdr.IsCompressed() {
+			t.Errorf("%s sector stored uncompressed (%d bytes)", sector, hdr.Length)
+		}
+		r.Seek(int64(hdr.Length), 1)
+	}
+
+	repacked, err := UnpackFile(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if err := repacked.WriteBMP(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Error("compressed AGF decodes to a different BMP")
+	}
+}
+
+func TestWriteSectorStoresIncompressibleData(t *testing.T) {
+	// Bytes from a full-period LCG have no repeats for LZSS to use
+	noise := make([]byte, 4096)
+	x := uint32(1)
+	for i := range noise {
+		x = x*1664525 + 1013904223
+		noise[i] = byte(x >> 24)
+	}
+
+	tests := []struct {
+		name           string
+		data           []byte
+		compress       bool
+		wantCompressed bool
+	}{
+		{"compressible", bytes.Repeat([]byte("abcd"), 1024), true, true},
+		{"incompressible", noise, true, false},
+		{"compression off", bytes.Repeat([]byte("abcd"), 1024), false, false},
+		{"empty", nil, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeSector(&buf, tt.data, tt.compress); err != nil {
+				t.Fatal(err)
+			}
+
+			var hdr SectorHeader
+			if err := binary.Read(bytes.NewReader(buf.Bytes()), binary.LittleEndian, &hdr); err != nil {
+				t.Fatal(err)
+			}
+			if hdr.OriginalLength != uint32(len(tt.data)) || hdr.OriginalLength2 != uint32(len(tt.data)) {
+				t.Errorf("original lengths = %d, %d, want %d", hdr.OriginalLength, hdr.OriginalLength2, len(tt.data))
+			}
+			if hdr.IsCompressed() != tt.wantCompressed {
+				t.Errorf("compressed = %v, want %v", hdr.IsCompressed(), tt.wantCompressed)
+			}
+
+			data, err := readSector(bytes.NewReader(buf.Bytes()), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, tt.data) {
+				t.Error("sector reads back different data")
+			}
+		})
+	}
+}
+
+func TestUnpackRejectsMismatchedAlphaHeader(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		wantErr       string
+	}{
+		{"matching", 0, 0, ""},
+		{"wider", 9, 6, "ACIF dimensions 9x6 do not match BMP dimensions 8x6"},
+		{"shorter", 8, 5, "ACIF dimensions 8x5 do not match BMP dimensions 8x6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := testImage32(8, 6)
+			img.alphaWidth, img.alphaHeight = tt.width, tt.height
+			path := filepath.Join(t.TempDir(), "ALPHA.AGF")
+			writeTestAGF(t, path, img)
+
+			_, err := UnpackFile(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("UnpackFile = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package agf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PaletteFormat selects an external palette file format.
+type PaletteFormat int
+
+const (
+	PaletteACT  PaletteFormat = iota // Adobe Color Table (.act)
+	PaletteJASC                      // JASC-PAL text palette (.pal)
+)
+
+// actSize is the size of the color data in an .act file (256 RGB triples).
+// An optional 4-byte trailer holds the color count and transparent index.
+const actSize = 256 * 3
+
+// PaletteFormatFromPath picks the palette format from a file extension.
+func PaletteFormatFromPath(path string) (PaletteFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".act":
+		return PaletteACT, nil
+	case ".pal":
+		return PaletteJASC, nil
+	default:
+		return 0, fmt.Errorf("unknown palette format: %s (expected .act or .pal)", path)
+	}
+}
+
+// ExportPalette writes the palette of an 8-bit AGF to a palette file.
+func ExportPalette(agfPath, outPath string, format PaletteFormat) error {
+	result, err := UnpackFile(agfPath)
+	if err != nil {
+		return err
+	}
+	if len(result.Palette) == 0 {
+		return fmt.Errorf("%s has no palette (%d-bit image)", agfPath, result.InfoHeader.BitCount)
+	}
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create palette file: %w", err)
+	}
+	defer f.Close()
+
+	if err := WritePalette(f, result.Palette, format); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// ImportPalette reads a palette file, choosing the format from its extension.
+func ImportPalette(path string) ([]RGBQuad, error) {
+	format, err := PaletteFormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read palette: %w", err)
+	}
+
+	return ReadPalette(bytes.NewReader(data), format)
+}
+
+// WritePalette serializes a palette in the given format.
+func WritePalette(w io.Writer, palette []RGBQuad, format PaletteFormat) error {
+	if len(palette) > 256 {
+		return fmt.Errorf("palette has %d colors, at most 256 are supported", len(palette))
+	}
+
+	switch format {
+	case PaletteACT:
+		buf := make([]byte, actSize, actSize+4)
+		for i, c := range palette {
+			buf[i*3] = c.Red
+			buf[i*3+1] = c.Green
+			buf[i*3+2] = c.Blue
+		}
+		// Record the real color count when the palette is short
+		if len(palette) < 256 {
+			buf = binary.BigEndian.AppendUint16(buf, uint16(len(palette)))
+			buf = binary.BigEndian.AppendUint16(buf, 0xFFFF) // no transparent color
+		}
+		_, err := w.Write(buf)
+		return err
+
+	case PaletteJASC:
+		bw := bufio.NewWriter(w)
+		fmt.Fprintf(bw, "JASC-PAL\r\n0100\r\n%d\r\n", len(palette))
+		for _, c := range palette {
+			fmt.Fprintf(bw, "%d %d %d\r\n", c.Red, c.Green, c.Blue)
+		}
+		return bw.Flush()
+
+	default:
+		return fmt.Errorf("unsupported palette format: %d", format)
+	}
+}
+
+// ReadPalette parses a palette in the given format.
+func ReadPalette(r io.Reader, format PaletteFormat) ([]RGBQuad, error) {
+	switch format {
+	case PaletteACT:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != actSize && len(data) != actSize+4 {
+			return nil, fmt.Errorf("invalid .act size: %d bytes (expected %d or %d)", len(data), actSize, actSize+4)
+		}
+		count := 256
+		if len(data) == actSize+4 {
+			if n := int(binary.BigEndian.Uint16(data[actSize:])); n > 0 && n <= 256 {
+				count = n
+			}
+		}
+		palette := make([]RGBQuad, count)
+		for i := range palette {
+			palette[i] = RGBQuad{Red: data[i*3], Green: data[i*3+1], Blue: data[i*3+2]}
+		}
+		return palette, nil
+
+	case PaletteJASC:
+		scanner := bufio.NewScanner(r)
+		var lines []string
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		if len(lines) < 3 || lines[0] != "JASC-PAL" {
+			return nil, fmt.Errorf("invalid JASC palette: missing JASC-PAL header")
+		}
+		count, err := strconv.Atoi(lines[2])
+		if err != nil || count < 0 || count > 256 {
+			return nil, fmt.Errorf("invalid JASC palette color count: %q", lines[2])
+		}
+		if len(lines)-3 < count {
+			return nil, fmt.Errorf("JASC palette declares %d colors but has %d", count, len(lines)-3)
+		}
+		palette := make([]RGBQuad, count)
+		for i := range palette {
+			var red, green, blue int
+			if _, err := fmt.Sscanf(lines[3+i], "%d %d %d", &red, &green, &blue); err != nil {
+				return nil, fmt.Errorf("invalid JASC palette entry %d: %q", i, lines[3+i])
+			}
+			if red > 255 || green > 255 || blue > 255 || red < 0 || green < 0 || blue < 0 {
+				return nil, fmt.Errorf("JASC palette entry %d out of range: %q", i, lines[3+i])
+			}
+			palette[i] = RGBQuad{Red: byte(red), Green: byte(green), Blue: byte(blue)}
+		}
+		return palette, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported palette format: %d", format)
+	}
+}
@@ -0,0 +1,190 @@
+package agf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"agetools/pkg/lzss"
+)
+
+// StreamConvert reads an AGF image from r and writes it to w as a BMP. The
+// output is the same as Unpack followed by WriteBMP, but far less of the
+// image is held in memory, so r and w may be pipes.
+//
+// 24-bit images are decompressed and written a block at a time, keeping only
+// the LZSS window and a small copy buffer.
+//
+// 32-bit images cannot be fully streamed: the alpha plane follows the color
+// data in the file and is stored upside down relative to it, so no output
+// row is complete before the end of the input. StreamConvert keeps the color
+// sector in its stored (usually compressed) form and the alpha plane decoded
+// at one byte per pixel, then decompresses the color data one row at a time
+// while writing. This replaces the full RGBA buffer of Unpack, which is four
+// bytes per pixel on top of the decoded color and alpha data.
+//
+// Sectors whose duplicated length fields disagree are rejected; use
+// UnpackWithOptions with Tolerant for such files.
+func StreamConvert(r io.Reader, w io.Writer) error {
+	hdr, err := ReadHeader(r)
+	if err != nil {
+		return err
+	}
+
+	if hdr.Type != Type24Bit && hdr.Type != Type32Bit {
+		return fmt.Errorf("%w: %d (possibly MPEG)", ErrUnsupportedType, hdr.Type)
+	}
+
+	// The BMP header sector is small enough to read whole
+	bmpHeaderData, err := readSector(r, false)
+	if err != nil {
+		return fmt.Errorf("failed to read BMP header sector: %w", err)
+	}
+
+	bmf, bmi, palette, err := ReadBitmapHeaders(bmpHeaderData)
+	if err != nil {
+		return fmt.Errorf("failed to parse BMP headers: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	if hdr.Type == Type32Bit {
+		err = streamBMP32(r, bw, bmi, palette)
+	} else {
+		err = streamBMP24(r, bw, bmf, bmi, palette)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// streamBMP24 copies the pixel sector to w as it is decompressed.
+func streamBMP24(r io.Reader, w io.Writer, bmf *BitmapFileHeader, bmi *BitmapInfoHeader, palette []RGBQuad) error {
+	pixels, err := openSector(r)
+	if err != nil {
+		return fmt.Errorf("failed to read pixel data sector: %w", err)
+	}
+
+	if err := writeBMP24Headers(w, bmf, bmi, palette, pixels.size); err != nil {
+		return err
+	}
+
+	n, err := io.Copy(w, pixels)
+	if err != nil {
+		return fmt.Errorf("failed to read pixel data sector: %w", err)
+	}
+	if n != int64(pixels.size) {
+		return fmt.Errorf("failed to read pixel data sector: decoded %d bytes, expected %d", n, pixels.size)
+	}
+	return nil
+}
+
+// streamBMP32 buffers the stored pixel sector and the alpha plane, then
+// writes the RGBA rows one at a time.
+func streamBMP32(r io.Reader, w io.Writer, bmi *BitmapInfoHeader, palette []RGBQuad) error {
+	storedPixels, err := readStoredSector(r)
+	if err != nil {
+		return fmt.Errorf("failed to read pixel data sector: %w", err)
+	}
+
+	alphaHdr, err := ReadAlphaHeader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read alpha header: %w", err)
+	}
+
+	alphaData, err := readSector(r, false)
+	if err != nil {
+		return fmt.Errorf("failed to read alpha sector: %w", err)
+	}
+
+	// The alpha plane is indexed with the BMP dimensions, so they must agree
+	if int64(alphaHdr.Width) != int64(bmi.Width) || int64(alphaHdr.Height) != int64(bmi.Height) {
+		return fmt.Errorf("ACIF dimensions %dx%d do not match BMP dimensions %dx%d",
+			alphaHdr.Width, alphaHdr.Height, bmi.Width, bmi.Height)
+	}
+	width := int(bmi.Width)
+	height := int(bmi.Height)
+	if alphaSize := width * height; len(alphaData) < alphaSize {
+		return fmt.Errorf("alpha data too short: got %d bytes, expected %d",
+			len(alphaData), alphaSize)
+	}
+
+	pixels, err := openSector(bytes.NewReader(storedPixels))
+	if err != nil {
+		return fmt.Errorf("failed to read pixel data sector: %w", err)
+	}
+	if expected := ExpectedPixelDataSize(width, height, bmi.BitCount); pixels.size < expected {
+		return fmt.Errorf("pixel data too short: got %d bytes, expected %d",
+			pixels.size, expected)
+	}
+
+	if err := writeBMP32Headers(w, bmi.Width, bmi.Height); err != nil {
+		return err
+	}
+
+	encodedRow := make([]byte, RowStride(width, bmi.BitCount))
+	decodedRow := make([]byte, width*4)
+	for y := 0; y < height; y++ {
+		if _, err := io.ReadFull(pixels, encodedRow); err != nil {
+			return fmt.Errorf("failed to decode pixel row %d: %w", y, err)
+		}
+
+		// Alpha Y is inverted
+		alphaLineIndex := (height - y - 1) * width
+		decodeRowWithAlpha(decodedRow, encodedRow, alphaData[alphaLineIndex:alphaLineIndex+width],
+			bmi.BitCount, palette)
+
+		if _, err := w.Write(decodedRow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sectorStream yields the decoded content of a sector as it is read.
+type sectorStream struct {
+	io.Reader
+	size int // decoded size (OriginalLength)
+}
+
+// openSector reads a sector header from r and returns a reader for the
+// decoded data that consumes no more than the sector's stored bytes from r.
+func openSector(r io.Reader) (*sectorStream, error) {
+	hdr, err := ReadSectorHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := hdr.Validate(); err != nil {
+		return nil, err
+	}
+
+	var decoded io.Reader = io.LimitReader(r, int64(hdr.Length))
+	if hdr.IsCompressed() {
+		decoded = lzss.NewReader(decoded)
+	}
+
+	return &sectorStream{
+		Reader: io.LimitReader(decoded, int64(hdr.OriginalLength)),
+		size:   int(hdr.OriginalLength),
+	}, nil
+}
+
+// readStoredSector reads a sector header and its stored data without
+// decompressing, returning both in file form for openSector.
+func readStoredSector(r io.Reader) ([]byte, error) {
+	hdr, err := ReadSectorHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(12 + int(hdr.Length))
+	if err := WriteSectorHeader(&buf, hdr); err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(&buf, r, int64(hdr.Length)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -3,6 +3,7 @@ package agf
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 )
@@ -13,6 +14,14 @@ const (
 	Type32Bit uint32 = 2 // 32-bit RGBA (with alpha channel)
 )
 
+// ErrUnsupportedType is returned for AGF files that are not 24/32-bit bitmaps,
+// such as the video (MPEG) variant.
+var ErrUnsupportedType = errors.New("unsupported AGF type")
+
+// ErrLengthMismatch is returned when the duplicated uncompressed size fields
+// of a sector header disagree.
+var ErrLengthMismatch = errors.New("sector header OriginalLength fields disagree")
+
 // Header is the main AGF file header (12 bytes).
 type Header struct {
 	Signature [4]byte // "ACGF"
@@ -32,6 +41,16 @@ func (h *SectorHeader) IsCompressed() bool {
 	return h.Length != h.OriginalLength
 }
 
+// Validate checks that the duplicated uncompressed size fields agree. Some
+// third-party tools write OriginalLength2 inconsistently.
+func (h *SectorHeader) Validate() error {
+	if h.OriginalLength != h.OriginalLength2 {
+		return fmt.Errorf("%w: OriginalLength=%d, OriginalLength2=%d, Length=%d",
+			ErrLengthMismatch, h.OriginalLength, h.OriginalLength2, h.Length)
+	}
+	return nil
+}
+
 // AlphaHeader is the alpha channel header for 32-bit images (24 bytes).
 type AlphaHeader struct {
 	Signature      [4]byte // "ACIF"
@@ -74,6 +93,21 @@ type RGBQuad struct {
 	Reserved byte
 }
 
+// RowStride returns the size in bytes of one BMP pixel row, padded to a
+// 4-byte boundary as the BMP format requires.
+func RowStride(width int, bitCount uint16) int {
+	return (width*int(bitCount) + 31) / 32 * 4
+}
+
+// ExpectedPixelDataSize returns the size in bytes of a BMP pixel array with the
+// given dimensions. A negative height (top-down bitmap) is treated as positive.
+func ExpectedPixelDataSize(width, height int, bitCount uint16) int {
+	if height < 0 {
+		height = -height
+	}
+	return height * RowStride(width, bitCount)
+}
+
 // ReadHeader reads an AGF header from a reader.
 // Note: Some AGF files don't have the "ACGF" signature, so we only validate the type.
 func ReadHeader(r io.Reader) (*Header, error) {
@@ -84,7 +118,7 @@ func ReadHeader(r io.Reader) (*Header, error) {
 	// Don't validate signature - some files have zeros instead of "ACGF"
 	// Only validate that type is valid
 	if hdr.Type != Type24Bit && hdr.Type != Type32Bit {
-		return nil, fmt.Errorf("unsupported AGF type: %d (possibly MPEG)", hdr.Type)
+		return nil, fmt.Errorf("%w: %d (possibly MPEG)", ErrUnsupportedType, hdr.Type)
 	}
 	return hdr, nil
 }
@@ -0,0 +1,54 @@
+package agf
+
+import "testing"
+
+func TestRowStride(t *testing.T) {
+	tests := []struct {
+		width    int
+		bitCount uint16
+		want     int
+	}{
+		{0, 24, 0},
+		{1, 8, 4},
+		{4, 8, 4},
+		{5, 8, 8},
+		{1, 24, 4},
+		{3, 24, 12},
+		{5, 24, 16},
+		{1, 32, 4},
+		{7, 32, 28},
+		{1, 1, 4},
+		{33, 1, 8},
+		{3, 4, 4},
+		{9, 4, 8},
+		{640, 24, 1920},
+		{641, 24, 1924},
+	}
+
+	for _, tt := range tests {
+		if got := RowStride(tt.width, tt.bitCount); got != tt.want {
+			t.Errorf("RowStride(%d, %d) = %d, want %d", tt.width, tt.bitCount, got, tt.want)
+		}
+	}
+}
+
+func TestExpectedPixelDataSize(t *testing.T) {
+	tests := []struct {
+		width, height int
+		bitCount      uint16
+		want          int
+	}{
+		{5, 3, 24, 48},
+		{5, -3, 24, 48}, // top-down
+		{5, 3, 8, 24},
+		{5, 3, 32, 60},
+		{5, 0, 24, 0},
+		{800, 600, 24, 1440000},
+	}
+
+	for _, tt := range tests {
+		if got := ExpectedPixelDataSize(tt.width, tt.height, tt.bitCount); got != tt.want {
+			t.Errorf("ExpectedPixelDataSize(%d, %d, %d) = %d, want %d", tt.width, tt.height, tt.bitCount, got, tt.want)
+		}
+	}
+}
@@ -22,8 +22,20 @@ type UnpackResult struct {
 	DecodedData []byte // Final RGBA pixel data for output
 }
 
+// UnpackOptions configures how AGF files are read.
+type UnpackOptions struct {
+	// Tolerant accepts sectors whose OriginalLength and OriginalLength2
+	// disagree, using whichever matches the decoded data (with a warning).
+	Tolerant bool
+}
+
 // Unpack reads an AGF file and extracts its contents.
 func Unpack(r io.Reader) (*UnpackResult, error) {
+	return UnpackWithOptions(r, UnpackOptions{})
+}
+
+// UnpackWithOptions reads an AGF file using the given options.
+func UnpackWithOptions(r io.Reader, opts UnpackOptions) (*UnpackResult, error) {
 	// Read AGF header
 	hdr, err := ReadHeader(r)
 	if err != nil {
@@ -31,11 +43,11 @@ func Unpack(r io.Reader) (*UnpackResult, error) {
 	}
 
 	if hdr.Type != Type24Bit && hdr.Type != Type32Bit {
-		return nil, fmt.Errorf("unsupported AGF type: %d (possibly MPEG)", hdr.Type)
+		return nil, fmt.Errorf("%w: %d (possibly MPEG)", ErrUnsupportedType, hdr.Type)
 	}
 
 	// Read BMP header sector
-	bmpHeaderData, err := readSector(r)
+	bmpHeaderData, err := readSector(r, opts.Tolerant)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read BMP header sector: %w", err)
 	}
@@ -46,7 +58,7 @@ func Unpack(r io.Reader) (*UnpackResult, error) {
 	}
 
 	// Read pixel data sector
-	pixelData, err := readSector(r)
+	pixelData, err := readSector(r, opts.Tolerant)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read pixel data sector: %w", err)
 	}
@@ -67,12 +79,26 @@ func Unpack(r io.Reader) (*UnpackResult, error) {
 		}
 		result.AlphaHeader = alphaHdr
 
-		alphaData, err := readSector(r)
+		alphaData, err := readSector(r, opts.Tolerant)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read alpha sector: %w", err)
 		}
 		result.AlphaData = alphaData
 
+		// The alpha plane is indexed with the BMP dimensions, so they must agree
+		if int64(alphaHdr.Width) != int64(bmi.Width) || int64(alphaHdr.Height) != int64(bmi.Height) {
+			return nil, fmt.Errorf("ACIF dimensions %dx%d do not match BMP dimensions %dx%d",
+				alphaHdr.Width, alphaHdr.Height, bmi.Width, bmi.Height)
+		}
+		if alphaSize := int(bmi.Width) * int(bmi.Height); len(alphaData) < alphaSize {
+			return nil, fmt.Errorf("alpha data too short: got %d bytes, expected %d",
+				len(alphaData), alphaSize)
+		}
+		if expected := ExpectedPixelDataSize(int(bmi.Width), int(bmi.Height), bmi.BitCount); len(pixelData) < expected {
+			return nil, fmt.Errorf("pixel data too short: got %d bytes, expected %d",
+				len(pixelData), expected)
+		}
+
 		// Decode color map with alpha
 		result.DecodedData = decodeColorMapWithAlpha(bmi, pixelData, palette, alphaData)
 	} else {
@@ -85,13 +111,18 @@ func Unpack(r io.Reader) (*UnpackResult, error) {
 
 // UnpackFile unpacks an AGF file from disk.
 func UnpackFile(path string) (*UnpackResult, error) {
+	return UnpackFileWithOptions(path, UnpackOptions{})
+}
+
+// UnpackFileWithOptions unpacks an AGF file from disk using the given options.
+func UnpackFileWithOptions(path string, opts UnpackOptions) (*UnpackResult, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open AGF file: %w", err)
 	}
 	defer f.Close()
 
-	return Unpack(f)
+	return UnpackWithOptions(f, opts)
 }
 
 // WriteBMP writes the unpacked data as a BMP file.
@@ -115,9 +146,17 @@ func (r *UnpackResult) WriteBMPFile(path string) error {
 
 // writeBMP32 writes a 32-bit RGBA BMP.
 func (r *UnpackResult) writeBMP32(w io.Writer) error {
-	width := r.InfoHeader.Width
-	height := r.InfoHeader.Height
+	if err := writeBMP32Headers(w, r.InfoHeader.Width, r.InfoHeader.Height); err != nil {
+		return err
+	}
 
+	// Write pixel data
+	_, err := w.Write(r.DecodedData)
+	return err
+}
+
+// writeBMP32Headers writes the file and info headers of a 32-bit BMP.
+func writeBMP32Headers(w io.Writer, width, height int32) error {
 	// Create new BMP headers for 32-bit output
 	bmf := BitmapFileHeader{
 		Type:       0x4D42, // "BM"
@@ -139,23 +178,29 @@ func (r *UnpackResult) writeBMP32(w io.Writer) error {
 	if err := binary.Write(w, binary.LittleEndian, &bmf); err != nil {
 		return err
 	}
-	if err := binary.Write(w, binary.LittleEndian, &bmi); err != nil {
+	return binary.Write(w, binary.LittleEndian, &bmi)
+}
+
+// writeBMP24 writes a 24-bit or 8-bit BMP (preserving original format).
+func (r *UnpackResult) writeBMP24(w io.Writer) error {
+	if err := writeBMP24Headers(w, r.FileHeader, r.InfoHeader, r.Palette, len(r.PixelData)); err != nil {
 		return err
 	}
 
 	// Write pixel data
-	_, err := w.Write(r.DecodedData)
+	_, err := w.Write(r.PixelData)
 	return err
 }
 
-// writeBMP24 writes a 24-bit or 8-bit BMP (preserving original format).
-func (r *UnpackResult) writeBMP24(w io.Writer) error {
+// writeBMP24Headers writes the headers and palette of a 24-bit or 8-bit BMP
+// whose pixel array is pixelSize bytes long.
+func writeBMP24Headers(w io.Writer, fileHeader *BitmapFileHeader, infoHeader *BitmapInfoHeader, palette []RGBQuad, pixelSize int) error {
 	// Determine if we should include the palette
 	// skipPalette = true when bmf.OffsetBits == 54 (no palette in output)
-	skipPalette := r.FileHeader.OffsetBits == 54
+	skipPalette := fileHeader.OffsetBits == 54
 	paletteSize := 0
-	if len(r.Palette) > 0 && !skipPalette {
-		paletteSize = len(r.Palette) * 4
+	if len(palette) > 0 && !skipPalette {
+		paletteSize = len(palette) * 4
 	}
 
 	// Create new BMP file header (AGF doesn't store the "BM" signature correctly)
@@ -163,16 +208,16 @@ func (r *UnpackResult) writeBMP24(w io.Writer) error {
 		Type:       0x4D42, // "BM" signature
 		OffsetBits: uint32(14 + 40 + paletteSize),
 	}
-	bmf.Size = bmf.OffsetBits + uint32(len(r.PixelData))
+	bmf.Size = bmf.OffsetBits + uint32(pixelSize)
 
 	// Create info header with correct size
 	// The original BMP files have zeros for optional fields
 	bmi := BitmapInfoHeader{
 		Size:     40,
-		Width:    r.InfoHeader.Width,
-		Height:   r.InfoHeader.Height,
+		Width:    infoHeader.Width,
+		Height:   infoHeader.Height,
 		Planes:   1,
-		BitCount: r.InfoHeader.BitCount,
+		BitCount: infoHeader.BitCount,
 		// Leave other fields as zero (matching original BMP output)
 	}
 
@@ -188,20 +233,18 @@ func (r *UnpackResult) writeBMP24(w io.Writer) error {
 
 	// Write palette if present and not skipped
 	if paletteSize > 0 {
-		for _, c := range r.Palette {
+		for _, c := range palette {
 			if err := binary.Write(w, binary.LittleEndian, &c); err != nil {
 				return err
 			}
 		}
 	}
 
-	// Write pixel data
-	_, err := w.Write(r.PixelData)
-	return err
+	return nil
 }
 
 // readSector reads a sector (header + data, with optional LZSS decompression).
-func readSector(r io.Reader) ([]byte, error) {
+func readSector(r io.Reader, tolerant bool) ([]byte, error) {
 	hdr, err := ReadSectorHeader(r)
 	if err != nil {
 		return nil, err
@@ -212,11 +255,19 @@ func readSector(r io.Reader) ([]byte, error) {
 		return nil, err
 	}
 
+	mismatch := hdr.Validate()
+	if mismatch != nil && tolerant {
+		return resolveSectorLength(hdr, data)
+	}
+
 	if hdr.IsCompressed() {
-		decompressed := lzss.Decompress(data)
-		if len(decompressed) != int(hdr.OriginalLength) {
-			return nil, fmt.Errorf("decompression size mismatch: got %d, expected %d",
-				len(decompressed), hdr.OriginalLength)
+		decompressed, err := lzss.DecompressChecked(data, int(hdr.OriginalLength))
+		if err != nil {
+			// Point at the header rather than leaving a bare size error
+			if mismatch != nil {
+				return nil, fmt.Errorf("failed to decompress sector: %w (%w)", err, mismatch)
+			}
+			return nil, fmt.Errorf("failed to decompress sector: %w", err)
 		}
 		return decompressed, nil
 	}
@@ -224,6 +275,37 @@ func readSector(r io.Reader) ([]byte, error) {
 	return data, nil
 }
 
+// resolveSectorLength decodes a sector whose OriginalLength fields disagree,
+// keeping whichever one is consistent with the stored data.
+func resolveSectorLength(hdr *SectorHeader, data []byte) ([]byte, error) {
+	candidates := []struct {
+		name  string
+		value uint32
+	}{
+		{"OriginalLength", hdr.OriginalLength},
+		{"OriginalLength2", hdr.OriginalLength2},
+	}
+
+	// A size equal to Length means the data was stored uncompressed
+	for _, c := range candidates {
+		if c.value == hdr.Length {
+			fmt.Fprintf(os.Stderr, "Warning: %v; using %s (stored uncompressed)\n", hdr.Validate(), c.name)
+			return data, nil
+		}
+	}
+
+	decompressed := lzss.DecompressSize(data, int(max(hdr.OriginalLength, hdr.OriginalLength2)))
+	for _, c := range candidates {
+		if len(decompressed) == int(c.value) {
+			fmt.Fprintf(os.Stderr, "Warning: %v; using %s\n", hdr.Validate(), c.name)
+			return decompressed, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to decompress sector: decoded %d bytes, matching neither field: %w",
+		len(decompressed), hdr.Validate())
+}
+
 // decodeColorMapWithAlpha combines RGB and Alpha data into RGBA.
 // The alpha channel has inverted Y-axis relative to RGB.
 func decodeColorMapWithAlpha(bmi *BitmapInfoHeader, encodedData []byte, palette []RGBQuad, alphaData []byte) []byte {
@@ -231,8 +313,7 @@ func decodeColorMapWithAlpha(bmi *BitmapInfoHeader, encodedData []byte, palette
 	height := int(bmi.Height)
 	decodedData := make([]byte, width*height*4)
 
-	// RGB stride must be padded to 4 bytes
-	rgbStride := (width*int(bmi.BitCount)/8 + 3) &^ 3
+	rgbStride := RowStride(width, bmi.BitCount)
 
 	for y := 0; y < height; y++ {
 		// Alpha Y is inverted
@@ -240,28 +321,36 @@ func decodeColorMapWithAlpha(bmi *BitmapInfoHeader, encodedData []byte, palette
 		rgbaLineIndex := y * width * 4
 		rgbLineIndex := y * rgbStride
 
-		for x := 0; x < width; x++ {
-			blueIndex := rgbaLineIndex + x*4
-
-			if bmi.BitCount == 8 {
-				// Palette-indexed
-				palIndex := encodedData[y*rgbStride+x]
-				decodedData[blueIndex] = palette[palIndex].Blue
-				decodedData[blueIndex+1] = palette[palIndex].Green
-				decodedData[blueIndex+2] = palette[palIndex].Red
-			} else {
-				// 24-bit RGB
-				decodedData[blueIndex] = encodedData[rgbLineIndex+x*3]
-				decodedData[blueIndex+1] = encodedData[rgbLineIndex+x*3+1]
-				decodedData[blueIndex+2] = encodedData[rgbLineIndex+x*3+2]
-			}
-			decodedData[blueIndex+3] = alphaData[alphaLineIndex+x]
-		}
+		decodeRowWithAlpha(decodedData[rgbaLineIndex:rgbaLineIndex+width*4],
+			encodedData[rgbLineIndex:], alphaData[alphaLineIndex:alphaLineIndex+width],
+			bmi.BitCount, palette)
 	}
 
 	return decodedData
 }
 
+// decodeRowWithAlpha combines one row of RGB or palette-indexed pixels with
+// its row of alpha values into BGRA in dst.
+func decodeRowWithAlpha(dst, encodedRow, alphaRow []byte, bitCount uint16, palette []RGBQuad) {
+	for x := range alphaRow {
+		blueIndex := x * 4
+
+		if bitCount == 8 {
+			// Palette-indexed
+			palIndex := encodedRow[x]
+			dst[blueIndex] = palette[palIndex].Blue
+			dst[blueIndex+1] = palette[palIndex].Green
+			dst[blueIndex+2] = palette[palIndex].Red
+		} else {
+			// 24-bit RGB
+			dst[blueIndex] = encodedRow[x*3]
+			dst[blueIndex+1] = encodedRow[x*3+1]
+			dst[blueIndex+2] = encodedRow[x*3+2]
+		}
+		dst[blueIndex+3] = alphaRow[x]
+	}
+}
+
 // ReadBMPFile reads a BMP file for packing back to AGF.
 func ReadBMPFile(path string) (*BitmapFileHeader, *BitmapInfoHeader, []RGBQuad, []byte, error) {
 	data, err := os.ReadFile(path)
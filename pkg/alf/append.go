@@ -0,0 +1,106 @@
+package alf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// An append index (APPENDxx.AAI, S4AC/S5AC) ships with a patch and layers
+// onto the base index of the game (SYS4INI.BIN or SYS5INI.BIN). It lists only
+// the archives it adds, but the engine loads them after the base archives, so
+// some append indexes number their entries' archives globally: index 0 is the
+// first base archive and the append's own first archive is the base count.
+//
+// When every entry's ArchiveIndex fits the append's own archive list, the
+// indexes are local and are used as is. Otherwise the base archive count is
+// subtracted so ArchiveIndex always resolves into Archive.Sources, and the
+// count is recorded in Archive.BaseArchives.
+
+// rebaseAppendEntries converts globally numbered archive indexes of an
+// append index into indexes of its own Sources.
+func (e *Extractor) rebaseAppendEntries() error {
+	a := e.archive
+	if !a.Header.IsAppend() {
+		return nil
+	}
+
+	count := uint32(len(a.Sources))
+	local := true
+	for _, entry := range a.Entries {
+		if entry.ArchiveIndex >= count {
+			local = false
+			break
+		}
+	}
+	if local {
+		return nil
+	}
+
+	base, err := e.baseArchiveCount()
+	if err != nil {
+		return err
+	}
+
+	for i := range a.Entries {
+		entry := &a.Entries[i]
+		if entry.ArchiveIndex < base || entry.ArchiveIndex-base >= count {
+			return fmt.Errorf("archive index %d of %s is outside the append archives %d-%d",
+				entry.ArchiveIndex, entry.Filename, base, base+count-1)
+		}
+		entry.ArchiveIndex -= base
+	}
+	a.BaseArchives = base
+
+	if e.opts.Verbose {
+		fmt.Printf("Rebased append archive indexes by %d base archives\n", base)
+	}
+	return nil
+}
+
+// baseArchiveCount returns the number of archives in the base index, taken
+// from the BaseIndex option or the base index next to the append index.
+func (e *Extractor) baseArchiveCount() (uint32, error) {
+	path := e.opts.BaseIndex
+	if path == "" {
+		path = findBaseIndex(e.baseDir, e.archive.Header.Version)
+		if path == "" {
+			return 0, ErrNoBaseIndex
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read base index: %w", err)
+	}
+
+	header, names, _, err := ParseIndexMetadata(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse base index %s: %w", path, err)
+	}
+	if header.IsAppend() {
+		return 0, fmt.Errorf("base index %s is itself an append index", path)
+	}
+	return uint32(len(names)), nil
+}
+
+// findBaseIndex looks for the base index of the given format version in dir,
+// ignoring filename case. It returns "" if there is none.
+func findBaseIndex(dir string, version FormatVersion) string {
+	name := "SYS5INI.BIN"
+	if version == FormatS4 {
+		name = "SYS4INI.BIN"
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, f := range files {
+		if !f.IsDir() && strings.EqualFold(f.Name(), name) {
+			return filepath.Join(dir, f.Name())
+		}
+	}
+	return ""
+}
@@ -0,0 +1,190 @@
+package alf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testAppendArchives returns count archives PATCH1.ALF... of three files each.
+func testAppendArchives(count int) []testArchive {
+	archives := testArchives(count, 3, 40)
+	for i := range archives {
+		archives[i].name = fmt.Sprintf("PATCH%d.ALF", i+1)
+	}
+	return archives
+}
+
+func TestRebaseAppendEntries(t *testing.T) {
+	tests := []struct {
+		name      string
+		sig       string
+		numbering uint32 // Offset of the append entries' archive indexes
+		base      int    // Archives in the base index next to the append index (-1 = none)
+		option    bool   // Pass the base index through ExtractOptions.BaseIndex instead
+		wantBase  uint32
+		wantErr   error
+	}{
+		{"local numbering", "S5AC", 0, 3, false, 0, nil},
+		{"local numbering without base index", "S5AC", 0, -1, false, 0, nil},
+		{"global numbering", "S5AC", 3, 3, false, 3, nil},
+		{"global numbering S4", "S4AC", 3, 3, false, 3, nil},
+		{"base index option", "S5AC", 4, 4, true, 4, nil},
+		{"no base index", "S5AC", 3, -1, false, 0, ErrNoBaseIndex},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			archives := testAppendArchives(2)
+			appendPath := writeTestAppendIndex(t, dir, tt.sig, tt.numbering, archives)
+
+			var opts ExtractOptions
+			if tt.base >= 0 {
+				baseSig := "S5IC"
+				if tt.sig == "S4AC" {
+					baseSig = "S4IC"
+				}
+				baseDir := dir
+				if tt.option {
+					baseDir = t.TempDir()
+				}
+				opts.BaseIndex = writeTestIndex(t, baseDir, baseSig, testArchives(tt.base, 1, 10))
+				if !tt.option {
+					opts.BaseIndex = ""
+				}
+			}
+
+			opts.OutputDir = t.TempDir()
+			e, err := NewExtractor(appendPath, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+			err = e.Open(appendPath)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Open = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := e.GetArchive().BaseArchives; got != tt.wantBase {
+				t.Errorf("BaseArchives = %d, want %d", got, tt.wantBase)
+			}
+			if err := e.Extract(); err != nil {
+				t.Fatal(err)
+			}
+			for _, arc := range archives {
+				for _, f := range arc.files {
+					path := filepath.Join(opts.OutputDir, strings.TrimSuffix(arc.name, ".ALF"), f.name)
+					data, err := os.ReadFile(path)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(data, f.data) {
+						t.Errorf("%s differs from %s/%s", path, arc.name, f.name)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRebaseAppendEntriesRejects(t *testing.T) {
+	tests := []struct {
+		name      string
+		numbering uint32
+		base      int
+	}{
+		{"past the append archives", 5, 3},
+		{"before the append archives", 2, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			appendPath := writeTestAppendIndex(t, dir, "S5AC", tt.numbering, testAppendArchives(2))
+			writeTestIndex(t, dir, "S5IC", testArchives(tt.base, 1, 10))
+
+			e, err := NewExtractor(appendPath, ExtractOptions{OutputDir: t.TempDir()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+			err = e.Open(appendPath)
+			if err == nil || !strings.Contains(err.Error(), "outside the append archives") {
+				t.Errorf("Open = %v, want an archive index outside the append archives", err)
+			}
+		})
+	}
+
+	t.Run("base index is an append index", func(t *testing.T) {
+		dir := t.TempDir()
+		appendPath := writeTestAppendIndex(t, dir, "S5AC", 3, testAppendArchives(2))
+
+		e, err := NewExtractor(appendPath, ExtractOptions{OutputDir: t.TempDir(), BaseIndex: appendPath})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer e.Close()
+		if err := e.Open(appendPath); err == nil || !strings.Contains(err.Error(), "is itself an append index") {
+			t.Errorf("Open = %v, want a base index that is itself an append index", err)
+		}
+	})
+}
+
+func TestPackAppendRestoresGlobalNumbering(t *testing.T) {
+	dir := t.TempDir()
+	archives := testAppendArchives(2)
+	appendPath := writeTestAppendIndex(t, dir, "S5AC", 3, archives)
+	writeTestIndex(t, dir, "S5IC", testArchives(3, 1, 10))
+
+	input := testExtract(t, appendPath)
+	if err := os.WriteFile(filepath.Join(input, "PATCH2", "NEW.DAT"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := testPack(t, appendPath, input, PackOptions{})
+
+	data, err := os.ReadFile(filepath.Join(out, "APPEND01.AAI"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, names, entries, err := ParseIndexMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.ArchiveIndex < 3 {
+			t.Errorf("%s has local archive index %d", entry.Filename, entry.ArchiveIndex)
+			continue
+		}
+		if arc := names[entry.ArchiveIndex-3]; entry.Filename == "NEW.DAT" && arc != "PATCH2.ALF" {
+			t.Errorf("NEW.DAT is in %s, want PATCH2", arc)
+		}
+	}
+
+	// The packed append index extracts the same files again next to the
+	// base index and the archive packing left untouched
+	for _, name := range []string{"SYS5INI.BIN", "PATCH1.ALF"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err == nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(out, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertSameFiles(t, input, testExtract(t, filepath.Join(out, "APPEND01.AAI")),
+		append(testArchiveFiles(archives), filepath.Join("PATCH2", "NEW.DAT"))...)
+}
@@ -0,0 +1,74 @@
+package alf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DiffArchives compares two index files and returns the entries of newIndex
+// that are absent from oldIndex or whose archive, offset or length changed,
+// i.e. the files a game patch most likely touched. Only the index metadata is
+// read; the ALF files do not need to be present. Filenames are compared
+// case-insensitively and archives by name, since a patch may renumber them.
+func DiffArchives(oldIndex, newIndex string) ([]FileEntry, error) {
+	oldNames, oldEntries, err := readIndexEntries(oldIndex)
+	if err != nil {
+		return nil, err
+	}
+	newNames, newEntries, err := readIndexEntries(newIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	type location struct {
+		archive string
+		offset  uint32
+		length  uint32
+	}
+
+	archiveName := func(names []string, idx uint32) string {
+		if int(idx) < len(names) {
+			return strings.ToLower(names[idx])
+		}
+		return ""
+	}
+
+	previous := make(map[string]location, len(oldEntries))
+	for _, entry := range oldEntries {
+		previous[strings.ToLower(entry.Filename)] = location{
+			archive: archiveName(oldNames, entry.ArchiveIndex),
+			offset:  entry.Offset,
+			length:  entry.Length,
+		}
+	}
+
+	var changed []FileEntry
+	for _, entry := range newEntries {
+		current := location{
+			archive: archiveName(newNames, entry.ArchiveIndex),
+			offset:  entry.Offset,
+			length:  entry.Length,
+		}
+		if old, exists := previous[strings.ToLower(entry.Filename)]; !exists || old != current {
+			changed = append(changed, entry)
+		}
+	}
+
+	return changed, nil
+}
+
+// readIndexEntries reads an index file and parses its metadata.
+func readIndexEntries(indexPath string) ([]string, []FileEntry, error) {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read index %s: %w", indexPath, err)
+	}
+
+	_, names, entries, err := ParseIndexMetadata(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse index %s: %w", indexPath, err)
+	}
+
+	return names, entries, nil
+}
@@ -0,0 +1,39 @@
+package alf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DuplicatePolicy selects what extraction does when several entries of one
+// archive share a filename, as seen in some append archives. Names are
+// compared case-insensitively.
+type DuplicatePolicy int
+
+const (
+	DuplicateOverwrite DuplicatePolicy = iota // Later entries replace earlier ones
+	DuplicateSkip                             // Keep the first entry, skip the others
+	DuplicateRename                           // Write later entries as name.1.ext, name.2.ext, ...
+)
+
+// ParseDuplicatePolicy parses "overwrite", "skip" or "rename" (case-insensitive).
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "overwrite":
+		return DuplicateOverwrite, nil
+	case "skip":
+		return DuplicateSkip, nil
+	case "rename":
+		return DuplicateRename, nil
+	default:
+		return DuplicateOverwrite, fmt.Errorf("unknown duplicate policy %q (expected overwrite, skip or rename)", s)
+	}
+}
+
+// duplicateName returns the name the nth duplicate of filename is written
+// under, inserting n before the extension.
+func duplicateName(filename string, n int) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(filename, ext), n, ext)
+}
@@ -0,0 +1,167 @@
+package alf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    DuplicatePolicy
+		wantErr bool
+	}{
+		{"", DuplicateOverwrite, false},
+		{"overwrite", DuplicateOverwrite, false},
+		{"Skip", DuplicateSkip, false},
+		{"RENAME", DuplicateRename, false},
+		{"keep", DuplicateOverwrite, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuplicatePolicy(tt.s)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseDuplicatePolicy(%q) = %v, %v, want %v (error %v)", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDuplicateName(t *testing.T) {
+	tests := []struct {
+		filename string
+		n        int
+		want     string
+	}{
+		{"A.DAT", 1, "A.1.DAT"},
+		{"A.DAT", 2, "A.2.DAT"},
+		{"ARCHIVE.TAR.GZ", 1, "ARCHIVE.TAR.1.GZ"},
+		{"NOEXT", 1, "NOEXT.1"},
+		{"SUB\\A.DAT", 3, "SUB\\A.3.DAT"},
+	}
+
+	for _, tt := range tests {
+		if got := duplicateName(tt.filename, tt.n); got != tt.want {
+			t.Errorf("duplicateName(%q, %d) = %q, want %q", tt.filename, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestExtractDuplicates(t *testing.T) {
+	// Names repeat within DATA1, case-insensitively; DATA2 has its own
+	// A.DAT, which is not a duplicate
+	archives := []testArchive{
+		{name: "DATA1.ALF", files: []testFile{
+			{name: "A.DAT", data: []byte("first")},
+			{name: "B.DAT", data: []byte("b")},
+			{name: "a.dat", data: []byte("second")},
+			{name: "A.DAT", data: []byte("third")},
+		}},
+		{name: "DATA2.ALF", files: []testFile{
+			{name: "A.DAT", data: []byte("other")},
+		}},
+	}
+
+	tests := []struct {
+		name   string
+		policy DuplicatePolicy
+		want   map[string]string // DATA1 file -> content
+		exact  bool              // DATA1 holds only the files in want
+	}{
+		// Which of A.DAT and a.dat survive overwriting depends on whether
+		// the file system ignores case, but A.DAT is written last
+		{"overwrite", DuplicateOverwrite, map[string]string{"A.DAT": "third", "B.DAT": "b"}, false},
+		{"skip", DuplicateSkip, map[string]string{"A.DAT": "first", "B.DAT": "b"}, true},
+		{"rename", DuplicateRename, map[string]string{
+			"A.DAT":   "first",
+			"B.DAT":   "b",
+			"a.1.dat": "second",
+			"A.2.DAT": "third",
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexPath := writeTestIndex(t, t.TempDir(), "S5IC", archives)
+			out := t.TempDir()
+
+			e, err := NewExtractor(indexPath, ExtractOptions{OutputDir: out, OnDuplicate: tt.policy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+			if err := e.Open(indexPath); err != nil {
+				t.Fatal(err)
+			}
+			// Without Verbose the library prints nothing, warnings included
+			if output := testCaptureOutput(t, func() {
+				if err := e.Extract(); err != nil {
+					t.Fatal(err)
+				}
+			}); output != "" {
+				t.Errorf("Extract printed %q", output)
+			}
+
+			for name, want := range tt.want {
+				data, err := os.ReadFile(filepath.Join(out, "DATA1", name))
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+				if string(data) != want {
+					t.Errorf("DATA1/%s = %q, want %q", name, data, want)
+				}
+			}
+			if tt.exact {
+				files, err := os.ReadDir(filepath.Join(out, "DATA1"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got, want []string
+				for _, f := range files {
+					got = append(got, f.Name())
+				}
+				for name := range tt.want {
+					want = append(want, name)
+				}
+				sort.Strings(got)
+				sort.Strings(want)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("DATA1 holds %v, want %v", got, want)
+				}
+			}
+
+			files, err := os.ReadDir(filepath.Join(out, "DATA2"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 || files[0].Name() != "A.DAT" {
+				t.Errorf("DATA2 holds %v, want only A.DAT", files)
+			}
+		})
+	}
+}
+
+// testCaptureOutput runs fn and returns what it wrote to stdout and stderr.
+func testCaptureOutput(tb testing.TB, fn func()) string {
+	tb.Helper()
+
+	f, err := os.CreateTemp(tb.TempDir(), "output")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = f, f
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+	fn()
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return string(data)
+}
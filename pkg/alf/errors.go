@@ -5,4 +5,15 @@ import "errors"
 var (
 	ErrInvalidMagic = errors.New("invalid archive magic: expected S4 or S5 format")
 	ErrNotSupported = errors.New("archive format not supported")
+	ErrMetadataSize = errors.New("decompressed metadata size does not match header")
+	ErrFileNotFound = errors.New("file not found in archive index")
+	ErrAmbiguous    = errors.New("filename appears more than once in archive index")
+	ErrNameExists   = errors.New("filename already exists in archive index")
+	ErrUnsafePath   = errors.New("entry filename escapes the output directory")
+	ErrNoBaseIndex  = errors.New("append index needs its base index to resolve archive numbers")
+	ErrArchiveInUse = errors.New("output would overwrite archives the input index still references")
+
+	ErrLengthMismatch   = errors.New("duplicated uncompressed size fields disagree")
+	ErrArchiveMissing   = errors.New("archive file not found")
+	ErrChecksumMismatch = errors.New("file checksum does not match")
 )
@@ -1,11 +1,19 @@
 package alf
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
 	"sync"
 
@@ -15,15 +23,49 @@ import (
 // ExtractOptions configures the extraction process.
 type ExtractOptions struct {
 	Filter    string // Only extract files containing this string (case-insensitive)
+	Glob      string // Only extract files matching this path.Match pattern (case-insensitive)
+	Regex     string // Only extract files matching this regular expression
 	OutputDir string // Output directory (default: "data")
 	Verbose   bool   // Print detailed progress
+	Since     string // Only extract files changed relative to this older index file
+	Tolerant  bool   // Accept disagreeing duplicate size fields if one matches the data (see Archive.SizeMismatch)
+
+	// BaseIndex is the base index an append index layers onto. If empty,
+	// SYS5INI.BIN or SYS4INI.BIN next to the append index is used when
+	// its archive numbers need rebasing.
+	BaseIndex string
+
+	// TextExtensions lists the extensions (e.g. ".txt") of files whose line
+	// endings are rewritten according to NormalizeNewlines. Files with other
+	// extensions are always written unchanged.
+	TextExtensions    []string
+	NormalizeNewlines NewlineMode
+
+	OnDuplicate DuplicatePolicy // What to do with repeated filenames within an archive
+
+	Concurrency int          // Maximum archives extracted in parallel (0 = GOMAXPROCS)
+	OnProgress  ProgressFunc // Called after each file is written (may be nil)
+
+	// SkipMissingArchives opens an index even if some of its archives do
+	// not exist, marking those sources Missing and leaving their files out
+	// of extraction. Otherwise opening fails with one error naming every
+	// missing archive.
+	SkipMissingArchives bool
+
+	// VerifyHashes maps filenames (case-insensitive) to the expected CRC32
+	// (IEEE) of their data as stored in the archive, before any newline
+	// rewriting. Each listed file is hashed while it is written and a
+	// mismatch fails extraction, removing the file. Files not listed are
+	// not checked.
+	VerifyHashes map[string]uint32
 }
 
 // Extractor handles ALF archive extraction.
 type Extractor struct {
 	archive *Archive
 	opts    ExtractOptions
-	baseDir string // Directory containing the archive files
+	baseDir string            // Directory containing the archive files
+	memory  map[string][]byte // Archive contents by name, set by OpenBytes
 }
 
 // NewExtractor creates a new extractor for the given archive file.
@@ -45,6 +87,39 @@ func (e *Extractor) Open(archivePath string) error {
 		return fmt.Errorf("failed to read archive: %w", err)
 	}
 
+	return e.open(data, archivePath)
+}
+
+// OpenBytes parses an index held in memory and reads the archives it
+// references from sources, keyed by archive name (e.g. "DATA1.ALF"),
+// instead of from disk. Names are looked up case-insensitively if there
+// is no exact match. An append index whose archive numbers need rebasing
+// still reads its base index from the BaseIndex option.
+func (e *Extractor) OpenBytes(indexData []byte, sources map[string][]byte) error {
+	e.baseDir = ""
+	e.memory = sources
+	if e.memory == nil {
+		e.memory = map[string][]byte{}
+	}
+	return e.open(indexData, "")
+}
+
+// OpenBytes parses an index and its archives held in memory, as
+// Extractor.OpenBytes does, and returns the archive.
+func OpenBytes(indexData []byte, sources map[string][]byte) (*Archive, error) {
+	e, err := NewExtractor("", ExtractOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if err := e.OpenBytes(indexData, sources); err != nil {
+		return nil, err
+	}
+	return e.GetArchive(), nil
+}
+
+// open parses index data read from archivePath, or from memory if
+// archivePath is empty.
+func (e *Extractor) open(data []byte, archivePath string) error {
 	if len(data) < 8 {
 		return fmt.Errorf("file too small to be a valid archive")
 	}
@@ -61,12 +136,21 @@ func (e *Extractor) Open(archivePath string) error {
 
 	switch version {
 	case FormatS4:
-		return e.openS4(data)
+		err = e.openS4(data)
 	case FormatS5:
-		return e.openS5(data)
+		err = e.openS5(data)
 	default:
 		return ErrNotSupported
 	}
+	if err != nil {
+		return err
+	}
+
+	if missing := e.archive.MissingSources(); len(missing) > 0 && !e.opts.SkipMissingArchives {
+		return fmt.Errorf("%w: %s", ErrArchiveMissing, strings.Join(missing, ", "))
+	}
+
+	return e.rebaseAppendEntries()
 }
 
 // openS4 parses S4 format archives (S4IC/S4AC).
@@ -104,12 +188,23 @@ func (e *Extractor) openS4(data []byte) error {
 
 	// Decompress if needed
 	var metadata []byte
-	if sectHdr.OriginalLength != sectHdr.Length {
-		metadata = lzss.Decompress(compData)
-		if len(metadata) == 0 {
-			return fmt.Errorf("LZSS decompression failed: empty result")
+	mismatch := sectHdr.Validate()
+	switch {
+	case mismatch != nil && e.opts.Tolerant:
+		metadata, err = e.resolveMetadataLength(compData, sectHdr.Length,
+			sectHdr.OriginalLength, sectHdr.OriginalLength2, mismatch)
+		if err != nil {
+			return err
 		}
-	} else {
+	case sectHdr.OriginalLength != sectHdr.Length:
+		metadata, err = lzss.DecompressChecked(compData, int(sectHdr.OriginalLength))
+		if err != nil {
+			if mismatch != nil {
+				return fmt.Errorf("LZSS decompression failed: %w (%w)", err, mismatch)
+			}
+			return fmt.Errorf("LZSS decompression failed: %w", err)
+		}
+	default:
 		metadata = compData
 	}
 
@@ -130,6 +225,47 @@ func (e *Extractor) openS5(data []byte) error {
 	return e.parseS5Uncompressed(data)
 }
 
+// openSource opens the archive named name, from memory after OpenBytes and
+// otherwise from the directory of the index.
+func (e *Extractor) openSource(name string) (ArchiveSource, error) {
+	if e.memory != nil {
+		data, ok := e.memory[name]
+		if !ok {
+			for k, v := range e.memory {
+				if strings.EqualFold(k, name) {
+					data, ok = v, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return ArchiveSource{}, fmt.Errorf("failed to open archive %s: %w", name, os.ErrNotExist)
+		}
+		return ArchiveSource{Name: name, Reader: bytes.NewReader(data)}, nil
+	}
+
+	path := filepath.Join(e.baseDir, name)
+	handle, err := os.Open(path)
+	if err != nil {
+		return ArchiveSource{}, fmt.Errorf("failed to open archive %s: %w", name, err)
+	}
+	return ArchiveSource{Name: name, Path: path, Handle: handle, Reader: handle}, nil
+}
+
+// addSource opens the archive named name and appends it to the sources. An
+// archive that does not exist is appended marked Missing, for open to
+// report or skip.
+func (e *Extractor) addSource(name string) error {
+	src, err := e.openSource(name)
+	if errors.Is(err, os.ErrNotExist) {
+		src = ArchiveSource{Name: name, Missing: true}
+	} else if err != nil {
+		return err
+	}
+	e.archive.Sources = append(e.archive.Sources, src)
+	return nil
+}
+
 // parseS4Metadata parses the decompressed metadata from S4IC/S4AC.
 func (e *Extractor) parseS4Metadata(metadata []byte) error {
 	pos := 0
@@ -151,17 +287,9 @@ func (e *Extractor) parseS4Metadata(metadata []byte) error {
 		arcName := readNullTerminatedString(metadata[pos : pos+S4ArchiveEntrySize])
 		pos += S4ArchiveEntrySize
 
-		arcPath := filepath.Join(e.baseDir, arcName)
-		handle, err := os.Open(arcPath)
-		if err != nil {
-			return fmt.Errorf("failed to open archive %s: %w", arcName, err)
+		if err := e.addSource(arcName); err != nil {
+			return err
 		}
-
-		e.archive.Sources = append(e.archive.Sources, ArchiveSource{
-			Name:   arcName,
-			Path:   arcPath,
-			Handle: handle,
-		})
 	}
 
 	// Read entry count
@@ -219,9 +347,22 @@ func (e *Extractor) parseS5Compressed(data []byte) error {
 	}
 
 	compData := data[compStart:compEnd]
-	metadata := lzss.Decompress(compData)
-	if len(metadata) == 0 {
-		return fmt.Errorf("LZSS decompression failed: empty result")
+	mismatch := compInfo.Validate()
+	if mismatch != nil && e.opts.Tolerant {
+		metadata, err := e.resolveMetadataLength(compData, compInfo.CompSize,
+			compInfo.UncompSize1, compInfo.UncompSize2, mismatch)
+		if err != nil {
+			return err
+		}
+		return e.parseS5Metadata(metadata)
+	}
+
+	metadata := lzss.DecompressSize(compData, int(compInfo.UncompSize1))
+	if err := checkMetadataSize(metadata, compInfo.UncompSize1); err != nil {
+		if mismatch != nil {
+			return fmt.Errorf("%w (%w)", err, mismatch)
+		}
+		return err
 	}
 
 	return e.parseS5Metadata(metadata)
@@ -244,18 +385,10 @@ func (e *Extractor) parseS5Uncompressed(data []byte) error {
 	pos += 4
 
 	// Open the archive file
-	arcPath := filepath.Join(e.baseDir, arcName)
-	handle, err := os.Open(arcPath)
-	if err != nil {
-		return fmt.Errorf("failed to open archive %s: %w", arcName, err)
+	if err := e.addSource(arcName); err != nil {
+		return err
 	}
 
-	e.archive.Sources = append(e.archive.Sources, ArchiveSource{
-		Name:   arcName,
-		Path:   arcPath,
-		Handle: handle,
-	})
-
 	// Read entries
 	for i := uint32(0); i < entryCount; i++ {
 		if pos+S5FileEntrySize > len(data) {
@@ -304,17 +437,9 @@ func (e *Extractor) parseS5Metadata(metadata []byte) error {
 		arcName = strings.TrimRight(arcName, "\x00")
 		pos += S5ArchiveEntrySize
 
-		arcPath := filepath.Join(e.baseDir, arcName)
-		handle, err := os.Open(arcPath)
-		if err != nil {
-			return fmt.Errorf("failed to open archive %s: %w", arcName, err)
+		if err := e.addSource(arcName); err != nil {
+			return err
 		}
-
-		e.archive.Sources = append(e.archive.Sources, ArchiveSource{
-			Name:   arcName,
-			Path:   arcPath,
-			Handle: handle,
-		})
 	}
 
 	// Read entry count
@@ -353,6 +478,43 @@ func (e *Extractor) parseS5Metadata(metadata []byte) error {
 	return nil
 }
 
+// checkMetadataSize verifies that decompressed metadata has the length its
+// sector header or compression info recorded, catching truncated or variant
+// indexes before their entries are parsed.
+func checkMetadataSize(metadata []byte, expected uint32) error {
+	if len(metadata) == 0 {
+		return fmt.Errorf("LZSS decompression failed: empty result")
+	}
+	if len(metadata) != int(expected) {
+		return fmt.Errorf("%w: decompressed %d bytes, expected %d", ErrMetadataSize, len(metadata), expected)
+	}
+	return nil
+}
+
+// resolveMetadataLength decodes index metadata whose two uncompressed size
+// fields disagree, keeping whichever one is consistent with the data. A size
+// equal to the stored length means the metadata was stored uncompressed. The
+// disagreement and its resolution are recorded in Archive.SizeMismatch.
+func (e *Extractor) resolveMetadataLength(compData []byte, stored, size1, size2 uint32, mismatch error) ([]byte, error) {
+	if size1 == stored || size2 == stored {
+		e.archive.SizeMismatch = fmt.Errorf("%w; metadata is stored uncompressed", mismatch)
+		return compData, nil
+	}
+
+	metadata := lzss.DecompressSize(compData, int(max(size1, size2)))
+	if len(metadata) == 0 {
+		return nil, fmt.Errorf("LZSS decompression failed: empty result")
+	}
+	if len(metadata) != int(size1) && len(metadata) != int(size2) {
+		return nil, fmt.Errorf("%w: decompressed %d bytes, matching neither field (%w)",
+			ErrMetadataSize, len(metadata), mismatch)
+	}
+
+	e.archive.SizeMismatch = fmt.Errorf("%w; using %d, which matches the decompressed data",
+		mismatch, len(metadata))
+	return metadata, nil
+}
+
 // readNullTerminatedString reads a null-terminated UTF-8 string from data.
 func readNullTerminatedString(data []byte) string {
 	for i, b := range data {
@@ -365,48 +527,131 @@ func readNullTerminatedString(data []byte) string {
 
 // Extract extracts all files from the archive.
 func (e *Extractor) Extract() error {
-	if e.archive == nil {
-		return fmt.Errorf("archive not opened")
+	entries, err := e.selectedEntries()
+	if err != nil {
+		return err
 	}
 
 	// Group entries by archive for parallel extraction
 	groups := make(map[uint32][]FileEntry)
-	for _, entry := range e.archive.Entries {
-		// Apply filter if set
-		if e.opts.Filter != "" {
-			if !strings.Contains(strings.ToLower(entry.Filename), strings.ToLower(e.opts.Filter)) {
-				continue
-			}
+	var order []uint32
+	for _, entry := range entries {
+		if _, ok := groups[entry.ArchiveIndex]; !ok {
+			order = append(order, entry.ArchiveIndex)
 		}
 		groups[entry.ArchiveIndex] = append(groups[entry.ArchiveIndex], entry)
 	}
 
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(groups))
+	prog := newProgress(e.opts.OnProgress, len(entries))
+
+	var expected map[string]uint32
+	if e.opts.VerifyHashes != nil {
+		expected = make(map[string]uint32, len(e.opts.VerifyHashes))
+		for name, sum := range e.opts.VerifyHashes {
+			expected[strings.ToLower(name)] = sum
+		}
+	}
+
+	workers := e.opts.Concurrency
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(order))
+
+	// The first error cancels the archives not yet finished
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		firstErr error
+		errOnce  sync.Once
+	)
 
-	for arcIdx, entries := range groups {
+	jobs := make(chan uint32)
+	var wg sync.WaitGroup
+	for range workers {
 		wg.Add(1)
-		go func(idx uint32, files []FileEntry) {
+		go func() {
 			defer wg.Done()
-			if err := e.extractFromArchive(idx, files); err != nil {
-				errChan <- err
+			for idx := range jobs {
+				if err := e.extractFromArchive(ctx, idx, groups[idx], expected, prog); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
 			}
-		}(arcIdx, entries)
+		}()
 	}
 
+feed:
+	for _, idx := range order {
+		select {
+		case jobs <- idx:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
 	wg.Wait()
-	close(errChan)
 
-	// Return first error if any
-	for err := range errChan {
-		return err
+	return firstErr
+}
+
+// selectedEntries returns the entries matching the name filters and Since,
+// leaving out files in missing archives.
+func (e *Extractor) selectedEntries() ([]FileEntry, error) {
+	if e.archive == nil {
+		return nil, fmt.Errorf("archive not opened")
 	}
 
-	return nil
+	// Restrict to files changed since the older index if requested
+	var changed map[string]bool
+	if e.opts.Since != "" {
+		entries, err := DiffArchives(e.opts.Since, e.archive.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff against %s: %w", e.opts.Since, err)
+		}
+		changed = make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			changed[strings.ToLower(entry.Filename)] = true
+		}
+	}
+
+	match, err := e.nameMatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []FileEntry
+	skipped := make(map[uint32]int)
+	for _, entry := range e.archive.Entries {
+		if !match(entry.Filename) {
+			continue
+		}
+		if changed != nil && !changed[strings.ToLower(entry.Filename)] {
+			continue
+		}
+		if int(entry.ArchiveIndex) < len(e.archive.Sources) && e.archive.Sources[entry.ArchiveIndex].Missing {
+			skipped[entry.ArchiveIndex]++
+			continue
+		}
+		selected = append(selected, entry)
+	}
+
+	if e.opts.Verbose {
+		for i, src := range e.archive.Sources {
+			if n := skipped[uint32(i)]; n > 0 {
+				fmt.Printf("Skipping %d files in missing archive %s\n", n, src.Name)
+			}
+		}
+	}
+	return selected, nil
 }
 
-// extractFromArchive extracts files from a single archive source.
-func (e *Extractor) extractFromArchive(arcIdx uint32, entries []FileEntry) error {
+// extractFromArchive extracts files from a single archive source, stopping
+// between files once ctx is cancelled. Files listed in expected, keyed by
+// lower-cased name, have their CRC32 checked.
+func (e *Extractor) extractFromArchive(ctx context.Context, arcIdx uint32, entries []FileEntry, expected map[string]uint32, prog *progress) error {
 	if int(arcIdx) >= len(e.archive.Sources) {
 		return fmt.Errorf("archive index %d out of range", arcIdx)
 	}
@@ -420,8 +665,37 @@ func (e *Extractor) extractFromArchive(arcIdx uint32, entries []FileEntry) error
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	seen := make(map[string]int, len(entries))
 	for _, entry := range entries {
-		outPath := filepath.Join(outDir, entry.Filename)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		name := entry.Filename
+		key := strings.ToLower(name)
+		if n := seen[key]; n > 0 {
+			switch e.opts.OnDuplicate {
+			case DuplicateSkip:
+				if e.opts.Verbose {
+					fmt.Printf("\tskipping duplicate %s\n", name)
+				}
+				seen[key]++
+				prog.add(entry.Filename)
+				continue
+			case DuplicateRename:
+				name = duplicateName(name, n)
+			default:
+				if e.opts.Verbose {
+					fmt.Printf("\toverwriting duplicate %s\n", name)
+				}
+			}
+		}
+		seen[key]++
+
+		outPath, err := safeJoin(outDir, name)
+		if err != nil {
+			return err
+		}
 
 		// Ensure parent directory exists
 		if dir := filepath.Dir(outPath); dir != outDir {
@@ -434,21 +708,128 @@ func (e *Extractor) extractFromArchive(arcIdx uint32, entries []FileEntry) error
 			fmt.Printf("\t%s\n", outPath)
 		}
 
-		// Read file data from archive
-		data := make([]byte, entry.Length)
-		if _, err := src.Handle.ReadAt(data, int64(entry.Offset)); err != nil {
-			return fmt.Errorf("failed to read %s: %w", entry.Filename, err)
+		var h hash.Hash32
+		want, verify := expected[key]
+		if verify {
+			h = crc32.NewIEEE()
+		}
+
+		// Text files are rewritten whole; everything else is streamed
+		if e.opts.NormalizeNewlines != NewlinesKeep && isTextFile(entry.Filename, e.opts.TextExtensions) {
+			data := make([]byte, entry.Length)
+			if _, err := src.Reader.ReadAt(data, int64(entry.Offset)); err != nil {
+				return fmt.Errorf("failed to read %s: %w", entry.Filename, err)
+			}
+			if h != nil {
+				h.Write(data)
+			}
+			data = normalizeNewlines(data, e.opts.NormalizeNewlines)
+			if err := os.WriteFile(outPath, data, 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", outPath, err)
+			}
+		} else if err := copyEntryToFile(src, entry, outPath, h); err != nil {
+			return err
 		}
 
-		// Write output file
-		if err := os.WriteFile(outPath, data, 0644); err != nil {
-			return fmt.Errorf("failed to write %s: %w", outPath, err)
+		if verify && h.Sum32() != want {
+			os.Remove(outPath)
+			return fmt.Errorf("%w: %s has CRC32 %08X, expected %08X", ErrChecksumMismatch, entry.Filename, h.Sum32(), want)
 		}
+
+		prog.add(entry.Filename)
 	}
 
 	return nil
 }
 
+// copyEntryToFile streams an entry's data from its archive into a new file
+// at outPath, so memory use does not grow with the entry size. If h is not
+// nil the data is also written to it.
+func copyEntryToFile(src ArchiveSource, entry FileEntry, outPath string, h hash.Hash32) error {
+	f, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", outPath, err)
+	}
+
+	var w io.Writer = f
+	if h != nil {
+		w = io.MultiWriter(f, h)
+	}
+
+	r := io.NewSectionReader(src.Reader, int64(entry.Offset), int64(entry.Length))
+	if _, err := io.CopyN(w, r, int64(entry.Length)); err != nil {
+		f.Close()
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("failed to extract %s: %w", entry.Filename, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outPath, err)
+	}
+	return nil
+}
+
+// nameMatcher compiles whichever of Filter, Glob and Regex is set into a
+// filename predicate. At most one of them may be set.
+func (e *Extractor) nameMatcher() (func(string) bool, error) {
+	set := 0
+	for _, s := range []string{e.opts.Filter, e.opts.Glob, e.opts.Regex} {
+		if s != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("only one of filter, glob and regex may be set")
+	}
+
+	switch {
+	case e.opts.Filter != "":
+		filter := strings.ToLower(e.opts.Filter)
+		return func(name string) bool {
+			return strings.Contains(strings.ToLower(name), filter)
+		}, nil
+	case e.opts.Glob != "":
+		pattern := strings.ToLower(e.opts.Glob)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", e.opts.Glob, err)
+		}
+		return func(name string) bool {
+			ok, _ := path.Match(pattern, strings.ToLower(name))
+			return ok
+		}, nil
+	case e.opts.Regex != "":
+		re, err := regexp.Compile(e.opts.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		return re.MatchString, nil
+	}
+	return func(string) bool { return true }, nil
+}
+
+// safeJoin joins an entry filename onto dir, rejecting names that are
+// absolute or contain a ".." component. Both separators are checked since
+// entries use backslashes, which are path separators on Windows.
+func safeJoin(dir, filename string) (string, error) {
+	if filename == "" || filepath.IsAbs(filename) || filepath.VolumeName(filename) != "" ||
+		strings.HasPrefix(filename, "/") || strings.HasPrefix(filename, "\\") {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, filename)
+	}
+	for _, part := range strings.FieldsFunc(filename, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return "", fmt.Errorf("%w: %q", ErrUnsafePath, filename)
+		}
+	}
+
+	path := filepath.Join(dir, filepath.Clean(filename))
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, filename)
+	}
+	return path, nil
+}
+
 // Close closes the extractor and all open file handles.
 func (e *Extractor) Close() {
 	if e.archive != nil {
@@ -456,6 +837,100 @@ func (e *Extractor) Close() {
 	}
 }
 
+// Locate returns the index entry for filename and the source archive holding
+// its data. Names are compared case-insensitively.
+func (e *Extractor) Locate(filename string) (*FileEntry, *ArchiveSource, error) {
+	if e.archive == nil {
+		return nil, nil, fmt.Errorf("archive not opened")
+	}
+
+	i := e.archive.findEntry(filename)
+	if i < 0 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrFileNotFound, filename)
+	}
+	entry := &e.archive.Entries[i]
+	if int(entry.ArchiveIndex) >= len(e.archive.Sources) {
+		return nil, nil, fmt.Errorf("archive index %d out of range for %s", entry.ArchiveIndex, entry.Filename)
+	}
+	return entry, &e.archive.Sources[entry.ArchiveIndex], nil
+}
+
+// ExtractOne returns the contents of the file named exactly filename. The
+// bytes are returned as stored; NormalizeNewlines does not apply.
+func (e *Extractor) ExtractOne(filename string) ([]byte, error) {
+	entry, src, err := e.locateExact(filename)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, entry.Length)
+	if _, err := src.Reader.ReadAt(data, int64(entry.Offset)); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", entry.Filename, err)
+	}
+	return data, nil
+}
+
+// ExtractTo copies the contents of the file named exactly filename to w
+// without buffering the whole file.
+func (e *Extractor) ExtractTo(filename string, w io.Writer) error {
+	entry, src, err := e.locateExact(filename)
+	if err != nil {
+		return err
+	}
+	r := io.NewSectionReader(src.Reader, int64(entry.Offset), int64(entry.Length))
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %w", entry.Filename, err)
+	}
+	if n != int64(entry.Length) {
+		return fmt.Errorf("failed to read %s: %w", entry.Filename, io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
+// locateExact is Locate with a case-sensitive comparison that refuses to
+// guess when several entries share the name.
+func (e *Extractor) locateExact(filename string) (*FileEntry, *ArchiveSource, error) {
+	if e.archive == nil {
+		return nil, nil, fmt.Errorf("archive not opened")
+	}
+
+	var matches []int
+	for i := range e.archive.Entries {
+		if e.archive.Entries[i].Filename == filename {
+			matches = append(matches, i)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, nil, fmt.Errorf("%w: %s", ErrFileNotFound, filename)
+	case 1:
+	default:
+		var where []string
+		for _, i := range matches {
+			where = append(where, e.sourceName(e.archive.Entries[i].ArchiveIndex))
+		}
+		return nil, nil, fmt.Errorf("%w: %s (in %s)", ErrAmbiguous, filename, strings.Join(where, ", "))
+	}
+
+	entry := &e.archive.Entries[matches[0]]
+	if int(entry.ArchiveIndex) >= len(e.archive.Sources) {
+		return nil, nil, fmt.Errorf("archive index %d out of range for %s", entry.ArchiveIndex, entry.Filename)
+	}
+	src := &e.archive.Sources[entry.ArchiveIndex]
+	if src.Missing {
+		return nil, nil, fmt.Errorf("%w: %s holds %s", ErrArchiveMissing, src.Name, entry.Filename)
+	}
+	return entry, src, nil
+}
+
+// sourceName returns the name of archive idx, or its number if out of range.
+func (e *Extractor) sourceName(idx uint32) string {
+	if int(idx) < len(e.archive.Sources) {
+		return e.archive.Sources[idx].Name
+	}
+	return fmt.Sprintf("archive %d", idx)
+}
+
 // GetArchive returns the parsed archive metadata.
 func (e *Extractor) GetArchive() *Archive {
 	return e.archive
@@ -0,0 +1,174 @@
+package alf
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafeJoin(t *testing.T) {
+	dir := filepath.Join("out", "DATA1")
+
+	tests := []struct {
+		filename string
+		want     string // "" = rejected
+	}{
+		{"A.DAT", filepath.Join(dir, "A.DAT")},
+		{"SUB/A.DAT", filepath.Join(dir, "SUB", "A.DAT")},
+		{"SUB/./A.DAT", filepath.Join(dir, "SUB", "A.DAT")},
+		{"..A.DAT", filepath.Join(dir, "..A.DAT")},
+		{"", ""},
+		{".", ""},
+		{"..", ""},
+		{"../A.DAT", ""},
+		{"..\\A.DAT", ""},
+		{"..\\..\\A.DAT", ""},
+		{"SUB/../../A.DAT", ""},
+		{"SUB\\..\\A.DAT", ""},
+		{"/etc/A.DAT", ""},
+		{"\\A.DAT", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := safeJoin(dir, tt.filename)
+		if tt.want == "" {
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("safeJoin(%q) = %q, %v, want %v", tt.filename, got, err, ErrUnsafePath)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("safeJoin(%q) = %q, %v, want %q", tt.filename, got, err, tt.want)
+		}
+	}
+}
+
+func TestExtractRejectsUnsafeNames(t *testing.T) {
+	names := []string{
+		"..\\..\\EVIL.DAT",
+		"../../EVIL.DAT",
+		"SUB/../../../EVIL.DAT",
+		"/EVIL.DAT",
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			indexDir := filepath.Join(root, "game")
+			outDir := filepath.Join(root, "out", "data")
+			for _, dir := range []string{indexDir, outDir} {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			indexPath := writeTestIndex(t, indexDir, "S5IC", []testArchive{{
+				name: "DATA1.ALF",
+				files: []testFile{
+					{name: "GOOD.DAT", data: []byte("good")},
+					{name: name, data: []byte("evil")},
+				},
+			}})
+
+			e, err := NewExtractor(indexPath, ExtractOptions{OutputDir: outDir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+			if err := e.Open(indexPath); err != nil {
+				t.Fatal(err)
+			}
+			if err := e.Extract(); !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("Extract = %v, want %v", err, ErrUnsafePath)
+			}
+
+			// Nothing may be written outside the output directory, and the
+			// malicious entry nowhere at all
+			err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				if strings.Contains(d.Name(), "EVIL") {
+					t.Errorf("malicious entry written to %s", path)
+				}
+				if rel, _ := filepath.Rel(outDir, path); !filepath.IsLocal(rel) && filepath.Dir(path) != indexDir {
+					t.Errorf("%s written outside the output directory", path)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestTolerantMetadataSizes(t *testing.T) {
+	tests := []struct {
+		name       string
+		field      int // Offset of the corrupted size field after 0x21C (-1 = none)
+		tolerant   bool
+		wantOpen   bool
+		wantReport bool
+	}{
+		{"consistent", -1, true, true, false},
+		{"first size wrong", 0, false, false, false},
+		{"first size wrong tolerant", 0, true, true, true},
+		{"second size wrong", 4, false, true, false},
+		{"second size wrong tolerant", 4, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archives := testArchives(2, 3, 40)
+			indexPath := writeTestIndex(t, t.TempDir(), "S5IC", archives)
+			if tt.field >= 0 {
+				data, err := os.ReadFile(indexPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pos := 0x21C + tt.field
+				binary.LittleEndian.PutUint32(data[pos:], binary.LittleEndian.Uint32(data[pos:])+7)
+				if err := os.WriteFile(indexPath, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			e, err := NewExtractor(indexPath, ExtractOptions{OutputDir: t.TempDir(), Tolerant: tt.tolerant})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+
+			// The library leaves reporting the mismatch to the caller
+			var openErr error
+			if output := testCaptureOutput(t, func() { openErr = e.Open(indexPath) }); output != "" {
+				t.Errorf("Open printed %q", output)
+			}
+			if (openErr == nil) != tt.wantOpen {
+				t.Fatalf("Open = %v, want success %v", openErr, tt.wantOpen)
+			}
+			if openErr != nil {
+				if !errors.Is(openErr, ErrMetadataSize) {
+					t.Errorf("Open = %v, want %v", openErr, ErrMetadataSize)
+				}
+				return
+			}
+
+			mismatch := e.GetArchive().SizeMismatch
+			if (mismatch != nil) != tt.wantReport {
+				t.Errorf("SizeMismatch = %v, want reported %v", mismatch, tt.wantReport)
+			}
+			if mismatch != nil && !strings.Contains(mismatch.Error(), "which matches the decompressed data") {
+				t.Errorf("SizeMismatch = %v, want the size used", mismatch)
+			}
+			if got := len(e.GetArchive().Entries); got != 6 {
+				t.Errorf("%d entries, want 6", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package alf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agetools/pkg/lzss"
+)
+
+// testFile is a file of a test archive. A file with a shares name points
+// at the data of the earlier file of that name in the same archive instead
+// of having its own.
+type testFile struct {
+	name   string
+	data   []byte
+	shares string
+}
+
+// testArchive is an ALF archive of a test index.
+type testArchive struct {
+	name  string
+	files []testFile
+}
+
+// writeTestIndex writes an index with signature sig (S4IC, S5IC or S5IN)
+// and its archives into dir and returns the path of the index. Files are
+// stored back to back in each archive and numbered across all archives, as
+// the game indexes do.
+func writeTestIndex(tb testing.TB, dir, sig string, archives []testArchive) string {
+	tb.Helper()
+
+	entries := writeTestArchives(tb, dir, archives)
+
+	var index []byte
+	switch sig {
+	case "S4IC":
+		header := make([]byte, S4HeaderSize)
+		copy(header, sig+"\x00TEST")
+		index = testCompressedIndex(header, testS4Metadata(archives, entries))
+	case "S5IC":
+		header := make([]byte, S5HeaderSize)
+		copy(header, EncodeUTF16LE(sig))
+		copy(header[0x10:], EncodeUTF16LE("TEST"))
+		index = testCompressedIndex(header, testS5Metadata(archives, entries))
+	case "S5IN":
+		if len(archives) != 1 {
+			tb.Fatalf("S5IN index needs exactly one archive, got %d", len(archives))
+		}
+		index = make([]byte, 0x404+len(entries)*S5FileEntrySize)
+		copy(index, EncodeUTF16LE(sig))
+		copy(index[0x200:], EncodeUTF16LE(archives[0].name))
+		binary.LittleEndian.PutUint32(index[0x400:], uint32(len(entries)))
+		for i, entry := range entries {
+			pos := 0x404 + i*S5FileEntrySize
+			copy(index[pos:], EncodeUTF16LE(entry.Filename))
+			binary.LittleEndian.PutUint32(index[pos+0x88:], entry.Offset)
+			binary.LittleEndian.PutUint32(index[pos+0x8C:], entry.Length)
+		}
+	default:
+		tb.Fatalf("unsupported test index signature %s", sig)
+	}
+
+	name := "SYS5INI.BIN"
+	if sig[1] == '4' {
+		name = "SYS4INI.BIN"
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, index, 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// writeTestAppendIndex writes an append index APPEND01.AAI with signature
+// sig (S4AC or S5AC) and its archives into dir and returns its path. The
+// entries' archive indexes are offset by base, so a nonzero base numbers
+// them globally after that many base archives.
+func writeTestAppendIndex(tb testing.TB, dir, sig string, base uint32, archives []testArchive) string {
+	tb.Helper()
+
+	entries := writeTestArchives(tb, dir, archives)
+	for i := range entries {
+		entries[i].ArchiveIndex += base
+	}
+
+	var index []byte
+	switch sig {
+	case "S4AC":
+		header := make([]byte, 0x10C)
+		copy(header, sig+"\x00TEST")
+		index = testCompressedIndex(header, testS4Metadata(archives, entries))
+	case "S5AC":
+		header := make([]byte, 0x214)
+		copy(header, EncodeUTF16LE(sig))
+		copy(header[0x10:], EncodeUTF16LE("TEST"))
+		index = testCompressedIndex(header, testS5Metadata(archives, entries))
+	default:
+		tb.Fatalf("unsupported test append index signature %s", sig)
+	}
+
+	path := filepath.Join(dir, "APPEND01.AAI")
+	if err := os.WriteFile(path, index, 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// writeTestArchives writes archives into dir and returns their entries,
+// numbering files across all archives.
+func writeTestArchives(tb testing.TB, dir string, archives []testArchive) []FileEntry {
+	tb.Helper()
+
+	var entries []FileEntry
+	var fileIndex uint32
+	for arcIdx, arc := range archives {
+		var body bytes.Buffer
+		offsets := make(map[string]FileEntry)
+		for _, f := range arc.files {
+			entry := FileEntry{Filename: f.name, ArchiveIndex: uint32(arcIdx), FileIndex: fileIndex}
+			if f.shares != "" {
+				shared, ok := offsets[f.shares]
+				if !ok {
+					tb.Fatalf("%s shares unknown file %s", f.name, f.shares)
+				}
+				entry.Offset, entry.Length = shared.Offset, shared.Length
+			} else {
+				entry.Offset, entry.Length = uint32(body.Len()), uint32(len(f.data))
+				body.Write(f.data)
+			}
+			offsets[f.name] = entry
+			entries = append(entries, entry)
+			fileIndex++
+		}
+		if err := os.WriteFile(filepath.Join(dir, arc.name), body.Bytes(), 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return entries
+}
+
+// testCompressedIndex appends the size fields and compressed metadata to header.
+func testCompressedIndex(header, metadata []byte) []byte {
+	compressed := lzss.Compress(metadata)
+	index := append([]byte{}, header...)
+	index = binary.LittleEndian.AppendUint32(index, uint32(len(metadata)))
+	index = binary.LittleEndian.AppendUint32(index, uint32(len(metadata)))
+	index = binary.LittleEndian.AppendUint32(index, uint32(len(compressed)))
+	return append(index, compressed...)
+}
+
+// testS4Metadata returns S4 metadata with UTF-8 names.
+func testS4Metadata(archives []testArchive, entries []FileEntry) []byte {
+	var buf []byte
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(archives)))
+	for _, arc := range archives {
+		name := make([]byte, S4ArchiveEntrySize)
+		copy(name, arc.name)
+		buf = append(buf, name...)
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(entries)))
+	for _, entry := range entries {
+		rec := make([]byte, S4FileEntrySize)
+		copy(rec, entry.Filename)
+		binary.LittleEndian.PutUint32(rec[0x40:], entry.ArchiveIndex)
+		binary.LittleEndian.PutUint32(rec[0x44:], entry.FileIndex)
+		binary.LittleEndian.PutUint32(rec[0x48:], entry.Offset)
+		binary.LittleEndian.PutUint32(rec[0x4C:], entry.Length)
+		buf = append(buf, rec...)
+	}
+	return buf
+}
+
+// testS5Metadata returns S5 metadata with UTF-16LE names.
+func testS5Metadata(archives []testArchive, entries []FileEntry) []byte {
+	var buf []byte
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(archives)))
+	for _, arc := range archives {
+		name := make([]byte, S5ArchiveEntrySize)
+		copy(name, EncodeUTF16LE(arc.name))
+		buf = append(buf, name...)
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(entries)))
+	for _, entry := range entries {
+		rec := make([]byte, S5FileEntrySize)
+		copy(rec, EncodeUTF16LE(entry.Filename))
+		binary.LittleEndian.PutUint32(rec[0x80:], entry.ArchiveIndex)
+		binary.LittleEndian.PutUint32(rec[0x84:], entry.FileIndex)
+		binary.LittleEndian.PutUint32(rec[0x88:], entry.Offset)
+		binary.LittleEndian.PutUint32(rec[0x8C:], entry.Length)
+		buf = append(buf, rec...)
+	}
+	return buf
+}
+
+// testArchives returns count archives DATA1.ALF... of files files each,
+// with size bytes of varied content per file.
+func testArchives(count, files, size int) []testArchive {
+	archives := make([]testArchive, count)
+	for a := range archives {
+		archives[a].name = fmt.Sprintf("DATA%d.ALF", a+1)
+		for f := 0; f < files; f++ {
+			data := make([]byte, size)
+			for i := range data {
+				data[i] = byte((a*31 + f*7 + i*i) >> 2)
+			}
+			archives[a].files = append(archives[a].files, testFile{
+				name: fmt.Sprintf("F%d_%04d.DAT", a, f),
+				data: data,
+			})
+		}
+	}
+	return archives
+}
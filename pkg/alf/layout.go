@@ -0,0 +1,139 @@
+package alf
+
+import (
+	"fmt"
+	"sort"
+)
+
+// LayoutIssueKind classifies a problem found by CheckLayout.
+type LayoutIssueKind int
+
+const (
+	LayoutOverlap LayoutIssueKind = iota // Entry starts inside the data of an earlier entry
+	LayoutGap                            // Unreferenced bytes before the entry beyond the archive's alignment
+	LayoutPastEnd                        // Entry extends past the end of the archive file
+)
+
+func (k LayoutIssueKind) String() string {
+	switch k {
+	case LayoutOverlap:
+		return "overlap"
+	case LayoutGap:
+		return "gap"
+	case LayoutPastEnd:
+		return "past end"
+	}
+	return fmt.Sprintf("LayoutIssueKind(%d)", int(k))
+}
+
+// LayoutIssue describes an entry whose byte range looks wrong.
+type LayoutIssue struct {
+	Kind    LayoutIssueKind
+	Archive string     // Name of the archive holding the entry
+	Entry   FileEntry  // The entry the issue was found at
+	Other   *FileEntry // For overlaps, the earlier entry whose data it overlaps
+	Bytes   int64      // Size of the overlap or gap, or bytes past the end
+}
+
+func (i LayoutIssue) String() string {
+	switch i.Kind {
+	case LayoutOverlap:
+		return fmt.Sprintf("%s: %s overlaps %s by %d bytes at 0x%X", i.Archive, i.Entry.Filename, i.Other.Filename, i.Bytes, i.Entry.Offset)
+	case LayoutGap:
+		return fmt.Sprintf("%s: %d unreferenced bytes before %s at 0x%X", i.Archive, i.Bytes, i.Entry.Filename, i.Entry.Offset)
+	case LayoutPastEnd:
+		return fmt.Sprintf("%s: %s extends %d bytes past the end of the archive", i.Archive, i.Entry.Filename, i.Bytes)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Archive, i.Entry.Filename, i.Kind)
+}
+
+// CheckLayout scans the entries of each archive in offset order and reports
+// entries that overlap the data of an earlier one, entries preceded by
+// unreferenced bytes beyond the padding the archive's alignment (as found by
+// DetectEntryAlignment) accounts for, and entries extending past the end of
+// the archive file. Entries sharing exactly the same data are not overlaps,
+// and bytes after the last entry are not reported. Missing archives and
+// entries with an out-of-range archive index are skipped. Nothing is read
+// but the archive sizes.
+func (a *Archive) CheckLayout() []LayoutIssue {
+	byArchive := make([][]FileEntry, len(a.Sources))
+	for _, entry := range a.Entries {
+		if int(entry.ArchiveIndex) < len(a.Sources) {
+			byArchive[entry.ArchiveIndex] = append(byArchive[entry.ArchiveIndex], entry)
+		}
+	}
+
+	var issues []LayoutIssue
+	for i, src := range a.Sources {
+		entries := byArchive[i]
+		if src.Missing || len(entries) == 0 {
+			continue
+		}
+		sort.SliceStable(entries, func(x, y int) bool {
+			if entries[x].Offset != entries[y].Offset {
+				return entries[x].Offset < entries[y].Offset
+			}
+			return entries[x].Length < entries[y].Length
+		})
+		align := DetectEntryAlignment(entries)
+		size := sourceSize(src)
+
+		var end int64 // End of the data seen so far
+		var last *FileEntry
+		for k := range entries {
+			entry := &entries[k]
+			start, stop := int64(entry.Offset), int64(entry.Offset)+int64(entry.Length)
+
+			shared := last != nil && last.Offset == entry.Offset && last.Length == entry.Length
+			switch {
+			case shared:
+			case start < end:
+				issues = append(issues, LayoutIssue{
+					Kind:    LayoutOverlap,
+					Archive: src.Name,
+					Entry:   *entry,
+					Other:   last,
+					Bytes:   min(end, stop) - start,
+				})
+			case start > int64(alignUp(uint32(end), align)):
+				issues = append(issues, LayoutIssue{
+					Kind:    LayoutGap,
+					Archive: src.Name,
+					Entry:   *entry,
+					Bytes:   start - end,
+				})
+			}
+
+			if size >= 0 && stop > size {
+				issues = append(issues, LayoutIssue{
+					Kind:    LayoutPastEnd,
+					Archive: src.Name,
+					Entry:   *entry,
+					Bytes:   stop - size,
+				})
+			}
+
+			if stop > end || last == nil {
+				end, last = stop, entry
+			}
+		}
+	}
+
+	return issues
+}
+
+// sourceSize returns the size of an archive, or -1 if it cannot be
+// determined.
+func sourceSize(src ArchiveSource) int64 {
+	if src.Handle != nil {
+		info, err := src.Handle.Stat()
+		if err != nil {
+			return -1
+		}
+		return info.Size()
+	}
+	if r, ok := src.Reader.(interface{ Size() int64 }); ok {
+		return r.Size()
+	}
+	return -1
+}
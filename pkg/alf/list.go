@@ -0,0 +1,24 @@
+package alf
+
+import (
+	"fmt"
+	"os"
+)
+
+// ListEntries reads the header and file entries of any supported index file
+// (S4IC/S4AC, S5IN/S5IC/S5AC). Only the index is read; unlike Extractor.Open
+// it does not open the ALF files, so they do not need to be present.
+// Archive indexes of append entries are returned as stored, not rebased.
+func ListEntries(indexPath string) (*Header, []FileEntry, error) {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read index %s: %w", indexPath, err)
+	}
+
+	header, _, entries, err := ParseIndexMetadata(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse index %s: %w", indexPath, err)
+	}
+
+	return header, entries, nil
+}
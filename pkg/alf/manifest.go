@@ -0,0 +1,86 @@
+package alf
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// FileDigest records where a file is stored and the CRC32 (IEEE) of its
+// contents, for verifying a distribution or spotting changed files.
+type FileDigest struct {
+	Filename     string `json:"filename"`
+	ArchiveIndex uint32 `json:"archive_index"`
+	Offset       uint32 `json:"offset"`
+	Length       uint32 `json:"length"`
+	CRC32        uint32 `json:"crc32"`
+}
+
+// Manifest reads every selected file from its source archive and returns
+// its digest, in index order. The Filter, Glob, Regex and Since options
+// select files as in Extract; the contents are hashed as stored.
+func (e *Extractor) Manifest() ([]FileDigest, error) {
+	entries, err := e.selectedEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	digests := make([]FileDigest, 0, len(entries))
+	for _, entry := range entries {
+		if int(entry.ArchiveIndex) >= len(e.archive.Sources) {
+			return nil, fmt.Errorf("archive index %d out of range for %s", entry.ArchiveIndex, entry.Filename)
+		}
+		src := e.archive.Sources[entry.ArchiveIndex]
+
+		h := crc32.NewIEEE()
+		r := io.NewSectionReader(src.Reader, int64(entry.Offset), int64(entry.Length))
+		if n, err := io.Copy(h, r); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Filename, err)
+		} else if n != int64(entry.Length) {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Filename, io.ErrUnexpectedEOF)
+		}
+
+		digests = append(digests, FileDigest{
+			Filename:     entry.Filename,
+			ArchiveIndex: entry.ArchiveIndex,
+			Offset:       entry.Offset,
+			Length:       entry.Length,
+			CRC32:        h.Sum32(),
+		})
+	}
+
+	return digests, nil
+}
+
+// CompareManifests returns the files of newer that are absent from older,
+// the files of older absent from newer, and the files present in both whose
+// length or CRC32 differs, each in the order of its manifest. Filenames are
+// compared case-insensitively; where a name is listed more than once the
+// last digest counts. Files that only moved are not reported.
+func CompareManifests(older, newer []FileDigest) (added, removed, changed []FileDigest) {
+	index := func(digests []FileDigest) map[string]FileDigest {
+		m := make(map[string]FileDigest, len(digests))
+		for _, d := range digests {
+			m[strings.ToLower(d.Filename)] = d
+		}
+		return m
+	}
+	oldByName, newByName := index(older), index(newer)
+
+	for _, d := range newer {
+		old, ok := oldByName[strings.ToLower(d.Filename)]
+		switch {
+		case !ok:
+			added = append(added, d)
+		case old.Length != d.Length || old.CRC32 != d.CRC32:
+			changed = append(changed, d)
+		}
+	}
+	for _, d := range older {
+		if _, ok := newByName[strings.ToLower(d.Filename)]; !ok {
+			removed = append(removed, d)
+		}
+	}
+	return added, removed, changed
+}
@@ -3,8 +3,11 @@ package alf
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"unicode/utf16"
 
 	"agetools/pkg/lzss"
 )
@@ -29,7 +32,11 @@ func ParseSYS5Metadata(data []byte) (*Header, []string, []FileEntry, error) {
 	}
 
 	if !header.IsCompressed() {
-		return nil, nil, nil, fmt.Errorf("uncompressed S5IN format not supported")
+		names, entries, err := parseS5UncompressedEntries(data)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return header, names, entries, nil
 	}
 
 	// Parse metadata
@@ -46,9 +53,9 @@ func ParseSYS5Metadata(data []byte) (*Header, []string, []FileEntry, error) {
 	}
 
 	compData := data[compStart:compEnd]
-	metadata := lzss.Decompress(compData)
-	if len(metadata) == 0 {
-		return nil, nil, nil, fmt.Errorf("LZSS decompression failed")
+	metadata := lzss.DecompressSize(compData, int(compInfo.UncompSize1))
+	if err := checkMetadataSize(metadata, compInfo.UncompSize1); err != nil {
+		return nil, nil, nil, err
 	}
 
 	// Parse metadata content
@@ -102,6 +109,33 @@ func ParseSYS5Metadata(data []byte) (*Header, []string, []FileEntry, error) {
 	return header, archiveNames, entries, nil
 }
 
+// ParseSYS4Metadata parses SYS4INI.BIN (S4IC) or S4AC append index metadata
+// without opening ALF files. Stored metadata is read as is; compressed
+// metadata is decompressed first.
+// Returns header, archive names, file entries, and error.
+func ParseSYS4Metadata(data []byte) (*Header, []string, []FileEntry, error) {
+	version, err := DetectFormat(data)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to detect format: %w", err)
+	}
+
+	if version != FormatS4 {
+		return nil, nil, nil, fmt.Errorf("only S4 format supported, got S%d", version)
+	}
+
+	header, metadata, err := DecompressMetadata(data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	names, entries, err := parseMetadataEntries(metadata, FormatS4)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return header, names, entries, nil
+}
+
 // AddArchiveOptions configures adding a new archive.
 type AddArchiveOptions struct {
 	ArchiveName string   // Name of new archive (e.g., "DATA9.ALF")
@@ -110,57 +144,68 @@ type AddArchiveOptions struct {
 	Verbose     bool     // Print progress
 }
 
+// AddArchiveResult summarizes the index written by AddArchive.
+type AddArchiveResult struct {
+	ArchivesBefore  int    // Archives listed in the original index
+	ArchivesAfter   int    // Archives listed in the new index
+	FilesBefore     int    // File entries in the original index
+	FilesAfter      int    // File entries in the new index
+	NewArchivePath  string // Path of the created DATA*.ALF
+	NewMetadataSize int    // Uncompressed size of the new metadata
+	CompressedSize  int    // Compressed size of the new metadata
+}
+
 // AddArchive adds a new archive entry to SYS5INI.BIN and creates the corresponding DATA*.ALF file.
-func AddArchive(sys5iniPath string, opts AddArchiveOptions) error {
+func AddArchive(sys5iniPath string, opts AddArchiveOptions) (*AddArchiveResult, error) {
 	// Read original SYS5INI.BIN
 	data, err := os.ReadFile(sys5iniPath)
 	if err != nil {
-		return fmt.Errorf("failed to read SYS5INI.BIN: %w", err)
+		return nil, fmt.Errorf("failed to read SYS5INI.BIN: %w", err)
 	}
 
 	// Detect format
 	version, err := DetectFormat(data)
 	if err != nil {
-		return fmt.Errorf("failed to detect format: %w", err)
+		return nil, fmt.Errorf("failed to detect format: %w", err)
 	}
 
 	if version != FormatS5 {
-		return fmt.Errorf("only S5 format (SYS5INI.BIN) is supported, got S%d", version)
+		return nil, fmt.Errorf("only S5 format (SYS5INI.BIN) is supported, got S%d", version)
 	}
 
 	// Parse header
 	header, err := ReadS5Header(data)
 	if err != nil {
-		return fmt.Errorf("failed to read header: %w", err)
+		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
 
 	if !header.IsCompressed() {
-		return fmt.Errorf("uncompressed S5IN format not supported yet")
+		return nil, fmt.Errorf("S5IN indexes have a single archive and cannot reference another")
 	}
 
 	// Parse metadata
-	infoOffset := 0x21C
+	infoOffset := metadataOffset(header)
 	compInfo, err := ReadCompressionInfo(data, infoOffset)
 	if err != nil {
-		return fmt.Errorf("failed to read compression info: %w", err)
+		return nil, fmt.Errorf("failed to read compression info: %w", err)
 	}
 
 	compStart := infoOffset + 12
 	compEnd := compStart + int(compInfo.CompSize)
 	if compEnd > len(data) {
-		return fmt.Errorf("compressed data exceeds file size")
+		return nil, fmt.Errorf("compressed data exceeds file size")
 	}
 
 	compData := data[compStart:compEnd]
-	metadata := lzss.Decompress(compData)
-	if len(metadata) == 0 {
-		return fmt.Errorf("LZSS decompression failed")
+	metadata := lzss.DecompressSize(compData, int(compInfo.UncompSize1))
+	if err := checkMetadataSize(metadata, compInfo.UncompSize1); err != nil {
+		return nil, err
 	}
 
 	// Parse existing metadata
 	pos := 0
 	if pos+4 > len(metadata) {
-		return fmt.Errorf("metadata too short")
+		return nil, fmt.Errorf("metadata too short")
 	}
 	arcCount := binary.LittleEndian.Uint32(metadata[pos:])
 	pos += 4
@@ -169,7 +214,7 @@ func AddArchive(sys5iniPath string, opts AddArchiveOptions) error {
 	existingArchives := make([]string, arcCount)
 	for i := uint32(0); i < arcCount; i++ {
 		if pos+S5ArchiveEntrySize > len(metadata) {
-			return fmt.Errorf("metadata truncated at archive %d", i)
+			return nil, fmt.Errorf("metadata truncated at archive %d", i)
 		}
 		existingArchives[i] = ReadUTF16StringPadded(metadata, pos, S5ArchiveEntrySize)
 		pos += S5ArchiveEntrySize
@@ -177,7 +222,7 @@ func AddArchive(sys5iniPath string, opts AddArchiveOptions) error {
 
 	// Read file count
 	if pos+4 > len(metadata) {
-		return fmt.Errorf("metadata too short for file count")
+		return nil, fmt.Errorf("metadata too short for file count")
 	}
 	fileCount := binary.LittleEndian.Uint32(metadata[pos:])
 	pos += 4
@@ -186,7 +231,7 @@ func AddArchive(sys5iniPath string, opts AddArchiveOptions) error {
 	existingEntries := make([]FileEntry, fileCount)
 	for i := uint32(0); i < fileCount; i++ {
 		if pos+S5FileEntrySize > len(metadata) {
-			return fmt.Errorf("metadata truncated at entry %d", i)
+			return nil, fmt.Errorf("metadata truncated at entry %d", i)
 		}
 
 		filename := ReadUTF16StringPadded(metadata, pos, 0x80)
@@ -208,11 +253,11 @@ func AddArchive(sys5iniPath string, opts AddArchiveOptions) error {
 	// Collect new files from input directory
 	newFiles, err := collectFilesFromDir(opts.InputDir)
 	if err != nil {
-		return fmt.Errorf("failed to collect files: %w", err)
+		return nil, fmt.Errorf("failed to collect files: %w", err)
 	}
 
 	if len(newFiles) == 0 {
-		return fmt.Errorf("no files found in %s", opts.InputDir)
+		return nil, fmt.Errorf("no files found in %s", opts.InputDir)
 	}
 
 	// Create new DATA*.ALF file
@@ -225,40 +270,44 @@ func AddArchive(sys5iniPath string, opts AddArchiveOptions) error {
 
 	newFileEntries, err := createALFArchive(alfPath, newFiles, opts.InputDir, newArchiveIndex, opts.Verbose)
 	if err != nil {
-		return fmt.Errorf("failed to create ALF: %w", err)
+		return nil, fmt.Errorf("failed to create ALF: %w", err)
 	}
 
 	// Build new metadata
 	newMetadata := buildNewMetadata(existingArchives, opts.ArchiveName, existingEntries, newFileEntries)
 
 	// Compress new metadata
-	compressedMetadata := lzss.Compress(newMetadata)
-
-	// Build new SYS5INI.BIN
-	// Need space for header (540 bytes) + compression info (12 bytes)
-	newSys5ini := make([]byte, infoOffset+12)
-	copy(newSys5ini, data[:S5HeaderSize])
-
-	// Write compression info
-	binary.LittleEndian.PutUint32(newSys5ini[infoOffset:], uint32(len(newMetadata)))
-	binary.LittleEndian.PutUint32(newSys5ini[infoOffset+4:], uint32(len(newMetadata)))
-	binary.LittleEndian.PutUint32(newSys5ini[infoOffset+8:], uint32(len(compressedMetadata)))
+	compressedMetadata, err := lzss.CompressVerified(newMetadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress metadata: %w", err)
+	}
 
-	// Write compressed data
-	newSys5ini = append(newSys5ini[:infoOffset+12], compressedMetadata...)
+	// Build new SYS5INI.BIN behind the original header
+	newSys5ini := buildIndexFile(data[:infoOffset], newMetadata, compressedMetadata)
 
 	// Write output
 	if err := os.WriteFile(opts.OutputPath, newSys5ini, 0644); err != nil {
-		return fmt.Errorf("failed to write output: %w", err)
+		return nil, fmt.Errorf("failed to write output: %w", err)
+	}
+
+	result := &AddArchiveResult{
+		ArchivesBefore:  len(existingArchives),
+		ArchivesAfter:   len(existingArchives) + 1,
+		FilesBefore:     len(existingEntries),
+		FilesAfter:      len(existingEntries) + len(newFileEntries),
+		NewArchivePath:  alfPath,
+		NewMetadataSize: len(newMetadata),
+		CompressedSize:  len(compressedMetadata),
 	}
 
 	if opts.Verbose {
 		fmt.Printf("Created modified SYS5INI.BIN: %s\n", opts.OutputPath)
-		fmt.Printf("Archives: %d -> %d\n", len(existingArchives), len(existingArchives)+1)
-		fmt.Printf("Files: %d -> %d\n", len(existingEntries), len(existingEntries)+len(newFiles))
+		fmt.Printf("Archives: %d -> %d\n", result.ArchivesBefore, result.ArchivesAfter)
+		fmt.Printf("Files: %d -> %d\n", result.FilesBefore, result.FilesAfter)
+		fmt.Printf("Metadata: %d bytes (%d compressed)\n", result.NewMetadataSize, result.CompressedSize)
 	}
 
-	return nil
+	return result, nil
 }
 
 // collectFilesFromDir collects all files from a directory.
@@ -375,14 +424,295 @@ func encodeFileEntry(entry FileEntry) []byte {
 }
 
 // encodeUTF16StringPadded encodes a string to UTF-16LE with padding.
+// Characters outside the BMP become surrogate pairs. A string too long for
+// the field is cut at the last whole character that fits.
 func encodeUTF16StringPadded(s string, size int) []byte {
 	buf := make([]byte, size)
-	runes := []rune(s)
-	for i, r := range runes {
-		if i*2+1 >= size {
-			break
+	u16 := utf16.Encode([]rune(s))
+	if n := size / 2; len(u16) > n {
+		u16 = u16[:n]
+		if n > 0 && u16[n-1] >= 0xD800 && u16[n-1] < 0xDC00 {
+			u16 = u16[:n-1] // Do not keep the high half of a split surrogate pair
 		}
-		binary.LittleEndian.PutUint16(buf[i*2:], uint16(r))
+	}
+	for i, c := range u16 {
+		binary.LittleEndian.PutUint16(buf[i*2:], c)
+	}
+	return buf
+}
+
+// UpdateEntry rewrites the offset and length of a single file entry in a
+// SYS5INI.BIN index, leaving the archive bodies untouched.
+// This is useful after manually rewriting a DATA*.ALF when only the index
+// needs to catch up.
+func UpdateEntry(indexPath, filename string, newOffset, newLength uint32) error {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	header, _, entries, err := ParseIndexMetadata(data)
+	if err != nil {
+		return err
+	}
+	if header.Version != FormatS5 {
+		return fmt.Errorf("only S5 format supported, got S%d", header.Version)
+	}
+
+	// Find the matching entry, refusing names that occur more than once
+	match := -1
+	for i, entry := range entries {
+		if strings.EqualFold(entry.Filename, filename) {
+			if match >= 0 {
+				return fmt.Errorf("%w: %s", ErrAmbiguous, filename)
+			}
+			match = i
+		}
+	}
+	if match < 0 {
+		return fmt.Errorf("%w: %s", ErrFileNotFound, filename)
+	}
+	entries[match].Offset, entries[match].Length = newOffset, newLength
+
+	out, err := patchIndexEntries(data, header, entries)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(indexPath, out, 0644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return nil
+}
+
+// DecompressMetadata returns the raw decompressed metadata blob of an S4IC/S4AC
+// or S5IC/S5AC index file without parsing it.
+// This is mainly useful for debugging indexes with an unexpected layout.
+func DecompressMetadata(data []byte) (*Header, []byte, error) {
+	version, err := DetectFormat(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to detect format: %w", err)
+	}
+
+	if version == FormatS4 {
+		header, err := ReadS4Header(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read header: %w", err)
+		}
+
+		if !header.IsCompressed() {
+			return nil, nil, fmt.Errorf("S4 uncompressed format not supported (only S4IC/S4AC)")
+		}
+
+		metadataOffset := S4HeaderSize
+		if header.IsAppend() {
+			metadataOffset = 0x10C
+		}
+
+		sectHdr, err := ReadS4SectorHeader(data, metadataOffset)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read sector header: %w", err)
+		}
+
+		compStart := metadataOffset + 12
+		compEnd := compStart + int(sectHdr.Length)
+		if compEnd > len(data) {
+			return nil, nil, fmt.Errorf("compressed data exceeds file size")
+		}
+
+		if sectHdr.OriginalLength == sectHdr.Length {
+			return header, data[compStart:compEnd], nil
+		}
+
+		metadata := lzss.DecompressSize(data[compStart:compEnd], int(sectHdr.OriginalLength))
+		if err := checkMetadataSize(metadata, sectHdr.OriginalLength); err != nil {
+			return nil, nil, err
+		}
+		return header, metadata, nil
+	}
+
+	header, err := ReadS5Header(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	if !header.IsCompressed() {
+		return nil, nil, fmt.Errorf("uncompressed S5IN format has no compressed metadata")
+	}
+
+	infoOffset := 0x21C
+	if header.IsAppend() {
+		infoOffset = 0x214
+	}
+
+	compInfo, err := ReadCompressionInfo(data, infoOffset)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read compression info: %w", err)
+	}
+
+	compStart := infoOffset + 12
+	compEnd := compStart + int(compInfo.CompSize)
+	if compEnd > len(data) {
+		return nil, nil, fmt.Errorf("compressed data exceeds file size")
+	}
+
+	metadata := lzss.DecompressSize(data[compStart:compEnd], int(compInfo.UncompSize1))
+	if err := checkMetadataSize(metadata, compInfo.UncompSize1); err != nil {
+		return nil, nil, err
+	}
+
+	return header, metadata, nil
+}
+
+// ParseIndexMetadata parses the metadata of any supported index file
+// (S4IC/S4AC, S5IN/S5IC/S5AC) without opening the ALF files.
+// Returns header, archive names, file entries, and error.
+func ParseIndexMetadata(data []byte) (*Header, []string, []FileEntry, error) {
+	version, err := DetectFormat(data)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to detect format: %w", err)
+	}
+
+	// S5IN stores a single archive name and its entries directly in the file
+	if version == FormatS5 {
+		header, err := ReadS5Header(data)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to read header: %w", err)
+		}
+		if !header.IsCompressed() {
+			names, entries, err := parseS5UncompressedEntries(data)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			return header, names, entries, nil
+		}
+	}
+
+	header, metadata, err := DecompressMetadata(data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	names, entries, err := parseMetadataEntries(metadata, version)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return header, names, entries, nil
+}
+
+// parseMetadataEntries parses decompressed S4 or S5 metadata into archive names and file entries.
+func parseMetadataEntries(metadata []byte, version FormatVersion) ([]string, []FileEntry, error) {
+	arcEntrySize, fileEntrySize, nameSize := S5ArchiveEntrySize, S5FileEntrySize, 0x80
+	if version == FormatS4 {
+		arcEntrySize, fileEntrySize, nameSize = S4ArchiveEntrySize, S4FileEntrySize, 0x40
+	}
+
+	decodeName := func(b []byte) string {
+		if version == FormatS4 {
+			return readNullTerminatedString(b)
+		}
+		return strings.TrimRight(DecodeUTF16LE(b), "\x00")
+	}
+
+	pos := 0
+	if pos+4 > len(metadata) {
+		return nil, nil, fmt.Errorf("metadata too short")
+	}
+	arcCount := binary.LittleEndian.Uint32(metadata[pos:])
+	pos += 4
+
+	archiveNames := make([]string, 0, recordCap(arcCount, len(metadata)-pos, arcEntrySize))
+	for i := uint32(0); i < arcCount; i++ {
+		if pos+arcEntrySize > len(metadata) {
+			return nil, nil, fmt.Errorf("metadata truncated at archive %d", i)
+		}
+		archiveNames = append(archiveNames, decodeName(metadata[pos:pos+arcEntrySize]))
+		pos += arcEntrySize
+	}
+
+	if pos+4 > len(metadata) {
+		return nil, nil, fmt.Errorf("metadata too short for file count")
+	}
+	fileCount := binary.LittleEndian.Uint32(metadata[pos:])
+	pos += 4
+
+	entries := make([]FileEntry, 0, recordCap(fileCount, len(metadata)-pos, fileEntrySize))
+	for i := uint32(0); i < fileCount; i++ {
+		if pos+fileEntrySize > len(metadata) {
+			return nil, nil, fmt.Errorf("metadata truncated at entry %d", i)
+		}
+
+		fields := metadata[pos+nameSize:]
+		entries = append(entries, FileEntry{
+			Filename:     decodeName(metadata[pos : pos+nameSize]),
+			ArchiveIndex: binary.LittleEndian.Uint32(fields[0:]),
+			FileIndex:    binary.LittleEndian.Uint32(fields[4:]),
+			Offset:       binary.LittleEndian.Uint32(fields[8:]),
+			Length:       binary.LittleEndian.Uint32(fields[12:]),
+		})
+		pos += fileEntrySize
+	}
+
+	return archiveNames, entries, nil
+}
+
+// parseS5UncompressedEntries parses the archive name and entries of an S5IN index.
+func parseS5UncompressedEntries(data []byte) ([]string, []FileEntry, error) {
+	pos := 0x200
+
+	arcName := strings.TrimRight(ReadUTF16StringPadded(data, pos, 0x200), "\x00")
+	pos += 0x200
+
+	if pos+4 > len(data) {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	entryCount := binary.LittleEndian.Uint32(data[pos:])
+	pos += 4
+
+	entries := make([]FileEntry, 0, recordCap(entryCount, len(data)-pos, S5FileEntrySize))
+	for i := uint32(0); i < entryCount; i++ {
+		if pos+S5FileEntrySize > len(data) {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+
+		entries = append(entries, FileEntry{
+			Filename:     strings.TrimRight(ReadUTF16StringPadded(data, pos, 0x88), "\x00"),
+			ArchiveIndex: 0,
+			FileIndex:    i,
+			Offset:       binary.LittleEndian.Uint32(data[pos+0x88:]),
+			Length:       binary.LittleEndian.Uint32(data[pos+0x8C:]),
+		})
+		pos += S5FileEntrySize
+	}
+
+	return []string{arcName}, entries, nil
+}
+
+// recordCap returns a capacity for count records of size bytes read from an
+// untrusted index, capped at the number that fit in the remaining bytes so a
+// corrupt count cannot exhaust memory before the records are bounds-checked.
+func recordCap(count uint32, remaining, size int) int {
+	return int(min(uint64(count), uint64(max(remaining, 0)/size)))
+}
+
+// buildS5UncompressedIndex builds an S5IN index, the inverse of
+// parseS5UncompressedEntries: the first 0x200 bytes of header, the archive
+// name, the entry count and one record per entry. S5IN records have no
+// archive or file index; entries are written in order.
+func buildS5UncompressedIndex(header []byte, archiveName string, entries []FileEntry) []byte {
+	buf := make([]byte, 0x404+len(entries)*S5FileEntrySize)
+	copy(buf[:0x200], header)
+	copy(buf[0x200:], encodeUTF16StringPadded(archiveName, 0x200))
+	binary.LittleEndian.PutUint32(buf[0x400:], uint32(len(entries)))
+
+	pos := 0x404
+	for _, entry := range entries {
+		copy(buf[pos:], encodeUTF16StringPadded(entry.Filename, 0x88))
+		binary.LittleEndian.PutUint32(buf[pos+0x88:], entry.Offset)
+		binary.LittleEndian.PutUint32(buf[pos+0x8C:], entry.Length)
+		pos += S5FileEntrySize
 	}
 	return buf
 }
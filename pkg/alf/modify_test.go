@@ -0,0 +1,298 @@
+package alf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agetools/pkg/lzss"
+)
+
+func TestUpdateEntry(t *testing.T) {
+	trailer := []byte("TRAILER")
+
+	for _, sig := range []string{"S5IC", "S5IN"} {
+		t.Run(sig, func(t *testing.T) {
+			archives := testArchives(1, 3, 16)
+			archives[0].files = append(archives[0].files, testFile{name: "DUP.DAT", data: []byte("a")}, testFile{name: "dup.dat", data: []byte("b")})
+			indexPath := writeTestIndex(t, t.TempDir(), sig, archives)
+
+			data, err := os.ReadFile(indexPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sig == "S5IC" {
+				data = append(data, trailer...)
+				if err := os.WriteFile(indexPath, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := UpdateEntry(indexPath, "f0_0001.dat", 0x1234, 0x56); err != nil {
+				t.Fatalf("UpdateEntry: %v", err)
+			}
+
+			updated, err := os.ReadFile(indexPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, entries, err := ParseIndexMetadata(updated)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(archives[0].files) {
+				t.Fatalf("%d entries after update, want %d", len(entries), len(archives[0].files))
+			}
+			for i, entry := range entries {
+				want := testFileLayout(archives[0], i)
+				if entry.Filename == "F0_0001.DAT" {
+					want.Offset, want.Length = 0x1234, 0x56
+				}
+				if entry.Offset != want.Offset || entry.Length != want.Length {
+					t.Errorf("%s at 0x%X+0x%X, want 0x%X+0x%X", entry.Filename, entry.Offset, entry.Length, want.Offset, want.Length)
+				}
+			}
+			if sig == "S5IC" && !bytes.HasSuffix(updated, trailer) {
+				t.Error("bytes after the compressed metadata were dropped")
+			}
+
+			tests := []struct {
+				name string
+				want error
+			}{
+				{"DUP.DAT", ErrAmbiguous},
+				{"MISSING.DAT", ErrFileNotFound},
+			}
+			for _, tt := range tests {
+				if err := UpdateEntry(indexPath, tt.name, 0, 0); !errors.Is(err, tt.want) {
+					t.Errorf("UpdateEntry(%s) = %v, want %v", tt.name, err, tt.want)
+				}
+			}
+			after, err := os.ReadFile(indexPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(after, updated) {
+				t.Error("failed update changed the index")
+			}
+		})
+	}
+}
+
+func TestUpdateEntryCompressedSameSize(t *testing.T) {
+	archives := testArchives(1, 3, 16)
+	dir := t.TempDir()
+	entries := writeTestArchives(t, dir, archives)
+
+	// Pad the metadata with noise until LZSS output is exactly as long as
+	// its input, which must still be decompressed rather than read raw
+	metadata := testS5Metadata(archives, entries)
+	seed := uint32(1)
+	for {
+		gap := len(metadata) - len(lzss.Compress(metadata))
+		if gap == 0 {
+			break
+		}
+		// Each 8 noise bytes add about 9 compressed bytes, so 7 per byte
+		// of gap never overshoots
+		for range max(gap*7, 1) {
+			seed = seed*1664525 + 1013904223
+			metadata = append(metadata, byte(seed>>24))
+		}
+	}
+	header := make([]byte, S5HeaderSize)
+	copy(header, EncodeUTF16LE("S5IC"))
+	indexPath := filepath.Join(dir, "SYS5INI.BIN")
+	if err := os.WriteFile(indexPath, testCompressedIndex(header, metadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := UpdateEntry(indexPath, "F0_0002.DAT", 0x40, 0x10); err != nil {
+		t.Fatalf("UpdateEntry: %v", err)
+	}
+
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, got, err := ParseIndexMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(entries) {
+		t.Fatalf("%d entries after update, want %d", len(got), len(entries))
+	}
+	entries[2].Offset, entries[2].Length = 0x40, 0x10
+	for i, entry := range got {
+		if entry != entries[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, entries[i])
+		}
+	}
+}
+
+// testFileLayout returns the offset and length writeTestIndex gave the i-th
+// file of arc, which has no shared files.
+func testFileLayout(arc testArchive, i int) FileEntry {
+	var offset uint32
+	for _, f := range arc.files[:i] {
+		offset += uint32(len(f.data))
+	}
+	return FileEntry{Offset: offset, Length: uint32(len(arc.files[i].data))}
+}
+
+func TestBuildS5UncompressedIndex(t *testing.T) {
+	archives := testArchives(1, 4, 32)
+	indexPath := writeTestIndex(t, t.TempDir(), "S5IN", archives)
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, names, entries, err := ParseIndexMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.IsCompressed() {
+		t.Error("S5IN header reports compressed metadata")
+	}
+	if len(names) != 1 || names[0] != archives[0].name {
+		t.Errorf("archive names = %v, want [%s]", names, archives[0].name)
+	}
+	if len(entries) != len(archives[0].files) {
+		t.Fatalf("%d entries, want %d", len(entries), len(archives[0].files))
+	}
+	for i, entry := range entries {
+		want := testFileLayout(archives[0], i)
+		if entry.Filename != archives[0].files[i].name || entry.Offset != want.Offset || entry.Length != want.Length {
+			t.Errorf("entry %d = %s at 0x%X+0x%X, want %s at 0x%X+0x%X", i,
+				entry.Filename, entry.Offset, entry.Length, archives[0].files[i].name, want.Offset, want.Length)
+		}
+	}
+
+	if rebuilt := buildS5UncompressedIndex(data[:0x200], names[0], entries); !bytes.Equal(rebuilt, data) {
+		t.Errorf("rebuilt index differs (%d vs %d bytes)", len(rebuilt), len(data))
+	}
+}
+
+func TestEncodeUTF16StringPadded(t *testing.T) {
+	tests := []struct {
+		s    string
+		size int
+		want string
+	}{
+		{"A.DAT", 0x20, "A.DAT"},
+		{"日本語.DAT", 0x20, "日本語.DAT"},
+		{"😀.DAT", 0x20, "😀.DAT"},
+		{"𠮷野家\\𝄞.OGG", 0x20, "𠮷野家\\𝄞.OGG"},
+		{"AB😀", 8, "AB😀"},   // The pair fills the field exactly
+		{"AB😀", 6, "AB"},    // A pair that does not fit is dropped whole
+		{"ABCDE", 6, "ABC"}, // Plain characters are truncated
+		{"😀", 2, ""},
+	}
+
+	for _, tt := range tests {
+		buf := encodeUTF16StringPadded(tt.s, tt.size)
+		if len(buf) != tt.size {
+			t.Errorf("encodeUTF16StringPadded(%q, %d) is %d bytes", tt.s, tt.size, len(buf))
+		}
+		if got := DecodeUTF16LE(buf); got != tt.want {
+			t.Errorf("encodeUTF16StringPadded(%q, %d) decodes to %q, want %q", tt.s, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestSupplementaryPlaneNames(t *testing.T) {
+	names := []string{"😀.DAT", "𠮷野家.TXT", "SUB\\𝄞.OGG"}
+	var entries []FileEntry
+	for i, name := range names {
+		entries = append(entries, FileEntry{Filename: name, FileIndex: uint32(i), Offset: uint32(i * 16), Length: 16})
+	}
+
+	check := func(t *testing.T, data []byte, wantArchive string) {
+		t.Helper()
+		_, archives, got, err := ParseIndexMetadata(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if archives[len(archives)-1] != wantArchive {
+			t.Errorf("archive names = %v, want %s last", archives, wantArchive)
+		}
+		if len(got) != len(names) {
+			t.Fatalf("%d entries, want %d", len(got), len(names))
+		}
+		for i, entry := range got {
+			if entry.Filename != names[i] || entry.Offset != entries[i].Offset {
+				t.Errorf("entry %d = %q at 0x%X, want %q at 0x%X", i, entry.Filename, entry.Offset, names[i], entries[i].Offset)
+			}
+		}
+	}
+
+	t.Run("S5IC", func(t *testing.T) {
+		header := make([]byte, S5HeaderSize)
+		copy(header, EncodeUTF16LE("S5IC"))
+		check(t, testCompressedIndex(header, buildNewMetadata(nil, "🎵.ALF", nil, entries)), "🎵.ALF")
+	})
+
+	t.Run("S5IN", func(t *testing.T) {
+		header := make([]byte, 0x200)
+		copy(header, EncodeUTF16LE("S5IN"))
+		check(t, buildS5UncompressedIndex(header, "🎵.ALF", entries), "🎵.ALF")
+	})
+
+	t.Run("pack", func(t *testing.T) {
+		archives := testArchives(1, 2, 20)
+		archives[0].files[1].name = "😀.DAT"
+		indexPath := writeTestIndex(t, t.TempDir(), "S5IC", archives)
+		input := testExtract(t, indexPath)
+
+		added := filepath.Join("DATA1", "𠮷野家.TXT")
+		if err := os.WriteFile(filepath.Join(input, added), []byte("new file"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		out := testPack(t, indexPath, input, PackOptions{})
+		assertSameFiles(t, input, testExtract(t, filepath.Join(out, "SYS5INI.BIN")),
+			append(testArchiveFiles(archives), added)...)
+	})
+}
+
+func TestParseIndexMetadataHugeCounts(t *testing.T) {
+	const huge = 0xFFFFFFF0
+
+	s5 := make([]byte, S5HeaderSize)
+	copy(s5, EncodeUTF16LE("S5IC"))
+	s4 := make([]byte, S4HeaderSize)
+	copy(s4, "S4IC\x00TEST")
+
+	// One archive name followed by a file count
+	oneArchive := func(size int, count uint32) []byte {
+		buf := binary.LittleEndian.AppendUint32(nil, 1)
+		buf = append(buf, make([]byte, size)...)
+		return binary.LittleEndian.AppendUint32(buf, count)
+	}
+
+	s5in := make([]byte, 0x404+S5FileEntrySize)
+	copy(s5in, EncodeUTF16LE("S5IN"))
+	binary.LittleEndian.PutUint32(s5in[0x400:], huge)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"S5 archive count", testCompressedIndex(s5, binary.LittleEndian.AppendUint32(nil, huge))},
+		{"S5 file count", testCompressedIndex(s5, oneArchive(S5ArchiveEntrySize, huge))},
+		{"S4 archive count", testCompressedIndex(s4, binary.LittleEndian.AppendUint32(nil, huge))},
+		{"S4 file count", testCompressedIndex(s4, oneArchive(S4ArchiveEntrySize, huge))},
+		{"S5IN entry count", s5in},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := ParseIndexMetadata(tt.data); err == nil {
+				t.Error("ParseIndexMetadata accepted a count larger than the index")
+			}
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package alf
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 
 	"agetools/pkg/lzss"
 )
@@ -18,6 +23,49 @@ type PackOptions struct {
 	Compress    bool          // Whether to compress the metadata (default: true)
 	Verbose     bool          // Print detailed progress
 	OriginalBIN string        // Path to original SYS5INI.BIN for metadata reference
+	Concurrency int           // Maximum archives written in parallel (0 = number of CPUs)
+
+	// AlignEntries pads each file so the next one starts on a multiple of this
+	// many bytes (0 = no padding, AlignMatchOriginal = detect from the original
+	// archive). AlignArchive pads each archive's total size the same way.
+	AlignEntries int
+	AlignArchive int
+
+	// Compact writes entries that shared data in the original archive once
+	// and leaves no padding after the last file, ignoring AlignArchive, so
+	// each archive holds nothing but referenced bytes and entry alignment.
+	Compact bool
+
+	OnProgress ProgressFunc // Called after each file is written to an archive (may be nil)
+}
+
+// AlignMatchOriginal requests the alignment detected in the original archive.
+const AlignMatchOriginal = -1
+
+// maxDetectedAlignment caps the alignment DetectEntryAlignment reports.
+const maxDetectedAlignment = 0x10000
+
+// DetectEntryAlignment returns the largest power of two (up to 0x10000) that
+// divides the offset of every entry, i.e. the boundary the original packer
+// aligned files to. Archives without padding typically report a small value
+// such as 1 or 4, for which padding to that boundary is a no-op.
+func DetectEntryAlignment(entries []FileEntry) int {
+	align := maxDetectedAlignment
+	for _, entry := range entries {
+		for align > 1 && entry.Offset%uint32(align) != 0 {
+			align >>= 1
+		}
+	}
+	return align
+}
+
+// alignUp rounds n up to a multiple of align (align <= 1 leaves n unchanged).
+func alignUp(n uint32, align int) uint32 {
+	if align <= 1 {
+		return n
+	}
+	a := uint32(align)
+	return (n + a - 1) / a * a
 }
 
 // Packer handles ALF archive packing.
@@ -55,18 +103,31 @@ func (p *Packer) LoadOriginal(archivePath string) error {
 	p.original = extractor.GetArchive()
 	p.version = p.original.Header.Version
 
+	if p.opts.AlignEntries == AlignMatchOriginal {
+		p.opts.AlignEntries = DetectEntryAlignment(p.original.Entries)
+		if p.opts.Verbose {
+			fmt.Printf("Detected entry alignment: 0x%X\n", p.opts.AlignEntries)
+		}
+	}
+
 	// Close file handles but keep metadata
 	for i := range p.original.Sources {
 		if p.original.Sources[i].Handle != nil {
 			p.original.Sources[i].Handle.Close()
 			p.original.Sources[i].Handle = nil
+			p.original.Sources[i].Reader = nil
 		}
 	}
 
 	return nil
 }
 
-// Pack repacks the files into ALF archives.
+// Pack repacks the files into ALF archives. Each archive with a directory
+// of the same name under the input directory is rewritten: files there
+// replace the original entries of the same name, entries without a file
+// are copied from the original archive, and files with no original entry
+// are added after all original files (see addNewFiles). Archives are
+// written in FileIndex order.
 func (p *Packer) Pack() error {
 	if p.original == nil {
 		return fmt.Errorf("original archive not loaded - call LoadOriginal first")
@@ -74,6 +135,7 @@ func (p *Packer) Pack() error {
 
 	// Collect all files from input directory, organized by archive
 	filesByArchive := make(map[int][]packedFile)
+	archiveDirs := make(map[int]string)
 
 	for i, src := range p.original.Sources {
 		arcName := strings.TrimSuffix(src.Name, filepath.Ext(src.Name))
@@ -87,6 +149,7 @@ func (p *Packer) Pack() error {
 		}
 
 		filesByArchive[i] = []packedFile{}
+		archiveDirs[i] = srcDir
 	}
 
 	// Build list of files to pack for each archive
@@ -115,6 +178,11 @@ func (p *Packer) Pack() error {
 		filesByArchive[arcIdx] = append(filesByArchive[arcIdx], pf)
 	}
 
+	// Add files not in the original index after the original entries
+	if err := p.addNewFiles(filesByArchive, archiveDirs); err != nil {
+		return err
+	}
+
 	// Sort files by original index within each archive
 	for arcIdx := range filesByArchive {
 		sort.Slice(filesByArchive[arcIdx], func(i, j int) bool {
@@ -122,8 +190,62 @@ func (p *Packer) Pack() error {
 		})
 	}
 
-	// Create output ALF files
+	// Lay out every archive up front so the index metadata can be built and
+	// compressed while the archives are written
+	results := make([][]FileEntry, len(p.original.Sources))
+	total := 0
+	for arcIdx := range p.original.Sources {
+		if files := filesByArchive[arcIdx]; len(files) > 0 {
+			results[arcIdx] = p.layoutArchive(files)
+			total += len(files)
+		}
+	}
+
+	// Collect entries in archive order so the output does not depend on scheduling
 	newEntries := make([]FileEntry, 0, len(p.original.Entries))
+	for _, entries := range results {
+		newEntries = append(newEntries, entries...)
+	}
+
+	// Sort entries by archive index then file index
+	sort.Slice(newEntries, func(i, j int) bool {
+		if newEntries[i].ArchiveIndex != newEntries[j].ArchiveIndex {
+			return newEntries[i].ArchiveIndex < newEntries[j].ArchiveIndex
+		}
+		return newEntries[i].FileIndex < newEntries[j].FileIndex
+	})
+
+	index, err := p.prepareIndexFile(newEntries)
+	if err != nil {
+		return err
+	}
+
+	// Write the archives and compress the metadata, bounded by the
+	// configured concurrency
+	concurrency := p.opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	prog := newProgress(p.opts.OnProgress, total)
+
+	logs := make([]bytes.Buffer, len(p.original.Sources))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(p.original.Sources)+1)
+
+	if index.metadata != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if err := index.compress(); err != nil {
+				errChan <- err
+			}
+		}()
+	}
 
 	for arcIdx, src := range p.original.Sources {
 		files := filesByArchive[arcIdx]
@@ -131,94 +253,326 @@ func (p *Packer) Pack() error {
 			continue
 		}
 
-		outPath := filepath.Join(p.opts.OutputDir, src.Name)
-		if p.opts.Verbose {
-			fmt.Printf("Creating %s\n", outPath)
+		wg.Add(1)
+		go func(idx int, src ArchiveSource, files []packedFile) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if err := p.writeArchive(src, files, results[idx], &logs[idx], prog); err != nil {
+				errChan <- err
+			}
+		}(arcIdx, src, files)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	// Print each archive's log in one piece, in archive order
+	if p.opts.Verbose {
+		for i := range logs {
+			os.Stdout.Write(logs[i].Bytes())
 		}
+	}
 
-		// Open original archive for reading unmodified files
-		origPath := filepath.Join(filepath.Dir(p.opts.OriginalBIN), src.Name)
-		origFile, err := os.Open(origPath)
-		if err != nil {
-			return fmt.Errorf("failed to open original archive %s: %w", origPath, err)
+	// Return first error if any
+	for err := range errChan {
+		return err
+	}
+
+	// Create new index file (SYS5INI.BIN or similar)
+	return p.writeIndexFile(index)
+}
+
+// addNewFiles appends to filesByArchive the files found under each archive
+// directory that have no entry in the original index. Subdirectories become
+// backslash-separated names as the engine stores them. The new files are
+// numbered after the highest FileIndex in the original index, archive by
+// archive in the order of their names, so each is written after every
+// original file of its archive, the original entries keep their numbers,
+// and the numbers stay unique whether the index counts files per archive
+// or across all archives.
+//
+// A new file whose name matches an original entry of another archive, or
+// another new file, case-insensitively is an error, as is a name too long
+// for the index format.
+func (p *Packer) addNewFiles(filesByArchive map[int][]packedFile, archiveDirs map[int]string) error {
+	owners := make(map[string]string, len(p.original.Entries)) // lower-cased name -> archive
+	var nextIndex uint32
+	for _, entry := range p.original.Entries {
+		owners[strings.ToLower(entry.Filename)] = p.original.Sources[entry.ArchiveIndex].Name
+		if entry.FileIndex >= nextIndex {
+			nextIndex = entry.FileIndex + 1
 		}
+	}
 
-		outFile, err := os.Create(outPath)
-		if err != nil {
-			origFile.Close()
-			return fmt.Errorf("failed to create output archive %s: %w", outPath, err)
+	// Files already repacked in place of an original entry
+	packed := make(map[string]bool)
+	for _, files := range filesByArchive {
+		for _, pf := range files {
+			if pf.modified {
+				packed[strings.ToLower(pf.path)] = true
+			}
 		}
+	}
 
-		var offset uint32 = 0
-		for i := range files {
-			pf := &files[i]
+	arcIdxs := make([]int, 0, len(archiveDirs))
+	for arcIdx := range archiveDirs {
+		arcIdxs = append(arcIdxs, arcIdx)
+	}
+	sort.Ints(arcIdxs)
 
-			if pf.modified {
-				// Read from modified file
-				data, err := os.ReadFile(pf.path)
-				if err != nil {
-					outFile.Close()
-					origFile.Close()
-					return fmt.Errorf("failed to read %s: %w", pf.path, err)
-				}
-
-				if _, err := outFile.Write(data); err != nil {
-					outFile.Close()
-					origFile.Close()
-					return fmt.Errorf("failed to write to archive: %w", err)
-				}
-
-				if p.opts.Verbose {
-					fmt.Printf("  + %s (modified)\n", pf.name)
-				}
-			} else {
-				// Copy from original archive
-				data := make([]byte, pf.origLength)
-				if _, err := origFile.ReadAt(data, int64(pf.origOffset)); err != nil {
-					outFile.Close()
-					origFile.Close()
-					return fmt.Errorf("failed to read from original: %w", err)
-				}
-
-				if _, err := outFile.Write(data); err != nil {
-					outFile.Close()
-					origFile.Close()
-					return fmt.Errorf("failed to write to archive: %w", err)
-				}
+	for _, arcIdx := range arcIdxs {
+		dir := archiveDirs[arcIdx]
+		arcName := p.original.Sources[arcIdx].Name
+
+		// WalkDir visits files in lexical order
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			if packed[strings.ToLower(path)] {
+				return nil
 			}
 
-			newEntries = append(newEntries, FileEntry{
-				Filename:     pf.name,
-				ArchiveIndex: pf.arcIndex,
-				FileIndex:    pf.fileIndex,
-				Offset:       offset,
-				Length:       pf.size,
-			})
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			name := strings.ReplaceAll(filepath.ToSlash(rel), "/", "\\")
+			if owner, ok := owners[strings.ToLower(name)]; ok {
+				return fmt.Errorf("%w: %s in %s collides with an entry of %s", ErrNameExists, name, arcName, owner)
+			}
+			if err := p.checkNameLength(name); err != nil {
+				return err
+			}
 
-			offset += pf.size
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			owners[strings.ToLower(name)] = arcName
+			filesByArchive[arcIdx] = append(filesByArchive[arcIdx], packedFile{
+				name:      name,
+				path:      path,
+				arcIndex:  uint32(arcIdx),
+				fileIndex: nextIndex,
+				size:      uint32(info.Size()),
+				modified:  true,
+				added:     true,
+			})
+			nextIndex++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", dir, err)
 		}
+	}
+
+	return nil
+}
 
-		origFile.Close()
-		outFile.Close()
+// checkNameLength reports whether name fits the index's filename field
+// along with its null terminator.
+func (p *Packer) checkNameLength(name string) error {
+	size, limit := len(EncodeUTF16LE(name)), 0x80
+	if p.version == FormatS4 {
+		size, limit = len(name), 0x40
 	}
+	if size >= limit {
+		return fmt.Errorf("filename %s is too long for the index (%d bytes, limit %d)", name, size, limit-1)
+	}
+	return nil
+}
 
-	// Sort entries by archive index then file index
-	sort.Slice(newEntries, func(i, j int) bool {
-		if newEntries[i].ArchiveIndex != newEntries[j].ArchiveIndex {
-			return newEntries[i].ArchiveIndex < newEntries[j].ArchiveIndex
+// layoutArchive returns the entries writeArchive writes for files, in the
+// same order. Each file starts on the entry alignment after the file before
+// it; when compacting, unmodified files that shared data in the original
+// archive share the first copy of it instead.
+func (p *Packer) layoutArchive(files []packedFile) []FileEntry {
+	type span struct{ offset, length uint32 }
+	shared := make(map[span]uint32) // original span -> new offset, when compacting
+
+	entries := make([]FileEntry, 0, len(files))
+	var offset uint32 = 0
+	for _, pf := range files {
+		entry := FileEntry{
+			Filename:     pf.name,
+			ArchiveIndex: pf.arcIndex,
+			FileIndex:    pf.fileIndex,
+			Length:       pf.size,
 		}
-		return newEntries[i].FileIndex < newEntries[j].FileIndex
-	})
 
-	// Create new index file (SYS5INI.BIN or similar)
-	return p.writeIndexFile(newEntries)
+		// Point entries that shared data at the copy already laid out
+		if p.opts.Compact && !pf.modified {
+			if newOffset, ok := shared[span{pf.origOffset, pf.origLength}]; ok {
+				entry.Offset = newOffset
+				entries = append(entries, entry)
+				continue
+			}
+		}
+
+		// Pad so this file starts on the requested boundary
+		if offset > 0 {
+			offset = alignUp(offset, p.opts.AlignEntries)
+		}
+		if !pf.modified {
+			shared[span{pf.origOffset, pf.origLength}] = offset
+		}
+
+		entry.Offset = offset
+		entries = append(entries, entry)
+		offset += pf.size
+	}
+
+	return entries
 }
 
-// writeIndexFile writes the archive index file.
-func (p *Packer) writeIndexFile(entries []FileEntry) error {
-	outPath := filepath.Join(p.opts.OutputDir, filepath.Base(p.original.FilePath))
+// writeArchive writes a single output ALF file with files at the offsets
+// layoutArchive gave them in entries. Verbose output goes to log.
+func (p *Packer) writeArchive(src ArchiveSource, files []packedFile, entries []FileEntry, log io.Writer, prog *progress) error {
+	outPath := filepath.Join(p.opts.OutputDir, src.Name)
 	if p.opts.Verbose {
-		fmt.Printf("Creating index file %s\n", outPath)
+		fmt.Fprintf(log, "Creating %s\n", outPath)
+	}
+
+	// Open original archive for reading unmodified files
+	origPath := filepath.Join(filepath.Dir(p.opts.OriginalBIN), src.Name)
+	origFile, err := os.Open(origPath)
+	if err != nil {
+		return fmt.Errorf("failed to open original archive %s: %w", origPath, err)
+	}
+	defer origFile.Close()
+
+	outFile, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output archive %s: %w", outPath, err)
+	}
+	defer outFile.Close()
+
+	var offset uint32 = 0
+	for i := range files {
+		pf := &files[i]
+		entry := &entries[i]
+
+		// Entries before the current offset share data already written
+		if entry.Offset < offset {
+			prog.add(pf.name)
+			continue
+		}
+
+		if err := padTo(outFile, &offset, entry.Offset); err != nil {
+			return err
+		}
+
+		var data []byte
+		if pf.modified {
+			// Read from modified file
+			data, err = os.ReadFile(pf.path)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", pf.path, err)
+			}
+			if uint32(len(data)) != pf.size {
+				return fmt.Errorf("%s changed size while packing (%d -> %d bytes)", pf.path, pf.size, len(data))
+			}
+		} else {
+			// Copy from original archive
+			data = make([]byte, pf.origLength)
+			if _, err := origFile.ReadAt(data, int64(pf.origOffset)); err != nil {
+				return fmt.Errorf("failed to read from original: %w", err)
+			}
+		}
+
+		if _, err := outFile.Write(data); err != nil {
+			return fmt.Errorf("failed to write to archive: %w", err)
+		}
+
+		if p.opts.Verbose && pf.added {
+			fmt.Fprintf(log, "  + %s (new)\n", pf.name)
+		} else if p.opts.Verbose && pf.modified {
+			fmt.Fprintf(log, "  + %s (modified)\n", pf.name)
+		}
+
+		offset += pf.size
+		prog.add(pf.name)
+	}
+
+	if !p.opts.Compact {
+		if err := writePadding(outFile, &offset, p.opts.AlignArchive); err != nil {
+			return err
+		}
+	} else if p.opts.Verbose {
+		if info, err := origFile.Stat(); err == nil {
+			fmt.Fprintf(log, "Compacted %s: %d -> %d bytes (%d saved)\n", src.Name, info.Size(), offset, info.Size()-int64(offset))
+		}
+	}
+
+	return nil
+}
+
+// writePadding writes zero bytes to advance offset to a multiple of align.
+func writePadding(f *os.File, offset *uint32, align int) error {
+	return padTo(f, offset, alignUp(*offset, align))
+}
+
+// padTo writes zero bytes to advance offset to target, if it is behind.
+func padTo(f *os.File, offset *uint32, target uint32) error {
+	if target <= *offset {
+		return nil
+	}
+	if _, err := f.Write(make([]byte, target-*offset)); err != nil {
+		return fmt.Errorf("failed to write padding: %w", err)
+	}
+	*offset = target
+	return nil
+}
+
+// indexFile is the index Pack writes. It is prepared before the archives
+// are written so its metadata can be compressed alongside them.
+type indexFile struct {
+	path string
+	data []byte // Complete contents, when known without compressing
+
+	unchanged bool // data is the original index, whose metadata is unchanged
+
+	header     []byte     // Original header in front of the size fields
+	metadata   []byte     // Metadata to compress (nil when data is set)
+	compressed []byte     // Set by compress
+	stats      lzss.Stats // Set by compress
+}
+
+// prepareIndexFile builds the index metadata for entries.
+func (p *Packer) prepareIndexFile(entries []FileEntry) (*indexFile, error) {
+	idx := &indexFile{path: filepath.Join(p.opts.OutputDir, filepath.Base(p.original.FilePath))}
+
+	// Append indexes number their archives after the base index's
+	if base := p.original.BaseArchives; base != 0 {
+		for i := range entries {
+			entries[i].ArchiveIndex += base
+		}
+	}
+
+	// The header is copied verbatim from the original index
+	orig, err := os.ReadFile(p.original.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original index: %w", err)
+	}
+
+	// S5IN stores its single archive's entries uncompressed in the index
+	if p.version == FormatS5 && !p.original.Header.IsCompressed() {
+		if len(p.original.Sources) != 1 {
+			return nil, fmt.Errorf("S5IN index must have exactly one archive, got %d", len(p.original.Sources))
+		}
+		if len(orig) < 0x200 {
+			return nil, fmt.Errorf("original index %s is missing its %d-byte header", p.original.FilePath, 0x200)
+		}
+		idx.data = buildS5UncompressedIndex(orig[:0x200], p.original.Sources[0].Name, entries)
+		return idx, nil
+	}
+
+	headerSize := metadataOffset(&p.original.Header)
+	if len(orig) < headerSize {
+		return nil, fmt.Errorf("original index %s is missing its %d-byte header", p.original.FilePath, headerSize)
 	}
 
 	// Build metadata
@@ -230,19 +584,57 @@ func (p *Packer) writeIndexFile(entries []FileEntry) error {
 		metadata = p.buildS4Metadata(entries)
 	}
 
-	// Compress metadata
-	compressed := lzss.Compress(metadata)
+	// Keep the original index byte for byte when the metadata is unchanged,
+	// since the compressor need not reproduce the original's output
+	if _, origMetadata, err := DecompressMetadata(orig); err == nil && bytes.Equal(origMetadata, metadata) {
+		idx.data = orig
+		idx.unchanged = true
+		return idx, nil
+	}
 
-	// Build full file
-	var buf []byte
+	idx.header = orig[:headerSize]
+	idx.metadata = metadata
+	return idx, nil
+}
 
-	if p.version == FormatS5 {
-		buf = p.buildS5IndexFile(metadata, compressed)
-	} else {
-		buf = p.buildS4IndexFile(metadata, compressed)
+// compress compresses the metadata of an index that needs it.
+func (idx *indexFile) compress() error {
+	compressed, stats := lzss.CompressStats(idx.metadata)
+	if err := lzss.VerifyCompressed(idx.metadata, compressed); err != nil {
+		return fmt.Errorf("failed to compress metadata: %w", err)
 	}
+	idx.compressed = compressed
+	idx.stats = stats
+	return nil
+}
 
-	return os.WriteFile(outPath, buf, 0644)
+// writeIndexFile writes the archive index file once its metadata has been
+// compressed.
+func (p *Packer) writeIndexFile(idx *indexFile) error {
+	if p.opts.Verbose {
+		fmt.Printf("Creating index file %s\n", idx.path)
+		if idx.unchanged {
+			fmt.Println("Metadata unchanged, copying original index")
+		}
+	}
+
+	if idx.data == nil {
+		compressed := idx.compressed
+		if p.opts.Verbose {
+			fmt.Printf("Metadata compression: %s\n", idx.stats)
+		}
+		if p.version == FormatS4 && len(compressed) >= len(idx.metadata) {
+			// S4 marks stored metadata by equal lengths in the sector header
+			compressed = idx.metadata
+		} else if idx.stats.OutputSize > idx.stats.InputSize {
+			fmt.Fprintf(os.Stderr, "Warning: metadata expanded when compressed (%s)\n", idx.stats)
+		}
+
+		// Build full file behind the original header
+		idx.data = buildIndexFile(idx.header, idx.metadata, compressed)
+	}
+
+	return os.WriteFile(idx.path, idx.data, 0644)
 }
 
 // buildS5Metadata builds the uncompressed metadata for S5 format.
@@ -329,22 +721,18 @@ func (p *Packer) buildS4Metadata(entries []FileEntry) []byte {
 	return buf
 }
 
-// buildS5IndexFile builds the complete S5 index file with header and compressed data.
-func (p *Packer) buildS5IndexFile(metadata, compressed []byte) []byte {
-	// Header (540 bytes) + CompressionInfo (12 bytes) + compressed data
-	size := S5HeaderSize + 12 + len(compressed)
-	buf := make([]byte, size)
-
-	// Copy original header
-	if p.original.Header.RawS5 != nil {
-		copy(buf[0:480], p.original.Header.RawS5.Signature[:])
-		copy(buf[480:540], p.original.Header.RawS5.Unknown[:])
-	}
-
-	// Compression info at 0x21C
-	pos := S5HeaderSize
-	binary.LittleEndian.PutUint32(buf[pos:], uint32(len(metadata)))   // Uncompressed size 1
-	binary.LittleEndian.PutUint32(buf[pos+4:], uint32(len(metadata))) // Uncompressed size 2
+// buildIndexFile appends the size fields and compressed metadata to a copy
+// of the original index header. The size fields (compression info for S5,
+// the sector header for S4) are the uncompressed size twice followed by the
+// compressed size. compressed may be metadata itself, which S4 then reads
+// as stored uncompressed.
+func buildIndexFile(header, metadata, compressed []byte) []byte {
+	pos := len(header)
+	buf := make([]byte, pos+12+len(compressed))
+	copy(buf, header)
+
+	binary.LittleEndian.PutUint32(buf[pos:], uint32(len(metadata)))     // Uncompressed size 1
+	binary.LittleEndian.PutUint32(buf[pos+4:], uint32(len(metadata)))   // Uncompressed size 2
 	binary.LittleEndian.PutUint32(buf[pos+8:], uint32(len(compressed))) // Compressed size
 	pos += 12
 
@@ -354,29 +742,19 @@ func (p *Packer) buildS5IndexFile(metadata, compressed []byte) []byte {
 	return buf
 }
 
-// buildS4IndexFile builds the complete S4 index file with header and compressed data.
-func (p *Packer) buildS4IndexFile(metadata, compressed []byte) []byte {
-	// Header (300 bytes) + SectorHeader (12 bytes) + compressed data
-	size := S4HeaderSize + 12 + len(compressed)
-	buf := make([]byte, size)
-
-	// Copy original header
-	if p.original.Header.RawS4 != nil {
-		copy(buf[0:240], p.original.Header.RawS4.Signature[:])
-		copy(buf[240:300], p.original.Header.RawS4.Unknown[:])
+// metadataOffset returns the offset of the size fields preceding the
+// compressed metadata of an S4IC/S4AC or S5IC/S5AC index, which is also the
+// length of the header in front of them.
+func metadataOffset(h *Header) int {
+	switch {
+	case h.Version == FormatS4 && h.IsAppend():
+		return 0x10C
+	case h.Version == FormatS4:
+		return S4HeaderSize
+	case h.IsAppend():
+		return 0x214
 	}
-
-	// Sector header at 0x12C
-	pos := S4HeaderSize
-	binary.LittleEndian.PutUint32(buf[pos:], uint32(len(metadata)))   // Original length
-	binary.LittleEndian.PutUint32(buf[pos+4:], uint32(len(metadata))) // Original length 2
-	binary.LittleEndian.PutUint32(buf[pos+8:], uint32(len(compressed))) // Compressed length
-	pos += 12
-
-	// Compressed data
-	copy(buf[pos:], compressed)
-
-	return buf
+	return 0x21C
 }
 
 // packedFile represents a file to be packed.
@@ -389,6 +767,7 @@ type packedFile struct {
 	origOffset uint32 // Original offset (if not modified)
 	origLength uint32 // Original length (if not modified)
 	modified   bool
+	added      bool // Not in the original index
 }
 
 // Close cleans up resources.
@@ -0,0 +1,339 @@
+package alf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPack packs inputDir against the index at indexPath into a new
+// directory and returns it.
+func testPack(tb testing.TB, indexPath, inputDir string, opts PackOptions) string {
+	tb.Helper()
+
+	opts.OutputDir = tb.TempDir()
+	opts.OriginalBIN = indexPath
+	packer, err := NewPacker(inputDir, opts)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer packer.Close()
+	if err := packer.LoadOriginal(indexPath); err != nil {
+		tb.Fatal(err)
+	}
+	if err := packer.Pack(); err != nil {
+		tb.Fatal(err)
+	}
+	return opts.OutputDir
+}
+
+// testExtract extracts every file of the index at indexPath into a new
+// directory and returns it.
+func testExtract(tb testing.TB, indexPath string) string {
+	tb.Helper()
+
+	out := tb.TempDir()
+	e, err := NewExtractor(indexPath, ExtractOptions{OutputDir: out})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer e.Close()
+	if err := e.Open(indexPath); err != nil {
+		tb.Fatal(err)
+	}
+	if err := e.Extract(); err != nil {
+		tb.Fatal(err)
+	}
+	return out
+}
+
+// assertSameFiles fails unless the files named in both directories have
+// identical contents.
+func assertSameFiles(tb testing.TB, want, got string, names ...string) {
+	tb.Helper()
+
+	for _, name := range names {
+		a, err := os.ReadFile(filepath.Join(want, name))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		b, err := os.ReadFile(filepath.Join(got, name))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if !bytes.Equal(a, b) {
+			tb.Errorf("%s differs (%d vs %d bytes)", name, len(a), len(b))
+		}
+	}
+}
+
+func TestPackDeterministicAcrossConcurrency(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := writeTestIndex(t, dir, "S5IC", testArchives(4, 20, 300))
+	input := testExtract(t, indexPath)
+
+	// A new file changes the metadata, so it is compressed while packing
+	if err := os.WriteFile(filepath.Join(input, "DATA3", "NEW.DAT"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"SYS5INI.BIN", "DATA1.ALF", "DATA2.ALF", "DATA3.ALF", "DATA4.ALF"}
+	serial := testPack(t, indexPath, input, PackOptions{Concurrency: 1})
+	for _, jobs := range []int{2, 4, 16} {
+		parallel := testPack(t, indexPath, input, PackOptions{Concurrency: jobs})
+		assertSameFiles(t, serial, parallel, names...)
+	}
+}
+
+func BenchmarkPack(b *testing.B) {
+	// Several large archives with many entries, so both the archive bodies
+	// and the metadata take noticeable time
+	dir := b.TempDir()
+	indexPath := writeTestIndex(b, dir, "S5IC", testArchives(6, 1500, 2048))
+	input := testExtract(b, indexPath)
+	if err := os.WriteFile(filepath.Join(input, "DATA1", "NEW.DAT"), []byte("new"), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, jobs := range []int{1, 4} {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			for b.Loop() {
+				testPack(b, indexPath, input, PackOptions{Concurrency: jobs})
+			}
+		})
+	}
+}
+
+func TestPackRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		sig      string
+		archives []testArchive
+		files    []string // index and archives, identical after an unchanged repack
+		changed  bool     // grow one file before packing
+	}{
+		{"S4IC", "S4IC", testArchives(2, 5, 100), []string{"SYS4INI.BIN", "DATA1.ALF", "DATA2.ALF"}, false},
+		{"S4IC changed", "S4IC", testArchives(2, 5, 100), []string{"SYS4INI.BIN", "DATA1.ALF", "DATA2.ALF"}, true},
+		{"S5IN", "S5IN", testArchives(1, 5, 100), []string{"SYS5INI.BIN", "DATA1.ALF"}, false},
+		{"S5IN changed", "S5IN", testArchives(1, 5, 100), []string{"SYS5INI.BIN", "DATA1.ALF"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			indexPath := writeTestIndex(t, dir, tt.sig, tt.archives)
+			input := testExtract(t, indexPath)
+
+			if !tt.changed {
+				out := testPack(t, indexPath, input, PackOptions{})
+				assertSameFiles(t, dir, out, tt.files...)
+				return
+			}
+
+			changed := filepath.Join(input, "DATA1", tt.archives[0].files[1].name)
+			if err := os.WriteFile(changed, bytes.Repeat([]byte("grown"), 100), 0644); err != nil {
+				t.Fatal(err)
+			}
+			out := testPack(t, indexPath, input, PackOptions{})
+			got := testExtract(t, filepath.Join(out, tt.files[0]))
+			assertSameFiles(t, input, got, testArchiveFiles(tt.archives)...)
+		})
+	}
+}
+
+// testArchiveFiles returns the extracted paths of every file of archives.
+func testArchiveFiles(archives []testArchive) []string {
+	var names []string
+	for _, arc := range archives {
+		dir := strings.TrimSuffix(arc.name, filepath.Ext(arc.name))
+		for _, f := range arc.files {
+			names = append(names, filepath.Join(dir, f.name))
+		}
+	}
+	return names
+}
+
+func TestPackNewFiles(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := writeTestIndex(t, dir, "S5IC", testArchives(2, 3, 40))
+	input := testExtract(t, indexPath)
+
+	body := []byte("a file that was not in the original index")
+	if err := os.WriteFile(filepath.Join(input, "DATA1", "NEW.DAT"), body, 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := testPack(t, indexPath, input, PackOptions{})
+
+	data, err := os.ReadFile(filepath.Join(out, "SYS5INI.BIN"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, entries, err := ParseIndexMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 7 {
+		t.Fatalf("%d entries, want 7", len(entries))
+	}
+	var added *FileEntry
+	for i := range entries {
+		if entries[i].Filename == "NEW.DAT" {
+			added = &entries[i]
+		}
+	}
+	if added == nil {
+		t.Fatal("NEW.DAT is not in the rebuilt index")
+	}
+	if added.ArchiveIndex != 0 || added.FileIndex != 6 {
+		t.Errorf("NEW.DAT in archive %d as file %d, want archive 0 as file 6", added.ArchiveIndex, added.FileIndex)
+	}
+
+	got := testExtract(t, filepath.Join(out, "SYS5INI.BIN"))
+	assertSameFiles(t, input, got, "DATA1/NEW.DAT", "DATA1/F0_0002.DAT", "DATA2/F1_0000.DAT")
+}
+
+func TestPackNewFileCollisions(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string // new files, relative to the extraction directory
+	}{
+		{"entry of another archive", []string{"DATA1/F1_0000.DAT"}},
+		{"entry of another archive, other case", []string{"DATA1/f1_0000.dat"}},
+		{"another new file", []string{"DATA1/NEW.DAT", "DATA2/new.dat"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			indexPath := writeTestIndex(t, dir, "S5IC", testArchives(2, 3, 40))
+			input := testExtract(t, indexPath)
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(input, name), []byte("new"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			packer, err := NewPacker(input, PackOptions{OutputDir: t.TempDir(), OriginalBIN: indexPath})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer packer.Close()
+			if err := packer.LoadOriginal(indexPath); err != nil {
+				t.Fatal(err)
+			}
+			if err := packer.Pack(); !errors.Is(err, ErrNameExists) {
+				t.Errorf("Pack = %v, want %v", err, ErrNameExists)
+			}
+		})
+	}
+}
+
+func TestPackArchiveLayout(t *testing.T) {
+	a := bytes.Repeat([]byte("a"), 10)
+	b := bytes.Repeat([]byte("b"), 20)
+	d := bytes.Repeat([]byte("d"), 5)
+	archives := []testArchive{{name: "DATA1.ALF", files: []testFile{
+		{name: "A.DAT", data: a},
+		{name: "B.DAT", data: b},
+		{name: "C.DAT", shares: "A.DAT"},
+		{name: "D.DAT", data: d},
+	}}}
+	pad := func(n int) []byte { return make([]byte, n) }
+
+	tests := []struct {
+		name string
+		opts PackOptions
+		want [][]byte
+	}{
+		// Padding comes before each entry, then the archive is padded to
+		// AlignArchive; shared data is written once per entry
+		{"aligned", PackOptions{AlignEntries: 16, AlignArchive: 64}, [][]byte{a, pad(6), b, pad(12), a, pad(6), d, pad(59)}},
+		{"unaligned", PackOptions{}, [][]byte{a, b, a, d}},
+		// Compact writes shared data once and leaves no trailing padding
+		{"compact", PackOptions{AlignEntries: 16, AlignArchive: 64, Compact: true}, [][]byte{a, pad(6), b, pad(12), d}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			indexPath := writeTestIndex(t, dir, "S5IC", archives)
+			original := testExtract(t, indexPath)
+
+			// Only files missing from the input are copied from the original
+			// archive, so only those can share data
+			input := testExtract(t, indexPath)
+			for _, name := range []string{"A.DAT", "C.DAT"} {
+				if err := os.Remove(filepath.Join(input, "DATA1", name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			out := testPack(t, indexPath, input, tt.opts)
+			got, err := os.ReadFile(filepath.Join(out, "DATA1.ALF"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := bytes.Join(tt.want, nil); !bytes.Equal(got, want) {
+				t.Errorf("archive = %q, want %q", got, want)
+			}
+
+			// Every entry reads back its original contents
+			assertSameFiles(t, original, testExtract(t, filepath.Join(out, "SYS5INI.BIN")),
+				"DATA1/A.DAT", "DATA1/B.DAT", "DATA1/C.DAT", "DATA1/D.DAT")
+		})
+	}
+}
+
+func TestPackKeepsIndexHeader(t *testing.T) {
+	tests := []struct {
+		sig        string
+		index      string
+		unknown    [2]int // range of the header's unknown bytes
+		headerSize int
+	}{
+		{"S4IC", "SYS4INI.BIN", [2]int{240, 300}, S4HeaderSize},
+		{"S5IC", "SYS5INI.BIN", [2]int{480, 540}, 0x21C},
+		{"S5IN", "SYS5INI.BIN", [2]int{480, 0x200}, 0x200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sig, func(t *testing.T) {
+			dir := t.TempDir()
+			indexPath := writeTestIndex(t, dir, tt.sig, testArchives(1, 3, 40))
+
+			// Fill the unknown header bytes, which the packer must carry over
+			orig, err := os.ReadFile(indexPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := tt.unknown[0]; i < tt.unknown[1]; i++ {
+				orig[i] = byte(i*7 + 1)
+			}
+			if err := os.WriteFile(indexPath, orig, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// A new file forces the metadata to be rebuilt
+			input := testExtract(t, indexPath)
+			if err := os.WriteFile(filepath.Join(input, "DATA1", "NEW.DAT"), []byte("new"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			out := testPack(t, indexPath, input, PackOptions{})
+
+			got, err := os.ReadFile(filepath.Join(out, tt.index))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(got, orig) {
+				t.Fatal("index was copied, not rebuilt")
+			}
+			if !bytes.Equal(got[:tt.headerSize], orig[:tt.headerSize]) {
+				t.Error("header region differs after repack")
+			}
+		})
+	}
+}
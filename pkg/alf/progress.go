@@ -0,0 +1,34 @@
+package alf
+
+import "sync"
+
+// ProgressFunc is called after each file is extracted or packed with the
+// number of files done so far, the total and the name of the file just
+// finished. Calls are serialized, so the function need not be safe for
+// concurrent use even though files are processed in parallel, but it
+// should return quickly since it holds up the other workers.
+type ProgressFunc func(done, total int, name string)
+
+// progress counts finished files and reports them to a ProgressFunc.
+type progress struct {
+	mu    sync.Mutex
+	fn    ProgressFunc
+	done  int
+	total int
+}
+
+// newProgress returns a counter reporting to fn, which may be nil.
+func newProgress(fn ProgressFunc, total int) *progress {
+	return &progress{fn: fn, total: total}
+}
+
+// add records that the file name has finished.
+func (p *progress) add(name string) {
+	if p.fn == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.fn(p.done, p.total, name)
+}
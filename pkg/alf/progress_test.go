@@ -0,0 +1,138 @@
+package alf
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// progressRecorder is a ProgressFunc recording its calls and whether any
+// overlapped.
+type progressRecorder struct {
+	tb         testing.TB
+	mu         sync.Mutex
+	inFlight   atomic.Int32
+	overlapped atomic.Bool
+	done       []int
+	totals     map[int]bool
+	names      []string
+}
+
+func newProgressRecorder(tb testing.TB) *progressRecorder {
+	return &progressRecorder{tb: tb, totals: make(map[int]bool)}
+}
+
+func (r *progressRecorder) progress(done, total int, name string) {
+	if r.inFlight.Add(1) > 1 {
+		r.overlapped.Store(true)
+	}
+	defer r.inFlight.Add(-1)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done = append(r.done, done)
+	r.totals[total] = true
+	r.names = append(r.names, name)
+}
+
+// check fails unless the recorded calls reported each of names once, counting
+// up to the total number of names one at a time.
+func (r *progressRecorder) check(names []string) {
+	r.tb.Helper()
+
+	if r.overlapped.Load() {
+		r.tb.Error("progress callback called concurrently")
+	}
+	if len(r.done) != len(names) {
+		r.tb.Fatalf("%d progress calls, want %d", len(r.done), len(names))
+	}
+	for i, done := range r.done {
+		if done != i+1 {
+			r.tb.Errorf("call %d reported %d done, want %d", i, done, i+1)
+		}
+	}
+	if len(r.totals) != 1 || !r.totals[len(names)] {
+		r.tb.Errorf("totals = %v, want only %d", r.totals, len(names))
+	}
+
+	got := append([]string{}, r.names...)
+	want := append([]string{}, names...)
+	sort.Strings(got)
+	sort.Strings(want)
+	for i := range want {
+		if got[i] != want[i] {
+			r.tb.Errorf("reported files %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestExtractProgress(t *testing.T) {
+	archives := testArchives(4, 10, 100)
+
+	var all, matching []string
+	for _, arc := range archives {
+		for _, f := range arc.files {
+			all = append(all, f.name)
+			if f.name[len(f.name)-5] == '1' {
+				matching = append(matching, f.name)
+			}
+		}
+	}
+
+	tests := []struct {
+		name        string
+		concurrency int
+		glob        string
+		want        []string
+	}{
+		{"serial", 1, "", all},
+		{"concurrent", 4, "", all},
+		{"filtered", 4, "*1.DAT", matching},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexPath := writeTestIndex(t, t.TempDir(), "S5IC", archives)
+			rec := newProgressRecorder(t)
+
+			e, err := NewExtractor(indexPath, ExtractOptions{
+				OutputDir:   t.TempDir(),
+				Glob:        tt.glob,
+				Concurrency: tt.concurrency,
+				OnProgress:  rec.progress,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer e.Close()
+			if err := e.Open(indexPath); err != nil {
+				t.Fatal(err)
+			}
+			if err := e.Extract(); err != nil {
+				t.Fatal(err)
+			}
+			rec.check(tt.want)
+		})
+	}
+}
+
+func TestPackProgress(t *testing.T) {
+	archives := testArchives(4, 10, 100)
+	var names []string
+	for _, arc := range archives {
+		for _, f := range arc.files {
+			names = append(names, f.name)
+		}
+	}
+
+	for _, concurrency := range []int{1, 4} {
+		indexPath := writeTestIndex(t, t.TempDir(), "S5IC", archives)
+		input := testExtract(t, indexPath)
+
+		rec := newProgressRecorder(t)
+		testPack(t, indexPath, input, PackOptions{Concurrency: concurrency, OnProgress: rec.progress})
+		rec.check(names)
+	}
+}
@@ -0,0 +1,143 @@
+package alf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// RemoveOptions configures removing entries from an index.
+type RemoveOptions struct {
+	OutputPath string // Output path for the modified index (default: overwrite the input index)
+	Verbose    bool   // Print progress
+}
+
+// RemoveEntries drops every entry whose filename matches one of names
+// (case-insensitively) from an S4IC/S4AC or S5IN/S5IC/S5AC index and writes
+// the new index. The DATA*.ALF files are not touched; the removed files'
+// bytes stay in them, unreferenced. An archive left without entries stays
+// listed in the index so the remaining archive numbers do not change.
+//
+// The remaining entries of each archive are renumbered consecutively in
+// their original order, starting from the lowest FileIndex the archive had.
+// A name that matches no entry is an error, reported before anything is
+// written.
+func RemoveEntries(indexPath string, names []string, opts RemoveOptions) error {
+	if opts.OutputPath == "" {
+		opts.OutputPath = indexPath
+	}
+
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	header, _, entries, err := ParseIndexMetadata(data)
+	if err != nil {
+		return err
+	}
+
+	remove := make(map[string]bool, len(names))
+	for _, name := range names {
+		remove[strings.ToLower(name)] = false
+	}
+	keep := make([]int, 0, len(entries))
+	for i, entry := range entries {
+		key := strings.ToLower(entry.Filename)
+		if _, ok := remove[key]; ok {
+			remove[key] = true
+			if opts.Verbose {
+				fmt.Printf("  - %s\n", entry.Filename)
+			}
+			continue
+		}
+		keep = append(keep, i)
+	}
+	for _, name := range names {
+		if !remove[strings.ToLower(name)] {
+			return fmt.Errorf("%w: %s", ErrFileNotFound, name)
+		}
+	}
+
+	var out []byte
+	if header.Version == FormatS5 && !header.IsCompressed() {
+		out = removeS5UncompressedEntries(data, keep)
+	} else {
+		_, metadata, err := DecompressMetadata(data)
+		if err != nil {
+			return err
+		}
+		metadata = removeMetadataEntries(metadata, header.Version, entries, keep)
+		if out, err = rebuildIndex(data, header, metadata); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(opts.OutputPath, out, 0644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	if opts.Verbose {
+		fmt.Printf("Files: %d -> %d\n", len(entries), len(keep))
+	}
+	return nil
+}
+
+// removeMetadataEntries returns decompressed metadata holding only the
+// entries listed in keep, renumbered as described for RemoveEntries. The
+// archive names and the kept records are copied byte for byte.
+func removeMetadataEntries(metadata []byte, version FormatVersion, entries []FileEntry, keep []int) []byte {
+	nameSize, fileEntrySize, arcEntrySize := 0x80, S5FileEntrySize, S5ArchiveEntrySize
+	if version == FormatS4 {
+		nameSize, fileEntrySize, arcEntrySize = 0x40, S4FileEntrySize, S4ArchiveEntrySize
+	}
+	arcCount := int(binary.LittleEndian.Uint32(metadata))
+	base := 4 + arcCount*arcEntrySize
+
+	// Each archive's numbering starts where it did before
+	next := make(map[uint32]uint32)
+	for _, entry := range entries {
+		if first, ok := next[entry.ArchiveIndex]; !ok || entry.FileIndex < first {
+			next[entry.ArchiveIndex] = entry.FileIndex
+		}
+	}
+
+	// Renumber in the order of the original file indexes
+	order := make([]int, len(keep))
+	copy(order, keep)
+	sort.SliceStable(order, func(a, b int) bool {
+		return entries[order[a]].FileIndex < entries[order[b]].FileIndex
+	})
+	fileIndex := make(map[int]uint32, len(keep))
+	for _, i := range order {
+		arc := entries[i].ArchiveIndex
+		fileIndex[i] = next[arc]
+		next[arc]++
+	}
+
+	out := make([]byte, base+4, base+4+len(keep)*fileEntrySize)
+	copy(out, metadata[:base])
+	binary.LittleEndian.PutUint32(out[base:], uint32(len(keep)))
+	for _, i := range keep {
+		pos := base + 4 + i*fileEntrySize
+		record := metadata[pos : pos+fileEntrySize]
+		out = append(out, record...)
+		binary.LittleEndian.PutUint32(out[len(out)-fileEntrySize+nameSize+4:], fileIndex[i])
+	}
+	return out
+}
+
+// removeS5UncompressedEntries returns an S5IN index holding only the
+// entries listed in keep. S5IN records have no file index to renumber.
+func removeS5UncompressedEntries(data []byte, keep []int) []byte {
+	out := make([]byte, 0x404, 0x404+len(keep)*S5FileEntrySize)
+	copy(out, data[:0x404])
+	binary.LittleEndian.PutUint32(out[0x400:], uint32(len(keep)))
+	for _, i := range keep {
+		pos := 0x404 + i*S5FileEntrySize
+		out = append(out, data[pos:pos+S5FileEntrySize]...)
+	}
+	return out
+}
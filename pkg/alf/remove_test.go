@@ -0,0 +1,72 @@
+package alf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRemoveEntries(t *testing.T) {
+	// DATA2 loses all its files, DATA1 and DATA3 one each
+	remove := []string{"f0_0001.dat", "F1_0000.DAT", "F1_0001.DAT", "F1_0002.DAT", "F2_0000.DAT"}
+	wantFiles := []string{"DATA1/F0_0000.DAT", "DATA1/F0_0002.DAT", "DATA3/F2_0001.DAT", "DATA3/F2_0002.DAT"}
+	wantArchives := []uint32{0, 0, 2, 2}
+	wantFileIndexes := []uint32{0, 1, 6, 7}
+
+	for _, sig := range []string{"S4IC", "S5IC"} {
+		t.Run(sig, func(t *testing.T) {
+			dir := t.TempDir()
+			archives := testArchives(3, 3, 48)
+			indexPath := writeTestIndex(t, dir, sig, archives)
+			original := testExtract(t, indexPath)
+
+			if err := RemoveEntries(indexPath, append(remove, "MISSING.DAT"), RemoveOptions{}); !errors.Is(err, ErrFileNotFound) {
+				t.Fatalf("RemoveEntries with unknown name = %v, want %v", err, ErrFileNotFound)
+			}
+			if err := RemoveEntries(indexPath, remove, RemoveOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(indexPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, arcNames, entries, err := ParseIndexMetadata(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"DATA1.ALF", "DATA2.ALF", "DATA3.ALF"}; !reflect.DeepEqual(arcNames, want) {
+				t.Errorf("archives = %v, want %v (an emptied archive stays listed)", arcNames, want)
+			}
+
+			var names, wantNames []string
+			for _, path := range wantFiles {
+				wantNames = append(wantNames, filepath.Base(path))
+			}
+			var arcs, fileIndexes []uint32
+			for _, entry := range entries {
+				names = append(names, entry.Filename)
+				arcs = append(arcs, entry.ArchiveIndex)
+				fileIndexes = append(fileIndexes, entry.FileIndex)
+			}
+			if !reflect.DeepEqual(names, wantNames) {
+				t.Errorf("entries = %v, want %v", names, wantNames)
+			}
+			if !reflect.DeepEqual(arcs, wantArchives) {
+				t.Errorf("archive indexes = %v, want %v", arcs, wantArchives)
+			}
+			if !reflect.DeepEqual(fileIndexes, wantFileIndexes) {
+				t.Errorf("file indexes = %v, want %v", fileIndexes, wantFileIndexes)
+			}
+
+			// The remaining files still extract from their untouched archives
+			got := testExtract(t, indexPath)
+			assertSameFiles(t, original, got, wantFiles...)
+			if _, err := os.Stat(filepath.Join(got, "DATA1", "F0_0001.DAT")); !os.IsNotExist(err) {
+				t.Error("removed file was extracted")
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package alf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"agetools/pkg/lzss"
+)
+
+// ReplaceOptions configures replacing files inside existing archives.
+type ReplaceOptions struct {
+	OutputPath string // Output path for the modified index (default: overwrite the input index)
+	Verbose    bool   // Print progress
+}
+
+// ReplaceFiles replaces the contents of existing entries of an S4IC/S4AC or
+// S5IN/S5IC/S5AC index. Each DATA*.ALF holding a replaced file is rewritten next
+// to OutputPath with the new data inline: untouched files are copied from
+// the original archive and every entry after a file that grew or shrank is
+// moved, keeping the alignment the archive was packed with. The entries'
+// offsets and lengths are then patched in the index, leaving all other
+// metadata bytes as they were. Archives without replaced files are neither
+// read nor written.
+//
+// The archives and the index are all written under temporary names first
+// and renamed into place only once every one of them is complete, the index
+// last, so a failure part way leaves the existing files as they were.
+//
+// Replacement names are matched case-insensitively, as the engine does. A
+// name that is not in the index or that matches several entries is an error,
+// reported before anything is written. So is an OutputPath other than
+// indexPath in the input's directory, since rewriting the archives there
+// would leave the input index pointing at moved data (ErrArchiveInUse).
+func ReplaceFiles(indexPath string, replacements map[string][]byte, opts ReplaceOptions) error {
+	if opts.OutputPath == "" {
+		opts.OutputPath = indexPath
+	}
+	srcDir := filepath.Dir(indexPath)
+	outDir := filepath.Dir(opts.OutputPath)
+	if samePath(srcDir, outDir) && !samePath(indexPath, opts.OutputPath) {
+		return fmt.Errorf("%w: %s is next to %s", ErrArchiveInUse, opts.OutputPath, indexPath)
+	}
+
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	header, names, entries, err := ParseIndexMetadata(data)
+	if err != nil {
+		return err
+	}
+
+	// Resolve every replacement to exactly one entry
+	byName := make(map[string][]int, len(entries))
+	for i, entry := range entries {
+		key := strings.ToLower(entry.Filename)
+		byName[key] = append(byName[key], i)
+	}
+	replaced := make(map[int][]byte, len(replacements))
+	for name, body := range replacements {
+		matches := byName[strings.ToLower(name)]
+		switch {
+		case len(matches) == 0:
+			return fmt.Errorf("%w: %s", ErrFileNotFound, name)
+		case len(matches) > 1:
+			return fmt.Errorf("%w: %s", ErrAmbiguous, name)
+		}
+		i := matches[0]
+		if int(entries[i].ArchiveIndex) >= len(names) {
+			return fmt.Errorf("archive index %d out of range for %s", entries[i].ArchiveIndex, entries[i].Filename)
+		}
+		replaced[i] = body
+	}
+
+	// Rewrite each archive that holds a replaced file
+	archives := make(map[uint32][]int)
+	for i := range replaced {
+		archives[entries[i].ArchiveIndex] = nil
+	}
+	for i, entry := range entries {
+		if _, ok := archives[entry.ArchiveIndex]; ok {
+			archives[entry.ArchiveIndex] = append(archives[entry.ArchiveIndex], i)
+		}
+	}
+
+	// Stage every archive and the index under temporary names first, so a
+	// failure leaves the existing files untouched
+	var staged []stagedFile
+	defer func() {
+		for _, f := range staged {
+			os.Remove(f.tmp)
+		}
+	}()
+
+	for _, arcIdx := range slices.Sorted(maps.Keys(archives)) {
+		name := names[arcIdx]
+		if opts.Verbose {
+			fmt.Printf("Rewriting %s\n", name)
+		}
+		dst := filepath.Join(outDir, name)
+		tmp, err := rewriteArchive(filepath.Join(srcDir, name), dst, entries, archives[arcIdx], replaced, opts.Verbose)
+		if err != nil {
+			return fmt.Errorf("failed to rewrite %s: %w", name, err)
+		}
+		staged = append(staged, stagedFile{tmp: tmp, dst: dst})
+	}
+
+	out, err := patchIndexEntries(data, header, entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := writeTempFile(outDir, out)
+	if err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	staged = append(staged, stagedFile{tmp: tmp, dst: opts.OutputPath})
+
+	// Move the archives into place, then the index that points into them
+	for _, f := range staged {
+		if err := os.Rename(f.tmp, f.dst); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", f.dst, err)
+		}
+	}
+
+	if opts.Verbose {
+		fmt.Printf("Replaced %d files in %d archives\n", len(replaced), len(archives))
+	}
+	return nil
+}
+
+// samePath reports whether a and b name the same file or directory. Paths
+// that do not exist yet are compared by their absolute form.
+func samePath(a, b string) bool {
+	sa, errA := os.Stat(a)
+	sb, errB := os.Stat(b)
+	if errA == nil && errB == nil {
+		return os.SameFile(sa, sb)
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// stagedFile is a file written under a temporary name, to be renamed to dst.
+type stagedFile struct {
+	tmp, dst string
+}
+
+// writeTempFile writes data to a new temporary file in dir and returns its
+// name. The file is removed again on error.
+func writeTempFile(dir string, data []byte) (string, error) {
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := finishTempFile(tmp); err != nil {
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// finishTempFile gives a temporary file the permissions of a regular output
+// file and closes it, removing it on error.
+func finishTempFile(tmp *os.File) error {
+	err := tmp.Chmod(0644)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// rewriteArchive writes the entries listed in members from the archive at
+// srcPath to a temporary file next to dstPath, substituting the replaced
+// bodies, and updates their offsets and lengths. It returns the name of the
+// temporary file, which the caller renames to dstPath; srcPath and dstPath
+// may be the same.
+func rewriteArchive(srcPath, dstPath string, entries []FileEntry, members []int, replaced map[int][]byte, verbose bool) (string, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dstPath), ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	written := false
+	defer func() {
+		if !written {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	// Keep the files in their original order and alignment
+	sort.SliceStable(members, func(a, b int) bool {
+		return entries[members[a]].Offset < entries[members[b]].Offset
+	})
+	layout := make([]FileEntry, len(members))
+	for k, i := range members {
+		layout[k] = entries[i]
+	}
+	align := DetectEntryAlignment(layout)
+
+	type span struct{ offset, length uint32 }
+	copied := make(map[span]uint32) // original span -> new offset, for shared data
+	var offset uint32
+	for _, i := range members {
+		entry := &entries[i]
+
+		if body, ok := replaced[i]; ok {
+			if err := writePadding(tmp, &offset, align); err != nil {
+				return "", err
+			}
+			if _, err := tmp.Write(body); err != nil {
+				return "", err
+			}
+			if verbose {
+				fmt.Printf("  + %s (%d -> %d bytes)\n", entry.Filename, entry.Length, len(body))
+			}
+			entry.Offset, entry.Length = offset, uint32(len(body))
+			offset += entry.Length
+			continue
+		}
+
+		old := span{entry.Offset, entry.Length}
+		if newOffset, ok := copied[old]; ok {
+			entry.Offset = newOffset
+			continue
+		}
+
+		if err := writePadding(tmp, &offset, align); err != nil {
+			return "", err
+		}
+		r := io.NewSectionReader(src, int64(entry.Offset), int64(entry.Length))
+		if _, err := io.CopyN(tmp, r, int64(entry.Length)); err != nil {
+			return "", fmt.Errorf("failed to copy %s: %w", entry.Filename, err)
+		}
+		copied[old] = offset
+		entry.Offset = offset
+		offset += entry.Length
+	}
+
+	written = true
+	if err := finishTempFile(tmp); err != nil {
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// patchIndexEntries returns the index data with the offset and length of
+// every entry set from entries, which must be in index order. All other
+// metadata bytes are preserved.
+func patchIndexEntries(data []byte, header *Header, entries []FileEntry) ([]byte, error) {
+	if header.Version == FormatS5 && !header.IsCompressed() {
+		out := slices.Clone(data)
+		for i, entry := range entries {
+			pos := 0x404 + i*S5FileEntrySize
+			binary.LittleEndian.PutUint32(out[pos+0x88:], entry.Offset)
+			binary.LittleEndian.PutUint32(out[pos+0x8C:], entry.Length)
+		}
+		return out, nil
+	}
+
+	_, metadata, err := DecompressMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+	metadata = slices.Clone(metadata)
+
+	nameSize, fileEntrySize, arcEntrySize := 0x80, S5FileEntrySize, S5ArchiveEntrySize
+	if header.Version == FormatS4 {
+		nameSize, fileEntrySize, arcEntrySize = 0x40, S4FileEntrySize, S4ArchiveEntrySize
+	}
+	arcCount := int(binary.LittleEndian.Uint32(metadata))
+	base := 4 + arcCount*arcEntrySize + 4
+	for i, entry := range entries {
+		pos := base + i*fileEntrySize + nameSize
+		binary.LittleEndian.PutUint32(metadata[pos+8:], entry.Offset)
+		binary.LittleEndian.PutUint32(metadata[pos+12:], entry.Length)
+	}
+
+	return rebuildIndex(data, header, metadata)
+}
+
+// rebuildIndex compresses metadata and places it after the header of the
+// original index data, reusing the header bytes verbatim. Any bytes stored
+// after the original compressed block are kept after the new one.
+func rebuildIndex(data []byte, header *Header, metadata []byte) ([]byte, error) {
+	infoOffset := metadataOffset(header)
+	if len(data) < infoOffset+12 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	compEnd := infoOffset + 12 + int(binary.LittleEndian.Uint32(data[infoOffset+8:]))
+	if compEnd > len(data) {
+		return nil, fmt.Errorf("compressed data exceeds file size")
+	}
+
+	compressed, err := lzss.CompressVerified(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress metadata: %w", err)
+	}
+	if header.Version == FormatS4 && len(compressed) >= len(metadata) {
+		// S4 marks stored metadata by equal lengths in the sector header
+		compressed = metadata
+	}
+
+	return append(buildIndexFile(data[:infoOffset], metadata, compressed), data[compEnd:]...), nil
+}
@@ -0,0 +1,127 @@
+package alf
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaceFilesOutputPath(t *testing.T) {
+	body := []byte("replaced and longer than before")
+
+	tests := []struct {
+		name    string
+		output  func(dir string) string
+		wantErr error
+	}{
+		{"in place", func(string) string { return "" }, nil},
+		{"same path", func(dir string) string { return filepath.Join(dir, "SYS5INI.BIN") }, nil},
+		{"other directory", func(string) string { return filepath.Join(t.TempDir(), "SYS5INI.BIN") }, nil},
+		{"renamed next to input", func(dir string) string { return filepath.Join(dir, "PATCHED.BIN") }, ErrArchiveInUse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			indexPath := writeTestIndex(t, dir, "S5IC", testArchives(2, 3, 64))
+			original := testExtract(t, indexPath)
+
+			output := tt.output(dir)
+			err := ReplaceFiles(indexPath, map[string][]byte{"F0_0001.DAT": body}, ReplaceOptions{OutputPath: output})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReplaceFiles = %v, want %v", err, tt.wantErr)
+			}
+			if output == "" {
+				output = indexPath
+			}
+
+			// The input index must still extract its original files
+			if tt.wantErr != nil || !samePath(output, indexPath) {
+				assertSameFiles(t, original, testExtract(t, indexPath), "DATA1/F0_0001.DAT", "DATA1/F0_0002.DAT")
+			}
+			if tt.wantErr != nil {
+				if _, err := os.Stat(output); !os.IsNotExist(err) {
+					t.Errorf("refused replace wrote %s", output)
+				}
+				return
+			}
+
+			// Untouched archives are not written, so supply DATA2 as the
+			// game directory would
+			if !samePath(output, indexPath) {
+				arc, err := os.ReadFile(filepath.Join(dir, "DATA2.ALF"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(filepath.Dir(output), "DATA2.ALF"), arc, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got := testExtract(t, output)
+			data, err := os.ReadFile(filepath.Join(got, "DATA1", "F0_0001.DAT"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, body) {
+				t.Errorf("replaced file = %q, want %q", data, body)
+			}
+			assertSameFiles(t, original, got, "DATA1/F0_0000.DAT", "DATA1/F0_0002.DAT", "DATA2/F1_0000.DAT")
+		})
+	}
+}
+
+func TestReplaceFilesFailureLeavesFilesUntouched(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := writeTestIndex(t, dir, "S5IC", testArchives(3, 3, 64))
+
+	// DATA3 ends before its last file, so rewriting it fails after DATA1
+	// and DATA2 have been written
+	data3 := filepath.Join(dir, "DATA3.ALF")
+	arc, err := os.ReadFile(data3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(data3, arc[:len(arc)-10], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	before := make(map[string][]byte)
+	for _, name := range []string{"SYS5INI.BIN", "DATA1.ALF", "DATA2.ALF", "DATA3.ALF"} {
+		if before[name], err = os.ReadFile(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replacements := map[string][]byte{
+		"F0_0000.DAT": []byte("a longer first file"),
+		"F1_0000.DAT": []byte("another longer first file"),
+		"F2_0000.DAT": []byte("and a third"),
+	}
+	if err := ReplaceFiles(indexPath, replacements, ReplaceOptions{}); err == nil {
+		t.Fatal("ReplaceFiles succeeded with a truncated archive")
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(before) {
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		t.Errorf("directory holds %v after the failure, want only the original files", names)
+	}
+	for name, want := range before {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s changed although the replace failed", name)
+		}
+	}
+}
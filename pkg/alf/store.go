@@ -0,0 +1,81 @@
+package alf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ExtractToStore writes the body of every selected file to storeDir under
+// the hex SHA-256 of its content, so identical files across archives and
+// game versions are stored once. It returns a map from each filename to its
+// hash. Bodies already present in the store are not written again.
+//
+// Files are stored exactly as they are in the archive; NormalizeNewlines
+// does not apply. The Filter, Glob, Regex and Since options select files as
+// in Extract.
+func (e *Extractor) ExtractToStore(storeDir string) (map[string]string, error) {
+	entries, err := e.selectedEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	prog := newProgress(e.opts.OnProgress, len(entries))
+	hashes := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if int(entry.ArchiveIndex) >= len(e.archive.Sources) {
+			return nil, fmt.Errorf("archive index %d out of range for %s", entry.ArchiveIndex, entry.Filename)
+		}
+		src := e.archive.Sources[entry.ArchiveIndex]
+
+		data := make([]byte, entry.Length)
+		if _, err := src.Reader.ReadAt(data, int64(entry.Offset)); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Filename, err)
+		}
+
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		hashes[entry.Filename] = hash
+
+		if err := writeStoreObject(filepath.Join(storeDir, hash), data); err != nil {
+			return nil, fmt.Errorf("failed to store %s: %w", entry.Filename, err)
+		}
+
+		if e.opts.Verbose {
+			fmt.Printf("\t%s %s\n", hash, entry.Filename)
+		}
+		prog.add(entry.Filename)
+	}
+
+	return hashes, nil
+}
+
+// writeStoreObject writes data to path unless it already exists. The data is
+// written to a temporary file first so an interrupted run never leaves a
+// truncated object under its final name.
+func writeStoreObject(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,80 @@
+package alf
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// NewlineMode selects how line endings of text files are rewritten on extraction.
+type NewlineMode int
+
+const (
+	NewlinesKeep NewlineMode = iota // Write files unchanged
+	NewlinesLF                      // Convert CRLF and CR to LF
+	NewlinesCRLF                    // Convert LF and CR to CRLF
+)
+
+// ParseNewlineMode parses "keep", "lf" or "crlf" (case-insensitive).
+func ParseNewlineMode(s string) (NewlineMode, error) {
+	switch strings.ToLower(s) {
+	case "", "keep":
+		return NewlinesKeep, nil
+	case "lf":
+		return NewlinesLF, nil
+	case "crlf":
+		return NewlinesCRLF, nil
+	default:
+		return NewlinesKeep, fmt.Errorf("unknown newline mode %q (expected keep, lf or crlf)", s)
+	}
+}
+
+// isTextFile reports whether filename has one of the given extensions.
+// Extensions are compared case-insensitively, with or without a leading dot.
+func isTextFile(filename string, extensions []string) bool {
+	ext := filepath.Ext(filename)
+	if ext == "" {
+		return false
+	}
+	for _, e := range extensions {
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeNewlines rewrites the line endings of data. Data containing a NUL
+// byte is returned unchanged: it is binary, or UTF-16 text whose newlines are
+// two bytes wide. Shift-JIS and UTF-8 never use CR or LF inside a character,
+// so those encodings are safe to convert.
+func normalizeNewlines(data []byte, mode NewlineMode) []byte {
+	if mode == NewlinesKeep || bytes.IndexByte(data, 0) >= 0 {
+		return data
+	}
+
+	newline := []byte("\n")
+	if mode == NewlinesCRLF {
+		newline = []byte("\r\n")
+	}
+
+	out := make([]byte, 0, len(data)+len(data)/32)
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '\r':
+			if i+1 < len(data) && data[i+1] == '\n' {
+				i++
+			}
+			out = append(out, newline...)
+		case '\n':
+			out = append(out, newline...)
+		default:
+			out = append(out, data[i])
+		}
+	}
+	return out
+}
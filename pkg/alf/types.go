@@ -3,8 +3,10 @@ package alf
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
 // Format version constants
@@ -126,6 +128,15 @@ type S4SectorHeader struct {
 	Length          uint32 // Compressed size
 }
 
+// Validate checks that the duplicated uncompressed size fields agree.
+func (h *S4SectorHeader) Validate() error {
+	if h.OriginalLength != h.OriginalLength2 {
+		return fmt.Errorf("%w: OriginalLength=%d, OriginalLength2=%d",
+			ErrLengthMismatch, h.OriginalLength, h.OriginalLength2)
+	}
+	return nil
+}
+
 // CompressionInfo contains compression metadata for S5IC/S5AC formats.
 // Located at offset 0x214 (append) or 0x21C (normal).
 type CompressionInfo struct {
@@ -134,6 +145,15 @@ type CompressionInfo struct {
 	CompSize    uint32 // Compressed data size
 }
 
+// Validate checks that the two uncompressed size fields agree.
+func (c *CompressionInfo) Validate() error {
+	if c.UncompSize1 != c.UncompSize2 {
+		return fmt.Errorf("%w: UncompSize1=%d, UncompSize2=%d",
+			ErrLengthMismatch, c.UncompSize1, c.UncompSize2)
+	}
+	return nil
+}
+
 // FileEntry represents a single file entry in the archive metadata.
 // S4: 80 bytes (0x50) - filename 64 bytes UTF-8
 // S5: 144 bytes (0x90) - filename 128 bytes UTF-16LE
@@ -147,9 +167,14 @@ type FileEntry struct {
 
 // ArchiveSource holds information about a source archive file (the .alf files).
 type ArchiveSource struct {
-	Name   string   // Archive filename
-	Path   string   // Full path to archive
-	Handle *os.File // Open file handle
+	Name   string      // Archive filename
+	Path   string      // Full path to archive (empty for in-memory archives)
+	Handle *os.File    // Open file handle (nil for in-memory archives)
+	Reader io.ReaderAt // Archive contents, read through Handle or from memory
+
+	// Missing marks an archive that could not be found when the index was
+	// opened with SkipMissingArchives. Its Handle and Reader are nil.
+	Missing bool
 }
 
 // Archive represents a complete ALF archive with all metadata and entries.
@@ -158,6 +183,27 @@ type Archive struct {
 	Sources  []ArchiveSource // Source .alf files
 	Entries  []FileEntry     // All file entries
 	FilePath string          // Path to the index file (SYS4INI.BIN, SYS5INI.BIN, or APPENDxx.AAI)
+
+	// BaseArchives is the number of archives in the base index that an
+	// append index (S4AC/S5AC) numbered its archives after. It is zero when
+	// the entries already index Sources directly.
+	BaseArchives uint32
+
+	// SizeMismatch describes the disagreeing uncompressed size fields that
+	// ExtractOptions.Tolerant accepted, and which size was used. It is nil
+	// when the fields agree.
+	SizeMismatch error
+}
+
+// MissingSources returns the names of the archives marked Missing.
+func (a *Archive) MissingSources() []string {
+	var names []string
+	for _, src := range a.Sources {
+		if src.Missing {
+			names = append(names, src.Name)
+		}
+	}
+	return names
 }
 
 // Close closes all open archive file handles.
@@ -169,6 +215,28 @@ func (a *Archive) Close() {
 	}
 }
 
+// findEntry returns the index of the first entry named filename, compared
+// case-insensitively as the engine does, or -1.
+func (a *Archive) findEntry(filename string) int {
+	for i := range a.Entries {
+		if strings.EqualFold(a.Entries[i].Filename, filename) {
+			return i
+		}
+	}
+	return -1
+}
+
+// SourceOf returns the name of the source archive that filename would be
+// extracted from. ok is false if the file is not in the index or its
+// archive index is out of range.
+func (a *Archive) SourceOf(filename string) (archiveName string, ok bool) {
+	i := a.findEntry(filename)
+	if i < 0 || int(a.Entries[i].ArchiveIndex) >= len(a.Sources) {
+		return "", false
+	}
+	return a.Sources[a.Entries[i].ArchiveIndex].Name, true
+}
+
 // DetectFormat detects the format version from raw file data.
 // Returns FormatS4 or FormatS5 based on the magic bytes.
 func DetectFormat(data []byte) (FormatVersion, error) {
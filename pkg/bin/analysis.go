@@ -0,0 +1,221 @@
+package bin
+
+import "sort"
+
+// ExternalTargets returns the distinct raw values of control-flow arguments
+// that did not resolve to an in-code label, sorted ascending.
+// These are typically addresses of engine routines invoked by the script.
+func (s *Script) ExternalTargets() []uint32 {
+	seen := make(map[uint32]bool)
+	for i := range s.Instructions {
+		instr := &s.Instructions[i]
+		if !IsControlFlow(instr.Opcode) {
+			continue
+		}
+		for j := range instr.Arguments {
+			if IsLabelArgument(instr, j) && !instr.Arguments[j].IsLabel {
+				seen[instr.Arguments[j].RawValue] = true
+			}
+		}
+	}
+
+	result := make([]uint32, 0, len(seen))
+	for v := range seen {
+		result = append(result, v)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// FunctionEntries returns the offsets of all labels targeted by a call
+// instruction, sorted ascending.
+func (s *Script) FunctionEntries() []int {
+	seen := make(map[int]bool)
+	for i := range s.Instructions {
+		instr := &s.Instructions[i]
+		if instr.Opcode != 0x8F || len(instr.Arguments) == 0 || !instr.Arguments[0].IsLabel {
+			continue
+		}
+		seen[s.Header.GetLength()+int(instr.Arguments[0].RawValue)*4] = true
+	}
+
+	result := make([]int, 0, len(seen))
+	for off := range seen {
+		result = append(result, off)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// StringByteRange returns the file offset and length of the encoded string
+// referenced by the given argument of the instruction at instrOffset.
+// The length covers the XOR'd characters, the terminator and the alignment
+// padding, matching the layout produced by the assembler.
+func (s *Script) StringByteRange(instrOffset, argIndex int) (start, length int, ok bool) {
+	idx := s.instructionIndex(instrOffset)
+	if idx < 0 {
+		return 0, 0, false
+	}
+
+	instr := &s.Instructions[idx]
+	if argIndex < 0 || argIndex >= len(instr.Arguments) || instr.Arguments[argIndex].Type != ArgString {
+		return 0, 0, false
+	}
+
+	start = s.Header.GetLength() + int(instr.Arguments[argIndex].RawValue)*4
+	if start >= len(s.RawData) {
+		return 0, 0, false
+	}
+
+	// Padding always advances to the next 4-byte boundary
+	end := stringEnd(s.RawData, start, s.Header.Version)
+	if end > len(s.RawData) {
+		end = len(s.RawData)
+	}
+
+	return start, end - start, true
+}
+
+// EntryPoints returns the instruction offsets listed in the opcode 0x71 table
+// (Table1), sorted ascending. Scripts can be entered at any of these points,
+// so they should be treated as roots by reachability analysis.
+func (s *Script) EntryPoints() []int {
+	seen := make(map[int]bool)
+	for _, v := range s.Tables[0] {
+		off := s.Header.GetLength() + int(v)*4
+		if s.instructionIndex(off) >= 0 {
+			seen[off] = true
+		}
+	}
+
+	result := make([]int, 0, len(seen))
+	for off := range seen {
+		result = append(result, off)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// instructionIndex returns the index of the instruction starting at offset,
+// or -1. Instructions are kept in ascending offset order.
+func (s *Script) instructionIndex(offset int) int {
+	idx := sort.Search(len(s.Instructions), func(i int) bool {
+		return s.Instructions[i].Offset >= offset
+	})
+	if idx >= len(s.Instructions) || s.Instructions[idx].Offset != offset {
+		return -1
+	}
+	return idx
+}
+
+// instructionContaining returns the index of the instruction whose bytes
+// include offset, or -1 if offset is outside the code.
+func (s *Script) instructionContaining(offset int) int {
+	i := sort.Search(len(s.Instructions), func(i int) bool {
+		return s.Instructions[i].Offset > offset
+	}) - 1
+	if i < 0 || offset >= s.Instructions[i].Offset+s.Instructions[i].Size() {
+		return -1
+	}
+	return i
+}
+
+// InstructionAtOffset returns the instruction whose bytes include the file
+// offset off, which need not be the start of the instruction. It returns
+// false for offsets in the header or past the code.
+func (s *Script) InstructionAtOffset(off int) (*Instruction, bool) {
+	i := s.instructionContaining(off)
+	if i < 0 {
+		return nil, false
+	}
+	return &s.Instructions[i], true
+}
+
+// NextInstruction returns the first instruction starting after off, or
+// false if there is none.
+func (s *Script) NextInstruction(off int) (*Instruction, bool) {
+	i := sort.Search(len(s.Instructions), func(i int) bool {
+		return s.Instructions[i].Offset > off
+	})
+	if i >= len(s.Instructions) {
+		return nil, false
+	}
+	return &s.Instructions[i], true
+}
+
+// PrevInstruction returns the last instruction that ends at or before off,
+// so an offset inside an instruction yields the one preceding it. It returns
+// false if there is none.
+func (s *Script) PrevInstruction(off int) (*Instruction, bool) {
+	i := sort.Search(len(s.Instructions), func(i int) bool {
+		return s.Instructions[i].Offset+s.Instructions[i].Size() > off
+	}) - 1
+	if i < 0 {
+		return nil, false
+	}
+	return &s.Instructions[i], true
+}
+
+// XRefs maps each referenced label name to the indices of the instructions
+// that reference it, in ascending order. An instruction referencing a label
+// in several arguments is listed once.
+func (s *Script) XRefs() map[string][]int {
+	refs := make(map[string][]int)
+	for i := range s.Instructions {
+		for _, arg := range s.Instructions[i].Arguments {
+			if !arg.IsLabel {
+				continue
+			}
+			list := refs[arg.LabelName]
+			if n := len(list); n == 0 || list[n-1] != i {
+				refs[arg.LabelName] = append(list, i)
+			}
+		}
+	}
+	return refs
+}
+
+// UnreferencedLabels returns the labels that neither an instruction argument
+// nor a table entry references, in offset order.
+func (s *Script) UnreferencedLabels() []string {
+	refs := s.XRefs()
+	inTable := make(map[string]bool)
+	for _, names := range s.TableLabels {
+		for _, name := range names {
+			inTable[name] = true
+		}
+	}
+
+	offsets := make([]int, 0, len(s.Labels))
+	for off, name := range s.Labels {
+		if _, ok := refs[name]; !ok && !inTable[name] {
+			offsets = append(offsets, off)
+		}
+	}
+	sort.Ints(offsets)
+
+	result := make([]string, len(offsets))
+	for i, off := range offsets {
+		result[i] = s.Labels[off]
+	}
+	return result
+}
+
+// DanglingReferences returns the label names referenced by arguments that
+// do not resolve to an instruction: either no label has the name, or its
+// offset is not the start of an instruction. Names are sorted.
+func (s *Script) DanglingReferences() []string {
+	defined := make(map[string]int, len(s.Labels))
+	for off, name := range s.Labels {
+		defined[name] = off
+	}
+
+	var result []string
+	for name := range s.XRefs() {
+		if off, ok := defined[name]; !ok || s.instructionIndex(off) < 0 {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,119 @@
+package bin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStringByteRange(t *testing.T) {
+	values := []string{"a", "ab", "abc", "abcd", "日本語のテキスト"}
+
+	for _, sig := range []string{"SYS5501", "SYS4415"} {
+		t.Run(sig, func(t *testing.T) {
+			var body strings.Builder
+			body.WriteString(strings.Replace(testHeaderText, "SYS5501", sig, 1))
+			for _, v := range values {
+				body.WriteString("    show-text 0 \"" + v + "\"\n")
+			}
+			body.WriteString("    exit\n")
+			res, err := Assemble(body.String(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			script, err := Disassemble(res.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := FormatVersion(sig[3] - '0'); script.Header.Version != want {
+				t.Fatalf("format SYS%d, want SYS%d", script.Header.Version, want)
+			}
+
+			// The assembler stores the strings back to back, each padded
+			// to a 4-byte boundary
+			next := -1
+			for i, v := range values {
+				start, length, ok := script.StringByteRange(script.Instructions[i].Offset, 1)
+				if !ok {
+					t.Fatalf("no range for %q", v)
+				}
+				if next >= 0 && start != next {
+					t.Errorf("%q starts at 0x%X, want 0x%X after the previous string", v, start, next)
+				}
+				if length%4 != 0 {
+					t.Errorf("%q has length %d, want a multiple of 4", v, length)
+				}
+				if got, err := decodeString(res.Data[:start+length], start, script.Header.Version); err != nil || got != v {
+					t.Errorf("range of %q decodes to %q, %v", v, got, err)
+				}
+				next = start + length
+			}
+
+			exit := script.Instructions[len(values)].Offset
+			rejects := []struct {
+				name        string
+				offset, arg int
+			}{
+				{"not a string argument", script.Instructions[0].Offset, 0},
+				{"argument out of range", script.Instructions[0].Offset, 2},
+				{"no instruction there", script.Instructions[0].Offset + 1, 1},
+				{"instruction without arguments", exit, 0},
+			}
+			for _, tt := range rejects {
+				if _, _, ok := script.StringByteRange(tt.offset, tt.arg); ok {
+					t.Errorf("%s: StringByteRange reported a range", tt.name)
+				}
+			}
+		})
+	}
+}
+
+func TestInstructionAtOffset(t *testing.T) {
+	script, _ := testScript(t, "    mov local-int:1 5\n    ret\n    exit\n")
+	ins := script.Instructions
+	if len(ins) != 3 || ins[0].Size() < 2 {
+		t.Fatalf("unexpected test script layout: %+v", ins)
+	}
+	end := ins[2].Offset + ins[2].Size()
+
+	// Indexes of the instructions found at, after and before each offset
+	// (-1 = none)
+	tests := []struct {
+		name           string
+		offset         int
+		at, next, prev int
+	}{
+		{"header", 0, -1, 0, -1},
+		{"first instruction", ins[0].Offset, 0, 1, -1},
+		{"mid instruction", ins[0].Offset + 1, 0, 1, -1},
+		{"last byte of instruction", ins[1].Offset - 1, 0, 1, -1},
+		{"second instruction", ins[1].Offset, 1, 2, 0},
+		{"last byte of code", end - 1, 2, -1, 1},
+		{"past the end", end, -1, -1, 2},
+		{"far past the end", end + 100, -1, -1, 2},
+	}
+
+	lookups := []struct {
+		name string
+		fn   func(int) (*Instruction, bool)
+		want func(at, next, prev int) int
+	}{
+		{"InstructionAtOffset", script.InstructionAtOffset, func(at, _, _ int) int { return at }},
+		{"NextInstruction", script.NextInstruction, func(_, next, _ int) int { return next }},
+		{"PrevInstruction", script.PrevInstruction, func(_, _, prev int) int { return prev }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, l := range lookups {
+				want := l.want(tt.at, tt.next, tt.prev)
+				got, ok := l.fn(tt.offset)
+				switch {
+				case want < 0 && ok:
+					t.Errorf("%s(0x%X) = instruction at 0x%X, want none", l.name, tt.offset, got.Offset)
+				case want >= 0 && (!ok || got != &ins[want]):
+					t.Errorf("%s(0x%X) = %v, %v, want instruction at 0x%X", l.name, tt.offset, got, ok, ins[want].Offset)
+				}
+			}
+		})
+	}
+}
@@ -16,21 +16,82 @@ import (
 
 // AssembleResult contains the assembled binary and metadata
 type AssembleResult struct {
-	Data   []byte
-	Header Header
+	Data     []byte
+	Header   Header
+	Labels   LabelMap   // Offset of every label defined in the text
+	Comments CommentMap // Offset of every instruction with a trailing comment
 }
 
-// Assemble parses assembly text and produces a BIN file
+// Assemble parses assembly text and produces a BIN file.
+//
+// Besides instructions, the text may contain a raw byte directive,
+// ".bytes 0x01 0x02 ..." (or "db ..."), which is emitted verbatim at its
+// position and shifts all following offsets. Raw bytes are an escape hatch:
+// keeping labels, strings and tables aligned around them is up to the author.
+// The ".word 0x00000000 ..." directive works the same way with little-endian
+// 32-bit words; the disassembler emits it for unknown opcodes. A string
+// argument of an unknown opcode is written as `.word 0x00000002 "text"`, and
+// the string is placed in the footer like any other.
+//
+// Labels may use any identifier as a name, not only the label_XXXXXXXX form
+// produced by the disassembler; see LabelMap for keeping names across edits.
+//
+// An instruction line may end with a "; comment". Semicolons inside string
+// arguments do not start a comment. Comments are returned in
+// AssembleResult.Comments; see CommentMap for keeping them across edits.
+//
+// The format is taken from the signature line of the header block. version is
+// only a fallback for a signature naming neither SYS4 nor SYS5; pass 0 to fall
+// back to SYS5. A local_vars line without six numbers is an error, as is a
+// missing signature line unless AssembleOptions.Version forces the format.
+//
+// Errors in the instruction section are prefixed with "line N:", counting
+// lines of text from 1.
 func Assemble(text string, version FormatVersion) (*AssembleResult, error) {
+	return AssembleWithOptions(text, version, AssembleOptions{})
+}
+
+// AssembleOptions controls the layout of the assembled file.
+type AssembleOptions struct {
+	// StringOrder lays out the footer strings in this order instead of
+	// instruction order, as captured in Script.StringOrder. Entries are
+	// matched to string arguments by text, so the order survives code edits;
+	// strings it does not name follow in instruction order.
+	StringOrder []StringRef
+
+	// Version forces SYS4 or SYS5 output regardless of the signature line.
+	// Zero keeps the detection described on Assemble. The written signature
+	// is adjusted to the forced format so the output can be read back.
+	Version FormatVersion
+
+	// Strict rejects instructions whose argument count does not match the
+	// opcode definition. Otherwise missing arguments are filled with zeros
+	// and extra arguments are ignored.
+	Strict bool
+
+	// DedupeStrings stores each distinct string once in the footer and points
+	// every argument with that text at the shared copy. This shrinks new
+	// content, but originals that repeat a string no longer round-trip byte
+	// for byte.
+	DedupeStrings bool
+}
+
+// AssembleWithOptions parses assembly text and produces a BIN file using the
+// given layout options.
+func AssembleWithOptions(text string, version FormatVersion, opts AssembleOptions) (*AssembleResult, error) {
 	parser := &assemblyParser{
 		version:       version,
+		stringOrder:   opts.StringOrder,
+		forceVersion:  opts.Version,
+		strict:        opts.Strict,
+		dedupeStrings: opts.DedupeStrings,
 		labels:        make(map[string]int),
 		labelRefs:     make([]labelReference, 0),
 		instructions:  make([]parsedInstruction, 0),
 		strings:       make([]string, 0),
-		stringOffsets: make(map[string]int),
+		stringOffsets: make(map[argRef]int),
 		arrays:        make([][]uint32, 0),
-		arrayOffsets:  make(map[int]int), // instruction index -> array offset
+		arrayOffsets:  make(map[argRef]int),
 		table1Offsets: make([]uint32, 0), // opcode 0x71
 		table2Offsets: make([]uint32, 0), // opcode 0x03
 		table3Offsets: make([]uint32, 0), // opcode 0x8F
@@ -50,9 +111,12 @@ func Assemble(text string, version FormatVersion) (*AssembleResult, error) {
 	return parser.build()
 }
 
-// AssembleFromScript rebuilds a BIN file from a Script structure
+// AssembleFromScript rebuilds a BIN file from a Script structure, keeping the
+// footer string order found by the disassembler
 func AssembleFromScript(script *Script) (*AssembleResult, error) {
-	return Assemble(script.ToText(), script.Header.Version)
+	return AssembleWithOptions(script.ToText(), script.Header.Version, AssembleOptions{
+		StringOrder: script.StringOrder,
+	})
 }
 
 type labelReference struct {
@@ -65,7 +129,22 @@ type parsedInstruction struct {
 	opcode    uint32
 	def       *InstructionDefinition
 	arguments []parsedArgument
-	offset    int // calculated offset
+	offset    int    // calculated offset
+	raw       []byte // verbatim bytes from a .bytes directive (no opcode/arguments)
+	argsOnly  bool   // arguments without an opcode, from a .word string directive
+	line      int    // source line number, for error messages
+	comment   string // trailing "; comment" text
+}
+
+// size returns the encoded size of the instruction in bytes
+func (pi *parsedInstruction) size() int {
+	if pi.raw != nil {
+		return len(pi.raw)
+	}
+	if pi.argsOnly {
+		return len(pi.arguments) * 8
+	}
+	return 4 + len(pi.arguments)*8
 }
 
 type parsedArgument struct {
@@ -84,29 +163,37 @@ type assemblyParser struct {
 	labelRefs     []labelReference
 	instructions  []parsedInstruction
 	strings       []string
-	stringOffsets map[string]int
+	stringOffsets map[argRef]int // file offset of each string argument's text
 	arrays        [][]uint32
-	arrayOffsets  map[int]int
+	arrayOffsets  map[argRef]int // file offset of each array argument's data
 	table1Offsets []uint32
 	table2Offsets []uint32
 	table3Offsets []uint32
+	stringOrder   []StringRef
+	forceVersion  FormatVersion
+	strict        bool
+	dedupeStrings bool
 }
 
 var (
 	headerLineRE  = regexp.MustCompile(`^(\w+)\s*=\s*(.+)$`)
-	labelRE       = regexp.MustCompile(`^(label_[0-9A-Fa-f]+):$`)
+	labelRE       = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*):$`)
 	instructionRE = regexp.MustCompile(`^\s*(\S+)(.*)$`)
 	stringArgRE   = regexp.MustCompile(`^"((?:[^"\\]|\\.)*)"`)
 	arrayArgRE    = regexp.MustCompile(`^\[([^\]]*)\]`)
-	typedArgRE    = regexp.MustCompile(`^(\w+(?:-\w+)*):(-?\d+)$`)
+	typedArgRE    = regexp.MustCompile(`^(\w+(?:-\w+)*):(-?\d+|0[xX][0-9A-Fa-f]+)$`)
 	labelArgRE    = regexp.MustCompile(`^label_([0-9A-Fa-f]+)$`)
+	offsetNoteRE  = regexp.MustCompile(`^0x[0-9A-Fa-f]+:(?:\s*\[[0-9A-Fa-f ]*\])?\s*`)
 )
 
 func (p *assemblyParser) parseHeader(text string) error {
 	scanner := bufio.NewScanner(strings.NewReader(text))
 	inHeader := false
+	hasSignature := false
+	lineNum := 0
 
 	for scanner.Scan() {
+		lineNum++
 		line := strings.TrimSpace(scanner.Text())
 
 		if line == "==Binary Information - do not edit==" {
@@ -129,6 +216,7 @@ func (p *assemblyParser) parseHeader(text string) error {
 		key, value := matches[1], matches[2]
 		switch key {
 		case "signature":
+			hasSignature = true
 			p.header.Signature = value
 			// Detect version from signature
 			if strings.HasPrefix(value, "SYS5") {
@@ -142,26 +230,74 @@ func (p *assemblyParser) parseHeader(text string) error {
 			// Parse { a b c d e f }
 			value = strings.Trim(value, "{ }")
 			parts := strings.Fields(value)
-			if len(parts) >= 6 {
-				p.header.LocalInteger1 = parseUint32(parts[0])
-				p.header.LocalFloats = parseUint32(parts[1])
-				p.header.LocalStrings1 = parseUint32(parts[2])
-				p.header.LocalInteger2 = parseUint32(parts[3])
-				p.header.UnknownData = parseUint32(parts[4])
-				p.header.LocalStrings2 = parseUint32(parts[5])
+			if len(parts) != 6 {
+				return fmt.Errorf("line %d: %w: local_vars needs 6 values, got %d", lineNum, ErrInvalidFormat, len(parts))
 			}
+			var vals [6]uint32
+			for i, part := range parts {
+				val, err := strconv.ParseUint(part, 10, 32)
+				if err != nil {
+					return fmt.Errorf("line %d: %w: invalid local_vars value %q", lineNum, ErrInvalidFormat, part)
+				}
+				vals[i] = uint32(val)
+			}
+			p.header.LocalInteger1 = vals[0]
+			p.header.LocalFloats = vals[1]
+			p.header.LocalStrings1 = vals[2]
+			p.header.LocalInteger2 = vals[3]
+			p.header.UnknownData = vals[4]
+			p.header.LocalStrings2 = vals[5]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !hasSignature && p.forceVersion == 0 {
+		return fmt.Errorf("%w: header has no signature line", ErrInvalidFormat)
+	}
+
+	// A forced version wins over the signature; without a recognizable
+	// signature, use the caller's version
+	if p.forceVersion != 0 {
+		p.version = p.forceVersion
+		p.header.Version = p.forceVersion
+	} else if p.header.Version == 0 {
+		if p.version == 0 {
+			p.version = FormatSYS5
 		}
+		p.header.Version = p.version
 	}
+	p.header.Signature = signatureFor(p.header.Signature, p.header.Version)
 
-	p.header.SubHeaderLen = 0x1C // Always 0x1C
-	return scanner.Err()
+	p.header.SubHeaderLen = SubHeaderSize
+	return nil
+}
+
+// signatureFor returns a signature that DetectFormat reads as version. A
+// signature for the other format keeps its suffix under the new prefix, and
+// a missing one becomes "SYS5501 " or "SYS4501 ".
+func signatureFor(sig string, version FormatVersion) string {
+	prefix := "SYS5"
+	if version == FormatSYS4 {
+		prefix = "SYS4"
+	}
+	switch {
+	case strings.HasPrefix(sig, prefix):
+		return sig
+	case strings.HasPrefix(sig, "SYS") && len(sig) > len(prefix):
+		return prefix + sig[len(prefix):]
+	default:
+		return prefix + "501 "
+	}
 }
 
 func (p *assemblyParser) parseInstructions(text string) error {
 	scanner := bufio.NewScanner(strings.NewReader(text))
 	pastHeader := false
+	lineNum := 0
 
 	for scanner.Scan() {
+		lineNum++
 		line := scanner.Text()
 		trimmed := strings.TrimSpace(line)
 
@@ -174,6 +310,14 @@ func (p *assemblyParser) parseInstructions(text string) error {
 			continue
 		}
 
+		// Drop the offset annotation written by ToAnnotatedText
+		if loc := offsetNoteRE.FindStringIndex(trimmed); loc != nil {
+			trimmed = trimmed[loc[1]:]
+		}
+
+		// Split off a trailing "; comment"
+		trimmed, comment := splitComment(trimmed)
+
 		// Skip empty lines and comments
 		if trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "#") {
 			continue
@@ -182,6 +326,10 @@ func (p *assemblyParser) parseInstructions(text string) error {
 		// Check for label
 		if matches := labelRE.FindStringSubmatch(trimmed); matches != nil {
 			labelName := matches[1]
+			// References to names like "inf" would be read as floats
+			if !IsValidLabelName(labelName) {
+				return fmt.Errorf("line %d: %w: %q cannot be referenced as a label", lineNum, ErrInvalidLabel, labelName)
+			}
 			p.labels[labelName] = len(p.instructions)
 			continue
 		}
@@ -195,20 +343,46 @@ func (p *assemblyParser) parseInstructions(text string) error {
 		mnemonic := matches[1]
 		argsStr := strings.TrimSpace(matches[2])
 
+		// Raw byte directive
+		if mnemonic == ".bytes" || mnemonic == "db" {
+			raw, err := parseRawBytes(argsStr)
+			if err != nil {
+				return fmt.Errorf("line %d: error parsing %s directive: %w", lineNum, mnemonic, err)
+			}
+			p.instructions = append(p.instructions, parsedInstruction{raw: raw, line: lineNum, comment: comment})
+			continue
+		}
+		if mnemonic == RawWordDirective {
+			instr, err := parseWordDirective(argsStr)
+			if err != nil {
+				return fmt.Errorf("line %d: error parsing %s directive: %w", lineNum, mnemonic, err)
+			}
+			instr.line = lineNum
+			instr.comment = comment
+			p.instructions = append(p.instructions, instr)
+			continue
+		}
+
 		def := LookupLabel(mnemonic)
 		if def == nil {
-			return fmt.Errorf("%w: %s", ErrUnknownOpcode, mnemonic)
+			return fmt.Errorf("line %d: %w: %s", lineNum, ErrUnknownOpcode, mnemonic)
 		}
 
 		instr := parsedInstruction{
 			opcode:    def.Opcode,
 			def:       def,
 			arguments: make([]parsedArgument, 0, def.ArgCount),
+			line:      lineNum,
+			comment:   comment,
 		}
 
 		// Parse arguments
 		if err := p.parseArguments(&instr, argsStr); err != nil {
-			return fmt.Errorf("error parsing arguments for %s: %w", mnemonic, err)
+			return fmt.Errorf("line %d: error parsing arguments for %s: %w", lineNum, mnemonic, err)
+		}
+		if p.strict && len(instr.arguments) != def.ArgCount {
+			return fmt.Errorf("line %d: %w: %s expects %d arguments, got %d: %s",
+				lineNum, ErrArgCountMismatch, mnemonic, def.ArgCount, len(instr.arguments), trimmed)
 		}
 
 		// Track special opcodes for tables
@@ -234,7 +408,8 @@ func (p *assemblyParser) parseArguments(instr *parsedInstruction, argsStr string
 		return nil
 	}
 
-	for len(argsStr) > 0 && len(instr.arguments) < instr.def.ArgCount {
+	// In strict mode every argument is parsed so that extras can be counted
+	for len(argsStr) > 0 && (p.strict || len(instr.arguments) < instr.def.ArgCount) {
 		argsStr = strings.TrimSpace(argsStr)
 		if argsStr == "" {
 			break
@@ -290,11 +465,10 @@ func (p *assemblyParser) parseArguments(instr *parsedInstruction, argsStr string
 			continue
 		}
 
-		// Try typed argument (e.g., local-int:5)
+		// Try typed argument (e.g., local-int:5, float:0x7FC00001)
 		if matches := typedArgRE.FindStringSubmatch(token); matches != nil {
 			arg.argType = parseArgType(matches[1])
-			val, _ := strconv.ParseInt(matches[2], 10, 64)
-			arg.rawValue = uint32(val)
+			arg.rawValue = parseTypedValue(matches[2])
 			instr.arguments = append(instr.arguments, arg)
 			continue
 		}
@@ -315,11 +489,24 @@ func (p *assemblyParser) parseArguments(instr *parsedInstruction, argsStr string
 			continue
 		}
 
+		// Try named label reference
+		if IsValidLabelName(token) {
+			arg.isLabel = true
+			arg.labelName = token
+			p.labelRefs = append(p.labelRefs, labelReference{
+				instrIndex: len(p.instructions),
+				argIndex:   len(instr.arguments),
+				labelName:  token,
+			})
+			instr.arguments = append(instr.arguments, arg)
+			continue
+		}
+
 		return fmt.Errorf("cannot parse argument: %s", token)
 	}
 
 	// Pad with empty arguments if needed
-	for len(instr.arguments) < instr.def.ArgCount {
+	for !p.strict && len(instr.arguments) < instr.def.ArgCount {
 		instr.arguments = append(instr.arguments, parsedArgument{})
 	}
 
@@ -333,66 +520,45 @@ func (p *assemblyParser) build() (*AssembleResult, error) {
 	offset := headerLen
 	for i := range p.instructions {
 		p.instructions[i].offset = offset
-		offset += 4 + len(p.instructions[i].arguments)*8
+		offset += p.instructions[i].size()
 	}
 	instrEndOffset := offset
 
 	// Build footer data: strings, arrays, tables
 	var footerData []byte
 
-	// Encode strings (DO NOT deduplicate - encode each occurrence separately to match original)
+	// Encode strings. Each occurrence is encoded separately to match the
+	// original, unless deduplication was asked for.
 	currentStringOffset := instrEndOffset
-	for i := range p.instructions {
-		for j := range p.instructions[i].arguments {
-			arg := &p.instructions[i].arguments[j]
-			if arg.argType == ArgString && arg.stringVal != "" {
-				// Store offset for this specific argument occurrence
-				offsetKey := fmt.Sprintf("%d_%d", i, j)
-				p.stringOffsets[offsetKey] = currentStringOffset
-
-				if p.version == FormatSYS5 {
-					// UTF-16LE encoding
-					runes := []rune(arg.stringVal)
-					currentStringOffset += (len(runes) + 1) * 2
-
-					// Write XOR'd string data
-					for _, r := range runes {
-						encoded := uint16(r) ^ 0xFFFF
-						footerData = append(footerData, byte(encoded), byte(encoded>>8))
-					}
-
-					// Calculate padding (includes terminator)
-					padding := 4 - (currentStringOffset % 4)
-					// Write padding + 2 bytes of 0xFF (includes 2-byte terminator)
-					for k := 0; k < padding+2; k++ {
-						footerData = append(footerData, 0xFF)
-					}
-					currentStringOffset += padding
-				} else {
-					// Shift-JIS encoding
-					encoder := japanese.ShiftJIS.NewEncoder()
-					sjisBytes, _, err := transform.Bytes(encoder, []byte(arg.stringVal))
-					if err != nil {
-						sjisBytes = []byte(arg.stringVal)
-					}
-
-					currentStringOffset += len(sjisBytes) + 1
-
-					// Write XOR'd string data
-					for _, b := range sjisBytes {
-						footerData = append(footerData, b^0xFF)
-					}
-
-					// Calculate padding (includes terminator)
-					padding := 4 - (currentStringOffset % 4)
-					// Write padding + 1 bytes of 0xFF (includes 1-byte terminator)
-					for k := 0; k < padding+1; k++ {
-						footerData = append(footerData, 0xFF)
-					}
-					currentStringOffset += padding
-				}
+	shared := make(map[string]int)
+	for _, ref := range p.stringLayout() {
+		i, j := ref.instr, ref.arg
+		arg := &p.instructions[i].arguments[j]
+
+		if p.dedupeStrings {
+			if off, ok := shared[arg.stringVal]; ok {
+				p.stringOffsets[ref] = off
+				continue
 			}
+			shared[arg.stringVal] = currentStringOffset
+		}
+
+		// Store offset for this specific argument occurrence
+		p.stringOffsets[ref] = currentStringOffset
+
+		encoded, err := encodeString(arg.stringVal, p.version)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.instructions[i].line, err)
 		}
+		footerData = append(footerData, encoded...)
+		currentStringOffset += len(encoded)
+
+		// Pad with 0xFF to the next 4-byte boundary, a full word if already aligned
+		padding := 4 - (currentStringOffset % 4)
+		for k := 0; k < padding; k++ {
+			footerData = append(footerData, 0xFF)
+		}
+		currentStringOffset += padding
 	}
 
 	// Encode arrays
@@ -401,7 +567,7 @@ func (p *assemblyParser) build() (*AssembleResult, error) {
 		for j := range p.instructions[i].arguments {
 			arg := &p.instructions[i].arguments[j]
 			if len(arg.arrayVal) > 0 {
-				p.arrayOffsets[i*100+j] = headerLen + int(currentArrayOffset<<2)
+				p.arrayOffsets[argRef{i, j}] = headerLen + int(currentArrayOffset<<2)
 				arg.rawValue = currentArrayOffset
 
 				// Write length
@@ -456,7 +622,7 @@ func (p *assemblyParser) build() (*AssembleResult, error) {
 	for _, ref := range p.labelRefs {
 		targetIdx, ok := p.labels[ref.labelName]
 		if !ok {
-			return nil, fmt.Errorf("%w: %s", ErrLabelNotFound, ref.labelName)
+			return nil, fmt.Errorf("line %d: %w: %s", p.instructions[ref.instrIndex].line, ErrLabelNotFound, ref.labelName)
 		}
 		targetOffset := p.instructions[targetIdx].offset
 		p.instructions[ref.instrIndex].arguments[ref.argIndex].rawValue = uint32((targetOffset - headerLen) / 4)
@@ -467,12 +633,11 @@ func (p *assemblyParser) build() (*AssembleResult, error) {
 		for j := range p.instructions[i].arguments {
 			arg := &p.instructions[i].arguments[j]
 			if arg.argType == ArgString && arg.stringVal != "" {
-				offsetKey := fmt.Sprintf("%d_%d", i, j)
-				strOffset := p.stringOffsets[offsetKey]
+				strOffset := p.stringOffsets[argRef{i, j}]
 				arg.rawValue = uint32((strOffset - headerLen) / 4)
 			}
 			if len(arg.arrayVal) > 0 {
-				arrayOffset := p.arrayOffsets[i*100+j]
+				arrayOffset := p.arrayOffsets[argRef{i, j}]
 				arg.rawValue = uint32((arrayOffset - headerLen) / 4)
 			}
 		}
@@ -489,9 +654,18 @@ func (p *assemblyParser) build() (*AssembleResult, error) {
 	// Write instructions
 	for _, instr := range p.instructions {
 		off := instr.offset
-		binary.LittleEndian.PutUint32(data[off:], instr.opcode)
+		if instr.raw != nil {
+			copy(data[off:], instr.raw)
+			continue
+		}
+		argBase := off + 4
+		if instr.argsOnly {
+			argBase = off
+		} else {
+			binary.LittleEndian.PutUint32(data[off:], instr.opcode)
+		}
 		for j, arg := range instr.arguments {
-			argOff := off + 4 + j*8
+			argOff := argBase + j*8
 			binary.LittleEndian.PutUint32(data[argOff:], uint32(arg.argType))
 			binary.LittleEndian.PutUint32(data[argOff+4:], arg.rawValue)
 		}
@@ -500,14 +674,80 @@ func (p *assemblyParser) build() (*AssembleResult, error) {
 	// Write footer
 	copy(data[instrEndOffset:], footerData)
 
+	labels := make(LabelMap, len(p.labels))
+	for name, idx := range p.labels {
+		if idx < len(p.instructions) {
+			labels[p.instructions[idx].offset] = name
+		}
+	}
+
+	comments := make(CommentMap)
+	for _, instr := range p.instructions {
+		if instr.comment != "" {
+			comments[instr.offset] = instr.comment
+		}
+	}
+
 	return &AssembleResult{
-		Data:   data,
-		Header: p.header,
+		Data:     data,
+		Header:   p.header,
+		Labels:   labels,
+		Comments: comments,
 	}, nil
 }
 
-func (p *assemblyParser) encodeString(s string) []byte {
-	if p.version == FormatSYS5 {
+// argRef addresses one argument of a parsed instruction
+type argRef struct {
+	instr, arg int
+}
+
+// stringLayout returns the string arguments in the order their text is laid
+// out in the footer. With a StringOrder, each entry takes the first unplaced
+// argument with the same text, in instruction order; copies of equal text
+// encode identically, so which copy goes where does not matter. Arguments the
+// order does not name follow in instruction order.
+func (p *assemblyParser) stringLayout() []argRef {
+	var all []argRef
+	for i := range p.instructions {
+		for j, arg := range p.instructions[i].arguments {
+			if arg.argType == ArgString && arg.stringVal != "" {
+				all = append(all, argRef{i, j})
+			}
+		}
+	}
+	if len(p.stringOrder) == 0 {
+		return all
+	}
+
+	byValue := make(map[string][]argRef)
+	for _, ref := range all {
+		val := p.instructions[ref.instr].arguments[ref.arg].stringVal
+		byValue[val] = append(byValue[val], ref)
+	}
+
+	layout := make([]argRef, 0, len(all))
+	placed := make(map[argRef]bool, len(all))
+	for _, sr := range p.stringOrder {
+		queue := byValue[sr.Value]
+		if len(queue) == 0 {
+			continue
+		}
+		layout = append(layout, queue[0])
+		placed[queue[0]] = true
+		byValue[sr.Value] = queue[1:]
+	}
+	for _, ref := range all {
+		if !placed[ref] {
+			layout = append(layout, ref)
+		}
+	}
+	return layout
+}
+
+// encodeString encodes s as footer text with its terminator, without the
+// alignment padding. Text that has no Shift-JIS encoding is an error for SYS4.
+func encodeString(s string, version FormatVersion) ([]byte, error) {
+	if version == FormatSYS5 {
 		// UTF-16LE XOR'd with 0xFFFF
 		runes := []rune(s)
 		buf := make([]byte, (len(runes)+1)*2)
@@ -517,14 +757,14 @@ func (p *assemblyParser) encodeString(s string) []byte {
 		}
 		// Terminator
 		binary.LittleEndian.PutUint16(buf[len(runes)*2:], 0xFFFF)
-		return buf
+		return buf, nil
 	}
 
 	// SYS4: Shift-JIS XOR'd with 0xFF
 	encoder := japanese.ShiftJIS.NewEncoder()
 	sjisBytes, _, err := transform.Bytes(encoder, []byte(s))
 	if err != nil {
-		sjisBytes = []byte(s)
+		return nil, fmt.Errorf("cannot encode %q as Shift-JIS: %w", s, err)
 	}
 
 	buf := make([]byte, len(sjisBytes)+1)
@@ -532,11 +772,17 @@ func (p *assemblyParser) encodeString(s string) []byte {
 		buf[i] = b ^ 0xFF
 	}
 	buf[len(sjisBytes)] = 0xFF // Terminator
-	return buf
+	return buf, nil
 }
 
-func parseUint32(s string) uint32 {
-	val, _ := strconv.ParseUint(s, 10, 32)
+// parseTypedValue parses the value of a typed argument: a decimal, or raw
+// bits in hex as written for floats that have no exact decimal form
+func parseTypedValue(s string) uint32 {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		val, _ := strconv.ParseUint(s[2:], 16, 32)
+		return uint32(val)
+	}
+	val, _ := strconv.ParseInt(s, 10, 64)
 	return uint32(val)
 }
 
@@ -581,6 +827,73 @@ func parseArgType(s string) ArgumentType {
 	}
 }
 
+// parseRawBytes parses the operands of a .bytes directive
+func parseRawBytes(s string) ([]byte, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ','
+	})
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no bytes given")
+	}
+
+	raw := make([]byte, 0, len(fields))
+	for _, field := range fields {
+		val, err := strconv.ParseUint(field, 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid byte value: %s", field)
+		}
+		raw = append(raw, byte(val))
+	}
+	return raw, nil
+}
+
+// parseWordDirective parses a .word directive: either plain words, or an
+// argument type followed by a quoted string
+func parseWordDirective(s string) (parsedInstruction, error) {
+	quote := strings.IndexByte(s, '"')
+	if quote < 0 {
+		raw, err := parseRawWords(s)
+		return parsedInstruction{raw: raw}, err
+	}
+
+	argType, err := strconv.ParseUint(strings.TrimSpace(s[:quote]), 0, 32)
+	if err != nil || ArgumentType(argType) != ArgString {
+		return parsedInstruction{}, fmt.Errorf("string operand must follow type 0x%08X", uint32(ArgString))
+	}
+	matches := stringArgRE.FindStringSubmatch(s[quote:])
+	if matches == nil || len(matches[0]) != len(s[quote:]) {
+		return parsedInstruction{}, fmt.Errorf("invalid string operand: %s", s[quote:])
+	}
+
+	return parsedInstruction{
+		argsOnly: true,
+		arguments: []parsedArgument{{
+			argType:   ArgString,
+			stringVal: unescapeString(matches[1]),
+		}},
+	}, nil
+}
+
+// parseRawWords parses the operands of a .word directive into little-endian bytes
+func parseRawWords(s string) ([]byte, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ','
+	})
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no words given")
+	}
+
+	raw := make([]byte, 0, len(fields)*4)
+	for _, field := range fields {
+		val, err := strconv.ParseUint(field, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid word value: %s", field)
+		}
+		raw = binary.LittleEndian.AppendUint32(raw, uint32(val))
+	}
+	return raw, nil
+}
+
 func parseArrayValues(s string) []uint32 {
 	s = strings.TrimSpace(s)
 	if s == "" {
@@ -609,30 +922,92 @@ func unescapeString(s string) string {
 
 // VerifyRoundTrip disassembles and reassembles a BIN file, returning true if they match
 func VerifyRoundTrip(originalData []byte) (bool, error) {
+	mismatch, err := VerifyRoundTripDetailed(originalData)
+	if err != nil {
+		return false, err
+	}
+	return mismatch == nil, nil
+}
+
+// roundTripContext is the number of bytes shown on each side of a mismatch
+const roundTripContext = 8
+
+// RoundTripMismatch describes where a reassembled file first differs from
+// the original.
+type RoundTripMismatch struct {
+	Offset         int    // First differing byte, or the shorter length if one file is a prefix of the other
+	LengthDiffers  bool   // The files have different sizes
+	OriginalLength int    // Size of the original file
+	ActualLength   int    // Size of the reassembled file
+	ContextStart   int    // File offset of the first byte of Expected and Actual
+	Expected       []byte // Original bytes around Offset
+	Actual         []byte // Reassembled bytes around Offset
+
+	// Instruction is the original instruction containing Offset, or nil if
+	// the offset lies in the header or the footer.
+	Instruction *Instruction
+}
+
+// String describes the mismatch over several lines: the offset and sizes,
+// both byte windows in hex, and the instruction at the offset if any.
+func (m *RoundTripMismatch) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "first difference at offset 0x%X", m.Offset)
+	if m.LengthDiffers {
+		fmt.Fprintf(&sb, " (size %d -> %d bytes)", m.OriginalLength, m.ActualLength)
+	}
+	fmt.Fprintf(&sb, "\n  expected @0x%X: % X", m.ContextStart, m.Expected)
+	fmt.Fprintf(&sb, "\n  actual   @0x%X: % X", m.ContextStart, m.Actual)
+	if m.Instruction != nil {
+		fmt.Fprintf(&sb, "\n  in instruction at 0x%X: %s", m.Instruction.Offset, formatInstruction(m.Instruction))
+	}
+	return sb.String()
+}
+
+// VerifyRoundTripDetailed disassembles and reassembles a BIN file like
+// VerifyRoundTrip, returning nil if the result is identical and a
+// description of the first difference otherwise.
+func VerifyRoundTripDetailed(originalData []byte) (*RoundTripMismatch, error) {
 	// Disassemble
 	script, err := Disassemble(originalData)
 	if err != nil {
-		return false, fmt.Errorf("disassembly failed: %w", err)
+		return nil, fmt.Errorf("disassembly failed: %w", err)
 	}
 
 	// Reassemble
-	result, err := Assemble(script.ToText(), script.Header.Version)
+	result, err := AssembleFromScript(script)
 	if err != nil {
-		return false, fmt.Errorf("assembly failed: %w", err)
+		return nil, fmt.Errorf("assembly failed: %w", err)
 	}
 
 	// Compare
-	if len(originalData) != len(result.Data) {
-		return false, nil
-	}
-
-	for i := range originalData {
-		if originalData[i] != result.Data[i] {
-			return false, nil
+	actual := result.Data
+	n := min(len(originalData), len(actual))
+	offset := n
+	for i := 0; i < n; i++ {
+		if originalData[i] != actual[i] {
+			offset = i
+			break
 		}
 	}
+	if offset == n && len(originalData) == len(actual) {
+		return nil, nil
+	}
 
-	return true, nil
+	ctxStart := max(0, offset-roundTripContext) &^ 3
+	m := &RoundTripMismatch{
+		Offset:         offset,
+		LengthDiffers:  len(originalData) != len(actual),
+		OriginalLength: len(originalData),
+		ActualLength:   len(actual),
+		ContextStart:   ctxStart,
+		Expected:       originalData[ctxStart:min(len(originalData), offset+roundTripContext)],
+		Actual:         actual[ctxStart:min(len(actual), offset+roundTripContext)],
+	}
+	if i := script.instructionContaining(offset); i >= 0 {
+		m.Instruction = &script.Instructions[i]
+	}
+	return m, nil
 }
 
 // SortLabels returns label names sorted by their offset
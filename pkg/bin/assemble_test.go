@@ -0,0 +1,234 @@
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAssembleFormatDetection(t *testing.T) {
+	tests := []struct {
+		name      string
+		signature string // "" omits the signature line
+		version   FormatVersion
+		force     FormatVersion
+		want      FormatVersion
+		wantSig   string
+		wantErr   error
+	}{
+		{"SYS5 signature", "SYS5501", 0, 0, FormatSYS5, "SYS5501", nil},
+		{"SYS4 signature", "SYS4415", 0, 0, FormatSYS4, "SYS4415", nil},
+		{"signature wins over version", "SYS4415", FormatSYS5, 0, FormatSYS4, "SYS4415", nil},
+		{"unknown signature uses version", "ABCD", FormatSYS4, 0, FormatSYS4, "SYS4501", nil},
+		{"unknown signature defaults to SYS5", "ABCD", 0, 0, FormatSYS5, "SYS5501", nil},
+		{"forced over signature", "SYS5501", 0, FormatSYS4, FormatSYS4, "SYS4501", nil},
+		{"forced without signature", "", 0, FormatSYS5, FormatSYS5, "SYS5501", nil},
+		{"missing signature", "", FormatSYS5, 0, 0, "", ErrInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := testHeaderText
+			if tt.signature == "" {
+				text = strings.Replace(text, "signature = SYS5501\n", "", 1)
+			} else {
+				text = strings.Replace(text, "SYS5501", tt.signature, 1)
+			}
+			text += "    show-text 0 \"テスト\"\n    exit\n"
+
+			res, err := AssembleWithOptions(text, tt.version, AssembleOptions{Version: tt.force})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Assemble = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			script, err := Disassemble(res.Data)
+			if err != nil {
+				t.Fatalf("Disassemble: %v", err)
+			}
+			if script.Header.Version != tt.want {
+				t.Errorf("format SYS%d, want SYS%d", script.Header.Version, tt.want)
+			}
+			if sig := strings.TrimRight(script.Header.Signature, "\x00 "); sig != tt.wantSig {
+				t.Errorf("signature %q, want %q", sig, tt.wantSig)
+			}
+			if got := script.Instructions[0].Arguments[1].StringVal; got != "テスト" {
+				t.Errorf("string reads back as %q", got)
+			}
+		})
+	}
+}
+
+func TestAssembleErrorLines(t *testing.T) {
+	// testHeaderText takes lines 1-4, so the body starts on line 5
+	tests := []struct {
+		name     string
+		body     string
+		strict   bool
+		wantLine string
+		wantErr  error
+	}{
+		{"undefined label", "    exit\n\n    jmp nowhere\n", false, "line 7:", ErrLabelNotFound},
+		{"unknown mnemonic", "    exit\n    frobnicate 1\n", false, "line 6:", ErrUnknownOpcode},
+		{"invalid label definition", "    exit\ninf:\n", false, "line 6:", ErrInvalidLabel},
+		{"argument count", "    mov local-int:0\n", true, "line 5:", ErrArgCountMismatch},
+		{"bad directive", "    exit\n    .bytes 0xZZ\n", false, "line 6:", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := AssembleWithOptions(testHeaderText+tt.body, FormatSYS5, AssembleOptions{Strict: tt.strict})
+			if err == nil {
+				t.Fatal("Assemble succeeded")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Assemble = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.HasPrefix(err.Error(), tt.wantLine) {
+				t.Errorf("Assemble = %q, want it to start with %q", err, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestAssembleUnencodableText(t *testing.T) {
+	header := strings.Replace(testHeaderText, "SYS5501", "SYS4415", 1)
+	encodable := header + "    show-text 0 \"テスト\"\n    exit\n"
+	res, err := Assemble(encodable, 0)
+	if err != nil {
+		t.Fatalf("Assemble: %v", err)
+	}
+
+	// Assembly and patching agree on what SYS4 can hold
+	for _, text := range []string{"😀", "テスト😀", "한국어"} {
+		_, err := Assemble(strings.Replace(encodable, "テスト", text, 1), 0)
+		if err == nil || !strings.HasPrefix(err.Error(), "line 5:") || !strings.Contains(err.Error(), "Shift-JIS") {
+			t.Errorf("Assemble of %q = %v, want a Shift-JIS error on line 5", text, err)
+		}
+		if _, err := PatchString(res.Data, 0, 1, text); err == nil {
+			t.Errorf("PatchString of %q succeeded", text)
+		}
+	}
+}
+
+func TestAssembleMinimalScripts(t *testing.T) {
+	tests := []struct {
+		name      string
+		signature string
+		body      string
+		force     FormatVersion
+		wantCount int
+	}{
+		{"empty SYS5", "SYS5501", "", 0, 0},
+		{"empty SYS4", "SYS4415", "", 0, 0},
+		{"single exit", "SYS5501", "    exit\n", 0, 1},
+		{"empty forced SYS4", "SYS5501", "", FormatSYS4, 0},
+		{"single exit forced SYS5", "SYS4415", "    exit\n", FormatSYS5, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := strings.Replace(testHeaderText, "SYS5501", tt.signature, 1) + tt.body
+			res, err := AssembleWithOptions(text, 0, AssembleOptions{Version: tt.force})
+			if err != nil {
+				t.Fatalf("Assemble: %v", err)
+			}
+
+			// The output must read back, including its signature
+			script, err := DisassembleWithOptions(res.Data, DisassembleOptions{StrictHeader: true})
+			if err != nil {
+				t.Fatalf("Disassemble: %v", err)
+			}
+			if len(script.Instructions) != tt.wantCount {
+				t.Errorf("%d instructions, want %d", len(script.Instructions), tt.wantCount)
+			}
+
+			again, err := Assemble(script.ToText(), 0)
+			if err != nil {
+				t.Fatalf("Assemble disassembly: %v", err)
+			}
+			if !bytes.Equal(again.Data, res.Data) {
+				t.Error("disassembly reassembles to different bytes")
+			}
+		})
+	}
+}
+
+func TestAssembleHeaderValidation(t *testing.T) {
+	const section = "==Binary Information - do not edit==\n"
+	const sig = "signature = SYS5501\n"
+	const end = "====\n    exit\n"
+
+	tests := []struct {
+		name    string
+		header  string
+		force   FormatVersion
+		wantErr string // "" = assembles
+	}{
+		{"valid", sig + "local_vars = { 1 2 3 4 5 6 }\n", 0, ""},
+		{"five values", sig + "local_vars = { 1 2 3 4 5 }\n", 0, "line 3: invalid file format: local_vars needs 6 values, got 5"},
+		{"seven values", sig + "local_vars = { 1 2 3 4 5 6 7 }\n", 0, "local_vars needs 6 values, got 7"},
+		{"empty", sig + "local_vars = { }\n", 0, "local_vars needs 6 values, got 0"},
+		{"not a number", sig + "local_vars = { 1 2 x 4 5 6 }\n", 0, `invalid local_vars value "x"`},
+		{"negative", sig + "local_vars = { 1 2 3 -4 5 6 }\n", 0, `invalid local_vars value "-4"`},
+		{"five values forced", sig + "local_vars = { 1 2 3 4 5 }\n", FormatSYS5, "local_vars needs 6 values, got 5"},
+		{"no local_vars", sig, 0, ""},
+		{"no signature", "local_vars = { 1 2 3 4 5 6 }\n", 0, "header has no signature line"},
+		{"no signature forced", "local_vars = { 1 2 3 4 5 6 }\n", FormatSYS4, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := AssembleWithOptions(section+tt.header+end, 0, AssembleOptions{Version: tt.force})
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Assemble = %v, want %s", err, tt.wantErr)
+				}
+				if res != nil {
+					t.Error("failed Assemble returned output")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Assemble: %v", err)
+			}
+
+			script, err := DisassembleWithOptions(res.Data, DisassembleOptions{StrictHeader: true})
+			if err != nil {
+				t.Fatalf("Disassemble: %v", err)
+			}
+			if strings.Contains(tt.header, "{ 1 2 3 4 5 6 }") {
+				h := script.Header
+				got := [6]uint32{h.LocalInteger1, h.LocalFloats, h.LocalStrings1, h.LocalInteger2, h.UnknownData, h.LocalStrings2}
+				if got != [6]uint32{1, 2, 3, 4, 5, 6} {
+					t.Errorf("local_vars = %v, want [1 2 3 4 5 6]", got)
+				}
+			}
+		})
+	}
+}
+
+func TestSignatureFor(t *testing.T) {
+	tests := []struct {
+		sig     string
+		version FormatVersion
+		want    string
+	}{
+		{"SYS5501 ", FormatSYS5, "SYS5501 "},
+		{"SYS5501 ", FormatSYS4, "SYS4501 "},
+		{"SYS4415 ", FormatSYS5, "SYS5415 "},
+		{"", FormatSYS5, "SYS5501 "},
+		{"", FormatSYS4, "SYS4501 "},
+		{"SYS", FormatSYS4, "SYS4501 "},
+		{"ABCD", FormatSYS5, "SYS5501 "},
+	}
+
+	for _, tt := range tests {
+		if got := signatureFor(tt.sig, tt.version); got != tt.want {
+			t.Errorf("signatureFor(%q, SYS%d) = %q, want %q", tt.sig, tt.version, got, tt.want)
+		}
+	}
+}
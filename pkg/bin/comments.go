@@ -0,0 +1,78 @@
+package bin
+
+import "strings"
+
+// CommentMap maps instruction offsets to the trailing comments of their
+// lines. Comments only live in the text, so like LabelMap it is stored as a
+// JSON sidecar: assembly records where each commented instruction ended up,
+// and disassembly attaches the comments again.
+type CommentMap map[int]string
+
+// LoadCommentMap reads a comment map from a JSON file of the form
+// {"0x00001234": "checked against the PS2 release", ...}.
+func LoadCommentMap(path string) (CommentMap, error) {
+	raw, err := loadOffsetMap(path, "comment map")
+	if err != nil {
+		return nil, err
+	}
+	return CommentMap(raw), nil
+}
+
+// Save writes the comment map as JSON with offsets in ascending order.
+func (m CommentMap) Save(path string) error {
+	return saveOffsetMap(path, m)
+}
+
+// CommentMap returns the comments of the script's instructions by offset.
+func (s *Script) CommentMap() CommentMap {
+	comments := make(CommentMap)
+	for _, instr := range s.Instructions {
+		if instr.Comment != "" {
+			comments[instr.Offset] = instr.Comment
+		}
+	}
+	return comments
+}
+
+// ApplyComments sets the comment of every instruction starting at an offset
+// in m. Offsets that are not the start of an instruction are ignored.
+// Returns the number of comments applied.
+func (s *Script) ApplyComments(m CommentMap) int {
+	applied := 0
+	for off, comment := range m {
+		if i := s.instructionIndex(off); i >= 0 {
+			s.Instructions[i].Comment = comment
+			applied++
+		}
+	}
+	return applied
+}
+
+// StripLine returns the code on a line of assembly text as the assembler
+// reads it, without surrounding space, the offset annotation written by
+// ToAnnotatedText or a trailing "; comment".
+func StripLine(line string) string {
+	code := strings.TrimSpace(line)
+	if loc := offsetNoteRE.FindStringIndex(code); loc != nil {
+		code = code[loc[1]:]
+	}
+	code, _ = splitComment(code)
+	return code
+}
+
+// splitComment splits a trailing "; comment" off an assembly line. A
+// semicolon inside a quoted string argument does not start a comment.
+func splitComment(line string) (code, comment string) {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case !inString && c == ';':
+			return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		}
+	}
+	return line, ""
+}
@@ -0,0 +1,67 @@
+package bin
+
+import "math"
+
+// DecodedKind identifies which field of a DecodedArg holds the value
+type DecodedKind int
+
+const (
+	DecodedImmediate DecodedKind = iota // Int holds the literal value
+	DecodedFloat                        // Float holds the literal value
+	DecodedString                       // String holds the decoded text
+	DecodedVariable                     // Space and Index name the variable
+	DecodedLabel                        // Label holds the target label name
+	DecodedArray                        // Array holds the 0x64 data words
+)
+
+// String returns the kind name for display
+func (k DecodedKind) String() string {
+	switch k {
+	case DecodedImmediate:
+		return "immediate"
+	case DecodedFloat:
+		return "float"
+	case DecodedString:
+		return "string"
+	case DecodedVariable:
+		return "variable"
+	case DecodedLabel:
+		return "label"
+	case DecodedArray:
+		return "array"
+	default:
+		return "unknown"
+	}
+}
+
+// DecodedArg is a typed view of an Argument. Only the fields for Kind are set.
+type DecodedArg struct {
+	Kind   DecodedKind
+	Int    int          // DecodedImmediate
+	Float  float32      // DecodedFloat
+	String string       // DecodedString
+	Space  ArgumentType // DecodedVariable: the variable type (e.g. ArgLocalInt)
+	Index  uint32       // DecodedVariable: the variable index
+	Label  string       // DecodedLabel
+	Array  []uint32     // DecodedArray
+}
+
+// Decoded resolves the argument to a single typed value. Labels, strings and
+// data arrays take precedence over the raw type, as in the assembly listing.
+// Types outside the known set decode as immediates of their raw value.
+func (a *Argument) Decoded() DecodedArg {
+	switch {
+	case a.IsLabel:
+		return DecodedArg{Kind: DecodedLabel, Label: a.LabelName}
+	case a.Type == ArgString:
+		return DecodedArg{Kind: DecodedString, String: a.StringVal}
+	case len(a.DataArray) > 0:
+		return DecodedArg{Kind: DecodedArray, Array: a.DataArray}
+	case a.Type == ArgFloat:
+		return DecodedArg{Kind: DecodedFloat, Float: math.Float32frombits(a.RawValue)}
+	case a.Type.IsVariable():
+		return DecodedArg{Kind: DecodedVariable, Space: a.Type, Index: a.RawValue}
+	default:
+		return DecodedArg{Kind: DecodedImmediate, Int: int(a.RawValue)}
+	}
+}
@@ -0,0 +1,41 @@
+package bin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArgumentDecoded(t *testing.T) {
+	script, _ := testScript(t, "    mov local-int:3 7\n"+
+		"    mov global-int:5 2.5\n"+
+		"    show-text 0 \"hi\"\n"+
+		"    copy-local-array local-int:0 [1, 2, 3]\n"+
+		"    jmp done\n"+
+		"\ndone:\n"+
+		"    exit\n")
+	done := script.Labels[script.Instructions[5].Offset]
+
+	tests := []struct {
+		instr, arg int
+		want       DecodedArg
+	}{
+		{0, 0, DecodedArg{Kind: DecodedVariable, Space: ArgLocalInt, Index: 3}},
+		{0, 1, DecodedArg{Kind: DecodedImmediate, Int: 7}},
+		{1, 0, DecodedArg{Kind: DecodedVariable, Space: ArgGlobalInt, Index: 5}},
+		{1, 1, DecodedArg{Kind: DecodedFloat, Float: 2.5}},
+		{2, 1, DecodedArg{Kind: DecodedString, String: "hi"}},
+		{3, 1, DecodedArg{Kind: DecodedArray, Array: []uint32{1, 2, 3}}},
+		{4, 0, DecodedArg{Kind: DecodedLabel, Label: done}},
+	}
+
+	for _, tt := range tests {
+		arg := &script.Instructions[tt.instr].Arguments[tt.arg]
+		got := arg.Decoded()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("instruction %d argument %d decodes to %+v, want %+v", tt.instr, tt.arg, got, tt.want)
+		}
+		if got.Kind.String() == "unknown" {
+			t.Errorf("kind %d has no name", got.Kind)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package bin
+
+// DefaultDialogueOpcodes lists the mnemonics of the instructions that display
+// dialogue text. The first argument of each is the speaker, 0 for narration.
+var DefaultDialogueOpcodes = []string{"show-text", "display-furigana"}
+
+// IsDialogueOpcode reports whether mnemonic is one of DefaultDialogueOpcodes.
+func IsDialogueOpcode(mnemonic string) bool {
+	for _, m := range DefaultDialogueOpcodes {
+		if m == mnemonic {
+			return true
+		}
+	}
+	return false
+}
+
+// DialogueLine is one dialogue instruction found by DialogueLines
+type DialogueLine struct {
+	Index    int      // Index in Script.Instructions
+	Offset   int      // File offset of the instruction
+	Mnemonic string   // Instruction mnemonic
+	Strings  []string // Decoded string arguments, in argument order
+}
+
+// DialogueLines returns the instructions of DefaultDialogueOpcodes that carry
+// text, in script order.
+func (s *Script) DialogueLines() []DialogueLine {
+	return s.DialogueLinesFor(DefaultDialogueOpcodes)
+}
+
+// DialogueLinesFor returns the instructions with one of the given mnemonics
+// and at least one non-empty string argument, in script order.
+func (s *Script) DialogueLinesFor(mnemonics []string) []DialogueLine {
+	wanted := make(map[string]bool, len(mnemonics))
+	for _, m := range mnemonics {
+		wanted[m] = true
+	}
+
+	var lines []DialogueLine
+	for i := range s.Instructions {
+		instr := &s.Instructions[i]
+		if instr.Unknown || !wanted[instr.Definition.Label] {
+			continue
+		}
+		var strs []string
+		for _, arg := range instr.Arguments {
+			if arg.Type == ArgString && arg.StringVal != "" {
+				strs = append(strs, arg.StringVal)
+			}
+		}
+		if len(strs) == 0 {
+			continue
+		}
+		lines = append(lines, DialogueLine{
+			Index:    i,
+			Offset:   instr.Offset,
+			Mnemonic: instr.Definition.Label,
+			Strings:  strs,
+		})
+	}
+	return lines
+}
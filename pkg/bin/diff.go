@@ -0,0 +1,256 @@
+package bin
+
+import "slices"
+
+// DiffKind classifies a Difference
+type DiffKind int
+
+const (
+	DiffAdded         DiffKind = iota // Instruction only in the second script
+	DiffRemoved                       // Instruction only in the first script
+	DiffOpcodeChanged                 // Paired instructions with different opcodes
+	DiffArgChanged                    // Same opcode, different non-string arguments
+	DiffStringChanged                 // Same opcode, only string arguments differ
+)
+
+// String returns the kind name for display
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffOpcodeChanged:
+		return "opcode-changed"
+	case DiffArgChanged:
+		return "arg-changed"
+	case DiffStringChanged:
+		return "string-changed"
+	default:
+		return "unknown"
+	}
+}
+
+// Difference is one changed instruction between two scripts. Indices and
+// offsets are -1 on the side where the instruction does not exist, and
+// Before/After are then empty.
+type Difference struct {
+	Kind    DiffKind
+	IndexA  int    // Instruction index in the first script
+	IndexB  int    // Instruction index in the second script
+	OffsetA int    // Byte offset in the first script
+	OffsetB int    // Byte offset in the second script
+	Arg     int    // First differing argument, or -1 for the whole instruction
+	Before  string // Instruction text in the first script
+	After   string // Instruction text in the second script
+}
+
+// maxDiffCells bounds the alignment table for the part of two scripts that
+// differs (about 16 MB); larger regions are paired by position instead.
+const maxDiffCells = 4 << 20
+
+// DiffScripts compares two scripts instruction by instruction.
+//
+// Scripts with the same instruction offsets are compared pairwise. Otherwise
+// instructions are aligned by opcode sequence (longest common subsequence
+// between the common prefix and suffix), and in each unmatched gap leftover
+// instructions are paired as opcode changes before the rest is reported as
+// added or removed. Label arguments are equal when they target aligned
+// instructions, and string arguments are compared by text, so the offset
+// shifts caused by an insertion are not reported as changes.
+func DiffScripts(a, b *Script) []Difference {
+	pairs := alignInstructions(a.Instructions, b.Instructions)
+
+	// Map each paired instruction of a to its partner in b, for labels
+	partner := make(map[int]int, len(pairs))
+	for _, p := range pairs {
+		if p[0] >= 0 && p[1] >= 0 {
+			partner[p[0]] = p[1]
+		}
+	}
+
+	var diffs []Difference
+	for _, p := range pairs {
+		d := Difference{IndexA: p[0], IndexB: p[1], OffsetA: -1, OffsetB: -1, Arg: -1}
+		var ia, ib *Instruction
+		if p[0] >= 0 {
+			ia = &a.Instructions[p[0]]
+			d.OffsetA = ia.Offset
+			d.Before = formatInstruction(ia)
+		}
+		if p[1] >= 0 {
+			ib = &b.Instructions[p[1]]
+			d.OffsetB = ib.Offset
+			d.After = formatInstruction(ib)
+		}
+
+		switch {
+		case ia == nil:
+			d.Kind = DiffAdded
+		case ib == nil:
+			d.Kind = DiffRemoved
+		case ia.Opcode != ib.Opcode || len(ia.Arguments) != len(ib.Arguments):
+			d.Kind = DiffOpcodeChanged
+		default:
+			kind, arg, same := compareArguments(a, b, ia, ib, partner)
+			if same {
+				continue
+			}
+			d.Kind, d.Arg = kind, arg
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+// compareArguments compares the arguments of two instructions with the same
+// opcode. It reports DiffStringChanged only if every difference is in string
+// text, with arg set to the first differing argument.
+func compareArguments(a, b *Script, ia, ib *Instruction, partner map[int]int) (kind DiffKind, arg int, same bool) {
+	kind, arg = DiffStringChanged, -1
+	for j := range ia.Arguments {
+		x, y := &ia.Arguments[j], &ib.Arguments[j]
+
+		var equal, isString bool
+		switch {
+		case x.IsLabel && y.IsLabel:
+			ta := a.instructionIndex(a.Header.GetLength() + int(x.RawValue)*4)
+			tb := b.instructionIndex(b.Header.GetLength() + int(y.RawValue)*4)
+			pb, ok := partner[ta]
+			equal = ok && pb == tb
+		case x.Type == ArgString && y.Type == ArgString:
+			equal = x.StringVal == y.StringVal
+			isString = true
+		case len(x.DataArray) > 0 || len(y.DataArray) > 0:
+			equal = slices.Equal(x.DataArray, y.DataArray)
+		default:
+			equal = x.IsLabel == y.IsLabel && x.Type == y.Type && x.RawValue == y.RawValue
+		}
+
+		if equal {
+			continue
+		}
+		if arg < 0 {
+			arg = j
+		}
+		if !isString {
+			kind = DiffArgChanged
+		}
+	}
+	return kind, arg, arg < 0
+}
+
+// alignInstructions pairs the instructions of a and b. Each pair holds an
+// index into a and an index into b, with -1 for an unpaired instruction.
+func alignInstructions(a, b []Instruction) [][2]int {
+	var pairs [][2]int
+
+	// Same layout: compare position by position
+	if len(a) == len(b) {
+		sameOffsets := true
+		for i := range a {
+			if a[i].Offset != b[i].Offset {
+				sameOffsets = false
+				break
+			}
+		}
+		if sameOffsets {
+			for i := range a {
+				pairs = append(pairs, [2]int{i, i})
+			}
+			return pairs
+		}
+	}
+
+	// Trim the common prefix and suffix of the opcode sequence
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix].Opcode == b[prefix].Opcode {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix].Opcode == b[len(b)-1-suffix].Opcode {
+		suffix++
+	}
+
+	for i := 0; i < prefix; i++ {
+		pairs = append(pairs, [2]int{i, i})
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+	for _, p := range alignMiddle(midA, midB) {
+		if p[0] >= 0 {
+			p[0] += prefix
+		}
+		if p[1] >= 0 {
+			p[1] += prefix
+		}
+		pairs = append(pairs, p)
+	}
+
+	for k := suffix; k > 0; k-- {
+		pairs = append(pairs, [2]int{len(a) - k, len(b) - k})
+	}
+	return pairs
+}
+
+// alignMiddle aligns two instruction runs by the longest common subsequence
+// of their opcodes, falling back to positional pairing for large runs.
+func alignMiddle(a, b []Instruction) [][2]int {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 || (n+1)*(m+1) > maxDiffCells {
+		return appendGap(nil, 0, n, 0, m)
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i].Opcode == b[j].Opcode {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var pairs [][2]int
+	i, j := 0, 0
+	gapA, gapB := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i].Opcode == b[j].Opcode:
+			pairs = appendGap(pairs, gapA, i, gapB, j)
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+			gapA, gapB = i, j
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return appendGap(pairs, gapA, n, gapB, m)
+}
+
+// appendGap pairs a[startA:endA] with b[startB:endB] position by position and
+// leaves the longer side's remainder unpaired.
+func appendGap(pairs [][2]int, startA, endA, startB, endB int) [][2]int {
+	for startA < endA && startB < endB {
+		pairs = append(pairs, [2]int{startA, startB})
+		startA++
+		startB++
+	}
+	for ; startA < endA; startA++ {
+		pairs = append(pairs, [2]int{startA, -1})
+	}
+	for ; startB < endB; startB++ {
+		pairs = append(pairs, [2]int{-1, startB})
+	}
+	return pairs
+}
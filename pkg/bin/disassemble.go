@@ -2,68 +2,132 @@ package bin
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/text/encoding/japanese"
 	"golang.org/x/text/transform"
 )
 
+// RawWordDirective is the mnemonic of the synthetic instruction that holds an
+// unknown opcode and the words following it; see DisassembleOptions.
+const RawWordDirective = ".word"
+
+// DisassembleOptions controls how unrecognized code is handled.
+type DisassembleOptions struct {
+	// TolerateUnknown emits an unknown opcode, together with the argument
+	// words that follow it, as raw .word directives and keeps going instead
+	// of stopping the disassembly there. String arguments are kept as text
+	// so the footer reassembles unchanged; any other value, including a
+	// possible code address, is reassembled verbatim.
+	TolerateUnknown bool
+
+	// CollectStrings fills Script.Strings with every decoded string. The
+	// strings are always available on the arguments themselves.
+	CollectStrings bool
+
+	// StrictHeader fails the disassembly when Header.Validate reports a
+	// problem. Otherwise the problems are kept in Script.HeaderErr and the
+	// script is disassembled as usual.
+	StrictHeader bool
+}
+
+// Allocation estimates for the first pass. Instructions average roughly 20
+// bytes in game scripts; arguments are carved from shared chunks.
+const (
+	estimatedInstructionSize = 20
+	argumentChunkSize        = 4096
+)
+
 // Disassemble parses a BIN file and returns a Script structure
 func Disassemble(data []byte) (*Script, error) {
+	return DisassembleWithOptions(data, DisassembleOptions{})
+}
+
+// DisassembleReader reads a BIN file from r and disassembles it. The whole
+// file is read first, since footer data is addressed by offset. Readers that
+// know their size, such as *io.SectionReader or *bytes.Reader, are read with
+// a single ReadAt (see DisassembleReaderAt).
+func DisassembleReader(r io.Reader) (*Script, error) {
+	if ra, ok := r.(interface {
+		io.ReaderAt
+		Size() int64
+	}); ok {
+		return DisassembleReaderAt(ra, ra.Size())
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	return Disassemble(data)
+}
+
+// DisassembleReaderAt disassembles the size-byte BIN file readable from r,
+// for example an archive entry exposed as an *io.SectionReader.
+func DisassembleReaderAt(r io.ReaderAt, size int64) (*Script, error) {
+	data := make([]byte, size)
+	if n, err := r.ReadAt(data, 0); n < len(data) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	return Disassemble(data)
+}
+
+// DisassembleWithOptions parses a BIN file using the given options
+func DisassembleWithOptions(data []byte, opts DisassembleOptions) (*Script, error) {
 	header, err := ReadHeader(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
 
-	script := &Script{
-		Header:  *header,
-		Labels:  make(map[int]string),
-		RawData: data,
+	headerErr := header.Validate(len(data))
+	if headerErr != nil && opts.StrictHeader {
+		return nil, headerErr
 	}
 
-	// Calculate where instruction data ends
-	dataEnd := header.DataArrayEnd()
-	if dataEnd == 0 || dataEnd > len(data) {
-		// Try to find end by parsing until we hit string data
-		dataEnd = len(data)
+	script := &Script{
+		Header:    *header,
+		Labels:    make(map[int]string),
+		RawData:   data,
+		HeaderErr: headerErr,
 	}
 
-	// First pass: parse all instructions
-	offset := header.GetLength()
-	for offset < dataEnd {
-		instr, err := parseInstruction(data, offset, header)
-		if err != nil {
-			// If we hit an error, we might have reached footer data
-			break
-		}
-		script.Instructions = append(script.Instructions, instr)
-		offset += instr.Size()
-	}
+	// Calculate where instruction data ends, scanning for the start of the
+	// string/array footer when the script has no tables
+	dataEnd := header.dataArrayEndScan(data, opts.TolerateUnknown)
 
-	// Build instruction offset map first
-	instrOffsets := make(map[int]bool)
-	for i := range script.Instructions {
-		instrOffsets[script.Instructions[i].Offset] = true
+	// First pass: parse all instructions
+	headerLen := header.GetLength()
+	if dataEnd > headerLen {
+		script.Instructions = make([]Instruction, 0, (dataEnd-headerLen)/estimatedInstructionSize)
 	}
+	var pool argumentPool
+	walkInstructions(data, header, dataEnd, opts.TolerateUnknown, &pool, func(instr Instruction) error {
+		script.Instructions = append(script.Instructions, instr)
+		return nil
+	})
 
 	// Second pass: identify labels from control flow instructions
-	labelOffsets := make(map[int]bool)
 	for i := range script.Instructions {
 		instr := &script.Instructions[i]
 		if IsControlFlow(instr.Opcode) {
 			for j := range instr.Arguments {
 				if IsLabelArgument(instr, j) {
 					// Calculate target offset
-					targetOffset := header.GetLength() + int(instr.Arguments[j].RawValue)*4
+					targetOffset := headerLen + int(instr.Arguments[j].RawValue)*4
 
 					// Only create label if target offset exists in code
-					if instrOffsets[targetOffset] {
-						labelOffsets[targetOffset] = true
+					if script.instructionIndex(targetOffset) >= 0 {
 						instr.Arguments[j].IsLabel = true
-						instr.Arguments[j].LabelName = fmt.Sprintf("label_%08X", targetOffset)
+						instr.Arguments[j].LabelName = script.labelAt(targetOffset)
 					}
 					// Otherwise, leave as raw value (external function address)
 				}
@@ -71,49 +135,166 @@ func Disassemble(data []byte) (*Script, error) {
 		}
 	}
 
-	// Create label map
-	for off := range labelOffsets {
-		script.Labels[off] = fmt.Sprintf("label_%08X", off)
-	}
-
 	// Third pass: decode strings for string arguments
+	collect := &script.Strings
+	if !opts.CollectStrings {
+		collect = nil
+	}
 	for i := range script.Instructions {
-		instr := &script.Instructions[i]
-		for j := range instr.Arguments {
-			arg := &instr.Arguments[j]
-			if arg.Type == ArgString {
-				strOffset := header.GetLength() + int(arg.RawValue)*4
-				str, err := decodeString(data, strOffset, header.Version)
-				if err == nil {
-					arg.StringVal = str
-					script.Strings = append(script.Strings, str)
-				}
+		resolveData(&script.Instructions[i], data, header, collect)
+	}
+
+	script.StringOrder = footerStringOrder(script.Instructions, headerLen)
+
+	// Read footer tables
+	script.Tables[0] = readTable(data, header.GetLength()+int(header.Table1Offset)*4, int(header.Table1Length))
+	script.Tables[1] = readTable(data, header.GetLength()+int(header.Table2Offset)*4, int(header.Table2Length))
+	script.Tables[2] = readTable(data, header.GetLength()+int(header.Table3Offset)*4, int(header.Table3Length))
+	script.resolveTableLabels()
+
+	return script, nil
+}
+
+// labelAt returns the name of the label at offset, creating one if needed
+func (s *Script) labelAt(offset int) string {
+	name, ok := s.Labels[offset]
+	if !ok {
+		name = fmt.Sprintf("label_%08X", offset)
+		s.Labels[offset] = name
+	}
+	return name
+}
+
+// resolveTableLabels labels the instruction each table entry points at and
+// records the names in TableLabels. Entries are offsets in 4-byte units from
+// the header end; those that do not land on an instruction stay unnamed.
+// The assembler rebuilds the tables from the opcodes, so the labels only
+// serve as jump targets in the text.
+func (s *Script) resolveTableLabels() {
+	headerLen := s.Header.GetLength()
+	for t, table := range s.Tables {
+		if len(table) == 0 {
+			continue
+		}
+		names := make([]string, len(table))
+		for i, v := range table {
+			off := headerLen + int(v)*4
+			if s.instructionIndex(off) >= 0 {
+				names[i] = s.labelAt(off)
 			}
 		}
+		s.TableLabels[t] = names
+	}
+}
+
+// walkInstructions parses the instructions from the end of the header up to
+// end and calls fn for each, stopping at the first one that fails to parse
+// (usually the start of footer data) or when fn returns an error, which is
+// passed on.
+func walkInstructions(data []byte, header *Header, end int, tolerateUnknown bool, pool *argumentPool, fn func(Instruction) error) error {
+	offset := header.GetLength()
+	for offset < end {
+		instr, err := parseInstructionPooled(data, offset, header, pool)
+		if err != nil && tolerateUnknown && errors.Is(err, ErrUnknownOpcode) {
+			instr, err = parseUnknownInstruction(data, offset, end), nil
+		}
+		if err != nil {
+			// If we hit an error, we might have reached footer data
+			return nil
+		}
+		if err := fn(instr); err != nil {
+			return err
+		}
+		offset += instr.Size()
+	}
+	return nil
+}
 
-		// Handle copy-local-array (0x64) - second argument is array reference
-		if instr.Opcode == 0x64 && len(instr.Arguments) >= 2 {
-			arg := &instr.Arguments[1]
-			if arg.Type == ArgString || arg.Type == ArgImmediate {
-				arrayOffset := header.GetLength() + int(arg.RawValue)*4
-				arr, err := readDataArray(data, arrayOffset)
-				if err == nil {
-					arg.DataArray = arr
+// resolveData decodes the footer strings and data arrays referenced by the
+// arguments of instr, appending each decoded string to collect unless it is nil
+func resolveData(instr *Instruction, data []byte, header *Header, collect *[]string) {
+	headerLen := header.GetLength()
+	for j := range instr.Arguments {
+		arg := &instr.Arguments[j]
+		if arg.Type == ArgString {
+			strOffset := headerLen + int(arg.RawValue)*4
+			if str, err := decodeString(data, strOffset, header.Version); err == nil {
+				arg.StringVal = str
+				if collect != nil {
+					*collect = append(*collect, str)
 				}
 			}
 		}
 	}
 
-	// Read footer tables
-	script.Tables[0] = readTable(data, header.GetLength()+int(header.Table1Offset)*4, int(header.Table1Length))
-	script.Tables[1] = readTable(data, header.GetLength()+int(header.Table2Offset)*4, int(header.Table2Length))
-	script.Tables[2] = readTable(data, header.GetLength()+int(header.Table3Offset)*4, int(header.Table3Length))
+	// Handle copy-local-array (0x64) - second argument is array reference
+	if instr.Opcode == 0x64 && len(instr.Arguments) >= 2 {
+		arg := &instr.Arguments[1]
+		if arg.Type == ArgString || arg.Type == ArgImmediate {
+			arrayOffset := headerLen + int(arg.RawValue)*4
+			if arr, err := readDataArray(data, arrayOffset); err == nil {
+				arg.DataArray = arr
+			}
+		}
+	}
+}
 
-	return script, nil
+// footerStringOrder returns the decoded string arguments ordered by where
+// their text sits in the footer, or nil if that is already instruction order
+// (the layout the assembler produces by default).
+func footerStringOrder(instrs []Instruction, headerLen int) []StringRef {
+	var refs []StringRef
+	inOrder := true
+	for i := range instrs {
+		for _, arg := range instrs[i].Arguments {
+			if arg.Type != ArgString || arg.StringVal == "" {
+				continue
+			}
+			offset := headerLen + int(arg.RawValue)*4
+			if n := len(refs); n > 0 && offset < refs[n-1].Offset {
+				inOrder = false
+			}
+			refs = append(refs, StringRef{Offset: offset, Value: arg.StringVal})
+		}
+	}
+	if inOrder {
+		return nil
+	}
+
+	sort.SliceStable(refs, func(a, b int) bool { return refs[a].Offset < refs[b].Offset })
+	return refs
+}
+
+// argumentPool hands out argument slices carved from larger chunks, so that
+// parsing does not allocate once per instruction.
+type argumentPool struct {
+	buf []Argument
+}
+
+// get returns a zeroed slice of n arguments with its capacity clipped to n,
+// or nil for no arguments.
+func (p *argumentPool) get(n int) []Argument {
+	if n == 0 {
+		return nil
+	}
+	if p == nil {
+		return make([]Argument, n)
+	}
+	if len(p.buf) < n {
+		p.buf = make([]Argument, max(n, argumentChunkSize))
+	}
+	args := p.buf[:n:n]
+	p.buf = p.buf[n:]
+	return args
 }
 
 // parseInstruction parses a single instruction from the data
 func parseInstruction(data []byte, offset int, header *Header) (Instruction, error) {
+	return parseInstructionPooled(data, offset, header, nil)
+}
+
+// parseInstructionPooled is parseInstruction taking the arguments from pool
+func parseInstructionPooled(data []byte, offset int, header *Header, pool *argumentPool) (Instruction, error) {
 	if offset+4 > len(data) {
 		return Instruction{}, ErrUnexpectedEOF
 	}
@@ -128,7 +309,7 @@ func parseInstruction(data []byte, offset int, header *Header) (Instruction, err
 		Offset:     offset,
 		Opcode:     opcode,
 		Definition: def,
-		Arguments:  make([]Argument, def.ArgCount),
+		Arguments:  pool.get(def.ArgCount),
 	}
 
 	argOffset := offset + 4
@@ -146,6 +327,36 @@ func parseInstruction(data []byte, offset int, header *Header) (Instruction, err
 	return instr, nil
 }
 
+// parseUnknownInstruction wraps an unknown opcode at offset into a synthetic
+// instruction. The argument count is not known, so words are taken as
+// (type, value) pairs for as long as the type word is a known argument type.
+// Low opcodes share values with argument types and may be swallowed too;
+// that only loses decoding, since the words are reassembled verbatim.
+func parseUnknownInstruction(data []byte, offset, end int) Instruction {
+	opcode := binary.LittleEndian.Uint32(data[offset:])
+	instr := Instruction{
+		Offset:     offset,
+		Opcode:     opcode,
+		Definition: &InstructionDefinition{Opcode: opcode, Label: RawWordDirective},
+		Unknown:    true,
+	}
+
+	pos := offset + 4
+	for pos+8 <= end {
+		argType := ArgumentType(binary.LittleEndian.Uint32(data[pos:]))
+		if argType.String() == "unknown" {
+			break
+		}
+		instr.Arguments = append(instr.Arguments, Argument{
+			Type:     argType,
+			RawValue: binary.LittleEndian.Uint32(data[pos+4:]),
+		})
+		pos += 8
+	}
+
+	return instr
+}
+
 // decodeString decodes a XOR'd string from the data
 func decodeString(data []byte, offset int, version FormatVersion) (string, error) {
 	if offset >= len(data) {
@@ -153,17 +364,18 @@ func decodeString(data []byte, offset int, version FormatVersion) (string, error
 	}
 
 	if version == FormatSYS5 {
-		// UTF-16LE XOR'd with 0xFFFF
-		var runes []rune
-		for i := offset; i+1 < len(data); i += 2 {
-			char := binary.LittleEndian.Uint16(data[i:])
-			if char == 0xFFFF {
-				break
-			}
-			decoded := char ^ 0xFFFF
-			runes = append(runes, rune(decoded))
+		// UTF-16LE XOR'd with 0xFFFF; find the terminator first so the
+		// output is sized once
+		end := offset
+		for end+1 < len(data) && binary.LittleEndian.Uint16(data[end:]) != 0xFFFF {
+			end += 2
+		}
+		var sb strings.Builder
+		sb.Grow(end - offset)
+		for i := offset; i < end; i += 2 {
+			sb.WriteRune(rune(binary.LittleEndian.Uint16(data[i:]) ^ 0xFFFF))
 		}
-		return string(runes), nil
+		return sb.String(), nil
 	}
 
 	// SYS4: Shift-JIS XOR'd with 0xFF
@@ -215,30 +427,133 @@ func readTable(data []byte, offset int, length int) []uint32 {
 	return table
 }
 
-// ToText converts a Script to human-readable assembly text
+// RenderOptions controls optional annotations in the text output.
+// Annotations are emitted as comments and are ignored on assembly.
+type RenderOptions struct {
+	FunctionHeaders bool // Mark call-target labels with a "// function N" comment
+	Offsets         bool // Prefix instruction lines with their file offset and argument types
+}
+
+// ToText converts a Script to human-readable assembly text.
+//
+// The rendered format is deterministic:
+//   - the header block ends with a "====" line, directly followed by the first
+//     label or instruction (no blank line)
+//   - every label after the first output line is preceded by exactly one blank line
+//   - instructions are indented with four spaces, one per line
 func (s *Script) ToText() string {
+	return s.ToTextWithOptions(RenderOptions{})
+}
+
+// ToAnnotatedText converts a Script to assembly text with every instruction
+// line prefixed by its file offset and the type index of each argument, as
+// in "0x00000044: [09 00]    mov local-int:0 5". The assembler skips the
+// prefix, so the annotated text assembles like the plain text.
+func (s *Script) ToAnnotatedText() string {
+	return s.ToTextWithOptions(RenderOptions{Offsets: true})
+}
+
+// ToTextWithOptions converts a Script to assembly text with optional annotations
+func (s *Script) ToTextWithOptions(opts RenderOptions) string {
 	var sb strings.Builder
 
+	// Collect call targets (function entries)
+	functionIndex := make(map[int]int)
+	if opts.FunctionHeaders {
+		for _, off := range s.FunctionEntries() {
+			functionIndex[off] = len(functionIndex)
+		}
+	}
+
 	// Write header info
+	sb.WriteString(s.HeaderText())
+
+	// Write instructions
+	s.writeInstructions(&sb, s.Instructions, functionIndex, opts.Offsets)
+
+	// Write the jump tables as comments; the assembler rebuilds them
+	s.writeTables(&sb)
+
+	return sb.String()
+}
+
+// writeTables writes one "// tableN: label, ..." comment per nonempty table,
+// after a blank line. Entries without a label are written as file offsets.
+func (s *Script) writeTables(sb *strings.Builder) {
+	headerLen := s.Header.GetLength()
+	first := true
+	for t, table := range s.Tables {
+		if len(table) == 0 {
+			continue
+		}
+		if first {
+			sb.WriteString("\n")
+			first = false
+		}
+		parts := make([]string, len(table))
+		for i, v := range table {
+			if i < len(s.TableLabels[t]) && s.TableLabels[t][i] != "" {
+				parts[i] = s.TableLabels[t][i]
+			} else {
+				parts[i] = fmt.Sprintf("0x%08X", headerLen+int(v)*4)
+			}
+		}
+		sb.WriteString(fmt.Sprintf("// table%d: %s\n", t+1, strings.Join(parts, ", ")))
+	}
+}
+
+// HeaderText returns the "==Binary Information==" block that starts the text output
+func (s *Script) HeaderText() string {
+	var sb strings.Builder
 	sb.WriteString("==Binary Information - do not edit==\n")
 	sb.WriteString(fmt.Sprintf("signature = %s\n", strings.TrimRight(s.Header.Signature, "\x00 ")))
 	sb.WriteString(fmt.Sprintf("local_vars = { %d %d %d %d %d %d }\n",
 		s.Header.LocalInteger1, s.Header.LocalFloats, s.Header.LocalStrings1,
 		s.Header.LocalInteger2, s.Header.UnknownData, s.Header.LocalStrings2))
-	sb.WriteString("====\n\n")
-
-	// Get sorted label offsets for output
-	sortedOffsets := make([]int, 0, len(s.Instructions))
-	for _, instr := range s.Instructions {
-		sortedOffsets = append(sortedOffsets, instr.Offset)
-	}
-	sort.Ints(sortedOffsets)
+	sb.WriteString("====\n")
+	return sb.String()
+}
 
-	// Write instructions
-	for _, instr := range s.Instructions {
+// writeInstructions writes instructions with their labels, separating each
+// label after the first line with one blank line. With offsets, each
+// instruction line starts with an offset annotation (see ToAnnotatedText).
+func (s *Script) writeInstructions(sb *strings.Builder, instrs []Instruction, functionIndex map[int]int, offsets bool) {
+	for n, instr := range instrs {
 		// Check if this offset has a label
 		if label, ok := s.Labels[instr.Offset]; ok {
-			sb.WriteString(fmt.Sprintf("\n%s:\n", label))
+			if n > 0 {
+				sb.WriteString("\n")
+			}
+			if idx, isFunc := functionIndex[instr.Offset]; isFunc {
+				sb.WriteString(fmt.Sprintf("// function %d\n", idx))
+			}
+			sb.WriteString(fmt.Sprintf("%s:\n", label))
+		}
+
+		// Unknown opcode: the opcode word, then one line per argument pair.
+		// Strings stay symbolic so the footer is rebuilt around them.
+		if instr.Unknown {
+			if offsets {
+				sb.WriteString(fmt.Sprintf("0x%08X:", instr.Offset))
+			}
+			sb.WriteString(fmt.Sprintf("    %s 0x%08X", RawWordDirective, instr.Opcode))
+			writeComment(sb, instr.Comment)
+			sb.WriteString("\n")
+			for i, arg := range instr.Arguments {
+				if offsets {
+					sb.WriteString(fmt.Sprintf("0x%08X:", instr.Offset+4+i*8))
+				}
+				if arg.Type == ArgString && arg.StringVal != "" {
+					sb.WriteString(fmt.Sprintf("    %s 0x%08X %s\n", RawWordDirective, uint32(arg.Type), formatArgument(&arg, &instr, 0)))
+				} else {
+					sb.WriteString(fmt.Sprintf("    %s 0x%08X 0x%08X\n", RawWordDirective, uint32(arg.Type), arg.RawValue))
+				}
+			}
+			continue
+		}
+
+		if offsets {
+			writeOffsetAnnotation(sb, &instr)
 		}
 
 		// Write instruction
@@ -251,10 +566,87 @@ func (s *Script) ToText() string {
 			}
 			sb.WriteString(formatArgument(&arg, &instr, i))
 		}
+		writeComment(sb, instr.Comment)
 		sb.WriteString("\n")
 	}
+}
 
-	return sb.String()
+// writeComment appends " ; comment" to an instruction line. Line breaks in
+// the comment are replaced with spaces so it stays on the line.
+func writeComment(sb *strings.Builder, comment string) {
+	if comment == "" {
+		return
+	}
+	comment = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(comment)
+	sb.WriteString(" ; ")
+	sb.WriteString(comment)
+}
+
+// writeOffsetAnnotation writes the "0x%08X: [types]" prefix of an annotated
+// instruction line. Argument types are written as hex type indices.
+func writeOffsetAnnotation(sb *strings.Builder, instr *Instruction) {
+	sb.WriteString(fmt.Sprintf("0x%08X:", instr.Offset))
+	if len(instr.Arguments) == 0 {
+		return
+	}
+	sb.WriteString(" [")
+	for i, arg := range instr.Arguments {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(fmt.Sprintf("%02X", uint32(arg.Type)))
+	}
+	sb.WriteString("]")
+}
+
+// FunctionText is the disassembly of one function in split output
+type FunctionText struct {
+	Name string // Entry label, or "_start" for code before the first function
+	Text string // Instructions without the header block
+}
+
+// SplitFunctions renders the instructions as one text per function.
+// A function runs from a call-target label up to the next call-target label,
+// so concatenating HeaderText and every Text in order reproduces the script.
+func (s *Script) SplitFunctions(opts RenderOptions) []FunctionText {
+	entries := s.FunctionEntries()
+	functionIndex := make(map[int]int, len(entries))
+	for _, off := range entries {
+		functionIndex[off] = len(functionIndex)
+	}
+
+	var result []FunctionText
+	start := 0
+	for i := 0; i <= len(s.Instructions); i++ {
+		atEnd := i == len(s.Instructions)
+		if !atEnd {
+			if _, isFunc := functionIndex[s.Instructions[i].Offset]; !isFunc || i == start {
+				continue
+			}
+		}
+		if i == start {
+			break
+		}
+
+		name := "_start"
+		if label, ok := s.Labels[s.Instructions[start].Offset]; ok {
+			if _, isFunc := functionIndex[s.Instructions[start].Offset]; isFunc {
+				name = label
+			}
+		}
+
+		headers := functionIndex
+		if !opts.FunctionHeaders {
+			headers = nil
+		}
+
+		var sb strings.Builder
+		s.writeInstructions(&sb, s.Instructions[start:i], headers, opts.Offsets)
+		result = append(result, FunctionText{Name: name, Text: sb.String()})
+		start = i
+	}
+
+	return result
 }
 
 // formatArgument formats an argument for text output
@@ -284,23 +676,58 @@ func formatArgument(arg *Argument, instr *Instruction, argIdx int) string {
 		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
 	}
 
+	// Float value
+	if arg.Type == ArgFloat {
+		return formatFloat(arg.RawValue)
+	}
+
 	// Variable reference with type prefix
 	typeStr := arg.Type.String()
 	if typeStr != "" {
 		return fmt.Sprintf("%s:%d", typeStr, arg.RawValue)
 	}
 
-	// Float value
-	if arg.Type == ArgFloat {
-		bits := arg.RawValue
-		f := math.Float32frombits(bits)
-		return fmt.Sprintf("%g", f)
-	}
-
 	// Immediate value
 	return fmt.Sprintf("%d", arg.RawValue)
 }
 
+// formatFloat renders float32 bits as the shortest decimal that reads back
+// to the same bits, with a ".0" suffix when needed to keep it from parsing as
+// an immediate. Values the assembler cannot reproduce from a decimal, such as
+// NaNs with a payload, are written as raw bits in the "float:0x<bits>" form.
+func formatFloat(bits uint32) string {
+	text := strconv.FormatFloat(float64(math.Float32frombits(bits)), 'g', -1, 32)
+	if !strings.ContainsAny(text, ".eIN") {
+		text += ".0"
+	}
+	if val, err := strconv.ParseFloat(text, 32); err != nil || math.Float32bits(float32(val)) != bits {
+		return fmt.Sprintf("float:0x%08X", bits)
+	}
+	return text
+}
+
+// formatInstruction renders one instruction as it appears in ToText, without
+// indentation. Unknown opcodes show the opcode word.
+func formatInstruction(instr *Instruction) string {
+	var sb strings.Builder
+	if instr.Unknown {
+		sb.WriteString(fmt.Sprintf("%s 0x%08X", RawWordDirective, instr.Opcode))
+	} else {
+		sb.WriteString(instr.Definition.Label)
+	}
+	for i := range instr.Arguments {
+		sb.WriteString(" ")
+		sb.WriteString(formatArgument(&instr.Arguments[i], instr, i))
+	}
+	return sb.String()
+}
+
+// String renders the instruction as one line of assembly text, without
+// indentation or offset annotation.
+func (i *Instruction) String() string {
+	return formatInstruction(i)
+}
+
 // DisassembleToText is a convenience function that disassembles and returns text
 func DisassembleToText(data []byte) (string, error) {
 	script, err := Disassemble(data)
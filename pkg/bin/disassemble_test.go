@@ -0,0 +1,243 @@
+package bin
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFloatArgumentsRoundTrip(t *testing.T) {
+	bits := []uint32{
+		0x00000000, // +0
+		0x80000000, // -0
+		0x3FC00000, // 1.5
+		0xBF800000, // -1
+		0x3DCCCCCD, // 0.1
+		0x7F7FFFFF, // largest finite
+		0x00800000, // smallest normal
+		0x00000001, // smallest denormal
+		0x007FFFFF, // largest denormal
+		0x80000001, // negative denormal
+		0x7F800000, // +Inf
+		0xFF800000, // -Inf
+		0x7FC00000, // quiet NaN
+		0xFFC00000, // negative quiet NaN
+		0x7FC00001, // NaN with payload
+		0x7F800001, // signaling NaN
+		0xFFFFFFFF, // all bits set, a NaN
+	}
+	// A coarse sweep across every exponent and sign
+	for b := uint64(0x00012345); b < 1<<32; b += 0x00F0F0F1 {
+		bits = append(bits, uint32(b))
+	}
+
+	var body strings.Builder
+	for _, b := range bits {
+		fmt.Fprintf(&body, "    mov local-int:0 float:0x%08X\n", b)
+	}
+	body.WriteString("    exit\n")
+	script, data := testScript(t, body.String())
+
+	for i, b := range bits {
+		arg := script.Instructions[i].Arguments[1]
+		if arg.Type != ArgFloat || arg.RawValue != b {
+			t.Errorf("argument %d = type %v bits 0x%08X, want float bits 0x%08X", i, arg.Type, arg.RawValue, b)
+		}
+	}
+
+	text := script.ToText()
+	res, err := Assemble(text, FormatSYS5)
+	if err != nil {
+		t.Fatalf("Assemble: %v", err)
+	}
+	if !bytes.Equal(res.Data, data) {
+		t.Error("disassembled floats reassemble to different bytes")
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		bits uint32
+		want string
+	}{
+		{math.Float32bits(1.5), "1.5"},
+		{math.Float32bits(2), "2.0"},
+		{math.Float32bits(-0.1), "-0.1"},
+		{0x80000000, "-0.0"},
+		{0x7F800000, "+Inf"},
+		{0xFF800000, "-Inf"},
+		{0x00000001, "1e-45"},
+		{0x7FC00001, "float:0x7FC00001"},
+	}
+
+	for _, tt := range tests {
+		if got := formatFloat(tt.bits); got != tt.want {
+			t.Errorf("formatFloat(0x%08X) = %q, want %q", tt.bits, got, tt.want)
+		}
+	}
+}
+
+func TestDataArrayFooterOffsets(t *testing.T) {
+	// Enough instructions that argument positions run past 100, with large
+	// arrays between strings so every footer offset is distinct
+	var body strings.Builder
+	var arrays [][]uint32
+	for k := 0; k < 150; k++ {
+		if k%3 != 0 {
+			fmt.Fprintf(&body, "    show-text 0 \"line %d\"\n", k)
+			continue
+		}
+		arr := make([]uint32, 200+k)
+		var parts []string
+		for i := range arr {
+			arr[i] = uint32(k<<16 | i)
+			parts = append(parts, fmt.Sprint(arr[i]))
+		}
+		arrays = append(arrays, arr)
+		fmt.Fprintf(&body, "    copy-local-array local-int:%d [%s]\n", k, strings.Join(parts, ", "))
+	}
+	body.WriteString("    exit\n")
+	script, data := testScript(t, body.String())
+
+	var got [][]uint32
+	for _, instr := range script.Instructions {
+		if instr.Opcode != 0x64 {
+			continue
+		}
+		arg := instr.Arguments[1]
+		offset := script.Header.GetLength() + int(arg.RawValue)*4
+		arr, err := readDataArray(data, offset)
+		if err != nil {
+			t.Fatalf("array at 0x%X: %v", offset, err)
+		}
+		got = append(got, arr)
+	}
+	if len(got) != len(arrays) {
+		t.Fatalf("%d arrays, want %d", len(got), len(arrays))
+	}
+	for i := range arrays {
+		if !slices.Equal(got[i], arrays[i]) {
+			t.Errorf("array %d read from its footer offset differs from the one assembled", i)
+		}
+	}
+
+	res, err := Assemble(script.ToText(), FormatSYS5)
+	if err != nil {
+		t.Fatalf("Assemble: %v", err)
+	}
+	if !bytes.Equal(res.Data, data) {
+		t.Error("disassembled arrays reassemble to different bytes")
+	}
+}
+
+// testLargeScript assembles a script of about n instructions mixing
+// dialogue strings, variable moves and jumps, like a long scenario file.
+func testLargeScript(tb testing.TB, n int) []byte {
+	tb.Helper()
+
+	var body strings.Builder
+	for k := 0; k < n/4; k++ {
+		fmt.Fprintf(&body, "\nlabel_%d:\n", k)
+		fmt.Fprintf(&body, "    mov local-int:%d %d\n", k%16, k)
+		fmt.Fprintf(&body, "    show-text 0 \"dialogue line %d of the benchmark scenario\"\n", k)
+		fmt.Fprintf(&body, "    mov local-float:%d 1.5\n", k%8)
+		fmt.Fprintf(&body, "    jmp label_%d\n", (k+1)%(n/4))
+	}
+	res, err := Assemble(testHeaderText+body.String(), FormatSYS5)
+	if err != nil {
+		tb.Fatalf("Assemble: %v", err)
+	}
+	return res.Data
+}
+
+func BenchmarkDisassemble(b *testing.B) {
+	data := testLargeScript(b, 200000)
+
+	tests := []struct {
+		name string
+		opts DisassembleOptions
+	}{
+		{"default", DisassembleOptions{}},
+		{"collect strings", DisassembleOptions{CollectStrings: true}},
+	}
+
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for b.Loop() {
+				if _, err := DisassembleWithOptions(data, tt.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestToTextDeterministic(t *testing.T) {
+	script, data := testScript(t, "    call label_00000001\n"+
+		"    u0041A7B0 0\n"+
+		"    jcc local-int:0 1 label_00000002\n"+
+		"    exit\n"+
+		"\nlabel_00000001:\n"+
+		"    show-text 0 \"first\"\n"+
+		"    ret\n"+
+		"\nlabel_00000002:\n"+
+		"    call label_00000001\n"+
+		"    jmp label_00000001\n")
+
+	tests := []struct {
+		name string
+		opts RenderOptions
+	}{
+		{"plain", RenderOptions{}},
+		{"function headers", RenderOptions{FunctionHeaders: true}},
+		{"offsets", RenderOptions{Offsets: true}},
+		{"both", RenderOptions{FunctionHeaders: true, Offsets: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := script.ToTextWithOptions(tt.opts)
+			// Map iteration order changes between runs, so render repeatedly
+			for range 20 {
+				if got := script.ToTextWithOptions(tt.opts); got != first {
+					t.Fatalf("rendering changed:\n%s\nthen:\n%s", first, got)
+				}
+			}
+
+			// The rendering reassembles to the same bytes, and those
+			// disassemble to the same text
+			res, err := Assemble(first, 0)
+			if err != nil {
+				t.Fatalf("Assemble rendering: %v", err)
+			}
+			if !bytes.Equal(res.Data, data) {
+				t.Error("rendering assembles to different bytes")
+			}
+			again, err := Disassemble(res.Data)
+			if err != nil {
+				t.Fatalf("Disassemble: %v", err)
+			}
+			if got := again.ToTextWithOptions(tt.opts); got != first {
+				t.Errorf("round trip changed the text:\n%s\nthen:\n%s", first, got)
+			}
+
+			if strings.Contains(first, "====\n\n") {
+				t.Error("blank line after the header block")
+			}
+			lines := strings.Split(first, "\n")
+			for i := 1; i < len(lines); i++ {
+				if _, ok := LabelDefinition(lines[i]); ok && lines[i-1] != "" && !strings.HasPrefix(lines[i-1], "//") && lines[i-1] != "====" {
+					t.Errorf("label %q is not preceded by a blank line", lines[i])
+				}
+				if lines[i] == "" && lines[i-1] == "" {
+					t.Errorf("two blank lines before line %d", i)
+				}
+			}
+		})
+	}
+}
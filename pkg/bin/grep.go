@@ -0,0 +1,64 @@
+package bin
+
+// StringMatch is a footer string accepted by GrepStrings.
+type StringMatch struct {
+	Offset            int    // File offset of the encoded string
+	Value             string // Decoded string
+	Instruction       string // Mnemonic of the referencing instruction, or "" if unknown
+	InstructionOffset int    // Offset of the referencing instruction, or -1 if unknown
+}
+
+// GrepStrings returns the footer strings of a script for which match returns
+// true, in file order.
+//
+// Strings are found with ScanStrings, so files without a match are never
+// disassembled. For files with matches the script is disassembled (tolerating
+// unknown opcodes) to find the instruction referencing each string; if that
+// fails the matches are returned without instruction context.
+func GrepStrings(data []byte, match func(string) bool) ([]StringMatch, error) {
+	refs, err := ScanStrings(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []StringMatch
+	for _, ref := range refs {
+		if match(ref.Value) {
+			matches = append(matches, StringMatch{Offset: ref.Offset, Value: ref.Value, InstructionOffset: -1})
+		}
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	script, err := DisassembleWithOptions(data, DisassembleOptions{TolerateUnknown: true})
+	if err != nil {
+		return matches, nil
+	}
+
+	// Map each string offset to the first instruction referencing it
+	headerLen := script.Header.GetLength()
+	users := make(map[int]*Instruction)
+	for i := range script.Instructions {
+		inst := &script.Instructions[i]
+		for _, arg := range inst.Arguments {
+			if arg.Type != ArgString || arg.IsLabel {
+				continue
+			}
+			target := headerLen + int(arg.RawValue)*4
+			if _, ok := users[target]; !ok {
+				users[target] = inst
+			}
+		}
+	}
+
+	for i := range matches {
+		if inst, ok := users[matches[i].Offset]; ok {
+			matches[i].InstructionOffset = inst.Offset
+			if inst.Definition != nil {
+				matches[i].Instruction = inst.Definition.Label
+			}
+		}
+	}
+	return matches, nil
+}
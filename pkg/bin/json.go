@@ -0,0 +1,179 @@
+package bin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// scriptJSON is the JSON form of a Script. RawData is not included.
+type scriptJSON struct {
+	Header       Header            `json:"header"`
+	Labels       map[int]string    `json:"labels"`
+	Instructions []instructionJSON `json:"instructions"`
+	Strings      []string          `json:"strings,omitempty"`
+	StringOrder  []StringRef       `json:"string_order,omitempty"`
+	Tables       [3][]uint32       `json:"tables"`
+	TableLabels  [3][]string       `json:"table_labels"`
+}
+
+type instructionJSON struct {
+	Offset    int            `json:"offset"`
+	Opcode    uint32         `json:"opcode"`
+	Mnemonic  string         `json:"mnemonic"`
+	Unknown   bool           `json:"unknown,omitempty"`
+	Comment   string         `json:"comment,omitempty"`
+	Arguments []argumentJSON `json:"args"`
+}
+
+type argumentJSON struct {
+	Type     string   `json:"type"`
+	RawValue uint32   `json:"raw"`
+	String   string   `json:"string,omitempty"`
+	Array    []uint32 `json:"array,omitempty"`
+	Label    string   `json:"label,omitempty"`
+}
+
+// knownArgTypes lists every named argument type, for parsing type names
+var knownArgTypes = []ArgumentType{
+	ArgImmediate, ArgFloat, ArgString,
+	ArgGlobalInt, ArgGlobalFloat, ArgGlobalString, ArgGlobalPtr, ArgGlobalStringPtr,
+	ArgLocalInt, ArgLocalFloat, ArgLocalString, ArgLocalPtr, ArgLocalFloatPtr, ArgLocalStringPtr,
+	ArgExtended8003, ArgExtended8005, ArgExtended8009, ArgExtended800B,
+}
+
+// argTypeName returns a name for t that argTypeFromName maps back to t.
+// Immediates are "immediate" and unnamed types are written in hex.
+func argTypeName(t ArgumentType) string {
+	switch name := t.String(); name {
+	case "":
+		return "immediate"
+	case "unknown":
+		return fmt.Sprintf("0x%X", uint32(t))
+	default:
+		return name
+	}
+}
+
+// argTypeFromName parses a name produced by argTypeName
+func argTypeFromName(name string) (ArgumentType, error) {
+	if name == "immediate" {
+		return ArgImmediate, nil
+	}
+	for _, t := range knownArgTypes {
+		if t.String() == name && name != "" {
+			return t, nil
+		}
+	}
+	if strings.HasPrefix(name, "0x") {
+		if v, err := strconv.ParseUint(name, 0, 32); err == nil {
+			return ArgumentType(v), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidArgType, name)
+}
+
+// ToJSON serializes the script with decoded mnemonics, argument type names,
+// raw values, labels, strings and data arrays. RawData is not included.
+func (s *Script) ToJSON() ([]byte, error) {
+	out := scriptJSON{
+		Header:       s.Header,
+		Labels:       s.Labels,
+		Instructions: make([]instructionJSON, len(s.Instructions)),
+		Strings:      s.Strings,
+		StringOrder:  s.StringOrder,
+		Tables:       s.Tables,
+		TableLabels:  s.TableLabels,
+	}
+
+	for i := range s.Instructions {
+		instr := &s.Instructions[i]
+		ij := instructionJSON{
+			Offset:    instr.Offset,
+			Opcode:    instr.Opcode,
+			Mnemonic:  instr.Definition.Label,
+			Unknown:   instr.Unknown,
+			Comment:   instr.Comment,
+			Arguments: make([]argumentJSON, len(instr.Arguments)),
+		}
+		for j, arg := range instr.Arguments {
+			aj := argumentJSON{
+				Type:     argTypeName(arg.Type),
+				RawValue: arg.RawValue,
+				String:   arg.StringVal,
+				Array:    arg.DataArray,
+			}
+			if arg.IsLabel {
+				aj.Label = arg.LabelName
+			}
+			ij.Arguments[j] = aj
+		}
+		out.Instructions[i] = ij
+	}
+
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// FromJSON rebuilds a Script from the output of ToJSON. Opcodes are looked
+// up again in the opcode table and must agree with the recorded mnemonic.
+func FromJSON(data []byte) (*Script, error) {
+	var in scriptJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, fmt.Errorf("failed to parse script JSON: %w", err)
+	}
+
+	script := &Script{
+		Header:       in.Header,
+		Labels:       in.Labels,
+		Instructions: make([]Instruction, len(in.Instructions)),
+		Strings:      in.Strings,
+		StringOrder:  in.StringOrder,
+		Tables:       in.Tables,
+		TableLabels:  in.TableLabels,
+	}
+	if script.Labels == nil {
+		script.Labels = make(map[int]string)
+	}
+
+	for i, ij := range in.Instructions {
+		var def *InstructionDefinition
+		if ij.Unknown {
+			def = &InstructionDefinition{Opcode: ij.Opcode, Label: RawWordDirective}
+		} else if def = LookupOpcode(ij.Opcode); def == nil {
+			return nil, fmt.Errorf("instruction %d: %w: 0x%X", i, ErrUnknownOpcode, ij.Opcode)
+		}
+		if ij.Mnemonic != def.Label {
+			return nil, fmt.Errorf("instruction %d: mnemonic %q does not match opcode 0x%X (%s)",
+				i, ij.Mnemonic, ij.Opcode, def.Label)
+		}
+
+		instr := Instruction{
+			Offset:     ij.Offset,
+			Opcode:     ij.Opcode,
+			Definition: def,
+			Unknown:    ij.Unknown,
+			Comment:    ij.Comment,
+		}
+		if len(ij.Arguments) > 0 {
+			instr.Arguments = make([]Argument, len(ij.Arguments))
+		}
+		for j, aj := range ij.Arguments {
+			argType, err := argTypeFromName(aj.Type)
+			if err != nil {
+				return nil, fmt.Errorf("instruction %d argument %d: %w", i, j, err)
+			}
+			instr.Arguments[j] = Argument{
+				Type:      argType,
+				RawValue:  aj.RawValue,
+				StringVal: aj.String,
+				DataArray: aj.Array,
+				IsLabel:   aj.Label != "",
+				LabelName: aj.Label,
+			}
+		}
+		script.Instructions[i] = instr
+	}
+
+	return script, nil
+}
@@ -0,0 +1,223 @@
+package bin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LabelMap maps instruction offsets to label names. It is stored as a JSON
+// sidecar (labels.json) so that label names survive edits that move code:
+// disassembly applies the names it finds, and assembly writes the map back with
+// the new offsets of every named label.
+type LabelMap map[int]string
+
+// labelNameRE matches names usable as labels in assembly text
+var labelNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// IsValidLabelName reports whether name can be used as a label in assembly text.
+// Identifiers that the assembler reads as float arguments, such as "NaN" or
+// "inf", are rejected.
+func IsValidLabelName(name string) bool {
+	if !labelNameRE.MatchString(name) {
+		return false
+	}
+	_, err := strconv.ParseFloat(name, 32)
+	return err != nil
+}
+
+// LabelDefinition returns the label that a line of assembly code, as
+// returned by StripLine, defines. ok is false if the line is not a label
+// definition with a valid name.
+func LabelDefinition(code string) (name string, ok bool) {
+	matches := labelRE.FindStringSubmatch(code)
+	if matches == nil || !IsValidLabelName(matches[1]) {
+		return "", false
+	}
+	return matches[1], true
+}
+
+// LoadLabelMap reads a label map from a JSON file of the form
+// {"0x00001234": "intro_scene", ...}.
+func LoadLabelMap(path string) (LabelMap, error) {
+	raw, err := loadOffsetMap(path, "label map")
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make(LabelMap, len(raw))
+	for offset, name := range raw {
+		if !IsValidLabelName(name) {
+			return nil, fmt.Errorf("invalid label name %q in label map %s", name, path)
+		}
+		labels[offset] = name
+	}
+
+	return labels, nil
+}
+
+// Save writes the label map as JSON with offsets in ascending order.
+func (m LabelMap) Save(path string) error {
+	return saveOffsetMap(path, m)
+}
+
+// loadOffsetMap reads a JSON object keyed by offsets, as written by
+// saveOffsetMap. kind names the file in error messages.
+func loadOffsetMap(path, kind string) (map[int]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", kind, err)
+	}
+
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s %s: %w", kind, path, err)
+	}
+
+	m := make(map[int]string, len(raw))
+	for key, value := range raw {
+		offset, err := strconv.ParseInt(key, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset %q in %s %s", key, kind, path)
+		}
+		m[int(offset)] = value
+	}
+
+	return m, nil
+}
+
+// saveOffsetMap writes m as a JSON object keyed by hex offsets, in ascending
+// offset order.
+func saveOffsetMap(path string, m map[int]string) error {
+	offsets := make([]int, 0, len(m))
+	for off := range m {
+		offsets = append(offsets, off)
+	}
+	sort.Ints(offsets)
+
+	// Built by hand so keys stay in offset order rather than string order
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	for i, off := range offsets {
+		name, _ := json.Marshal(m[off])
+		fmt.Fprintf(&sb, "  \"0x%08X\": %s", off, name)
+		if i < len(offsets)-1 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("}\n")
+
+	return os.WriteFile(path, []byte(sb.String()), 0644)
+}
+
+// LabelMap returns the script's current offset to label name mapping.
+func (s *Script) LabelMap() LabelMap {
+	labels := make(LabelMap, len(s.Labels))
+	for off, name := range s.Labels {
+		labels[off] = name
+	}
+	return labels
+}
+
+// ApplyLabelNames renames the script's labels using names from m. Offsets in
+// m without a label in the script are ignored, as are names already used by
+// another label, so the result always assembles. Returns the number of
+// labels renamed.
+func (s *Script) ApplyLabelNames(m LabelMap) int {
+	used := make(map[string]bool, len(s.Labels))
+	for _, name := range s.Labels {
+		used[name] = true
+	}
+
+	offsets := make([]int, 0, len(s.Labels))
+	for off := range s.Labels {
+		offsets = append(offsets, off)
+	}
+	sort.Ints(offsets)
+
+	renamed := make(map[string]string)
+	for _, off := range offsets {
+		oldName := s.Labels[off]
+		newName, ok := m[off]
+		if !ok || newName == oldName || used[newName] || !IsValidLabelName(newName) {
+			continue
+		}
+		used[newName] = true
+		s.Labels[off] = newName
+		renamed[oldName] = newName
+	}
+
+	s.renameReferences(renamed)
+	return len(renamed)
+}
+
+// RenameLabels renames labels by name, mapping old names to new ones. All
+// renames apply at once, so names may be swapped. It fails without changing
+// the script if an old name is not a label, a new name is not a valid label
+// name, or two labels would end up with the same name.
+func (s *Script) RenameLabels(names map[string]string) error {
+	offsets := make(map[string]int, len(s.Labels))
+	for off, name := range s.Labels {
+		offsets[name] = off
+	}
+
+	final := make(map[int]string, len(s.Labels))
+	for off, name := range s.Labels {
+		final[off] = name
+	}
+	for oldName, newName := range names {
+		off, ok := offsets[oldName]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrLabelNotFound, oldName)
+		}
+		if !IsValidLabelName(newName) {
+			return fmt.Errorf("%w: invalid name %q for %s", ErrInvalidLabel, newName, oldName)
+		}
+		final[off] = newName
+	}
+
+	seen := make(map[string]bool, len(final))
+	for _, name := range final {
+		if seen[name] {
+			return fmt.Errorf("%w: %s", ErrDuplicateLabel, name)
+		}
+		seen[name] = true
+	}
+
+	renamed := make(map[string]string, len(names))
+	for oldName, newName := range names {
+		if newName != oldName {
+			renamed[oldName] = newName
+		}
+	}
+	for off, name := range final {
+		s.Labels[off] = name
+	}
+	s.renameReferences(renamed)
+	return nil
+}
+
+// renameReferences updates label references in arguments and tables to
+// follow renamed label definitions
+func (s *Script) renameReferences(renamed map[string]string) {
+	for i := range s.Instructions {
+		for j := range s.Instructions[i].Arguments {
+			arg := &s.Instructions[i].Arguments[j]
+			if newName, ok := renamed[arg.LabelName]; ok && arg.IsLabel {
+				arg.LabelName = newName
+			}
+		}
+	}
+	for t := range s.TableLabels {
+		for i, name := range s.TableLabels[t] {
+			if newName, ok := renamed[name]; ok {
+				s.TableLabels[t][i] = newName
+			}
+		}
+	}
+}
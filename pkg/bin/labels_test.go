@@ -0,0 +1,177 @@
+package bin
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testHeaderText is the header block of a SYS5 test script.
+const testHeaderText = "==Binary Information - do not edit==\n" +
+	"signature = SYS5501\n" +
+	"local_vars = { 0 0 0 0 0 0 }\n" +
+	"====\n"
+
+// testScript assembles the instructions in body behind testHeaderText and
+// disassembles the result.
+func testScript(tb testing.TB, body string) (*Script, []byte) {
+	tb.Helper()
+
+	res, err := Assemble(testHeaderText+body, FormatSYS5)
+	if err != nil {
+		tb.Fatalf("Assemble: %v", err)
+	}
+	script, err := Disassemble(res.Data)
+	if err != nil {
+		tb.Fatalf("Disassemble: %v", err)
+	}
+	return script, res.Data
+}
+
+func TestIsValidLabelName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"label_00001234", true},
+		{"setup_character", true},
+		{"_start", true},
+		{"Inference", true},
+		{"nano", true},
+		{"", false},
+		{"1st", false},
+		{"has-dash", false},
+		{"inf", false},
+		{"Inf", false},
+		{"INFINITY", false},
+		{"nan", false},
+		{"NaN", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValidLabelName(tt.name); got != tt.want {
+			t.Errorf("IsValidLabelName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenameLabels(t *testing.T) {
+	script, data := testScript(t, "    call label_00000000\n    exit\n\nlabel_00000000:\n    mov local-int:1 5\n    ret\n")
+
+	var callee string
+	for _, arg := range script.Instructions[0].Arguments {
+		if arg.IsLabel {
+			callee = arg.LabelName
+		}
+	}
+	if callee == "" {
+		t.Fatal("call has no label argument")
+	}
+
+	if err := script.RenameLabels(map[string]string{callee: "setup_character"}); err != nil {
+		t.Fatalf("RenameLabels: %v", err)
+	}
+
+	if got := script.Instructions[0].Arguments[0].LabelName; got != "setup_character" {
+		t.Errorf("call target = %q, want setup_character", got)
+	}
+	if got := script.Labels[script.Instructions[2].Offset]; got != "setup_character" {
+		t.Errorf("label definition = %q, want setup_character", got)
+	}
+
+	text := script.ToText()
+	for _, want := range []string{"\nsetup_character:\n", "    call setup_character\n"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text does not contain %q:\n%s", want, text)
+		}
+	}
+
+	res, err := Assemble(text, FormatSYS5)
+	if err != nil {
+		t.Fatalf("Assemble renamed text: %v", err)
+	}
+	if !bytes.Equal(res.Data, data) {
+		t.Error("renamed text assembles to different bytes")
+	}
+}
+
+func TestRenameLabelsRejects(t *testing.T) {
+	script, _ := testScript(t, "    call label_00000000\n    jmp label_00000001\n\nlabel_00000000:\n    ret\n\nlabel_00000001:\n    exit\n")
+
+	var labels []string
+	for _, off := range []int{script.Instructions[2].Offset, script.Instructions[3].Offset} {
+		labels = append(labels, script.Labels[off])
+	}
+
+	tests := []struct {
+		name  string
+		names map[string]string
+		want  error
+	}{
+		{"unknown label", map[string]string{"missing": "x"}, ErrLabelNotFound},
+		{"float name inf", map[string]string{labels[0]: "inf"}, ErrInvalidLabel},
+		{"float name NaN", map[string]string{labels[0]: "NaN"}, ErrInvalidLabel},
+		{"not an identifier", map[string]string{labels[0]: "two words"}, ErrInvalidLabel},
+		{"duplicate", map[string]string{labels[0]: "same", labels[1]: "same"}, ErrDuplicateLabel},
+		{"existing name", map[string]string{labels[0]: labels[1]}, ErrDuplicateLabel},
+	}
+
+	before := script.ToText()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := script.RenameLabels(tt.names); !errors.Is(err, tt.want) {
+				t.Errorf("RenameLabels = %v, want %v", err, tt.want)
+			}
+			if script.ToText() != before {
+				t.Error("failed rename changed the script")
+			}
+		})
+	}
+
+	// Swapping two names is one rename, not a collision
+	if err := script.RenameLabels(map[string]string{labels[0]: labels[1], labels[1]: labels[0]}); err != nil {
+		t.Errorf("swap: %v", err)
+	}
+}
+
+func TestAssembleLabelNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{"named label", "    jmp done\n\ndone:\n    exit\n", nil},
+		{"definition named inf", "    jmp inf\n\ninf:\n    exit\n", ErrInvalidLabel},
+		{"definition named NaN", "    exit\n\nNaN:\n    exit\n", ErrInvalidLabel},
+		{"comment and annotation", "0x00000044: [00]    jmp done ; skip ahead\n\ndone:\n    exit\n", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Assemble(testHeaderText+tt.body, FormatSYS5)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Assemble = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStripLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"    call setup_character", "call setup_character"},
+		{"0x00000044: [00]    call setup_character ; sets up Alice", "call setup_character"},
+		{"0x00000064:    exit", "exit"},
+		{`    show-text 0 "a; b" ; note`, `show-text 0 "a; b"`},
+		{"setup_character:", "setup_character:"},
+	}
+
+	for _, tt := range tests {
+		if got := StripLine(tt.line); got != tt.want {
+			t.Errorf("StripLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
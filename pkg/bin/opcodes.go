@@ -587,6 +587,17 @@ func LookupLabel(label string) *InstructionDefinition {
 	return labelToOpcode[label]
 }
 
+// AllOpcodes returns every instruction definition, sorted by opcode. The
+// definitions are the ones LookupOpcode and LookupLabel return and must not
+// be modified.
+func AllOpcodes() []*InstructionDefinition {
+	defs := make([]*InstructionDefinition, len(opcodeTable))
+	for i := range opcodeTable {
+		defs[i] = &opcodeTable[i]
+	}
+	return defs
+}
+
 // Control flow opcodes
 var controlFlowOpcodes = map[uint32]bool{
 	0x8C: true, // jmp
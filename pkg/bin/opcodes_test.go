@@ -0,0 +1,32 @@
+package bin
+
+import "testing"
+
+func TestAllOpcodes(t *testing.T) {
+	defs := AllOpcodes()
+	if len(defs) == 0 {
+		t.Fatal("AllOpcodes returned no definitions")
+	}
+
+	for i, def := range defs {
+		if i > 0 && def.Opcode <= defs[i-1].Opcode {
+			t.Errorf("opcode 0x%X follows 0x%X, want ascending order", def.Opcode, defs[i-1].Opcode)
+		}
+		if def.Label == "" {
+			t.Errorf("opcode 0x%X has no mnemonic", def.Opcode)
+			continue
+		}
+
+		// A mnemonic shared by two opcodes maps back to only one of them
+		if got := LookupLabel(def.Label); got == nil || got.Opcode != def.Opcode {
+			t.Errorf("LookupLabel(%q) = %+v, want opcode 0x%X", def.Label, got, def.Opcode)
+		}
+		if got := LookupOpcode(def.Opcode); got != def {
+			t.Errorf("LookupOpcode(0x%X) = %+v, want %+v", def.Opcode, got, def)
+		}
+	}
+
+	if got := LookupLabel("no-such-mnemonic"); got != nil {
+		t.Errorf("LookupLabel of an unknown mnemonic = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,158 @@
+package bin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// Patch format:
+//
+//	0x00: magic "AGEP" (4 bytes)
+//	0x04: SHA-256 of the original file (32 bytes)
+//	0x24: original length (uint32)
+//	0x28: modified length (uint32)
+//	0x2C: runs, each an offset (uint32), a length (uint32) and the replacement bytes
+const (
+	patchMagic      = "AGEP"
+	patchHeaderSize = 4 + sha256.Size + 8
+
+	// patchMergeGap is the largest run of unchanged bytes folded into a
+	// neighbouring run, since a new run costs 8 bytes of framing.
+	patchMergeGap = 8
+)
+
+// MakePatch builds a compact patch that turns original into modified.
+// Most BIN edits are localized string changes, so the patch is stored as
+// a list of offset+replacement runs.
+func MakePatch(original, modified []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	hash := sha256.Sum256(original)
+	buf.WriteString(patchMagic)
+	buf.Write(hash[:])
+	binary.Write(&buf, binary.LittleEndian, uint32(len(original)))
+	binary.Write(&buf, binary.LittleEndian, uint32(len(modified)))
+
+	i := 0
+	for i < len(modified) {
+		if i < len(original) && original[i] == modified[i] {
+			i++
+			continue
+		}
+
+		// Extend the run until a long enough stretch of unchanged bytes
+		start := i
+		end := i + 1
+		for end < len(modified) {
+			same := 0
+			for end+same < len(modified) && end+same < len(original) &&
+				original[end+same] == modified[end+same] && same <= patchMergeGap {
+				same++
+			}
+			if same > patchMergeGap || end+same >= len(modified) {
+				break
+			}
+			end += same + 1
+		}
+
+		binary.Write(&buf, binary.LittleEndian, uint32(start))
+		binary.Write(&buf, binary.LittleEndian, uint32(end-start))
+		buf.Write(modified[start:end])
+		i = end
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ApplyPatch applies a patch created by MakePatch to original.
+// It refuses to apply the patch if original does not match the hash
+// recorded when the patch was made.
+func ApplyPatch(original, patch []byte) ([]byte, error) {
+	if len(patch) < patchHeaderSize || string(patch[:4]) != patchMagic {
+		return nil, ErrInvalidPatch
+	}
+
+	hash := sha256.Sum256(original)
+	if !bytes.Equal(hash[:], patch[4:4+sha256.Size]) {
+		return nil, ErrPatchBase
+	}
+
+	pos := 4 + sha256.Size
+	origLen := binary.LittleEndian.Uint32(patch[pos:])
+	modLen := binary.LittleEndian.Uint32(patch[pos+4:])
+	pos += 8
+
+	if int(origLen) != len(original) {
+		return nil, ErrPatchBase
+	}
+
+	result := make([]byte, modLen)
+	copy(result, original)
+
+	for pos < len(patch) {
+		if pos+8 > len(patch) {
+			return nil, fmt.Errorf("%w: truncated run header at 0x%X", ErrInvalidPatch, pos)
+		}
+		offset := int(binary.LittleEndian.Uint32(patch[pos:]))
+		length := int(binary.LittleEndian.Uint32(patch[pos+4:]))
+		pos += 8
+
+		if pos+length > len(patch) || offset+length > len(result) {
+			return nil, fmt.Errorf("%w: run at 0x%X out of range", ErrInvalidPatch, offset)
+		}
+		copy(result[offset:], patch[pos:pos+length])
+		pos += length
+	}
+
+	return result, nil
+}
+
+// PatchString replaces the text of the string argument argIndex of
+// instruction instrIndex in place and returns the modified copy of data.
+// The new text is encoded for the script's format and written over the old
+// slot, which spans the old text, its terminator and the alignment padding;
+// the rest of the slot is filled with padding. Nothing else in the file
+// moves. It fails if the new text does not fit the slot.
+//
+// Every argument referencing the same footer copy, as after assembly with
+// AssembleOptions.DedupeStrings, sees the new text.
+func PatchString(data []byte, instrIndex, argIndex int, newText string) ([]byte, error) {
+	script, err := Disassemble(data)
+	if err != nil {
+		return nil, err
+	}
+	if instrIndex < 0 || instrIndex >= len(script.Instructions) {
+		return nil, fmt.Errorf("instruction %d out of range (script has %d)", instrIndex, len(script.Instructions))
+	}
+
+	instr := &script.Instructions[instrIndex]
+	if argIndex < 0 || argIndex >= len(instr.Arguments) || instr.Arguments[argIndex].Type != ArgString {
+		return nil, fmt.Errorf("instruction %d (%s) has no string argument %d", instrIndex, instr.Definition.Label, argIndex)
+	}
+	// Empty strings have no footer slot; their value points at the code
+	if instr.Arguments[argIndex].StringVal == "" {
+		return nil, fmt.Errorf("string argument %d of instruction %d is empty and has no slot to patch", argIndex, instrIndex)
+	}
+
+	start, length, ok := script.StringByteRange(instr.Offset, argIndex)
+	if !ok {
+		return nil, fmt.Errorf("string argument %d of instruction %d points outside the file", argIndex, instrIndex)
+	}
+
+	encoded, err := encodeString(newText, script.Header.Version)
+	if err != nil {
+		return nil, err
+	}
+	if len(encoded) > length {
+		return nil, fmt.Errorf("new text needs %d bytes but the slot at 0x%X holds %d", len(encoded), start, length)
+	}
+
+	patched := bytes.Clone(data)
+	copy(patched[start:], encoded)
+	for i := start + len(encoded); i < start+length; i++ {
+		patched[i] = 0xFF
+	}
+	return patched, nil
+}
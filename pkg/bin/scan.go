@@ -0,0 +1,90 @@
+package bin
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// StringRef is a string found in the footer string pool.
+type StringRef struct {
+	Offset int    `json:"offset"` // File offset of the encoded string
+	Value  string `json:"value"`  // Decoded string
+}
+
+// ScanStrings decodes the footer string pool without decoding instructions,
+// so strings can be extracted from titles whose opcode table is incomplete.
+//
+// Every 4-byte aligned (type, value) word pair with the string argument type is
+// treated as a candidate reference. A candidate is kept if it points past the
+// reference into the footer and sits on a string boundary: either directly after
+// 0xFF padding, or as the first string of the pool whose end lands on another
+// candidate.
+func ScanStrings(data []byte) ([]StringRef, error) {
+	header, err := ReadHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	headerLen := header.GetLength()
+	footerEnd := header.DataArrayEnd()
+	if footerEnd == 0 || footerEnd > len(data) {
+		footerEnd = len(data)
+	}
+
+	candidates := make(map[int]bool)
+	for i := headerLen; i+8 <= footerEnd; i += 4 {
+		if ArgumentType(binary.LittleEndian.Uint32(data[i:])) != ArgString {
+			continue
+		}
+		target := headerLen + int(binary.LittleEndian.Uint32(data[i+4:]))*4
+		if target > i+8 && target < footerEnd {
+			candidates[target] = true
+		}
+	}
+
+	offsets := make([]int, 0, len(candidates))
+	for off := range candidates {
+		offsets = append(offsets, off)
+	}
+	sort.Ints(offsets)
+
+	var refs []StringRef
+	for n, off := range offsets {
+		if data[off-1] != 0xFF {
+			// Only the first string of the pool may follow non-padding bytes
+			if n != 0 {
+				continue
+			}
+			end := stringEnd(data, off, header.Version)
+			if !candidates[end] && end < footerEnd {
+				continue
+			}
+		}
+
+		value, err := decodeString(data, off, header.Version)
+		if err != nil {
+			continue
+		}
+		refs = append(refs, StringRef{Offset: off, Value: value})
+	}
+
+	return refs, nil
+}
+
+// stringEnd returns the offset following an encoded string, its terminator
+// and its alignment padding.
+func stringEnd(data []byte, offset int, version FormatVersion) int {
+	end := offset
+	if version == FormatSYS5 {
+		for end+1 < len(data) && binary.LittleEndian.Uint16(data[end:]) != 0xFFFF {
+			end += 2
+		}
+		end += 2
+	} else {
+		for end < len(data) && data[end] != 0xFF {
+			end++
+		}
+		end++
+	}
+	return end + 4 - (end % 4)
+}
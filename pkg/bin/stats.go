@@ -0,0 +1,96 @@
+package bin
+
+import "sort"
+
+// Stats counts instructions and arguments over one or more scripts.
+type Stats struct {
+	Scripts      int                  // Number of scripts added
+	Instructions int                  // Total instructions, including unknown opcodes
+	Opcodes      map[string]int       // Known instructions by mnemonic
+	Unknown      map[uint32]int       // Unknown opcodes (see DisassembleOptions.TolerateUnknown) by value
+	ArgTypes     map[ArgumentType]int // Arguments by type
+	Strings      int                  // String arguments with decoded text
+	Arrays       int                  // Data array arguments
+}
+
+// OpcodeCount is one entry of Stats.TopOpcodes
+type OpcodeCount struct {
+	Mnemonic string
+	Count    int
+}
+
+// NewStats returns empty statistics ready for Add.
+func NewStats() *Stats {
+	return &Stats{
+		Opcodes:  make(map[string]int),
+		Unknown:  make(map[uint32]int),
+		ArgTypes: make(map[ArgumentType]int),
+	}
+}
+
+// Stats returns the instruction and argument counts of the script.
+func (s *Script) Stats() *Stats {
+	st := NewStats()
+	st.Scripts = 1
+	for i := range s.Instructions {
+		instr := &s.Instructions[i]
+		st.Instructions++
+		if instr.Unknown {
+			st.Unknown[instr.Opcode]++
+		} else {
+			st.Opcodes[instr.Definition.Label]++
+		}
+		for _, arg := range instr.Arguments {
+			st.ArgTypes[arg.Type]++
+			if arg.Type == ArgString && arg.StringVal != "" {
+				st.Strings++
+			}
+			if arg.DataArray != nil {
+				st.Arrays++
+			}
+		}
+	}
+	return st
+}
+
+// OpcodeHistogram returns the number of instructions per mnemonic. Unknown
+// opcodes are not included; see Stats.Unknown.
+func (s *Script) OpcodeHistogram() map[string]int {
+	return s.Stats().Opcodes
+}
+
+// Add accumulates the counts of other into st.
+func (st *Stats) Add(other *Stats) {
+	st.Scripts += other.Scripts
+	st.Instructions += other.Instructions
+	st.Strings += other.Strings
+	st.Arrays += other.Arrays
+	for k, v := range other.Opcodes {
+		st.Opcodes[k] += v
+	}
+	for k, v := range other.Unknown {
+		st.Unknown[k] += v
+	}
+	for k, v := range other.ArgTypes {
+		st.ArgTypes[k] += v
+	}
+}
+
+// TopOpcodes returns the n most common mnemonics, most common first and ties
+// in name order. A negative n returns all of them.
+func (st *Stats) TopOpcodes(n int) []OpcodeCount {
+	result := make([]OpcodeCount, 0, len(st.Opcodes))
+	for name, count := range st.Opcodes {
+		result = append(result, OpcodeCount{Mnemonic: name, Count: count})
+	}
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].Count != result[b].Count {
+			return result[a].Count > result[b].Count
+		}
+		return result[a].Mnemonic < result[b].Mnemonic
+	})
+	if n >= 0 && n < len(result) {
+		result = result[:n]
+	}
+	return result
+}
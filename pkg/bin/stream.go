@@ -0,0 +1,69 @@
+package bin
+
+import (
+	"fmt"
+	"sort"
+)
+
+// StreamOptions controls DisassembleStreamWithOptions.
+type StreamOptions struct {
+	// TolerateUnknown emits unknown opcodes as .word instructions, as in
+	// DisassembleOptions.
+	TolerateUnknown bool
+
+	// Labels marks control-flow arguments that point at an instruction with
+	// IsLabel and a label_XXXXXXXX name, as Disassemble does. This needs an
+	// extra pass over the code to find where instructions start.
+	Labels bool
+}
+
+// DisassembleStream parses a BIN file and calls fn for each instruction in
+// order, without building a Script. Strings and data arrays are decoded as
+// each instruction is reached; label arguments are left unresolved.
+// Returning an error from fn stops the walk and is returned.
+func DisassembleStream(data []byte, fn func(Instruction) error) error {
+	return DisassembleStreamWithOptions(data, StreamOptions{}, fn)
+}
+
+// DisassembleStreamWithOptions is DisassembleStream using the given options.
+// Instructions handed to fn may be retained; they do not share memory with
+// later ones.
+func DisassembleStreamWithOptions(data []byte, opts StreamOptions, fn func(Instruction) error) error {
+	header, err := ReadHeader(data)
+	if err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	dataEnd := header.dataArrayEndScan(data, opts.TolerateUnknown)
+	headerLen := header.GetLength()
+
+	// Instruction starts, in ascending order, for resolving label targets
+	var starts []int
+	if opts.Labels {
+		walkInstructions(data, header, dataEnd, opts.TolerateUnknown, nil, func(instr Instruction) error {
+			starts = append(starts, instr.Offset)
+			return nil
+		})
+	}
+	isStart := func(offset int) bool {
+		i := sort.SearchInts(starts, offset)
+		return i < len(starts) && starts[i] == offset
+	}
+
+	var pool argumentPool
+	return walkInstructions(data, header, dataEnd, opts.TolerateUnknown, &pool, func(instr Instruction) error {
+		if opts.Labels && IsControlFlow(instr.Opcode) {
+			for j := range instr.Arguments {
+				if !IsLabelArgument(&instr, j) {
+					continue
+				}
+				target := headerLen + int(instr.Arguments[j].RawValue)*4
+				if isStart(target) {
+					instr.Arguments[j].IsLabel = true
+					instr.Arguments[j].LabelName = fmt.Sprintf("label_%08X", target)
+				}
+			}
+		}
+		resolveData(&instr, data, header, nil)
+		return fn(instr)
+	})
+}
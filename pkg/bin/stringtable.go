@@ -0,0 +1,161 @@
+package bin
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// StringEntry is one string argument of a script, addressed by instruction
+// and argument index so it can be edited outside the assembly text.
+type StringEntry struct {
+	InstrIndex int    `json:"instr"`
+	ArgIndex   int    `json:"arg"`
+	Text       string `json:"text"`
+}
+
+// stringTableHeader is the first row of the CSV form
+var stringTableHeader = []string{"instr", "arg", "text"}
+
+// ExportStrings returns every decoded string argument in instruction order.
+func (s *Script) ExportStrings() []StringEntry {
+	var entries []StringEntry
+	for i := range s.Instructions {
+		for j, arg := range s.Instructions[i].Arguments {
+			if arg.Type == ArgString && arg.StringVal != "" {
+				entries = append(entries, StringEntry{InstrIndex: i, ArgIndex: j, Text: arg.StringVal})
+			}
+		}
+	}
+	return entries
+}
+
+// ImportStrings replaces the text of the string arguments named by entries.
+// All entries are checked before any is applied, so a bad table leaves the
+// script unchanged. Empty text is rejected, since the assembler only places
+// non-empty strings in the footer.
+func (s *Script) ImportStrings(entries []StringEntry) error {
+	for n, e := range entries {
+		if e.InstrIndex < 0 || e.InstrIndex >= len(s.Instructions) {
+			return fmt.Errorf("entry %d: instruction index %d out of range", n, e.InstrIndex)
+		}
+		instr := &s.Instructions[e.InstrIndex]
+		if e.ArgIndex < 0 || e.ArgIndex >= len(instr.Arguments) {
+			return fmt.Errorf("entry %d: argument index %d out of range for %s", n, e.ArgIndex, instr.Definition.Label)
+		}
+		arg := &instr.Arguments[e.ArgIndex]
+		if arg.Type != ArgString || arg.StringVal == "" {
+			return fmt.Errorf("entry %d: argument %d of instruction %d is not a string", n, e.ArgIndex, e.InstrIndex)
+		}
+		if e.Text == "" {
+			return fmt.Errorf("entry %d: empty strings are not supported", n)
+		}
+	}
+
+	for _, e := range entries {
+		s.Instructions[e.InstrIndex].Arguments[e.ArgIndex].StringVal = e.Text
+	}
+	return nil
+}
+
+// WriteStringsCSV writes entries as CSV with an instr,arg,text header.
+// Quotes and newlines in the text are quoted by the CSV encoding. CSV readers
+// turn a quoted "\r\n" into "\n", so use JSON for text with carriage returns.
+func WriteStringsCSV(w io.Writer, entries []StringEntry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(stringTableHeader); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		record := []string{strconv.Itoa(e.InstrIndex), strconv.Itoa(e.ArgIndex), e.Text}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadStringsCSV reads entries written by WriteStringsCSV.
+func ReadStringsCSV(r io.Reader) ([]StringEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(stringTableHeader)
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse string table: %w", err)
+	}
+	if len(records) == 0 || records[0][0] != stringTableHeader[0] {
+		return nil, fmt.Errorf("string table is missing the %s header", strings.Join(stringTableHeader, ","))
+	}
+
+	entries := make([]StringEntry, 0, len(records)-1)
+	for line, record := range records[1:] {
+		instr, err := strconv.Atoi(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid instruction index %q", line+2, record[0])
+		}
+		arg, err := strconv.Atoi(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid argument index %q", line+2, record[1])
+		}
+		entries = append(entries, StringEntry{InstrIndex: instr, ArgIndex: arg, Text: record[2]})
+	}
+	return entries, nil
+}
+
+// WriteStringsJSON writes entries as an indented JSON array.
+func WriteStringsJSON(w io.Writer, entries []StringEntry) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(entries)
+}
+
+// ReadStringsJSON reads entries written by WriteStringsJSON.
+func ReadStringsJSON(r io.Reader) ([]StringEntry, error) {
+	var entries []StringEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to parse string table: %w", err)
+	}
+	return entries, nil
+}
+
+// SaveStringTable writes entries to path, as JSON for a .json extension and
+// as CSV otherwise.
+func SaveStringTable(path string, entries []StringEntry) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = WriteStringsJSON(f, entries)
+	} else {
+		err = WriteStringsCSV(f, entries)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// LoadStringTable reads entries saved by SaveStringTable.
+func LoadStringTable(path string) ([]StringEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return ReadStringsJSON(f)
+	}
+	return ReadStringsCSV(f)
+}
@@ -4,7 +4,9 @@ package bin
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"strings"
 )
 
 // Format version constants
@@ -19,6 +21,7 @@ const (
 const (
 	SYS4HeaderSize = 0x3C // 60 bytes
 	SYS5HeaderSize = 0x44 // 68 bytes
+	SubHeaderSize  = 0x1C // sub_header_length of every known script
 )
 
 // Common errors
@@ -32,6 +35,10 @@ var (
 	ErrLabelNotFound    = errors.New("label not found")
 	ErrDuplicateLabel   = errors.New("duplicate label")
 	ErrInstructionParse = errors.New("instruction parse error")
+	ErrInvalidPatch     = errors.New("invalid patch data")
+	ErrPatchBase        = errors.New("patch does not apply to this file")
+	ErrArgCountMismatch = errors.New("argument count mismatch")
+	ErrInvalidHeader    = errors.New("invalid header")
 )
 
 // ArgumentType represents the type of an instruction argument
@@ -123,7 +130,12 @@ type Header struct {
 	LocalFloats    uint32 // local_floats
 	LocalStrings1  uint32 // local_strings_1
 	LocalInteger2  uint32 // local_integer_2
-	UnknownData    uint32 // unknown_data
+	// unknown_data is the fifth local_vars value. Its position between
+	// local_integer_2 and local_strings_2 suggests a variable count, but no
+	// argument type is known to index it and no script with a nonzero value
+	// has been examined, so its meaning cannot be determined and it is
+	// carried through unvalidated.
+	UnknownData    uint32
 	LocalStrings2  uint32 // local_strings_2
 	SubHeaderLen   uint32 // sub_header_length (always 0x1C)
 	Table1Length   uint32 // table_1_length (opcode 0x71 offsets)
@@ -134,6 +146,71 @@ type Header struct {
 	Table3Offset   uint32 // table_3_offset
 }
 
+// String returns a multi-line summary of the header fields.
+// A nonzero unknown_data is flagged, since its meaning cannot be determined
+// and edits to scripts that rely on it may not behave as expected.
+func (h *Header) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "signature:       %s\n", strings.TrimRight(h.Signature, "\x00 "))
+	fmt.Fprintf(&sb, "format:          SYS%d\n", h.Version)
+	fmt.Fprintf(&sb, "local_integer_1: %d\n", h.LocalInteger1)
+	fmt.Fprintf(&sb, "local_floats:    %d\n", h.LocalFloats)
+	fmt.Fprintf(&sb, "local_strings_1: %d\n", h.LocalStrings1)
+	fmt.Fprintf(&sb, "local_integer_2: %d\n", h.LocalInteger2)
+	fmt.Fprintf(&sb, "unknown_data:    %d\n", h.UnknownData)
+	fmt.Fprintf(&sb, "local_strings_2: %d\n", h.LocalStrings2)
+	fmt.Fprintf(&sb, "sub_header_len:  0x%X\n", h.SubHeaderLen)
+	fmt.Fprintf(&sb, "table_1 (0x71):  %d entries at 0x%X\n", h.Table1Length, h.Table1Offset)
+	fmt.Fprintf(&sb, "table_2 (0x03):  %d entries at 0x%X\n", h.Table2Length, h.Table2Offset)
+	fmt.Fprintf(&sb, "table_3 (0x8F):  %d entries at 0x%X\n", h.Table3Length, h.Table3Offset)
+	if h.UnknownData != 0 {
+		fmt.Fprintf(&sb, "WARNING: unknown_data is nonzero (%d); its meaning is undocumented and edits may break\n", h.UnknownData)
+	}
+	return sb.String()
+}
+
+// Validate checks the fixed header invariants against a file of size bytes:
+// sub_header_length is 0x1C, the signature prefix matches Version, and every
+// table lies between the end of the header and the end of the file. All
+// problems found are returned joined, each wrapping ErrInvalidHeader.
+//
+// The assembler always writes a sub_header_length of 0x1C, so a file with a
+// different value does not round-trip.
+func (h *Header) Validate(size int) error {
+	var errs []error
+	if h.SubHeaderLen != SubHeaderSize {
+		errs = append(errs, fmt.Errorf("%w: sub_header_length is 0x%X, expected 0x%X", ErrInvalidHeader, h.SubHeaderLen, SubHeaderSize))
+	}
+
+	switch h.Version {
+	case FormatSYS4, FormatSYS5:
+		if prefix := fmt.Sprintf("SYS%d", h.Version); !strings.HasPrefix(h.Signature, prefix) {
+			errs = append(errs, fmt.Errorf("%w: signature %q does not match format SYS%d", ErrInvalidHeader, strings.TrimRight(h.Signature, "\x00 "), h.Version))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%w: unknown format version %d", ErrInvalidHeader, h.Version))
+	}
+
+	tables := [3]struct{ length, offset uint32 }{
+		{h.Table1Length, h.Table1Offset},
+		{h.Table2Length, h.Table2Offset},
+		{h.Table3Length, h.Table3Offset},
+	}
+	for i, t := range tables {
+		if t.length == 0 {
+			continue
+		}
+		start := h.GetLength() + int(t.offset)*4
+		end := start + int(t.length)*4
+		if end > size {
+			errs = append(errs, fmt.Errorf("%w: table_%d (%d entries at 0x%X) ends at 0x%X, past the end of the file (0x%X)",
+				ErrInvalidHeader, i+1, t.length, start, end, size))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // GetLength returns the header length in bytes
 func (h *Header) GetLength() int {
 	if h.Version == FormatSYS5 {
@@ -143,7 +220,8 @@ func (h *Header) GetLength() int {
 }
 
 // DataArrayEnd returns the byte offset where instruction data ends
-// This is calculated from table offsets
+// This is calculated from table offsets, so it is 0 for a script without
+// tables (including an empty one); use DataArrayEndScan for those.
 func (h *Header) DataArrayEnd() int {
 	// The first table offset indicates where data ends
 	if h.Table1Length > 0 {
@@ -158,6 +236,62 @@ func (h *Header) DataArrayEnd() int {
 	return 0
 }
 
+// DataArrayEndScan returns the byte offset where instruction data ends, like
+// DataArrayEnd, but falls back to scanning when the script has no tables.
+// Instructions are walked from the end of the header, and the scan stops at
+// the lowest offset referenced as a string or array, since the footer data
+// starts there. Without any such reference the whole file is code.
+func (h *Header) DataArrayEndScan(data []byte) int {
+	return h.dataArrayEndScan(data, false)
+}
+
+// dataArrayEndScan implements DataArrayEndScan, optionally stepping over
+// unknown opcodes the way DisassembleWithOptions does. Unknown opcodes never
+// fail to parse, so the tolerant scan always runs, bounded by the tables, to
+// keep the string footer from being read as code.
+func (h *Header) dataArrayEndScan(data []byte, tolerateUnknown bool) int {
+	end := h.DataArrayEnd()
+	if end != 0 && end <= len(data) && !tolerateUnknown {
+		return end
+	}
+
+	headerLen := h.GetLength()
+	dataStart := len(data)
+	if end != 0 && end <= len(data) {
+		dataStart = end
+	}
+	var pool argumentPool
+	offset := headerLen
+	for offset < dataStart {
+		instr, err := parseInstructionPooled(data, offset, h, &pool)
+		if err != nil && tolerateUnknown && errors.Is(err, ErrUnknownOpcode) {
+			instr, err = parseUnknownInstruction(data, offset, dataStart), nil
+		}
+		if err != nil {
+			break
+		}
+
+		for i, arg := range instr.Arguments {
+			isData := arg.Type == ArgString ||
+				(instr.Opcode == 0x64 && i == 1 && arg.Type == ArgImmediate)
+			if !isData {
+				continue
+			}
+			target := headerLen + int(arg.RawValue)*4
+			if target > offset && target < dataStart {
+				dataStart = target
+			}
+		}
+
+		offset += instr.Size()
+	}
+
+	if offset > dataStart {
+		return offset
+	}
+	return dataStart
+}
+
 // Argument represents an instruction argument
 type Argument struct {
 	Type       ArgumentType
@@ -174,6 +308,8 @@ type Instruction struct {
 	Opcode     uint32                 // Opcode value
 	Definition *InstructionDefinition // Opcode definition
 	Arguments  []Argument             // Instruction arguments
+	Unknown    bool                   // Opcode not in the table, rendered as .word directives
+	Comment    string                 // Trailing "; comment" of the instruction line, kept across assembly
 }
 
 // Size returns the instruction size in bytes
@@ -186,8 +322,11 @@ type Script struct {
 	Header       Header
 	Instructions []Instruction
 	Labels       map[int]string // Offset -> label name mapping
-	Strings      []string       // All decoded strings
+	Strings      []string       // All decoded strings (only with DisassembleOptions.CollectStrings)
+	StringOrder  []StringRef    // Footer order of the strings; nil when it follows instruction order
+	HeaderErr    error          // Problems found by Header.Validate; nil for a consistent header
 	Tables       [3][]uint32    // The three offset tables
+	TableLabels  [3][]string    // Label of each table entry's instruction; "" if it resolves to none
 	RawData      []byte         // Original file data for reference
 }
 
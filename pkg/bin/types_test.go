@@ -0,0 +1,38 @@
+package bin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHeaderUnknownData(t *testing.T) {
+	tests := []struct {
+		name     string
+		vars     string
+		want     uint32
+		wantWarn bool
+	}{
+		{"zero", "{ 0 0 0 0 0 0 }", 0, false},
+		{"nonzero", "{ 1 2 3 4 5 6 }", 5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := strings.Replace(testHeaderText, "{ 0 0 0 0 0 0 }", tt.vars, 1) + "    exit\n"
+			res, err := Assemble(text, FormatSYS5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header, err := ReadHeader(res.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.UnknownData != tt.want {
+				t.Errorf("UnknownData = %d, want %d", header.UnknownData, tt.want)
+			}
+			if got := strings.Contains(header.String(), "WARNING: unknown_data"); got != tt.wantWarn {
+				t.Errorf("warning shown = %v, want %v:\n%s", got, tt.wantWarn, header.String())
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@
 // Based on the Allegro LZSS implementation by Haruhiko Okumura and Shawn Hargreaves.
 package lzss
 
+import "fmt"
+
 const (
 	N         = 4096 // Ring buffer size
 	F         = 18   // Max match length
@@ -9,138 +11,392 @@ const (
 	NMask     = N - 1
 )
 
+// Params describes the LZSS window geometry. The token format stores
+// positions in 12 bits and lengths in 4 bits, so WindowSize must be a power of
+// two no larger than 4096 and MaxMatch at most Threshold+16.
+type Params struct {
+	WindowSize int // Ring buffer size (N)
+	MaxMatch   int // Longest match encoded as one reference (F)
+	Threshold  int // Matches this long or shorter are sent as literals
+}
+
+// DefaultParams returns the Allegro parameters used by the Eushully engine.
+func DefaultParams() Params {
+	return Params{WindowSize: N, MaxMatch: F, Threshold: Threshold}
+}
+
+// withDefaults returns p with its zero fields taken from DefaultParams.
+func (p Params) withDefaults() Params {
+	d := DefaultParams()
+	if p.WindowSize == 0 {
+		p.WindowSize = d.WindowSize
+	}
+	if p.MaxMatch == 0 {
+		p.MaxMatch = d.MaxMatch
+	}
+	if p.Threshold == 0 {
+		p.Threshold = d.Threshold
+	}
+	return p
+}
+
+// Validate reports whether the parameters can be encoded in the token format.
+// Zero fields stand for their DefaultParams values, as everywhere Params are
+// accepted.
+func (p Params) Validate() error {
+	p = p.withDefaults()
+	if p.WindowSize < 16 || p.WindowSize > 4096 || p.WindowSize&(p.WindowSize-1) != 0 {
+		return fmt.Errorf("lzss: window size %d must be a power of two between 16 and 4096", p.WindowSize)
+	}
+	if p.Threshold < 1 {
+		return fmt.Errorf("lzss: threshold %d must be at least 1", p.Threshold)
+	}
+	if p.MaxMatch <= p.Threshold || p.MaxMatch > p.Threshold+16 {
+		return fmt.Errorf("lzss: max match %d must be between %d and %d for threshold %d",
+			p.MaxMatch, p.Threshold+1, p.Threshold+16, p.Threshold)
+	}
+	if p.MaxMatch >= p.WindowSize {
+		return fmt.Errorf("lzss: max match %d must be smaller than the window size %d", p.MaxMatch, p.WindowSize)
+	}
+	return nil
+}
+
+// window holds the parameters in the form the coder loops use.
+type window struct {
+	n         int // Ring buffer size
+	f         int // Max match length
+	threshold int
+	nMask     int
+}
+
+// newWindow converts params to a window, taking zero fields from
+// DefaultParams. It panics if the parameters fail Validate, since the coders
+// would otherwise produce garbage; CompressWithErr reports the error instead.
+func newWindow(p Params) window {
+	p = p.withDefaults()
+	if err := p.Validate(); err != nil {
+		panic(err)
+	}
+	return window{n: p.WindowSize, f: p.MaxMatch, threshold: p.Threshold, nMask: p.WindowSize - 1}
+}
+
+// CompressWith compresses data using the given window parameters. It returns
+// nil if the parameters are invalid; use CompressWithErr to get the reason.
+func CompressWith(src []byte, p Params) []byte {
+	result, _ := CompressWithErr(src, p)
+	return result
+}
+
+// CompressWithErr compresses data using the given window parameters after
+// validating them.
+func CompressWithErr(src []byte, p Params) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return CompressWithOptions(src, CompressorOptions{Params: p}), nil
+}
+
+// DecompressWith decompresses data using the given window parameters. It
+// returns nil if the parameters are invalid.
+func DecompressWith(src []byte, p Params) []byte {
+	if p.Validate() != nil {
+		return nil
+	}
+	return DecompressWithOptions(src, CompressorOptions{Params: p})
+}
+
+// CompressorOptions controls variant-specific details of the LZSS stream.
+//
+// FillByte is the value the ring buffer is pre-filled with before the first
+// byte is processed. The Eushully engine uses zero, but other Allegro-derived
+// decoders pre-fill with 0x20 (space). The fill only affects back references
+// into the not-yet-written part of the window, so a mismatch typically shows up
+// as a handful of wrong bytes near the start of a stream. To discover the
+// value for a title, decompress a known sample (for example a metadata block
+// whose plaintext is recognizable) with each candidate fill and keep the one
+// whose output matches, then confirm that compressing the result with the same
+// options and decompressing it again round-trips.
+//
+// Params selects the window geometry; fields left zero take their
+// DefaultParams values. The compressors and decompressors panic if the
+// parameters fail Params.Validate.
+type CompressorOptions struct {
+	FillByte byte
+	Params   Params
+}
+
+// DefaultCompressorOptions returns the options matching the Eushully engine.
+func DefaultCompressorOptions() CompressorOptions {
+	return CompressorOptions{FillByte: 0, Params: DefaultParams()}
+}
+
+// newTextBuf allocates the ring buffer pre-filled with the configured byte.
+func (o CompressorOptions) newTextBuf() []byte {
+	w := newWindow(o.Params)
+	textBuf := make([]byte, w.n+w.f-1)
+	if o.FillByte != 0 {
+		for i := range textBuf {
+			textBuf[i] = o.FillByte
+		}
+	}
+	return textBuf
+}
+
 // Compress compresses data using LZSS algorithm compatible with Eushully engine.
 func Compress(src []byte) []byte {
+	return CompressWithOptions(src, DefaultCompressorOptions())
+}
+
+// CompressWithOptions compresses data using the given variant options.
+func CompressWithOptions(src []byte, opts CompressorOptions) []byte {
 	if len(src) == 0 {
+		newWindow(opts.Params) // Still reject invalid parameters
 		return nil
 	}
 
-	// Initialize ring buffer with zeros
-	textBuf := make([]byte, N+F-1)
+	e := newEncoder(opts)
+	e.pending = src
+	e.run(true)
+	e.finish()
+	return e.out
+}
 
-	// Binary search trees
-	lson := make([]int, N+1)
-	rson := make([]int, N+257)
-	dad := make([]int, N+1)
+// Stats describes the result of a compression pass.
+type Stats struct {
+	InputSize    int
+	OutputSize   int
+	Literals     int     // Bytes sent as literals
+	Matches      int     // Back references emitted
+	AverageMatch float64 // Mean back reference length in bytes
+}
 
-	// Initialize trees
-	for i := N + 1; i <= N+256; i++ {
-		rson[i] = N
+// Ratio returns the output size as a fraction of the input size. Values above
+// 1 mean the data expanded, typically because it was nearly all literals.
+func (s Stats) Ratio() float64 {
+	if s.InputSize == 0 {
+		return 0
+	}
+	return float64(s.OutputSize) / float64(s.InputSize)
+}
+
+// String formats the statistics on one line.
+func (s Stats) String() string {
+	return fmt.Sprintf("%d -> %d bytes (%.1f%%), %d literals, %d matches (avg %.1f bytes)",
+		s.InputSize, s.OutputSize, s.Ratio()*100, s.Literals, s.Matches, s.AverageMatch)
+}
+
+// CompressStats compresses data like Compress and also reports statistics
+// gathered during the same pass.
+func CompressStats(src []byte) ([]byte, Stats) {
+	stats := Stats{InputSize: len(src)}
+	if len(src) == 0 {
+		return nil, stats
 	}
-	for i := 0; i < N; i++ {
-		dad[i] = N
+
+	e := newEncoder(DefaultCompressorOptions())
+	e.pending = src
+	e.run(true)
+	e.finish()
+
+	stats.OutputSize = len(e.out)
+	stats.Literals = e.literals
+	stats.Matches = e.matches
+	if e.matches > 0 {
+		stats.AverageMatch = float64(e.matchedBytes) / float64(e.matches)
 	}
+	return e.out, stats
+}
 
-	var result []byte
-	codeBuf := make([]byte, 17)
-	codeBuf[0] = 0
-	codeBufPtr := 1
-	var mask byte = 1
+// encoder holds the state of the compressor between input chunks so that the
+// batch and streaming entry points share one implementation. Input is only
+// consumed while at least F bytes are pending (or the input has ended), which
+// keeps the emitted tokens identical regardless of how the input is split.
+type encoder struct {
+	window
 
-	s := 0
-	r := N - F
-	srcPos := 0
+	textBuf []byte
+
+	// Binary search trees
+	lson []int
+	rson []int
+	dad  []int
+
+	codeBuf    []byte
+	codeBufPtr int
+	mask       byte
+
+	s, r     int
+	length   int
+	matchLen int
+	matchPos int
+
+	started bool
+	pending []byte // input not yet consumed
+	out     []byte // output not yet handed to the caller
+
+	// Token counts for CompressStats
+	literals     int
+	matches      int
+	matchedBytes int
+}
 
-	// Read initial F bytes
-	var matchLen, matchPos int
-	length := 0
-	for length < F && srcPos < len(src) {
-		textBuf[r+length] = src[srcPos]
-		srcPos++
-		length++
+// newEncoder creates an encoder with an initialized ring buffer and trees.
+func newEncoder(opts CompressorOptions) *encoder {
+	w := newWindow(opts.Params)
+	e := &encoder{
+		window: w,
+		// Initialize ring buffer with the fill byte
+		textBuf:    opts.newTextBuf(),
+		lson:       make([]int, w.n+1),
+		rson:       make([]int, w.n+257),
+		dad:        make([]int, w.n+1),
+		codeBuf:    make([]byte, 17),
+		codeBufPtr: 1,
+		mask:       1,
+		r:          w.n - w.f,
 	}
 
-	if length == 0 {
-		return nil
+	// Initialize trees
+	for i := e.n + 1; i <= e.n+256; i++ {
+		e.rson[i] = e.n
+	}
+	for i := 0; i < e.n; i++ {
+		e.dad[i] = e.n
 	}
 
-	// Insert initial strings
-	for i := 1; i <= F; i++ {
-		insertNode(r-i, textBuf, lson, rson, dad, &matchPos, &matchLen)
+	return e
+}
+
+// next consumes one byte of pending input.
+func (e *encoder) next() (byte, bool) {
+	if len(e.pending) == 0 {
+		return 0, false
 	}
-	insertNode(r, textBuf, lson, rson, dad, &matchPos, &matchLen)
+	c := e.pending[0]
+	e.pending = e.pending[1:]
+	return c, true
+}
 
-	for length > 0 {
-		if matchLen > length {
-			matchLen = length
+// run encodes as much pending input as can be encoded without knowing what
+// follows it. When eof is true, all remaining input is encoded.
+func (e *encoder) run(eof bool) {
+	if !e.started {
+		if !eof && len(e.pending) < e.f {
+			return
 		}
+		e.started = true
 
-		if matchLen <= Threshold {
-			// Send literal byte
-			matchLen = 1
-			codeBuf[0] |= mask
-			codeBuf[codeBufPtr] = textBuf[r]
-			codeBufPtr++
-		} else {
-			// Send position and length pair
-			codeBuf[codeBufPtr] = byte(matchPos & 0xFF)
-			codeBufPtr++
-			codeBuf[codeBufPtr] = byte(((matchPos >> 4) & 0xF0) | ((matchLen - (Threshold + 1)) & 0x0F))
-			codeBufPtr++
+		// Read initial e.f bytes
+		for e.length < e.f {
+			c, ok := e.next()
+			if !ok {
+				break
+			}
+			e.textBuf[e.r+e.length] = c
+			e.length++
 		}
 
-		mask <<= 1
-		if mask == 0 {
-			// Flush code buffer
-			for i := 0; i < codeBufPtr; i++ {
-				result = append(result, codeBuf[i])
-			}
-			codeBuf[0] = 0
-			codeBufPtr = 1
-			mask = 1
+		if e.length == 0 {
+			return
 		}
 
-		lastMatchLen := matchLen
+		// Insert initial strings
+		for i := 1; i <= e.f; i++ {
+			e.insertNode(e.r - i)
+		}
+		e.insertNode(e.r)
+	}
 
-		var i int
-		for i = 0; i < lastMatchLen && srcPos < len(src); i++ {
-			c := src[srcPos]
-			srcPos++
+	for e.length > 0 && (eof || len(e.pending) >= e.f) {
+		e.step()
+	}
+}
 
-			deleteNode(s, dad, lson, rson)
-			textBuf[s] = c
-			if s < F-1 {
-				textBuf[s+N] = c
-			}
-			s = (s + 1) & NMask
-			r = (r + 1) & NMask
-			insertNode(r, textBuf, lson, rson, dad, &matchPos, &matchLen)
+// step emits one token and slides the window past it.
+func (e *encoder) step() {
+	if e.matchLen > e.length {
+		e.matchLen = e.length
+	}
+
+	if e.matchLen <= e.threshold {
+		// Send literal byte
+		e.matchLen = 1
+		e.codeBuf[0] |= e.mask
+		e.codeBuf[e.codeBufPtr] = e.textBuf[e.r]
+		e.codeBufPtr++
+		e.literals++
+	} else {
+		// Send position and length pair
+		e.codeBuf[e.codeBufPtr] = byte(e.matchPos & 0xFF)
+		e.codeBufPtr++
+		e.codeBuf[e.codeBufPtr] = byte(((e.matchPos >> 4) & 0xF0) | ((e.matchLen - (e.threshold + 1)) & 0x0F))
+		e.codeBufPtr++
+		e.matches++
+		e.matchedBytes += e.matchLen
+	}
+
+	e.mask <<= 1
+	if e.mask == 0 {
+		// Flush code buffer
+		e.out = append(e.out, e.codeBuf[:e.codeBufPtr]...)
+		e.codeBuf[0] = 0
+		e.codeBufPtr = 1
+		e.mask = 1
+	}
+
+	lastMatchLen := e.matchLen
+
+	var i int
+	for i = 0; i < lastMatchLen; i++ {
+		c, ok := e.next()
+		if !ok {
+			break
 		}
 
-		for i < lastMatchLen {
-			i++
-			deleteNode(s, dad, lson, rson)
-			s = (s + 1) & NMask
-			r = (r + 1) & NMask
-			length--
-			if length > 0 {
-				insertNode(r, textBuf, lson, rson, dad, &matchPos, &matchLen)
-			}
+		e.deleteNode(e.s)
+		e.textBuf[e.s] = c
+		if e.s < e.f-1 {
+			e.textBuf[e.s+e.n] = c
 		}
+		e.s = (e.s + 1) & e.nMask
+		e.r = (e.r + 1) & e.nMask
+		e.insertNode(e.r)
 	}
 
-	// Flush remaining code
-	if codeBufPtr > 1 {
-		for i := 0; i < codeBufPtr; i++ {
-			result = append(result, codeBuf[i])
+	for i < lastMatchLen {
+		i++
+		e.deleteNode(e.s)
+		e.s = (e.s + 1) & e.nMask
+		e.r = (e.r + 1) & e.nMask
+		e.length--
+		if e.length > 0 {
+			e.insertNode(e.r)
 		}
 	}
+}
 
-	return result
+// finish flushes the remaining partial code buffer.
+func (e *encoder) finish() {
+	if e.codeBufPtr > 1 {
+		e.out = append(e.out, e.codeBuf[:e.codeBufPtr]...)
+		e.codeBufPtr = 1
+	}
 }
 
 // insertNode inserts a string into the binary search tree.
-func insertNode(r int, textBuf []byte, lson, rson, dad []int, matchPos, matchLen *int) {
+func (e *encoder) insertNode(r int) {
+	textBuf, lson, rson, dad := e.textBuf, e.lson, e.rson, e.dad
+	n, f := e.n, e.f
 	cmp := 1
 	key := textBuf[r:]
-	p := N + 1 + int(key[0])
-	rson[r] = N
-	lson[r] = N
-	*matchLen = 0
+	p := n + 1 + int(key[0])
+	rson[r] = n
+	lson[r] = n
+	e.matchLen = 0
 
 	for {
 		if cmp >= 0 {
-			if rson[p] != N {
+			if rson[p] != n {
 				p = rson[p]
 			} else {
 				rson[p] = r
@@ -148,7 +404,7 @@ func insertNode(r int, textBuf []byte, lson, rson, dad []int, matchPos, matchLen
 				return
 			}
 		} else {
-			if lson[p] != N {
+			if lson[p] != n {
 				p = lson[p]
 			} else {
 				lson[p] = r
@@ -158,17 +414,17 @@ func insertNode(r int, textBuf []byte, lson, rson, dad []int, matchPos, matchLen
 		}
 
 		var i int
-		for i = 1; i < F; i++ {
+		for i = 1; i < f; i++ {
 			cmp = int(key[i]) - int(textBuf[p+i])
 			if cmp != 0 {
 				break
 			}
 		}
 
-		if i > *matchLen {
-			*matchPos = p
-			*matchLen = i
-			if i >= F {
+		if i > e.matchLen {
+			e.matchPos = p
+			e.matchLen = i
+			if i >= f {
 				break
 			}
 		}
@@ -185,24 +441,26 @@ func insertNode(r int, textBuf []byte, lson, rson, dad []int, matchPos, matchLen
 	} else {
 		lson[dad[p]] = r
 	}
-	dad[p] = N
+	dad[p] = n
 }
 
 // deleteNode removes a node from the binary search tree.
-func deleteNode(p int, dad, lson, rson []int) {
-	if dad[p] == N {
+func (e *encoder) deleteNode(p int) {
+	dad, lson, rson := e.dad, e.lson, e.rson
+	n := e.n
+	if dad[p] == n {
 		return
 	}
 
 	var q int
-	if rson[p] == N {
+	if rson[p] == n {
 		q = lson[p]
-	} else if lson[p] == N {
+	} else if lson[p] == n {
 		q = rson[p]
 	} else {
 		q = lson[p]
-		if rson[q] != N {
-			for rson[q] != N {
+		if rson[q] != n {
+			for rson[q] != n {
 				q = rson[q]
 			}
 			rson[dad[q]] = lson[q]
@@ -220,29 +478,100 @@ func deleteNode(p int, dad, lson, rson []int) {
 	} else {
 		lson[dad[p]] = q
 	}
-	dad[p] = N
+	dad[p] = n
 }
 
 // Decompress decompresses LZSS data compatible with Eushully engine.
+// Truncated input yields the output produced so far; use DecompressChecked
+// when the caller needs to know whether the stream was complete.
 func Decompress(src []byte) []byte {
+	return DecompressWithOptions(src, DefaultCompressorOptions())
+}
+
+// DecompressWithOptions decompresses LZSS data using the given variant options.
+// The options must match those used to compress the data.
+func DecompressWithOptions(src []byte, opts CompressorOptions) []byte {
+	result, _ := decompress(src, -1, opts)
+	return result
+}
+
+// DecompressChecked decompresses LZSS data and verifies that the stream is well
+// formed. It returns an error, including the source offset of the offending
+// token, when the stream ends inside a token or when the output length does
+// not equal expectedLen. A negative expectedLen disables the length check.
+//
+// References into the part of the ring buffer that has not been written yet are
+// not treated as errors: the encoder seeds its search tree with the pre-filled
+// window, so runs of the fill byte at the start of a stream legitimately
+// decode from there.
+func DecompressChecked(src []byte, expectedLen int) ([]byte, error) {
+	return DecompressCheckedWithOptions(src, expectedLen, DefaultCompressorOptions())
+}
+
+// DecompressCheckedWithOptions is DecompressChecked using the given variant options.
+func DecompressCheckedWithOptions(src []byte, expectedLen int, opts CompressorOptions) ([]byte, error) {
+	if expectedLen < 0 {
+		return decompress(src, expectedLen, opts)
+	}
+	if expectedLen > maxDecompressedSize(len(src), newWindow(opts.Params)) {
+		return nil, fmt.Errorf("lzss: expected length %d is impossible for %d bytes of input",
+			expectedLen, len(src))
+	}
+	return decompressInto(src, make([]byte, expectedLen), opts)
+}
+
+// DecompressSize decompresses LZSS data whose decompressed size is known, as
+// recorded in sector and compression headers. The output is allocated once
+// up front instead of growing byte by byte. The size is only a capacity hint:
+// like Decompress, the whole stream is decoded and malformed input yields the
+// output produced so far.
+func DecompressSize(src []byte, size int) []byte {
+	// Don't trust a corrupt header to size the allocation
+	if size < 0 || size > maxDecompressedSize(len(src), newWindow(Params{})) {
+		size = 0
+	}
+	result, _ := decodeAppend(make([]byte, 0, size), src, -1, DefaultCompressorOptions())
+	return result
+}
+
+// maxDecompressedSize bounds the output of srcLen bytes of input: one flag
+// byte followed by eight back references of the longest match.
+func maxDecompressedSize(srcLen int, w window) int {
+	return (srcLen/17 + 1) * 8 * w.f
+}
+
+// decompress decodes into a buffer grown as needed.
+func decompress(src []byte, expectedLen int, opts CompressorOptions) ([]byte, error) {
+	return decodeAppend(nil, src, expectedLen, opts)
+}
+
+// decompressInto decodes into dst, whose length is the expected output size.
+// The returned slice shares dst's storage unless the stream overruns it.
+func decompressInto(src []byte, dst []byte, opts CompressorOptions) ([]byte, error) {
+	return decodeAppend(dst[:0], src, len(dst), opts)
+}
+
+// decodeAppend is the shared decoder, appending output to result. On error it
+// returns the partial output alongside the error so that the unchecked entry
+// points stay compatible. A negative expectedLen disables the length checks.
+func decodeAppend(result []byte, src []byte, expectedLen int, opts CompressorOptions) ([]byte, error) {
+	w := newWindow(opts.Params)
 	if len(src) == 0 {
-		return nil
+		if expectedLen > 0 {
+			return result, fmt.Errorf("lzss: empty input, expected %d bytes", expectedLen)
+		}
+		return result, nil
 	}
 
-	textBuf := make([]byte, N+F-1)
-	// Initialize buffer with zeros (already done by make)
+	textBuf := opts.newTextBuf()
 
-	var result []byte
-	r := N - F
+	r := w.n - w.f
 	var flags uint
 
 	srcPos := 0
 	for srcPos < len(src) {
 		flags >>= 1
 		if (flags & 256) == 0 {
-			if srcPos >= len(src) {
-				break
-			}
 			c := src[srcPos]
 			srcPos++
 			flags = uint(c) | 0xFF00
@@ -251,17 +580,17 @@ func Decompress(src []byte) []byte {
 		if (flags & 1) != 0 {
 			// Literal byte
 			if srcPos >= len(src) {
-				break
+				return result, fmt.Errorf("lzss: stream ends after flag byte at offset %d", srcPos-1)
 			}
 			c := src[srcPos]
 			srcPos++
 			textBuf[r] = c
-			r = (r + 1) & NMask
+			r = (r + 1) & w.nMask
 			result = append(result, c)
 		} else {
 			// Back reference
 			if srcPos+1 >= len(src) {
-				break
+				return result, fmt.Errorf("lzss: stream ends inside position/length pair at offset %d", srcPos)
 			}
 			i := int(src[srcPos])
 			srcPos++
@@ -269,16 +598,26 @@ func Decompress(src []byte) []byte {
 			srcPos++
 
 			i |= (j & 0xF0) << 4
-			j = (j & 0x0F) + Threshold
+			j = (j & 0x0F) + w.threshold
 
 			for k := 0; k <= j; k++ {
-				c := textBuf[(i+k)&NMask]
+				c := textBuf[(i+k)&w.nMask]
 				textBuf[r] = c
-				r = (r + 1) & NMask
+				r = (r + 1) & w.nMask
 				result = append(result, c)
 			}
 		}
+
+		if expectedLen >= 0 && len(result) > expectedLen {
+			return result, fmt.Errorf("lzss: output exceeds expected length %d at source offset %d",
+				expectedLen, srcPos)
+		}
 	}
 
-	return result
+	if expectedLen >= 0 && len(result) != expectedLen {
+		return result, fmt.Errorf("lzss: decompressed %d bytes, expected %d (input ends at offset %d)",
+			len(result), expectedLen, srcPos)
+	}
+
+	return result, nil
 }
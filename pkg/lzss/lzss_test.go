@@ -0,0 +1,172 @@
+package lzss
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// testData returns size bytes of repetitive text with some variation, like
+// the index metadata and scripts the codec is used on.
+func testData(size int) []byte {
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < size; i++ {
+		fmt.Fprintf(&buf, "DATA%d\\FILE_%05d.BIN offset=%08X ", i%7, i, i*0x1234)
+	}
+	return buf.Bytes()[:size]
+}
+
+func TestDecompressSize(t *testing.T) {
+	src := testData(1 << 16)
+	compressed := Compress(src)
+
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"exact", len(src)},
+		{"too small", 16},
+		{"negative", -1},
+		{"impossible", 1 << 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The size is only a hint; the whole stream is always decoded
+			if got := DecompressSize(compressed, tt.size); !bytes.Equal(got, src) {
+				t.Errorf("DecompressSize returned %d bytes, want the %d original", len(got), len(src))
+			}
+		})
+	}
+
+	// With the exact size the output is allocated once, besides the ring buffer
+	if allocs := testing.AllocsPerRun(10, func() { DecompressSize(compressed, len(src)) }); allocs > 2 {
+		t.Errorf("DecompressSize makes %v allocations, want at most 2", allocs)
+	}
+}
+
+func BenchmarkDecompress(b *testing.B) {
+	src := testData(1 << 20)
+	compressed := Compress(src)
+
+	tests := []struct {
+		name string
+		fn   func() []byte
+	}{
+		{"Decompress", func() []byte { return Decompress(compressed) }},
+		{"DecompressSize", func() []byte { return DecompressSize(compressed, len(src)) }},
+	}
+
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(src)))
+			for b.Loop() {
+				tt.fn()
+			}
+		})
+	}
+}
+
+func TestFillByte(t *testing.T) {
+	for _, fill := range []byte{0x00, 0x20, 0xFF} {
+		t.Run(fmt.Sprintf("0x%02X", fill), func(t *testing.T) {
+			// A leading run of the fill byte is encoded as a reference into
+			// the pre-filled window
+			src := append(bytes.Repeat([]byte{fill}, 40), testData(4096)...)
+			opts := CompressorOptions{FillByte: fill}
+
+			compressed := CompressWithOptions(src, opts)
+			if got := DecompressWithOptions(compressed, opts); !bytes.Equal(got, src) {
+				t.Fatal("round trip with the same fill byte differs")
+			}
+			got, err := DecompressCheckedWithOptions(compressed, len(src), opts)
+			if err != nil || !bytes.Equal(got, src) {
+				t.Fatalf("checked round trip: %v", err)
+			}
+
+			var stream bytes.Buffer
+			w := NewWriterWithOptions(&stream, opts)
+			if _, err := w.Write(src); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := io.ReadAll(NewReaderWithOptions(&stream, opts)); err != nil || !bytes.Equal(got, src) {
+				t.Fatalf("stream round trip: %v", err)
+			}
+
+			// Another fill byte decodes the leading run wrongly
+			other := CompressorOptions{FillByte: fill ^ 0x55}
+			if got := DecompressWithOptions(compressed, other); bytes.Equal(got, src) {
+				t.Error("decoding with a different fill byte reproduced the input")
+			}
+		})
+	}
+}
+
+func TestParams(t *testing.T) {
+	src := testData(20000)
+
+	tests := []struct {
+		name    string
+		params  Params
+		wantErr bool
+	}{
+		{"zero", Params{}, false},
+		{"defaults", DefaultParams(), false},
+		{"window only", Params{WindowSize: 1024}, false},
+		{"max match only", Params{MaxMatch: 10}, false},
+		{"threshold only", Params{Threshold: 3}, false},
+		{"small window", Params{WindowSize: 16, MaxMatch: 8, Threshold: 2}, false},
+		{"window not a power of two", Params{WindowSize: 1000}, true},
+		{"window too large", Params{WindowSize: 8192}, true},
+		{"max match too long", Params{MaxMatch: 19}, true},
+		{"negative threshold", Params{Threshold: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate = %v, want error %v", err, tt.wantErr)
+			}
+
+			compressed, err := CompressWithErr(src, tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("CompressWithErr accepted invalid parameters")
+				}
+				opts := CompressorOptions{Params: tt.params}
+				for name, fn := range map[string]func(){
+					"CompressWithOptions":       func() { CompressWithOptions(src, opts) },
+					"CompressWithOptions empty": func() { CompressWithOptions(nil, opts) },
+					"DecompressWithOptions":     func() { DecompressWithOptions(Compress(src), opts) },
+					"NewWriterWithOptions":      func() { NewWriterWithOptions(io.Discard, opts) },
+				} {
+					if !panics(fn) {
+						t.Errorf("%s accepted invalid parameters", name)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CompressWithErr: %v", err)
+			}
+			if got := DecompressWith(compressed, tt.params); !bytes.Equal(got, src) {
+				t.Error("round trip differs")
+			}
+		})
+	}
+}
+
+// panics reports whether fn panics.
+func panics(fn func()) (panicked bool) {
+	defer func() {
+		panicked = recover() != nil
+	}()
+	fn()
+	return false
+}
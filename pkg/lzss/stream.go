@@ -0,0 +1,192 @@
+package lzss
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// Writer compresses data written to it and writes the LZSS stream to the
+// underlying writer. The output is byte-identical to Compress of the
+// concatenated input. Close must be called to flush the final code buffer.
+type Writer struct {
+	w      io.Writer
+	enc    *encoder
+	buf    []byte
+	err    error
+	closed bool
+}
+
+// NewWriter returns a Writer compressing with the Eushully defaults.
+func NewWriter(w io.Writer) *Writer {
+	return NewWriterWithOptions(w, DefaultCompressorOptions())
+}
+
+// NewWriterWithOptions returns a Writer compressing with the given options.
+func NewWriterWithOptions(w io.Writer, opts CompressorOptions) *Writer {
+	return &Writer{w: w, enc: newEncoder(opts)}
+}
+
+// Write compresses p. Up to F bytes of input are held back until more data or
+// Close arrives, since the encoder needs that much lookahead.
+func (zw *Writer) Write(p []byte) (int, error) {
+	if zw.closed {
+		return 0, errors.New("lzss: write to closed Writer")
+	}
+	if zw.err != nil {
+		return 0, zw.err
+	}
+
+	zw.buf = append(zw.buf, p...)
+	zw.enc.pending = zw.buf
+	zw.enc.run(false)
+
+	// Keep only the unconsumed tail so the buffer does not grow without bound
+	zw.buf = append(zw.buf[:0], zw.enc.pending...)
+
+	if err := zw.flush(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close encodes the remaining input and flushes the final code buffer.
+// It does not close the underlying writer.
+func (zw *Writer) Close() error {
+	if zw.closed {
+		return zw.err
+	}
+	zw.closed = true
+	if zw.err != nil {
+		return zw.err
+	}
+
+	zw.enc.pending = zw.buf
+	zw.enc.run(true)
+	zw.enc.finish()
+	zw.buf = nil
+
+	return zw.flush()
+}
+
+// flush writes the encoder's pending output to the underlying writer.
+func (zw *Writer) flush() error {
+	if len(zw.enc.out) == 0 {
+		return nil
+	}
+	if _, err := zw.w.Write(zw.enc.out); err != nil {
+		zw.err = err
+		return err
+	}
+	zw.enc.out = zw.enc.out[:0]
+	return nil
+}
+
+// Reader decompresses an LZSS stream read from the underlying reader.
+// A stream that ends inside a token yields io.ErrUnexpectedEOF.
+type Reader struct {
+	window
+
+	r       io.ByteReader
+	textBuf []byte
+	pos     int
+	flags   uint
+	out     []byte
+	err     error
+}
+
+// NewReader returns a Reader decompressing with the Eushully defaults.
+func NewReader(r io.Reader) *Reader {
+	return NewReaderWithOptions(r, DefaultCompressorOptions())
+}
+
+// NewReaderWithOptions returns a Reader decompressing with the given options.
+func NewReaderWithOptions(r io.Reader, opts CompressorOptions) *Reader {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	w := newWindow(opts.Params)
+	return &Reader{
+		window:  w,
+		r:       br,
+		textBuf: opts.newTextBuf(),
+		pos:     w.n - w.f,
+	}
+}
+
+// Read decompresses into p.
+func (zr *Reader) Read(p []byte) (int, error) {
+	for len(zr.out) == 0 {
+		if zr.err != nil {
+			return 0, zr.err
+		}
+		zr.err = zr.decodeToken()
+	}
+
+	n := copy(p, zr.out)
+	zr.out = zr.out[n:]
+	return n, nil
+}
+
+// decodeToken decodes one literal or back reference into zr.out.
+func (zr *Reader) decodeToken() error {
+	zr.out = zr.out[:0]
+
+	// End of stream before a token starts is a clean EOF; once a flag byte
+	// has been read, at least one token must follow it
+	zr.flags >>= 1
+	freshFlags := false
+	if (zr.flags & 256) == 0 {
+		c, err := zr.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		zr.flags = uint(c) | 0xFF00
+		freshFlags = true
+	}
+
+	first, err := zr.r.ReadByte()
+	if err != nil {
+		if freshFlags {
+			return unexpectedEOF(err)
+		}
+		return err
+	}
+
+	if (zr.flags & 1) != 0 {
+		// Literal byte
+		zr.put(first)
+		return nil
+	}
+
+	// Back reference
+	lo := first
+	hi, err := zr.r.ReadByte()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	i := int(lo) | (int(hi)&0xF0)<<4
+	j := (int(hi) & 0x0F) + zr.threshold
+
+	for k := 0; k <= j; k++ {
+		zr.put(zr.textBuf[(i+k)&zr.nMask])
+	}
+	return nil
+}
+
+// put appends a decoded byte to the output and the ring buffer.
+func (zr *Reader) put(c byte) {
+	zr.textBuf[zr.pos] = c
+	zr.pos = (zr.pos + 1) & zr.nMask
+	zr.out = append(zr.out, c)
+}
+
+// unexpectedEOF converts a mid-token EOF into io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package lzss
+
+import "fmt"
+
+// Verify compresses original, decompresses the result and compares it with
+// original byte for byte. It returns false and an error giving the first
+// differing offset when the round trip does not reproduce the input.
+func Verify(original []byte) (bool, error) {
+	if err := VerifyCompressed(original, Compress(original)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CompressVerified compresses src and checks that the result decompresses
+// back to src before returning it, so corrupt output never reaches disk.
+func CompressVerified(src []byte) ([]byte, error) {
+	compressed := Compress(src)
+	if err := VerifyCompressed(src, compressed); err != nil {
+		return nil, err
+	}
+	return compressed, nil
+}
+
+// VerifyCompressed checks that compressed decodes to original, for callers
+// that already hold the compressed form.
+func VerifyCompressed(original, compressed []byte) error {
+	decoded, err := DecompressChecked(compressed, len(original))
+	if err != nil {
+		return fmt.Errorf("lzss: round trip failed: %w", err)
+	}
+
+	for i := range original {
+		if decoded[i] != original[i] {
+			return fmt.Errorf("lzss: round trip differs at offset %d: got 0x%02X, expected 0x%02X",
+				i, decoded[i], original[i])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package lzss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	allValues := make([]byte, 256)
+	for i := range allValues {
+		allValues[i] = byte(i)
+	}
+
+	tests := []struct {
+		name string
+		src  []byte
+	}{
+		{"empty", nil},
+		{"one byte", []byte{0x42}},
+		{"zeros", make([]byte, 5000)},
+		{"text", testData(20000)},
+		{"all values", allValues},
+		{"window minus one", testData(N - 1)},
+		{"window", testData(N)},
+		{"window plus one", testData(N + 1)},
+		{"window of zeros", make([]byte, N)},
+	}
+
+	for _, tt := range tests {
+		name, src := tt.name, tt.src
+		if ok, err := Verify(src); !ok || err != nil {
+			t.Errorf("Verify(%s) = %v, %v", name, ok, err)
+		}
+		compressed, err := CompressVerified(src)
+		if err != nil {
+			t.Errorf("CompressVerified(%s): %v", name, err)
+		}
+		if !bytes.Equal(compressed, Compress(src)) {
+			t.Errorf("CompressVerified(%s) differs from Compress", name)
+		}
+	}
+}
+
+func TestVerifyCompressedRejects(t *testing.T) {
+	src := testData(4096)
+	compressed := Compress(src)
+
+	changed := bytes.Clone(src)
+	changed[100] ^= 0xFF
+
+	corrupt := bytes.Clone(compressed)
+	corrupt[len(corrupt)/2] ^= 0xFF
+
+	tests := []struct {
+		name       string
+		original   []byte
+		compressed []byte
+	}{
+		{"different original", changed, compressed},
+		{"truncated stream", src, compressed[:len(compressed)-3]},
+		{"corrupted stream", src, corrupt},
+		{"longer original", append(bytes.Clone(src), 'x'), compressed},
+	}
+
+	for _, tt := range tests {
+		if err := VerifyCompressed(tt.original, tt.compressed); err == nil {
+			t.Errorf("%s: VerifyCompressed accepted the stream", tt.name)
+		}
+	}
+}
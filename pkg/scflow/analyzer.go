@@ -3,10 +3,14 @@ package scflow
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+
+	"agetools/pkg/bin"
 )
 
 // Instruction represents a parsed instruction from SC file
@@ -38,6 +42,7 @@ type Analyzer struct {
 	Labels       map[string]int
 	Variables    map[string]*Variable
 	FunctionCalls map[string][]int // function label -> line numbers
+	EntryLines   []int            // lines of Table1 entry points, set by ResolveEntryPoints
 }
 
 // NewAnalyzer creates a new analyzer for an SC file
@@ -76,8 +81,6 @@ func (a *Analyzer) ReadFile() error {
 func (a *Analyzer) Parse() error {
 	currentLabel := "_start"
 
-	labelRegex := regexp.MustCompile(`^(label_[0-9A-Fa-f]+):`)
-
 	for lineNum, rawLine := range a.Lines {
 		line := strings.TrimSpace(rawLine)
 
@@ -87,17 +90,21 @@ func (a *Analyzer) Parse() error {
 			continue
 		}
 
+		// Match on the code alone, without offset annotations or comments
+		code := bin.StripLine(line)
+
 		// Check for labels
-		if match := labelRegex.FindStringSubmatch(line); match != nil {
-			currentLabel = match[1]
+		if name, ok := bin.LabelDefinition(code); ok {
+			currentLabel = name
 			a.Labels[currentLabel] = lineNum
 			continue
 		}
 
-		// Parse instructions (they start with spaces in raw line)
-		if len(rawLine) > 0 && (rawLine[0] == ' ' || rawLine[0] == '\t') {
-			parts := strings.Fields(line)
-			if len(parts) == 0 {
+		// Parse instructions (they start with spaces in raw line, or with
+		// an offset annotation in annotated listings)
+		if rawLine[0] == ' ' || rawLine[0] == '\t' || strings.HasPrefix(line, "0x") {
+			parts := strings.Fields(code)
+			if len(parts) == 0 || strings.HasPrefix(code, "//") {
 				continue
 			}
 
@@ -109,7 +116,7 @@ func (a *Analyzer) Parse() error {
 				Label:   currentLabel,
 				Opcode:  opcode,
 				Args:    args,
-				Raw:     line,
+				Raw:     code,
 			}
 
 			a.Instructions[lineNum] = instr
@@ -396,3 +403,64 @@ func (a *Analyzer) FindCallsTo(funcLabel string) []struct {
 
 	return result
 }
+
+// StringRef is a string literal argument found in the SC file
+type StringRef struct {
+	LineNum int
+	Label   string
+	Value   string
+}
+
+var stringLiteralRegex = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+
+// LiveStrings classifies every string literal by whether its instruction is
+// reachable from the given entry labels. When entries is empty, the CFG's
+// entry points are used, which include Table1 entries only after
+// ResolveEntryPoints. Both results are ordered by line number.
+func (a *Analyzer) LiveStrings(entries []string) (live, dead []StringRef) {
+	cfg := a.BuildCFG()
+	if len(entries) == 0 {
+		entries = cfg.EntryPoints()
+	}
+	reached := cfg.ReachableBlocks(entries)
+
+	lines := make([]int, 0, len(a.Instructions))
+	for line := range a.Instructions {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	for _, line := range lines {
+		instr := a.Instructions[line]
+		for _, match := range stringLiteralRegex.FindAllStringSubmatch(instr.Raw, -1) {
+			ref := StringRef{LineNum: line, Label: instr.Label, Value: match[1]}
+			if reached[cfg.LineToBlock[line]] {
+				live = append(live, ref)
+			} else {
+				dead = append(dead, ref)
+			}
+		}
+	}
+
+	return live, dead
+}
+
+// WriteAnnotated writes the SC file with a speaker comment above each dialogue
+// instruction in speakers, keeping every original line intact.
+func (a *Analyzer) WriteAnnotated(w io.Writer, speakers map[int]int) error {
+	bw := bufio.NewWriter(w)
+
+	for lineNum, rawLine := range a.Lines {
+		if charID, ok := speakers[lineNum]; ok {
+			indent := rawLine[:len(rawLine)-len(strings.TrimLeft(rawLine, " \t"))]
+			if charID == 0 {
+				fmt.Fprintf(bw, "%s// speaker: 0 (narrator)\n", indent)
+			} else {
+				fmt.Fprintf(bw, "%s// speaker: %d\n", indent, charID)
+			}
+		}
+		fmt.Fprintln(bw, rawLine)
+	}
+
+	return bw.Flush()
+}
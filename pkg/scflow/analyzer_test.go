@@ -0,0 +1,112 @@
+package scflow
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"agetools/pkg/bin"
+)
+
+// testAnalyzer parses text as the lines of an SC file.
+func testAnalyzer(t *testing.T, text string) *Analyzer {
+	t.Helper()
+
+	a := NewAnalyzer("test.txt")
+	a.Lines = strings.Split(text, "\n")
+	if err := a.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestParseNamedLabels(t *testing.T) {
+	const body = "==Binary Information - do not edit==\n" +
+		"signature = SYS5501\n" +
+		"local_vars = { 0 0 0 0 0 0 }\n" +
+		"====\n" +
+		"    call label_00000001\n" +
+		"    jmp label_00000002\n" +
+		"\nlabel_00000001:\n" +
+		"    mov local-ptr:0 7\n" +
+		"    ret\n" +
+		"\nlabel_00000002:\n" +
+		"    show-text 0 \"hi; label_00000001\"\n" +
+		"    exit\n"
+
+	res, err := bin.Assemble(body, bin.FormatSYS5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := bin.Disassemble(res.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := script.Labels[script.Instructions[0].Offset]
+	callee := script.Labels[script.Instructions[2].Offset]
+	scene := script.Labels[script.Instructions[4].Offset]
+	script.Instructions[0].Comment = "jmp label_00000099"
+	if err := script.RenameLabels(map[string]string{callee: "setup_character", scene: "scene_end"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"plain", script.ToText()},
+		{"annotated", script.ToAnnotatedText()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testAnalyzer(t, tt.text)
+
+			var labels []string
+			for label := range a.Labels {
+				labels = append(labels, label)
+			}
+			for _, want := range []string{entry, "setup_character", "scene_end"} {
+				if _, ok := a.Labels[want]; !ok {
+					t.Errorf("label %s not found, have %v", want, labels)
+				}
+			}
+
+			var opcodes []string
+			for _, line := range sortedInstructionLines(a) {
+				instr := a.Instructions[line]
+				opcodes = append(opcodes, instr.Opcode)
+				if strings.Contains(instr.Raw, ";") && instr.Opcode != "show-text" {
+					t.Errorf("line %d keeps its comment: %q", line, instr.Raw)
+				}
+			}
+			want := []string{"call", "jmp", "mov", "ret", "show-text", "exit"}
+			if !reflect.DeepEqual(opcodes, want) {
+				t.Errorf("opcodes = %v, want %v", opcodes, want)
+			}
+
+			cfg := a.BuildCFG()
+			if got := cfg.Blocks[entry].Successors; !reflect.DeepEqual(got, []string{"scene_end"}) {
+				t.Errorf("%s successors = %v, want [scene_end]", entry, got)
+			}
+			reached := cfg.ReachableBlocks([]string{entry})
+			if !reached["setup_character"] || !reached["scene_end"] {
+				t.Errorf("reachable blocks = %v, want setup_character and scene_end", reached)
+			}
+			if got := a.FunctionCalls["setup_character"]; len(got) != 1 {
+				t.Errorf("calls to setup_character = %v, want one", got)
+			}
+		})
+	}
+}
+
+// sortedInstructionLines returns the line numbers of a's instructions in order.
+func sortedInstructionLines(a *Analyzer) []int {
+	var lines []int
+	for line := range a.Instructions {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
@@ -3,8 +3,11 @@ package scflow
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+
+	"agetools/pkg/bin"
 )
 
 // BasicBlock represents a basic block in the control flow graph
@@ -23,6 +26,7 @@ type CFG struct {
 	LineToBlock   map[int]string         // line -> block label
 	CallGraph     map[string][]string    // func label -> called functions
 	ReverseGraph  map[string][]string    // func label -> functions that call it
+	EntryBlocks   []string               // blocks holding a Table1 entry point, in file order
 }
 
 // BuildCFG builds a control flow graph from instructions
@@ -51,27 +55,48 @@ func (a *Analyzer) BuildCFG() *CFG {
 		}
 	}
 
+	// An entry point that lands mid-block starts a block of its own. The
+	// text has no label there, so the block is named after the enclosing
+	// label and the line, which cannot clash with a label name
+	entryLines := make(map[int]bool, len(a.EntryLines))
+	for _, line := range a.EntryLines {
+		entryLines[line] = true
+	}
+	blockOf := make(map[int]string, len(sortedLines))
+	prevLabel, current := "", ""
+	for _, lineNum := range sortedLines {
+		instr := a.Instructions[lineNum]
+		switch {
+		case instr.Label != prevLabel:
+			current = instr.Label
+		case entryLines[lineNum]:
+			current = fmt.Sprintf("%s@%d", instr.Label, lineNum)
+		}
+		prevLabel = instr.Label
+		blockOf[lineNum] = current
+	}
+
 	// Create blocks for each unique label
 	labelOrder := make([]string, 0)
 	for _, lineNum := range sortedLines {
-		instr := a.Instructions[lineNum]
-		if _, exists := blocksByLabel[instr.Label]; !exists {
-			blocksByLabel[instr.Label] = &BasicBlock{
-				Label:        instr.Label,
+		label := blockOf[lineNum]
+		if _, exists := blocksByLabel[label]; !exists {
+			blocksByLabel[label] = &BasicBlock{
+				Label:        label,
 				Instructions: make([]*Instruction, 0),
 				Successors:   make([]string, 0),
 				Predecessors: make([]string, 0),
 			}
-			labelOrder = append(labelOrder, instr.Label)
+			labelOrder = append(labelOrder, label)
 		}
 	}
 
 	// Assign instructions to blocks and map line to block
 	for _, lineNum := range sortedLines {
 		instr := a.Instructions[lineNum]
-		block := blocksByLabel[instr.Label]
+		block := blocksByLabel[blockOf[lineNum]]
 		block.Instructions = append(block.Instructions, instr)
-		cfg.LineToBlock[lineNum] = instr.Label
+		cfg.LineToBlock[lineNum] = block.Label
 
 		// Set block start/end lines
 		if block.StartLine == 0 {
@@ -80,6 +105,16 @@ func (a *Analyzer) BuildCFG() *CFG {
 		block.EndLine = lineNum
 	}
 
+	for _, line := range a.EntryLines {
+		label, ok := blockOf[line]
+		if !ok {
+			continue
+		}
+		if n := len(cfg.EntryBlocks); n == 0 || cfg.EntryBlocks[n-1] != label {
+			cfg.EntryBlocks = append(cfg.EntryBlocks, label)
+		}
+	}
+
 	// Second pass: build successor relationships based on jmp instructions
 	for _, block := range blocksByLabel {
 		if len(block.Instructions) == 0 {
@@ -93,7 +128,7 @@ func (a *Analyzer) BuildCFG() *CFG {
 			// Unconditional jump - only one successor
 			target := ""
 			for _, arg := range lastInstr.Args {
-				if strings.HasPrefix(arg, "label_") {
+				if bin.IsValidLabelName(arg) {
 					target = arg
 					break
 				}
@@ -106,7 +141,7 @@ func (a *Analyzer) BuildCFG() *CFG {
 			// Conditional jump - two successors: true (jump target) and false (fallthrough)
 			target := ""
 			for _, arg := range lastInstr.Args {
-				if strings.HasPrefix(arg, "label_") {
+				if bin.IsValidLabelName(arg) {
 					target = arg
 					break
 				}
@@ -186,7 +221,28 @@ func (a *Analyzer) BuildCFG() *CFG {
 
 // QueryCharacterIDUsingCFG uses CFG to trace character ID more accurately
 func (a *Analyzer) QueryCharacterIDUsingCFG(dialogueLine int) (int, []string) {
+	return a.queryCharacterID(a.BuildCFG(), dialogueLine)
+}
+
+// ResolveAllSpeakers resolves the character ID for every dialogue instruction
+// in the file, building the CFG once. The result maps line number to character
+// ID, where 0 is the narrator.
+func (a *Analyzer) ResolveAllSpeakers() map[int]int {
 	cfg := a.BuildCFG()
+	speakers := make(map[int]int)
+
+	for lineNum, instr := range a.Instructions {
+		if !bin.IsDialogueOpcode(instr.Opcode) {
+			continue
+		}
+		speakers[lineNum], _ = a.queryCharacterID(cfg, lineNum)
+	}
+
+	return speakers
+}
+
+// queryCharacterID traces the character ID for a dialogue line using a prebuilt CFG
+func (a *Analyzer) queryCharacterID(cfg *CFG, dialogueLine int) (int, []string) {
 	var explanation []string
 	explanation = append(explanation, fmt.Sprintf("Tracing character ID for dialogue at line %d using CFG", dialogueLine))
 
@@ -203,7 +259,7 @@ func (a *Analyzer) QueryCharacterIDUsingCFG(dialogueLine int) (int, []string) {
 	// Check if the dialogue line itself has a narration flag (first arg is 0)
 	// This applies to show-text, display-furigana, and similar instructions
 	if instr, exists := a.Instructions[dialogueLine]; exists && len(instr.Args) > 0 {
-		if instr.Args[0] == "0" && isDialogueRelatedOpcode(instr.Opcode) {
+		if instr.Args[0] == "0" && bin.IsDialogueOpcode(instr.Opcode) {
 			explanation = append(explanation, fmt.Sprintf("  Dialogue line has %s 0 (narrator)", instr.Opcode))
 			return 0, explanation
 		}
@@ -216,81 +272,77 @@ func (a *Analyzer) QueryCharacterIDUsingCFG(dialogueLine int) (int, []string) {
 	return charID, explanation
 }
 
-// queryCharIDInBlock recursively searches for character ID in a block and its predecessors
+// queryCharIDInBlock searches for character ID in a block and its predecessors.
+// A block without an ID defers to its first predecessor and takes whatever that
+// yields, 0 included, so the search walks a single chain of first predecessors.
+// It is written as a loop so that long chains cannot overflow the stack.
 func queryCharIDInBlock(cfg *CFG, blockLabel string, visited map[string]bool, explanation *[]string) int {
-	if visited[blockLabel] {
-		return 0
-	}
-	visited[blockLabel] = true
+	for {
+		if visited[blockLabel] {
+			return 0
+		}
+		visited[blockLabel] = true
 
-	block, exists := cfg.Blocks[blockLabel]
-	if !exists {
-		return 0
-	}
+		block, exists := cfg.Blocks[blockLabel]
+		if !exists {
+			return 0
+		}
 
-	*explanation = append(*explanation, fmt.Sprintf("    Examining block %s", blockLabel))
+		*explanation = append(*explanation, fmt.Sprintf("    Examining block %s", blockLabel))
 
-	// Look for show-text instruction in this block (dialogue line)
-	var dialogueLineInBlock int = -1
-	for i, instr := range block.Instructions {
-		if instr.Opcode == "show-text" {
-			dialogueLineInBlock = i
-			break
+		// Look for show-text instruction in this block (dialogue line)
+		var dialogueLineInBlock int = -1
+		for i, instr := range block.Instructions {
+			if instr.Opcode == "show-text" {
+				dialogueLineInBlock = i
+				break
+			}
 		}
-	}
 
-	// If this block has dialogue, look backwards from it for character ID
-	if dialogueLineInBlock >= 0 {
-		*explanation = append(*explanation, fmt.Sprintf("      Found dialogue at line %d in this block", block.Instructions[dialogueLineInBlock].LineNum))
+		// If this block has dialogue, look backwards from it for character ID
+		if dialogueLineInBlock >= 0 {
+			*explanation = append(*explanation, fmt.Sprintf("      Found dialogue at line %d in this block", block.Instructions[dialogueLineInBlock].LineNum))
+
+			// Search forward from the block start to find character ID assignments before dialogue
+			// This captures the first assignment which is typically the character ID
+			for i := 0; i < dialogueLineInBlock; i++ {
+				instr := block.Instructions[i]
+				if charID := extractCharacterID(instr); charID >= 0 {
+					*explanation = append(*explanation, fmt.Sprintf("      Found character ID %d at line %d: %s",
+						charID, instr.LineNum, instr.Raw))
+					return charID
+				}
+			}
 
-		// Search forward from the block start to find character ID assignments before dialogue
-		// This captures the first assignment which is typically the character ID
+			// If not found in current block, continue with the first predecessor
+			if len(block.Predecessors) > 0 {
+				blockLabel = block.Predecessors[0]
+				continue
+			}
+		}
+
+		// If no dialogue or no ID found in this block, search this block's full instruction list
+		// This handles cases where character ID is set earlier in the block
 		var foundCharID int = -1
-		for i := 0; i < dialogueLineInBlock; i++ {
-			instr := block.Instructions[i]
+		for _, instr := range block.Instructions {
 			if charID := extractCharacterID(instr); charID >= 0 {
 				foundCharID = charID
-				*explanation = append(*explanation, fmt.Sprintf("      Found character ID %d at line %d: %s",
-					charID, instr.LineNum, instr.Raw))
-				break
+				// Don't return immediately - keep looking to find the LAST (most recent) assignment
 			}
 		}
-
 		if foundCharID >= 0 {
+			*explanation = append(*explanation, fmt.Sprintf("      Found character ID %d in block %s instructions", foundCharID, blockLabel))
 			return foundCharID
 		}
 
-		// If not found in current block, search in all predecessors recursively
-		for _, predLabel := range block.Predecessors {
-			if charID := queryCharIDInBlock(cfg, predLabel, visited, explanation); charID >= 0 {
-				return charID
-			}
-		}
-	}
-
-	// If no dialogue or no ID found in this block, search this block's full instruction list
-	// This handles cases where character ID is set earlier in the block
-	var foundCharID int = -1
-	for _, instr := range block.Instructions {
-		if charID := extractCharacterID(instr); charID >= 0 {
-			foundCharID = charID
-			// Don't return immediately - keep looking to find the LAST (most recent) assignment
-		}
-	}
-	if foundCharID >= 0 {
-		*explanation = append(*explanation, fmt.Sprintf("      Found character ID %d in block %s instructions", foundCharID, blockLabel))
-		return foundCharID
-	}
-
-	// If still not found, recursively search in predecessors
-	for _, predLabel := range block.Predecessors {
-		if charID := queryCharIDInBlock(cfg, predLabel, visited, explanation); charID >= 0 {
-			return charID
+		// If still not found, continue with the first predecessor
+		if len(block.Predecessors) == 0 {
+			return 0
 		}
+		blockLabel = block.Predecessors[0]
 	}
-
-	return 0
 }
+
 // extractCharacterID extracts character ID from an instruction
 func extractCharacterID(instr *Instruction) int {
 	// Look for: mov <character-related-var> <number>
@@ -319,12 +371,107 @@ func (cfg *CFG) GetBlockInfo(label string) string {
 	return info
 }
 
-// isDialogueRelatedOpcode checks if an opcode is related to dialogue/text display
-func isDialogueRelatedOpcode(opcode string) bool {
-	switch opcode {
-	case "show-text", "display-furigana":
-		return true
-	default:
-		return false
+// ResolveEntryPoints assembles the text and records in EntryLines the line of
+// every instruction listed in the resulting BIN's Table1, as reported by
+// bin.Script.EntryPoints. BuildCFG splits blocks at these lines and
+// CFG.EntryPoints lists them; without this call only "_start" is an entry.
+func (a *Analyzer) ResolveEntryPoints() error {
+	res, err := bin.Assemble(strings.Join(a.Lines, "\n"), 0)
+	if err != nil {
+		return fmt.Errorf("failed to assemble: %w", err)
+	}
+	script, err := bin.DisassembleWithOptions(res.Data, bin.DisassembleOptions{TolerateUnknown: true})
+	if err != nil {
+		return fmt.Errorf("failed to disassemble: %w", err)
+	}
+
+	var lines []int
+	for line := range a.Instructions {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	if len(lines) != len(script.Instructions) {
+		return fmt.Errorf("text has %d instructions but assembles to %d", len(lines), len(script.Instructions))
+	}
+
+	// Instructions assemble in text order, so the n-th one is on the n-th line
+	a.EntryLines = a.EntryLines[:0]
+	for _, off := range script.EntryPoints() {
+		i := sort.Search(len(script.Instructions), func(i int) bool {
+			return script.Instructions[i].Offset >= off
+		})
+		a.EntryLines = append(a.EntryLines, lines[i])
+	}
+	return nil
+}
+
+// EntryPoints returns the labels of blocks that can be entered directly:
+// "_start" (when present) followed by every block holding one of the
+// analyzer's EntryLines, in file order.
+func (cfg *CFG) EntryPoints() []string {
+	var entries []string
+	if _, exists := cfg.Blocks["_start"]; exists {
+		entries = append(entries, "_start")
+	}
+	for _, label := range cfg.EntryBlocks {
+		if label != "_start" {
+			entries = append(entries, label)
+		}
+	}
+	return entries
+}
+
+// ReachableBlocks returns the labels of blocks reachable from the given entry
+// labels, following both successor edges and call targets.
+func (cfg *CFG) ReachableBlocks(entries []string) map[string]bool {
+	visited := make(map[string]bool)
+	queue := make([]string, 0, len(entries))
+	queue = append(queue, entries...)
+
+	for len(queue) > 0 {
+		label := queue[0]
+		queue = queue[1:]
+
+		if visited[label] {
+			continue
+		}
+
+		block, exists := cfg.Blocks[label]
+		if !exists {
+			continue
+		}
+		visited[label] = true
+
+		queue = append(queue, block.Successors...)
+
+		// Calls can appear anywhere in a block, not only as the last instruction
+		for _, instr := range block.Instructions {
+			if instr.Opcode != "call" {
+				continue
+			}
+			for _, arg := range instr.Args {
+				if bin.IsValidLabelName(arg) {
+					queue = append(queue, arg)
+					break
+				}
+			}
+		}
+	}
+
+	return visited
+}
+
+// ReachableInstructionCount counts the instructions in blocks reachable from the
+// given entry labels, following both successor edges and call targets.
+// Returns the reachable count and the total instruction count for comparison.
+func (cfg *CFG) ReachableInstructionCount(entries []string) (reachable, total int) {
+	reached := cfg.ReachableBlocks(entries)
+	for label, block := range cfg.Blocks {
+		total += len(block.Instructions)
+		if reached[label] {
+			reachable += len(block.Instructions)
+		}
 	}
+
+	return reachable, total
 }
@@ -0,0 +1,276 @@
+package scflow
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"agetools/pkg/bin"
+)
+
+// recursiveQueryCharID is the recursive search queryCharIDInBlock replaced,
+// kept as the reference for its results and explanation trail.
+func recursiveQueryCharID(cfg *CFG, blockLabel string, visited map[string]bool, explanation *[]string) int {
+	if visited[blockLabel] {
+		return 0
+	}
+	visited[blockLabel] = true
+
+	block, exists := cfg.Blocks[blockLabel]
+	if !exists {
+		return 0
+	}
+
+	*explanation = append(*explanation, fmt.Sprintf("    Examining block %s", blockLabel))
+
+	dialogueLineInBlock := -1
+	for i, instr := range block.Instructions {
+		if instr.Opcode == "show-text" {
+			dialogueLineInBlock = i
+			break
+		}
+	}
+
+	if dialogueLineInBlock >= 0 {
+		*explanation = append(*explanation, fmt.Sprintf("      Found dialogue at line %d in this block", block.Instructions[dialogueLineInBlock].LineNum))
+
+		foundCharID := -1
+		for i := 0; i < dialogueLineInBlock; i++ {
+			instr := block.Instructions[i]
+			if charID := extractCharacterID(instr); charID >= 0 {
+				foundCharID = charID
+				*explanation = append(*explanation, fmt.Sprintf("      Found character ID %d at line %d: %s",
+					charID, instr.LineNum, instr.Raw))
+				break
+			}
+		}
+		if foundCharID >= 0 {
+			return foundCharID
+		}
+
+		for _, predLabel := range block.Predecessors {
+			if charID := recursiveQueryCharID(cfg, predLabel, visited, explanation); charID >= 0 {
+				return charID
+			}
+		}
+	}
+
+	foundCharID := -1
+	for _, instr := range block.Instructions {
+		if charID := extractCharacterID(instr); charID >= 0 {
+			foundCharID = charID
+		}
+	}
+	if foundCharID >= 0 {
+		*explanation = append(*explanation, fmt.Sprintf("      Found character ID %d in block %s instructions", foundCharID, blockLabel))
+		return foundCharID
+	}
+
+	for _, predLabel := range block.Predecessors {
+		if charID := recursiveQueryCharID(cfg, predLabel, visited, explanation); charID >= 0 {
+			return charID
+		}
+	}
+
+	return 0
+}
+
+// testCFG builds a CFG from blocks given as label -> raw instructions and
+// predecessor lists. Instructions whose raw text is "show-text" are dialogue.
+func testCFG(blocks map[string][]string, preds map[string][]string) *CFG {
+	cfg := &CFG{Blocks: make(map[string]*BasicBlock)}
+	line := 1
+	for label, raws := range blocks {
+		block := &BasicBlock{Label: label, Predecessors: preds[label]}
+		for _, raw := range raws {
+			opcode := raw
+			if len(raw) > 3 && raw[:3] == "mov" {
+				opcode = "mov"
+			}
+			block.Instructions = append(block.Instructions, &Instruction{LineNum: line, Opcode: opcode, Raw: raw})
+			line++
+		}
+		cfg.Blocks[label] = block
+	}
+	return cfg
+}
+
+func TestQueryCharIDInBlockMatchesRecursion(t *testing.T) {
+	tests := []struct {
+		name   string
+		blocks map[string][]string
+		preds  map[string][]string
+		start  string
+		want   int
+	}{
+		{
+			name:   "id before dialogue",
+			blocks: map[string][]string{"a": {"mov local-ptr:0 7", "show-text"}},
+			start:  "a",
+			want:   7,
+		},
+		{
+			name: "first predecessor without id settles the search",
+			blocks: map[string][]string{
+				"a":  {"show-text"},
+				"p1": {"nop"},
+				"p2": {"mov local-ptr:0 9"},
+			},
+			preds: map[string][]string{"a": {"p1", "p2"}},
+			start: "a",
+			want:  0,
+		},
+		{
+			name: "second predecessor is not searched after the first",
+			blocks: map[string][]string{
+				"a":  {"nop"},
+				"p1": {"nop"},
+				"p2": {"mov global-int:1566494 4"},
+			},
+			preds: map[string][]string{"a": {"p1", "p2"}, "p1": {"p0"}},
+			start: "a",
+			want:  0,
+		},
+		{
+			name: "last assignment in block wins",
+			blocks: map[string][]string{
+				"a": {"show-text"},
+				"p": {"mov local-ptr:0 1", "mov local-ptr:0 2"},
+			},
+			preds: map[string][]string{"a": {"p"}},
+			start: "a",
+			want:  2,
+		},
+		{
+			name: "cycle",
+			blocks: map[string][]string{
+				"a": {"show-text"},
+				"b": {"nop"},
+			},
+			preds: map[string][]string{"a": {"b"}, "b": {"a"}},
+			start: "a",
+			want:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testCFG(tt.blocks, tt.preds)
+
+			var got, want []string
+			id := queryCharIDInBlock(cfg, tt.start, map[string]bool{}, &got)
+			refID := recursiveQueryCharID(cfg, tt.start, map[string]bool{}, &want)
+
+			if id != tt.want || refID != tt.want {
+				t.Errorf("character ID = %d, recursion = %d, want %d", id, refID, tt.want)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("explanation = %q, recursion gave %q", got, want)
+			}
+		})
+	}
+}
+
+func TestQueryCharIDInBlockLongChain(t *testing.T) {
+	const n = 20000
+
+	// b0 <- b1 <- ... <- b(n-1); every block has dialogue and a second
+	// predecessor holding a different ID that must never be reached
+	blocks := map[string][]string{"decoy": {"mov local-ptr:0 99"}}
+	preds := map[string][]string{}
+	for i := 0; i < n; i++ {
+		label := fmt.Sprintf("b%d", i)
+		blocks[label] = []string{"show-text"}
+		if i+1 < n {
+			preds[label] = []string{fmt.Sprintf("b%d", i+1), "decoy"}
+		}
+	}
+	blocks[fmt.Sprintf("b%d", n-1)] = []string{"mov local-ptr:0 42", "nop"}
+	cfg := testCFG(blocks, preds)
+
+	var got, want []string
+	id := queryCharIDInBlock(cfg, "b0", map[string]bool{}, &got)
+	refID := recursiveQueryCharID(cfg, "b0", map[string]bool{}, &want)
+
+	if id != 42 || refID != 42 {
+		t.Fatalf("character ID = %d, recursion = %d, want 42", id, refID)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("explanation differs from recursion (%d vs %d lines)", len(got), len(want))
+	}
+	if len(got) != 2*n {
+		t.Errorf("explanation has %d lines, want %d", len(got), 2*n)
+	}
+}
+
+func TestEntryPoints(t *testing.T) {
+	const body = "==Binary Information - do not edit==\n" +
+		"signature = SYS5501\n" +
+		"local_vars = { 0 0 0 0 0 0 }\n" +
+		"====\n" +
+		"    jmp done\n" +
+		"    u0041A7B0 0\n" +
+		"    show-text 0 \"entry\"\n" +
+		"    exit\n" +
+		"\ndone:\n" +
+		"    show-text 0 \"main\"\n" +
+		"    exit\n" +
+		"\nunused:\n" +
+		"    show-text 0 \"dead\"\n" +
+		"    jmp unused\n"
+
+	res, err := bin.Assemble(body, bin.FormatSYS5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := bin.Disassemble(res.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tableLabel := script.Labels[script.Instructions[1].Offset]
+	if err := script.RenameLabels(map[string]string{script.Labels[script.Instructions[4].Offset]: "done"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		text        string
+		wantEntries []string
+	}{
+		// Hand-written text has no label at the entry point, so the
+		// block is split there
+		{"mid-block", body, []string{"_start", "_start@5"}},
+		// The disassembler labels every table target
+		{"labeled", script.ToText(), []string{"_start", tableLabel}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testAnalyzer(t, tt.text)
+			if err := a.ResolveEntryPoints(); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := a.BuildCFG()
+			entries := cfg.EntryPoints()
+			if !reflect.DeepEqual(entries, tt.wantEntries) {
+				t.Errorf("EntryPoints = %v, want %v", entries, tt.wantEntries)
+			}
+			if got := cfg.Blocks["_start"].Successors; !reflect.DeepEqual(got, []string{"done"}) {
+				t.Errorf("_start successors = %v, want [done]", got)
+			}
+
+			live, dead := a.LiveStrings(nil)
+			var liveValues, deadValues []string
+			for _, ref := range live {
+				liveValues = append(liveValues, ref.Value)
+			}
+			for _, ref := range dead {
+				deadValues = append(deadValues, ref.Value)
+			}
+			if !reflect.DeepEqual(liveValues, []string{"entry", "main"}) || !reflect.DeepEqual(deadValues, []string{"dead"}) {
+				t.Errorf("live = %v, dead = %v, want [entry main] and [dead]", liveValues, deadValues)
+			}
+		})
+	}
+}